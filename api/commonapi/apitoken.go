@@ -0,0 +1,72 @@
+package commonapi
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/fido-alliance/iot-fdo-conformance-tools/dbs"
+)
+
+type apiTokenCtxKey struct{}
+
+// TokenUserEmail returns the email of the user authenticated with an API token by RequireApiToken
+func TokenUserEmail(r *http.Request) (string, bool) {
+	email, ok := r.Context().Value(apiTokenCtxKey{}).(string)
+	return email, ok
+}
+
+func getBearerToken(r *http.Request) (string, bool) {
+	authHeader := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authHeader, "Bearer ") {
+		return "", false
+	}
+
+	return strings.TrimSpace(strings.TrimPrefix(authHeader, "Bearer ")), true
+}
+
+// RequireApiToken authenticates requests with an "Authorization: Bearer" API token of the required scope.
+// Requests without a bearer token fall back to the session cookie, and are rejected if there is no logged in session
+func RequireApiToken(tokenDb *dbs.ApiTokenDB, sessionDb *dbs.SessionDB, scope dbs.ApiTokenScope, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := getBearerToken(r)
+		if !ok {
+			if !hasLoggedInSession(sessionDb, r) {
+				RespondError(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			next(w, r)
+			return
+		}
+
+		tokenInst, err := tokenDb.Authenticate(token)
+		if err != nil {
+			log.Println("Failed to authenticate api token. " + err.Error())
+			RespondError(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		if !tokenInst.Scope.Allows(scope) {
+			RespondError(w, "The api token does not have the "+string(scope)+" scope", http.StatusForbidden)
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), apiTokenCtxKey{}, tokenInst.Email)))
+	}
+}
+
+func hasLoggedInSession(sessionDb *dbs.SessionDB, r *http.Request) bool {
+	sessionCookie, err := r.Cookie("session")
+	if err != nil {
+		return false
+	}
+
+	sessionInst, err := sessionDb.GetSessionEntry([]byte(sessionCookie.Value))
+	if err != nil {
+		return false
+	}
+
+	return sessionInst.LoggedIn
+}
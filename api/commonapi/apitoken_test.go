@@ -0,0 +1,76 @@
+package commonapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/storage"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/dbs"
+)
+
+func TestRequireApiToken(t *testing.T) {
+	db := storage.NewMemoryStore()
+	tokenDb := dbs.NewApiTokenDB(db)
+	sessionDb := dbs.NewSessionDB(db)
+
+	readToken, _, _ := tokenDb.Create("tester@fido.local", "ci", dbs.ATS_Read)
+	loggedInId, _ := sessionDb.NewSessionEntry(dbs.SessionEntry{Email: "tester@fido.local", LoggedIn: true})
+	loggedOutId, _ := sessionDb.NewSessionEntry(dbs.SessionEntry{Email: "tester@fido.local"})
+
+	var tokenEmail string
+	var hasTokenEmail bool
+	handler := RequireApiToken(tokenDb, sessionDb, dbs.ATS_Execute, func(w http.ResponseWriter, r *http.Request) {
+		tokenEmail, hasTokenEmail = TokenUserEmail(r)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	for _, testCase := range []struct {
+		name          string
+		authorization string
+		session       []byte
+		status        int
+	}{
+		{"neither token nor session", "", nil, http.StatusUnauthorized},
+		{"unknown session", "", []byte("unknown"), http.StatusUnauthorized},
+		{"logged out session", "", loggedOutId, http.StatusUnauthorized},
+		{"session cookie without a bearer token", "", loggedInId, http.StatusOK},
+		{"non bearer authorization falls back to the session", "Basic dGVzdGVy", loggedInId, http.StatusOK},
+		{"unknown token", "Bearer " + dbs.API_TOKEN_PREFIX + "00", loggedInId, http.StatusUnauthorized},
+		{"token without the scope", "Bearer " + readToken, nil, http.StatusForbidden},
+	} {
+		tokenEmail, hasTokenEmail = "", false
+
+		r := httptest.NewRequest(http.MethodPost, "/api/rvt/execute", nil)
+		if testCase.authorization != "" {
+			r.Header.Set("Authorization", testCase.authorization)
+		}
+
+		if testCase.session != nil {
+			r.AddCookie(GenerateCookie(testCase.session))
+		}
+
+		w := httptest.NewRecorder()
+		handler(w, r)
+
+		if w.Code != testCase.status {
+			t.Errorf("%s: expected status %d. Got %d", testCase.name, testCase.status, w.Code)
+		}
+
+		if hasTokenEmail {
+			t.Errorf("%s: expected no token user. Got %s", testCase.name, tokenEmail)
+		}
+	}
+
+	executeToken, _, _ := tokenDb.Create("tester@fido.local", "ci", dbs.ATS_Execute)
+
+	r := httptest.NewRequest(http.MethodPost, "/api/rvt/execute", nil)
+	r.Header.Set("Authorization", "Bearer "+executeToken)
+
+	w := httptest.NewRecorder()
+	handler(w, r)
+
+	if w.Code != http.StatusOK || tokenEmail != "tester@fido.local" {
+		t.Errorf("Expected the token user to be passed on. Got status %d and user %q", w.Code, tokenEmail)
+	}
+}
@@ -16,3 +16,27 @@ type User_ResetPassword struct {
 type User_ResetPasswordReq struct {
 	Email string `json:"email"`
 }
+
+type User_ApiTokenReq struct {
+	Name  string `json:"name"`
+	Scope string `json:"scope"`
+}
+
+type User_ApiTokenInfo struct {
+	Id        string `json:"id"`
+	Name      string `json:"name"`
+	Scope     string `json:"scope"`
+	CreatedAt int64  `json:"created_at"`
+	LastUsed  int64  `json:"last_used,omitempty"`
+}
+
+type User_ApiTokenCreated struct {
+	User_ApiTokenInfo
+	Token  string           `json:"token"`
+	Status FdoConfApiStatus `json:"status"`
+}
+
+type User_ApiTokenList struct {
+	Tokens []User_ApiTokenInfo `json:"tokens"`
+	Status FdoConfApiStatus    `json:"status"`
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+)
+
+var (
+	apiMetricsRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: fdoshared.METRICS_NAMESPACE,
+		Name:      "api_requests_total",
+		Help:      "API requests, by route, method and HTTP status code.",
+	}, []string{"route", "method", "code"})
+
+	apiMetricsHandlerDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: fdoshared.METRICS_NAMESPACE,
+		Name:      "api_handler_duration_seconds",
+		Help:      "API handler latency, by route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route"})
+)
+
+type apiMetricsWriter struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (h *apiMetricsWriter) WriteHeader(statusCode int) {
+	if h.statusCode == 0 {
+		h.statusCode = statusCode
+	}
+
+	h.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (h *apiMetricsWriter) Write(body []byte) (int, error) {
+	if h.statusCode == 0 {
+		h.statusCode = http.StatusOK
+	}
+
+	return h.ResponseWriter.Write(body)
+}
+
+// Flush keeps the events stream working through the metrics middleware
+func (h *apiMetricsWriter) Flush() {
+	if flusher, ok := h.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// MetricsMiddleware counts API requests by route template, so that ids in the path do not create new series
+func MetricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := "unknown"
+		if currentRoute := mux.CurrentRoute(r); currentRoute != nil {
+			if pathTemplate, err := currentRoute.GetPathTemplate(); err == nil {
+				route = pathTemplate
+			}
+		}
+
+		start := time.Now()
+		mw := &apiMetricsWriter{ResponseWriter: w}
+
+		next.ServeHTTP(mw, r)
+
+		if mw.statusCode == 0 {
+			mw.statusCode = http.StatusOK
+		}
+
+		apiMetricsRequests.WithLabelValues(route, r.Method, strconv.Itoa(mw.statusCode)).Inc()
+		apiMetricsHandlerDuration.WithLabelValues(route).Observe(time.Since(start).Seconds())
+	})
+}
@@ -2,26 +2,52 @@ package api
 
 import (
 	"context"
+	"log"
 	"mime"
 	"net/http"
 
-	"github.com/dgraph-io/badger/v4"
 	"github.com/gorilla/mux"
 
+	"github.com/fido-alliance/iot-fdo-conformance-tools/api/commonapi"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/api/testapi"
 	dodbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/do/dbs"
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/storage"
 	testdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/events"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/webhooks"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/dbs"
 )
 
+// valuesContext is the request context, with the values of another context added. Cancellation follows the request
+type valuesContext struct {
+	context.Context
+	values context.Context
+}
+
+func (h valuesContext) Value(key any) any {
+	if value := h.Context.Value(key); value != nil {
+		return value
+	}
+
+	return h.values.Value(key)
+}
+
+// AddContext makes the values of ctx, such as the config, available to the handlers. The request context is kept, so
+// handlers still see the client disconnect
 func AddContext(next http.Handler, ctx context.Context) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		next.ServeHTTP(w, r.WithContext(ctx))
+		next.ServeHTTP(w, r.WithContext(valuesContext{Context: r.Context(), values: ctx}))
 	})
 }
 
-func SetupServer(db *badger.DB, ctx context.Context) {
+// SetupServer registers the management API and frontend on the default mux
+func SetupServer(db storage.Store, ctx context.Context) {
+	RegisterRoutes(http.DefaultServeMux, db, ctx)
+}
+
+// RegisterRoutes registers the management API and frontend on serveMux
+func RegisterRoutes(serveMux *http.ServeMux, db storage.Store, ctx context.Context) {
 	userDb := dbs.NewUserTestDB(db)
 	rvtDb := testdbs.NewRequestTestDB(db)
 	sessionDb := dbs.NewSessionDB(db)
@@ -29,6 +55,12 @@ func SetupServer(db *badger.DB, ctx context.Context) {
 	devBaseDb := dbs.NewDeviceBaseDB(db)
 	listenerDb := testdbs.NewListenerTestDB(db)
 	doVoucherDb := dodbs.NewVoucherDB(db)
+	apiTokenDb := dbs.NewApiTokenDB(db)
+	webhookDb := testdbs.NewWebhookDB(db)
+
+	if ephemeral, _ := ctx.Value(fdoshared.CFG_ENV_EPHEMERAL).(bool); ephemeral {
+		configDb.EnableLazySeeding(devBaseDb)
+	}
 
 	rvtApiHandler := testapi.RVTestMgmtAPI{
 		UserDB:    userDb,
@@ -45,6 +77,7 @@ func SetupServer(db *badger.DB, ctx context.Context) {
 		SessionDB: sessionDb,
 		ConfigDB:  configDb,
 		DevBaseDB: devBaseDb,
+		Ctx:       ctx,
 	}
 
 	deviceApiHandler := testapi.DeviceTestMgmtAPI{
@@ -57,33 +90,82 @@ func SetupServer(db *badger.DB, ctx context.Context) {
 		Ctx:          ctx,
 	}
 
-	userApiHandler := UserAPI{
+	eventsApiHandler := testapi.EventsAPI{
 		UserDB:    userDb,
 		SessionDB: sessionDb,
 	}
 
+	webhooksApiHandler := testapi.WebhooksAPI{
+		UserDB:    userDb,
+		SessionDB: sessionDb,
+		WebhookDB: webhookDb,
+	}
+
+	userApiHandler := UserAPI{
+		UserDB:     userDb,
+		SessionDB:  sessionDb,
+		ApiTokenDB: apiTokenDb,
+	}
+
+	apiUrl, _ := ctx.Value(fdoshared.CFG_ENV_FDO_API_URL).(string)
+	webhookDispatcher := webhooks.NewDispatcher(webhookDb, apiUrl)
+	removeWebhookHook := events.DefaultBroker.AddHook(webhookDispatcher.HandleEvent)
+	go func() {
+		<-ctx.Done()
+		removeWebhookHook()
+	}()
+	go webhookDispatcher.Run(ctx)
+
+	err := fdoshared.RegisterGaugeFunc(ctx, "seeded_credentials", "Size of the seeded device credentials pool.", nil, devBaseDb.Count)
+	if err != nil {
+		log.Println(err.Error())
+	}
+
 	r := mux.NewRouter()
+	r.Use(MetricsMiddleware)
+
+	// Test management endpoints also accept personal API tokens, see api/openapi.yaml
+	read := func(handler http.HandlerFunc) http.HandlerFunc {
+		return commonapi.RequireApiToken(apiTokenDb, sessionDb, dbs.ATS_Read, handler)
+	}
+	execute := func(handler http.HandlerFunc) http.HandlerFunc {
+		return commonapi.RequireApiToken(apiTokenDb, sessionDb, dbs.ATS_Execute, handler)
+	}
+
+	r.HandleFunc("/api/rvt/create", execute(rvtApiHandler.Generate))
+	r.HandleFunc("/api/rvt/testruns", read(rvtApiHandler.List))
+	r.HandleFunc("/api/rvt/testruns/{testinsthex}/{testrunid}", execute(rvtApiHandler.DeleteTestRun)).Methods("DELETE")
+	r.HandleFunc("/api/rvt/execute", execute(rvtApiHandler.Execute))
+	r.HandleFunc("/api/rvt/execute/{testrunid}", execute(rvtApiHandler.CancelRun)).Methods("DELETE")
+
+	r.HandleFunc("/api/dot/create", execute(dotApiHandler.Generate))
+	r.HandleFunc("/api/dot/testruns", read(dotApiHandler.List))
+	r.HandleFunc("/api/dot/testruns/{testinsthex}/{testrunid}", execute(dotApiHandler.DeleteTestRun)).Methods("DELETE")
+	r.HandleFunc("/api/dot/vouchers/{uuid}", read(dotApiHandler.GetVouchers))
+	r.HandleFunc("/api/dot/execute", execute(dotApiHandler.Execute))
+	r.HandleFunc("/api/dot/execute/{testrunid}", execute(dotApiHandler.CancelRun)).Methods("DELETE")
+
+	r.HandleFunc("/api/device/create", execute(deviceApiHandler.Generate))
+	r.HandleFunc("/api/device/testruns", read(deviceApiHandler.List))
+	r.HandleFunc("/api/device/testruns/{toprotocol}/{testinsthex}/{testrunid}", execute(deviceApiHandler.DeleteTestRun)).Methods("DELETE")
+	r.HandleFunc("/api/device/testruns/{toprotocol}/{testinsthex}", execute(deviceApiHandler.StartNewTestRun)).Methods("POST")
 
-	r.HandleFunc("/api/rvt/create", rvtApiHandler.Generate)
-	r.HandleFunc("/api/rvt/testruns", rvtApiHandler.List)
-	r.HandleFunc("/api/rvt/testruns/{testinsthex}/{testrunid}", rvtApiHandler.DeleteTestRun).Methods("DELETE")
-	r.HandleFunc("/api/rvt/execute", rvtApiHandler.Execute)
+	r.HandleFunc("/api/events", read(eventsApiHandler.Stream)).Methods("GET")
 
-	r.HandleFunc("/api/dot/create", dotApiHandler.Generate)
-	r.HandleFunc("/api/dot/testruns", dotApiHandler.List)
-	r.HandleFunc("/api/dot/testruns/{testinsthex}/{testrunid}", dotApiHandler.DeleteTestRun).Methods("DELETE")
-	r.HandleFunc("/api/dot/vouchers/{uuid}", dotApiHandler.GetVouchers)
-	r.HandleFunc("/api/dot/execute", dotApiHandler.Execute)
+	r.HandleFunc("/api/webhooks", execute(webhooksApiHandler.Create)).Methods("POST")
+	r.HandleFunc("/api/webhooks", read(webhooksApiHandler.List)).Methods("GET")
+	r.HandleFunc("/api/webhooks/{webhookid}", execute(webhooksApiHandler.Delete)).Methods("DELETE")
+	r.HandleFunc("/api/webhooks/{webhookid}/deliveries", read(webhooksApiHandler.ListDeliveries)).Methods("GET")
 
-	r.HandleFunc("/api/device/create", deviceApiHandler.Generate)
-	r.HandleFunc("/api/device/testruns", deviceApiHandler.List)
-	r.HandleFunc("/api/device/testruns/{toprotocol}/{testinsthex}/{testrunid}", deviceApiHandler.DeleteTestRun).Methods("DELETE")
-	r.HandleFunc("/api/device/testruns/{toprotocol}/{testinsthex}", deviceApiHandler.StartNewTestRun).Methods("POST")
+	r.HandleFunc("/api/openapi.yaml", ServeOpenAPI).Methods("GET")
 
 	r.HandleFunc("/api/user/login/onprem", userApiHandler.OnPremNoLogin)
 	r.HandleFunc("/api/user/loggedin", userApiHandler.UserLoggedIn)
 	r.HandleFunc("/api/user/logout", userApiHandler.Logout)
 	r.HandleFunc("/api/user/purgetests", userApiHandler.PurgeTests)
+	r.HandleFunc("/api/user/tokens", userApiHandler.CreateApiToken).Methods("POST")
+	r.HandleFunc("/api/user/tokens", userApiHandler.ListApiTokens).Methods("GET")
+	r.HandleFunc("/api/user/tokens/{tokenid}", userApiHandler.RevokeApiToken).Methods("DELETE")
 
 	if ctx.Value(fdoshared.CFG_DEV_ENV) == fdoshared.CFG_ENV_DEV {
 		r.PathPrefix("/").HandlerFunc(ProxyDevUI)
@@ -95,5 +177,5 @@ func SetupServer(db *badger.DB, ctx context.Context) {
 		r.PathPrefix("/").Handler(http.FileServer(http.Dir("./frontend/dist")))
 	}
 
-	http.Handle("/", AddContext(r, ctx))
+	serveMux.Handle("/", AddContext(r, ctx))
 }
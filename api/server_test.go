@@ -0,0 +1,97 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/fido-alliance/iot-fdo-conformance-tools/api/commonapi"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/api/testapi"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/storage"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/events"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/dbs"
+)
+
+func TestEventsStreamEndsOnDisconnect(t *testing.T) {
+	db := storage.NewMemoryStore()
+	defer db.Close()
+
+	userDb := dbs.NewUserTestDB(db)
+	sessionDb := dbs.NewSessionDB(db)
+
+	err := userDb.Save(dbs.UserTestDBEntry{Email: "tester@fido.local"})
+	if err != nil {
+		t.Fatalf("Failed to save user. %s", err.Error())
+	}
+
+	sessionId, err := sessionDb.NewSessionEntry(dbs.SessionEntry{Email: "tester@fido.local", LoggedIn: true})
+	if err != nil {
+		t.Fatalf("Failed to create session. %s", err.Error())
+	}
+
+	eventsApiHandler := testapi.EventsAPI{
+		UserDB:    userDb,
+		SessionDB: sessionDb,
+	}
+
+	// The server context outlives the request, so the stream must follow the request context
+	serverCtx, cancelServer := context.WithCancel(context.Background())
+	defer cancelServer()
+
+	server := httptest.NewServer(AddContext(http.HandlerFunc(eventsApiHandler.Stream), serverCtx))
+	defer server.Close()
+
+	subscriptions := events.DefaultBroker.SubscriptionsCount()
+
+	reqCtx, cancelReq := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(reqCtx, http.MethodGet, server.URL+"/api/events", nil)
+	req.AddCookie(commonapi.GenerateCookie(sessionId))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to open the stream. %s", err.Error())
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || events.DefaultBroker.SubscriptionsCount() != subscriptions+1 {
+		t.Fatalf("Expected the stream to subscribe. Got status %d", resp.StatusCode)
+	}
+
+	cancelReq()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for events.DefaultBroker.SubscriptionsCount() != subscriptions {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the subscription to end with the client disconnect")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRegisterRoutesRemovesWebhookHook(t *testing.T) {
+	db := storage.NewMemoryStore()
+	defer db.Close()
+
+	hooks := events.DefaultBroker.HooksCount()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	RegisterRoutes(http.NewServeMux(), db, ctx)
+
+	if events.DefaultBroker.HooksCount() != hooks+1 {
+		t.Fatalf("Expected the webhook dispatcher to be hooked")
+	}
+
+	cancel()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for events.DefaultBroker.HooksCount() != hooks {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the webhook hook to be removed once the context is done")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,21 @@
+package testapi
+
+import (
+	testdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
+)
+
+// runBelongsToInsts checks that the test run is the current run of one of the given test instances
+func runBelongsToInsts(reqtDB *testdbs.RequestTestDB, instIds [][]byte, testRunId string) bool {
+	for _, instId := range instIds {
+		reqte, err := reqtDB.Get(instId)
+		if err != nil {
+			continue
+		}
+
+		if reqte.CurrentTestRun.Uuid == testRunId {
+			return true
+		}
+	}
+
+	return false
+}
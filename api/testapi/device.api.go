@@ -36,7 +36,7 @@ type DeviceTestMgmtAPI struct {
 
 func (h *DeviceTestMgmtAPI) submitToRvOwnerSign(voucherdbe *fdoshared.VoucherDBEntry) error {
 	to0client := to0.NewTo0Requestor(fdoshared.SRVEntry{
-		SrvURL: h.Ctx.Value(fdoshared.CFG_ENV_FDO_SERVICE_URL).(string),
+		SrvURL: h.Ctx.Value(fdoshared.CFG_ENV_FDO_RV_URL).(string),
 	}, *voucherdbe, h.Ctx)
 
 	helloAck21, _, err := to0client.Hello20(testcom.NULL_TEST)
@@ -57,6 +57,15 @@ func (h *DeviceTestMgmtAPI) submitVoucherToDO(voucherDBEntry *fdoshared.VoucherD
 }
 
 func (h *DeviceTestMgmtAPI) checkAutzAndGetUser(r *http.Request) (*dbs.UserTestDBEntry, error) {
+	if tokenEmail, ok := commonapi.TokenUserEmail(r); ok {
+		userInst, err := h.UserDB.Get(tokenEmail)
+		if err != nil {
+			return nil, errors.New("user does not exists. " + err.Error())
+		}
+
+		return userInst, nil
+	}
+
 	sessionCookie, err := r.Cookie("session")
 	if err != nil {
 		return nil, errors.New("failed to read cookie. " + err.Error())
@@ -257,15 +266,16 @@ func (h *DeviceTestMgmtAPI) StartNewTestRun(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	runnerInst, err := reqListInst.GetProtocolInst(int(toPInt))
+	_, err = reqListInst.GetProtocolInst(int(toPInt))
 	if err != nil {
 		commonapi.RespondError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	runnerInst.StartNewTestRun()
-
-	err = h.ListenerDB.Update(reqListInst)
+	_, err = h.ListenerDB.UpdateRunner(reqListInst.Uuid, fdoshared.FdoToProtocol(toPInt), func(runner *listenertestsdeps.RequestListenerRunnerInst) error {
+		runner.StartNewTestRun()
+		return nil
+	})
 	if err != nil {
 		commonapi.RespondError(w, err.Error(), http.StatusBadRequest)
 		return
@@ -316,7 +326,11 @@ func (h *DeviceTestMgmtAPI) DeleteTestRun(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	h.ListenerDB.RemoveTestRun(fdoshared.FdoToProtocol(topInt), testIstIdBytes, testrunid)
+	err = h.ListenerDB.RemoveTestRun(fdoshared.FdoToProtocol(topInt), testIstIdBytes, testrunid)
+	if err != nil {
+		commonapi.RespondError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	commonapi.RespondSuccess(w)
 }
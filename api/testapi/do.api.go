@@ -3,6 +3,7 @@ package testapi
 import (
 	"archive/zip"
 	"bytes"
+	"context"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rsa"
@@ -16,6 +17,7 @@ import (
 	"log"
 	"net/http"
 	"net/url"
+	"time"
 
 	"github.com/gorilla/mux"
 
@@ -35,9 +37,19 @@ type DOTestMgmtAPI struct {
 	DevBaseDB *dbs.DeviceBaseDB
 	SessionDB *dbs.SessionDB
 	ConfigDB  *dbs.ConfigDB
+	Ctx       context.Context
 }
 
 func (h *DOTestMgmtAPI) checkAutzAndGetUser(r *http.Request) (*dbs.UserTestDBEntry, error) {
+	if tokenEmail, ok := commonapi.TokenUserEmail(r); ok {
+		userInst, err := h.UserDB.Get(tokenEmail)
+		if err != nil {
+			return nil, errors.New("user does not exists. " + err.Error())
+		}
+
+		return userInst, nil
+	}
+
 	sessionCookie, err := r.Cookie("session")
 	if err != nil {
 		return nil, errors.New("Failed to read cookie. " + err.Error())
@@ -176,52 +188,65 @@ func (h *DOTestMgmtAPI) Generate(w http.ResponseWriter, r *http.Request) {
 		PkBody: pubKeyBytes,
 	}
 
-	mainConfig, err := h.ConfigDB.Get()
+	rvInfo, err := fdoshared.UrlsToRendezvousInfo([]string{
+		"https://localhost:8043",
+	})
 	if err != nil {
-		log.Println("Failed to generate VDIs. " + err.Error())
+		log.Println("Failed to get rendezvous info. " + err.Error())
 		commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	guid := mainConfig.SeededGuids.GetRandomTestGuidForSgType(sgType)
+	// One voucher per worker, so parallel tests never share a device
+	concurrency := testexec.NormaliseConcurrency(createTestCase.Concurrency)
 
-	deviceCredential, err := h.DevBaseDB.Get(guid)
+	mainConfig, err := h.ConfigDB.EnsureSeeded([]fdoshared.SgType{sgType}, concurrency)
 	if err != nil {
-		log.Println("Failed to get device base. " + err.Error())
+		log.Println("Failed to generate VDIs. " + err.Error())
 		commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	rvInfo, err := fdoshared.UrlsToRendezvousInfo([]string{
-		"https://localhost:8043",
-	})
-	if err != nil {
-		log.Println("Failed to get rendezvous info. " + err.Error())
+	guids := mainConfig.SeededGuids[sgType].GetRandomSelection(concurrency)
+	if len(guids) == 0 {
+		log.Printf("No seeded guids found for sgType %d", sgType)
 		commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	credentialAndVoucher, err := fdodeviceimplementation.NewVirtualDeviceAndVoucherWithKeys(
-		*deviceCredential,
-		privKey,
-		fdoPubKey,
-		sgType,
-		rvInfo,
-		testcom.NULL_TEST,
-	)
-	if err != nil {
-		log.Println("Error creating virtual device and voucher. " + err.Error())
-		commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
-		return
+	var testVouchers []fdoshared.DeviceCredAndVoucher
+	for _, guid := range guids {
+		deviceCredential, err := h.DevBaseDB.Get(guid)
+		if err != nil {
+			log.Println("Failed to get device base. " + err.Error())
+			commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		credentialAndVoucher, err := fdodeviceimplementation.NewVirtualDeviceAndVoucherWithKeys(
+			*deviceCredential,
+			privKey,
+			fdoPubKey,
+			sgType,
+			rvInfo,
+			testcom.NULL_TEST,
+		)
+		if err != nil {
+			log.Println("Error creating virtual device and voucher. " + err.Error())
+			commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		testVouchers = append(testVouchers, *credentialAndVoucher)
 	}
 
 	// New request test instance
-	newDOTTestTo2 := reqtestsdeps.NewRequestTestInst(doUrl, 2)
+	newDOTTestTo2 := reqtestsdeps.NewRequestTestInst(doUrl, 2, concurrency)
 	newDOTTestTo2.TestVouchers = map[testcom.FDOTestID][]fdoshared.DeviceCredAndVoucher{
-		testcom.NULL_TEST: {*credentialAndVoucher},
+		testcom.NULL_TEST: testVouchers,
 	}
 	newDOTTestTo2.FdoSeedIDs = map[fdoshared.SgType]fdoshared.FdoGuidList{
-		sgType: {guid},
+		sgType: guids,
 	}
 
 	// Saving stuff
@@ -408,7 +433,12 @@ func (h *DOTestMgmtAPI) DeleteTestRun(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	h.ReqTDB.RemoveTestRun(dotId, testrunid)
+	err = h.ReqTDB.RemoveTestRun(dotId, testrunid)
+	if err != nil {
+		log.Println("Failed to remove test run. " + err.Error())
+		commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
 
 	commonapi.RespondSuccess(w)
 }
@@ -432,7 +462,7 @@ func (h *DOTestMgmtAPI) Execute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var execReq RVT_RequestInfo
+	var execReq DOT_RequestInfo
 	err = json.Unmarshal(bodyBytes, &execReq)
 	if err != nil {
 		log.Println("Failed to decode body. " + err.Error())
@@ -460,7 +490,102 @@ func (h *DOTestMgmtAPI) Execute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	testexec.ExecuteDOTestsTo2(*rvte, h.ReqTDB)
+	if execReq.Concurrency > 0 {
+		rvte.Concurrency = testexec.NormaliseConcurrency(execReq.Concurrency)
+	}
+
+	strictness, ok := testcom.ParseErrorCodeStrictness(execReq.ErrorCodeStrictness)
+	if !ok {
+		commonapi.RespondError(w, "Invalid error code strictness! Expected lenient, warn or strict", http.StatusBadRequest)
+		return
+	}
+
+	ctx := testcom.WithErrorCodeStrictness(h.Ctx, strictness)
+
+	if execReq.SessionExpiryWait < 0 {
+		commonapi.RespondError(w, "Invalid session expiry wait!", http.StatusBadRequest)
+		return
+	}
+
+	if execReq.SessionExpiryWait > 0 {
+		ctx = testcom.WithSessionExpiry(ctx, time.Duration(execReq.SessionExpiryWait)*time.Second, nil)
+	}
+
+	if execReq.StressTests {
+		ctx = testcom.WithStressTests(ctx)
+	}
+
+	if execReq.Profile != nil {
+		if execReq.Profile.Role != "do" {
+			commonapi.RespondError(w, "Invalid profile! Expected a DO profile", http.StatusBadRequest)
+			return
+		}
+
+		if execReq.SuiteMatrix == "" {
+			commonapi.RespondError(w, "Profile requires a suite matrix!", http.StatusBadRequest)
+			return
+		}
+
+		ctx = testcom.WithCryptoSuiteFilter(ctx, execReq.Profile.FilterCryptoSuites)
+	}
+
+	var runId string
+	if execReq.SuiteMatrix != "" {
+		matrixMode, ok := testexec.ParseSuiteMatrixMode(execReq.SuiteMatrix)
+		if !ok {
+			commonapi.RespondError(w, "Invalid suite matrix mode! Expected positive or all", http.StatusBadRequest)
+			return
+		}
+
+		runId, err = testexec.ExecuteDOTestsTo2SuiteMatrix(*rvte, h.ReqTDB, matrixMode, ctx)
+	} else {
+		runId, err = testexec.ExecuteDOTestsTo2(*rvte, h.ReqTDB, ctx)
+	}
+
+	if err != nil {
+		log.Println("Failed to start run. " + err.Error())
+		commonapi.RespondError(w, "Failed to start run!", http.StatusInternalServerError)
+		return
+	}
+
+	commonapi.RespondSuccessStruct(w, DOT_ExecuteResponse{
+		RunId:  runId,
+		Status: commonapi.FdoApiStatus_OK,
+	})
+}
+
+func (h *DOTestMgmtAPI) CancelRun(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "DELETE" {
+		commonapi.RespondError(w, "Method not allowed!", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userInst, err := h.checkAutzAndGetUser(r)
+	if err != nil {
+		log.Println("Failed to read cookie. " + err.Error())
+		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	testrunid := mux.Vars(r)["testrunid"]
+
+	var dotIds [][]byte
+	for _, dotInfo := range userInst.DOTestInsts {
+		dotIds = append(dotIds, dotInfo.To2)
+	}
+
+	if !runBelongsToInsts(h.ReqTDB, dotIds, testrunid) {
+		log.Println("Run does not belong to user")
+		commonapi.RespondError(w, "Invalid id!", http.StatusBadRequest)
+		return
+	}
+
+	err = testexec.CancelRun(testrunid)
+	if err != nil {
+		log.Println("Failed to cancel run. " + err.Error())
+		commonapi.RespondError(w, "Run is not in progress!", http.StatusConflict)
+		return
+	}
 
 	commonapi.RespondSuccess(w)
 }
@@ -4,11 +4,13 @@ import (
 	"github.com/fido-alliance/iot-fdo-conformance-tools/api/commonapi"
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
 	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/probe"
 )
 
 type DOT_CreateTestCase struct {
-	Url     string `json:"url"`
-	PrivKey string `json:"priv_key"`
+	Url         string `json:"url"`
+	PrivKey     string `json:"priv_key"`
+	Concurrency int    `json:"concurrency,omitempty"`
 }
 
 type DOT_InstInfo struct {
@@ -30,6 +32,28 @@ type DOT_ListTestEntries struct {
 }
 
 type DOT_RequestInfo struct {
-	Id        string `json:"id"`
-	TestRunId string `json:"testRunId,omitempty"`
+	Id          string `json:"id"`
+	TestRunId   string `json:"testRunId,omitempty"`
+	Concurrency int    `json:"concurrency,omitempty"`
+
+	// SuiteMatrix "positive" or "all" runs the positive flow or all the TO2 tests with every crypto suite
+	SuiteMatrix string `json:"suiteMatrix,omitempty"`
+
+	// Profile is the DO profile written by the probe command. The suite matrix skips the suites it rejected
+	Profile *probe.Profile `json:"profile,omitempty"`
+
+	// ErrorCodeStrictness "lenient", "warn" or "strict" sets how unexpected error codes are treated. Defaults to lenient
+	ErrorCodeStrictness string `json:"errorCodeStrictness,omitempty"`
+
+	// SessionExpiryWait is the pause, in seconds, of the session expiry tests. They only run if it is set
+	SessionExpiryWait int `json:"sessionExpiryWait,omitempty"`
+
+	// StressTests runs the session isolation stress tests after the other tests
+	StressTests bool `json:"stressTests,omitempty"`
+}
+
+// DOT_ExecuteResponse is returned as soon as the run is started. The run continues in the background
+type DOT_ExecuteResponse struct {
+	RunId  string                     `json:"runId"`
+	Status commonapi.FdoConfApiStatus `json:"status"`
 }
@@ -0,0 +1,154 @@
+package testapi
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/fido-alliance/iot-fdo-conformance-tools/api/commonapi"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/events"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/dbs"
+)
+
+const eventsKeepAliveInterval = 15 * time.Second
+
+type EventsAPI struct {
+	UserDB    *dbs.UserTestDB
+	SessionDB *dbs.SessionDB
+}
+
+func (h *EventsAPI) checkAutzAndGetUser(r *http.Request) (*dbs.UserTestDBEntry, error) {
+	if tokenEmail, ok := commonapi.TokenUserEmail(r); ok {
+		userInst, err := h.UserDB.Get(tokenEmail)
+		if err != nil {
+			return nil, errors.New("User does not exists. " + err.Error())
+		}
+
+		return userInst, nil
+	}
+
+	sessionCookie, err := r.Cookie("session")
+	if err != nil {
+		return nil, errors.New("Failed to read cookie. " + err.Error())
+	}
+
+	if sessionCookie == nil {
+		return nil, errors.New("Cookie does not exists")
+	}
+
+	sessionInst, err := h.SessionDB.GetSessionEntry([]byte(sessionCookie.Value))
+	if err != nil {
+		return nil, errors.New("Session expired. " + err.Error())
+	}
+
+	if !sessionInst.LoggedIn {
+		return nil, errors.New("Unauthorized!")
+	}
+
+	userInst, err := h.UserDB.Get(sessionInst.Email)
+	if err != nil {
+		return nil, errors.New("User does not exists. " + err.Error())
+	}
+
+	return userInst, nil
+}
+
+// Stream publishes live test progress as Server-Sent Events. By default all the user's test instances are
+// streamed, the optional "id" query parameter limits the stream to a single test instance.
+func (h *EventsAPI) Stream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		commonapi.RespondError(w, "Method not allowed!", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userInst, err := h.checkAutzAndGetUser(r)
+	if err != nil {
+		log.Println("Failed to read cookie. " + err.Error())
+		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var instanceIds [][]byte
+	if idHex := r.URL.Query().Get("id"); idHex != "" {
+		instanceId, err := hex.DecodeString(idHex)
+		if err != nil {
+			log.Println("Can not decode hex id " + err.Error())
+			commonapi.RespondError(w, "Invalid id!", http.StatusBadRequest)
+			return
+		}
+
+		if !userInst.RVT_ContainID(instanceId) && !userInst.DOT_ContainID(instanceId) && !userInst.DeviceT_ContainID(instanceId) {
+			log.Println("Id does not belong to user")
+			commonapi.RespondError(w, "Invalid id!", http.StatusBadRequest)
+			return
+		}
+
+		instanceIds = append(instanceIds, instanceId)
+	} else {
+		for _, rvtInst := range userInst.RVTestInsts {
+			instanceIds = append(instanceIds, rvtInst.To0, rvtInst.To1)
+		}
+
+		for _, dotInst := range userInst.DOTestInsts {
+			instanceIds = append(instanceIds, dotInst.To2)
+		}
+
+		for _, devtInst := range userInst.DeviceTestInsts {
+			instanceIds = append(instanceIds, devtInst.ListenerUuid)
+		}
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		log.Println("Response writer does not support streaming")
+		commonapi.RespondError(w, "Streaming unsupported!", http.StatusInternalServerError)
+		return
+	}
+
+	sub := events.DefaultBroker.Subscribe(instanceIds)
+	defer events.DefaultBroker.Unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventsKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case <-keepAlive.C:
+			_, err := fmt.Fprint(w, ": keepalive\n\n")
+			if err != nil {
+				return
+			}
+			flusher.Flush()
+
+		case event, ok := <-sub.Events:
+			if !ok {
+				return
+			}
+
+			eventBytes, err := json.Marshal(event)
+			if err != nil {
+				log.Println("Failed to encode event. " + err.Error())
+				continue
+			}
+
+			_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, eventBytes)
+			if err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -9,11 +9,13 @@ import (
 	"log"
 	"net/http"
 	"net/url"
+	"time"
 
 	"github.com/gorilla/mux"
 
 	"github.com/fido-alliance/iot-fdo-conformance-tools/api/commonapi"
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
 	testdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
 	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/dbs"
@@ -32,6 +34,15 @@ type RVTestMgmtAPI struct {
 }
 
 func (h *RVTestMgmtAPI) checkAutzAndGetUser(r *http.Request) (*dbs.UserTestDBEntry, error) {
+	if tokenEmail, ok := commonapi.TokenUserEmail(r); ok {
+		userInst, err := h.UserDB.Get(tokenEmail)
+		if err != nil {
+			return nil, errors.New("User does not exists. " + err.Error())
+		}
+
+		return userInst, nil
+	}
+
 	sessionCookie, err := r.Cookie("session")
 	if err != nil {
 		return nil, errors.New("Failed to read cookie. " + err.Error())
@@ -100,15 +111,18 @@ func (h *RVTestMgmtAPI) Generate(w http.ResponseWriter, r *http.Request) {
 
 	rvUrl := parsedUrl.Scheme + "://" + parsedUrl.Host
 
-	mainConfig, err := h.ConfigDB.Get()
+	// Only the device signature types the RV tests use are seeded, credentials seeded for DO tests are not picked
+	mainConfig, err := h.ConfigDB.EnsureSeeded(fdoshared.DeviceSgTypeList, RVSeedIDsBatchSize)
 	if err != nil {
 		log.Println("Failed to generate VDIs. " + err.Error())
 		commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	newRVTestTo0 := reqtestsdeps.NewRequestTestInst(rvUrl, 0)
-	newRVTestTo0.FdoSeedIDs = mainConfig.SeededGuids.GetTestBatch(RVSeedIDsBatchSize)
+	concurrency := testexec.NormaliseConcurrency(createTestCase.Concurrency)
+
+	newRVTestTo0 := reqtestsdeps.NewRequestTestInst(rvUrl, 0, concurrency)
+	newRVTestTo0.FdoSeedIDs = mainConfig.SeededGuids.GetTestBatch(fdoshared.DeviceSgTypeList, RVSeedIDsBatchSize)
 	err = h.ReqTDB.Save(newRVTestTo0)
 	if err != nil {
 		log.Println("Failed to save rvte. " + err.Error())
@@ -116,8 +130,8 @@ func (h *RVTestMgmtAPI) Generate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	newRVTestTo1 := reqtestsdeps.NewRequestTestInst(rvUrl, 1)
-	newRVTestTo1.FdoSeedIDs = mainConfig.SeededGuids.GetTestBatch(RVSeedIDsBatchSize)
+	newRVTestTo1 := reqtestsdeps.NewRequestTestInst(rvUrl, 1, concurrency)
+	newRVTestTo1.FdoSeedIDs = mainConfig.SeededGuids.GetTestBatch(fdoshared.DeviceSgTypeList, RVSeedIDsBatchSize)
 	err = h.ReqTDB.Save(newRVTestTo1)
 	if err != nil {
 		log.Println("Failed to save rvte. " + err.Error())
@@ -222,7 +236,12 @@ func (h *RVTestMgmtAPI) DeleteTestRun(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	h.ReqTDB.RemoveTestRun(rvtId, testrunid)
+	err = h.ReqTDB.RemoveTestRun(rvtId, testrunid)
+	if err != nil {
+		log.Println("Failed to remove test run. " + err.Error())
+		commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
 
 	commonapi.RespondSuccess(w)
 }
@@ -274,15 +293,86 @@ func (h *RVTestMgmtAPI) Execute(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if execReq.Concurrency > 0 {
+		rvte.Concurrency = testexec.NormaliseConcurrency(execReq.Concurrency)
+	}
+
+	strictness, ok := testcom.ParseErrorCodeStrictness(execReq.ErrorCodeStrictness)
+	if !ok {
+		commonapi.RespondError(w, "Invalid error code strictness! Expected lenient, warn or strict", http.StatusBadRequest)
+		return
+	}
+
+	ctx := testcom.WithErrorCodeStrictness(h.Ctx, strictness)
+
+	if execReq.SessionExpiryWait < 0 {
+		commonapi.RespondError(w, "Invalid session expiry wait!", http.StatusBadRequest)
+		return
+	}
+
+	if execReq.SessionExpiryWait > 0 {
+		ctx = testcom.WithSessionExpiry(ctx, time.Duration(execReq.SessionExpiryWait)*time.Second, nil)
+	}
+
+	if execReq.StressTests {
+		ctx = testcom.WithStressTests(ctx)
+	}
+
+	var runId string
 	if rvte.Protocol == fdoshared.To0 {
-		testexec.ExecuteRVTestsTo0(*rvte, h.ReqTDB, h.DevBaseDB, h.Ctx)
+		runId, err = testexec.ExecuteRVTestsTo0(*rvte, h.ReqTDB, h.DevBaseDB, ctx)
 	} else if rvte.Protocol == fdoshared.To1 {
-		testexec.ExecuteRVTestsTo1(*rvte, h.ReqTDB, h.DevBaseDB, h.Ctx)
+		runId, err = testexec.ExecuteRVTestsTo1(*rvte, h.ReqTDB, h.DevBaseDB, ctx)
 	} else {
 		log.Printf("Protocol TO%d is not supported. ", rvte.Protocol)
 		commonapi.RespondError(w, "Unsupported protocol!", http.StatusBadRequest)
 		return
 	}
 
+	if err != nil {
+		log.Println("Failed to start run. " + err.Error())
+		commonapi.RespondError(w, "Failed to start run!", http.StatusInternalServerError)
+		return
+	}
+
+	commonapi.RespondSuccessStruct(w, RVT_ExecuteResponse{
+		RunId:  runId,
+		Status: commonapi.FdoApiStatus_OK,
+	})
+}
+
+func (h *RVTestMgmtAPI) CancelRun(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "DELETE" {
+		commonapi.RespondError(w, "Method not allowed!", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userInst, err := h.checkAutzAndGetUser(r)
+	if err != nil {
+		log.Println("Failed to read cookie. " + err.Error())
+		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	testrunid := mux.Vars(r)["testrunid"]
+
+	var rvtIds [][]byte
+	for _, rvtInfo := range userInst.RVTestInsts {
+		rvtIds = append(rvtIds, rvtInfo.To0, rvtInfo.To1)
+	}
+
+	if !runBelongsToInsts(h.ReqTDB, rvtIds, testrunid) {
+		log.Println("Run does not belong to user")
+		commonapi.RespondError(w, "Invalid id!", http.StatusBadRequest)
+		return
+	}
+
+	err = testexec.CancelRun(testrunid)
+	if err != nil {
+		log.Println("Failed to cancel run. " + err.Error())
+		commonapi.RespondError(w, "Run is not in progress!", http.StatusConflict)
+		return
+	}
+
 	commonapi.RespondSuccess(w)
 }
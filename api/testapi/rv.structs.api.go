@@ -7,7 +7,8 @@ import (
 )
 
 type RVT_CreateTestCase struct {
-	Url string `json:"url"`
+	Url         string `json:"url"`
+	Concurrency int    `json:"concurrency,omitempty"`
 }
 
 type RVT_InstInfo struct {
@@ -43,6 +44,22 @@ type RVT_ListRvts struct {
 }
 
 type RVT_RequestInfo struct {
-	Id        string `json:"id"`
-	TestRunId string `json:"testRunId,omitempty"`
+	Id          string `json:"id"`
+	TestRunId   string `json:"testRunId,omitempty"`
+	Concurrency int    `json:"concurrency,omitempty"`
+
+	// ErrorCodeStrictness "lenient", "warn" or "strict" sets how unexpected error codes are treated. Defaults to lenient
+	ErrorCodeStrictness string `json:"errorCodeStrictness,omitempty"`
+
+	// SessionExpiryWait is the pause, in seconds, of the session expiry tests. They only run if it is set
+	SessionExpiryWait int `json:"sessionExpiryWait,omitempty"`
+
+	// StressTests runs the session isolation stress tests after the other tests
+	StressTests bool `json:"stressTests,omitempty"`
+}
+
+// RVT_ExecuteResponse is returned as soon as the run is started. The run continues in the background
+type RVT_ExecuteResponse struct {
+	RunId  string                     `json:"runId"`
+	Status commonapi.FdoConfApiStatus `json:"status"`
 }
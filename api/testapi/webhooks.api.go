@@ -0,0 +1,286 @@
+package testapi
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+
+	"github.com/fido-alliance/iot-fdo-conformance-tools/api/commonapi"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	testdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/dbs"
+)
+
+type WebhooksAPI struct {
+	UserDB    *dbs.UserTestDB
+	SessionDB *dbs.SessionDB
+	WebhookDB *testdbs.WebhookDB
+}
+
+func (h *WebhooksAPI) checkAutzAndGetUser(r *http.Request) (*dbs.UserTestDBEntry, error) {
+	if tokenEmail, ok := commonapi.TokenUserEmail(r); ok {
+		userInst, err := h.UserDB.Get(tokenEmail)
+		if err != nil {
+			return nil, errors.New("User does not exists. " + err.Error())
+		}
+
+		return userInst, nil
+	}
+
+	sessionCookie, err := r.Cookie("session")
+	if err != nil {
+		return nil, errors.New("Failed to read cookie. " + err.Error())
+	}
+
+	if sessionCookie == nil {
+		return nil, errors.New("Cookie does not exists")
+	}
+
+	sessionInst, err := h.SessionDB.GetSessionEntry([]byte(sessionCookie.Value))
+	if err != nil {
+		return nil, errors.New("Session expired. " + err.Error())
+	}
+
+	if !sessionInst.LoggedIn {
+		return nil, errors.New("Unauthorized!")
+	}
+
+	userInst, err := h.UserDB.Get(sessionInst.Email)
+	if err != nil {
+		return nil, errors.New("User does not exists. " + err.Error())
+	}
+
+	return userInst, nil
+}
+
+// getInstanceIds returns the ids of the test instances that publish the run events of the user's test
+func getInstanceIds(userInst *dbs.UserTestDBEntry, testType testdbs.WebhookTestType, testInstId []byte) ([][]byte, error) {
+	switch testType {
+	case testdbs.WTT_RVT:
+		for _, rvtInfo := range userInst.RVTestInsts {
+			if bytes.Equal(rvtInfo.Uuid, testInstId) {
+				return [][]byte{rvtInfo.To0, rvtInfo.To1}, nil
+			}
+		}
+	case testdbs.WTT_DOT:
+		for _, dotInfo := range userInst.DOTestInsts {
+			if bytes.Equal(dotInfo.Uuid, testInstId) {
+				return [][]byte{dotInfo.To2}, nil
+			}
+		}
+	case testdbs.WTT_Device:
+		for _, deviceInfo := range userInst.DeviceTestInsts {
+			if bytes.Equal(deviceInfo.Uuid, testInstId) {
+				return [][]byte{deviceInfo.ListenerUuid}, nil
+			}
+		}
+	default:
+		return nil, errors.New("Unknown test type " + string(testType))
+	}
+
+	return nil, errors.New("Test instance not found")
+}
+
+func webhookItem(webhookInst testdbs.WebhookEntry) Webhook_Item {
+	return Webhook_Item{
+		Id:             webhookInst.Id,
+		TestType:       webhookInst.TestType,
+		TestInstId:     hex.EncodeToString(webhookInst.TestInstId),
+		Url:            webhookInst.Url,
+		CreatedAt:      webhookInst.CreatedAt,
+		LastDeliveryAt: webhookInst.LastDeliveryAt,
+		LastError:      webhookInst.LastError,
+	}
+}
+
+// Create registers a webhook on a test instance. The signing secret is only returned once
+func (h *WebhooksAPI) Create(w http.ResponseWriter, r *http.Request) {
+	if !commonapi.CheckHeaders(w, r) {
+		return
+	}
+
+	userInst, err := h.checkAutzAndGetUser(r)
+	if err != nil {
+		log.Println("Failed to read cookie. " + err.Error())
+		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	bodyBytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Println("Failed to read body. " + err.Error())
+		commonapi.RespondError(w, "Failed to read body!", http.StatusBadRequest)
+		return
+	}
+
+	var createReq Webhook_Create
+	err = json.Unmarshal(bodyBytes, &createReq)
+	if err != nil {
+		log.Println("Failed to decode body. " + err.Error())
+		commonapi.RespondError(w, "Failed to decode body!", http.StatusBadRequest)
+		return
+	}
+
+	parsedUrl, err := url.ParseRequestURI(createReq.Url)
+	if err != nil || (parsedUrl.Scheme != "http" && parsedUrl.Scheme != "https") {
+		commonapi.RespondError(w, "Bad URL", http.StatusBadRequest)
+		return
+	}
+
+	testInstId, err := hex.DecodeString(createReq.TestInstId)
+	if err != nil {
+		commonapi.RespondError(w, "Failed to decode test instance id!", http.StatusBadRequest)
+		return
+	}
+
+	instanceIds, err := getInstanceIds(userInst, createReq.TestType, testInstId)
+	if err != nil {
+		log.Println("Failed to find test instance. " + err.Error())
+		commonapi.RespondError(w, "Test instance not found!", http.StatusNotFound)
+		return
+	}
+
+	webhookId, _ := uuid.NewRandom()
+	webhookInst := testdbs.WebhookEntry{
+		Id:          webhookId.String(),
+		Email:       userInst.Email,
+		TestType:    createReq.TestType,
+		TestInstId:  testInstId,
+		InstanceIds: instanceIds,
+		Url:         parsedUrl.String(),
+		Secret:      hex.EncodeToString(fdoshared.NewRandomBuffer(32)),
+		CreatedAt:   time.Now().Unix(),
+	}
+
+	err = h.WebhookDB.Save(webhookInst)
+	if err != nil {
+		log.Println("Failed to save webhook. " + err.Error())
+		commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	commonapi.RespondSuccessStruct(w, Webhook_Created{
+		Webhook_Item: webhookItem(webhookInst),
+		Secret:       webhookInst.Secret,
+		Status:       commonapi.FdoApiStatus_OK,
+	})
+}
+
+// List returns the user's webhooks. The optional "testInstId" query parameter limits it to a single test instance
+func (h *WebhooksAPI) List(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		commonapi.RespondError(w, "Method not allowed!", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userInst, err := h.checkAutzAndGetUser(r)
+	if err != nil {
+		log.Println("Failed to read cookie. " + err.Error())
+		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var testInstId []byte
+	if idHex := r.URL.Query().Get("testInstId"); idHex != "" {
+		testInstId, err = hex.DecodeString(idHex)
+		if err != nil {
+			commonapi.RespondError(w, "Failed to decode test instance id!", http.StatusBadRequest)
+			return
+		}
+	}
+
+	webhooks, err := h.WebhookDB.List(userInst.Email, testInstId)
+	if err != nil {
+		log.Println("Failed to list webhooks. " + err.Error())
+		commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	webhookList := Webhook_List{
+		Webhooks: []Webhook_Item{},
+		Status:   commonapi.FdoApiStatus_OK,
+	}
+
+	for _, webhookInst := range webhooks {
+		webhookList.Webhooks = append(webhookList.Webhooks, webhookItem(webhookInst))
+	}
+
+	commonapi.RespondSuccessStruct(w, webhookList)
+}
+
+func (h *WebhooksAPI) Delete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "DELETE" {
+		commonapi.RespondError(w, "Method not allowed!", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userInst, err := h.checkAutzAndGetUser(r)
+	if err != nil {
+		log.Println("Failed to read cookie. " + err.Error())
+		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	err = h.WebhookDB.Delete(userInst.Email, mux.Vars(r)["webhookid"])
+	if err != nil {
+		log.Println("Failed to delete webhook. " + err.Error())
+		commonapi.RespondError(w, "Webhook not found!", http.StatusNotFound)
+		return
+	}
+
+	commonapi.RespondSuccess(w)
+}
+
+// ListDeliveries returns the pending retries and the failed deliveries of the last week
+func (h *WebhooksAPI) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		commonapi.RespondError(w, "Method not allowed!", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userInst, err := h.checkAutzAndGetUser(r)
+	if err != nil {
+		log.Println("Failed to read cookie. " + err.Error())
+		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	webhookInst, err := h.WebhookDB.Get(mux.Vars(r)["webhookid"])
+	if err != nil || webhookInst.Email != userInst.Email {
+		commonapi.RespondError(w, "Webhook not found!", http.StatusNotFound)
+		return
+	}
+
+	deliveries, err := h.WebhookDB.ListDeliveries(webhookInst.Id)
+	if err != nil {
+		log.Println("Failed to list webhook deliveries. " + err.Error())
+		commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	deliveryList := Webhook_ListDeliveries{
+		Deliveries: []Webhook_Delivery{},
+		Status:     commonapi.FdoApiStatus_OK,
+	}
+
+	for _, delivery := range deliveries {
+		deliveryList.Deliveries = append(deliveryList.Deliveries, Webhook_Delivery{
+			Id:            delivery.Id,
+			Attempts:      delivery.Attempts,
+			NextAttemptAt: delivery.NextAttemptAt,
+			LastError:     delivery.LastError,
+			Failed:        delivery.Failed,
+		})
+	}
+
+	commonapi.RespondSuccessStruct(w, deliveryList)
+}
@@ -0,0 +1,46 @@
+package testapi
+
+import (
+	"github.com/fido-alliance/iot-fdo-conformance-tools/api/commonapi"
+	testdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
+)
+
+type Webhook_Create struct {
+	TestType   testdbs.WebhookTestType `json:"testType"`
+	TestInstId string                  `json:"testInstId"`
+	Url        string                  `json:"url"`
+}
+
+type Webhook_Item struct {
+	Id             string                  `json:"id"`
+	TestType       testdbs.WebhookTestType `json:"testType"`
+	TestInstId     string                  `json:"testInstId"`
+	Url            string                  `json:"url"`
+	CreatedAt      int64                   `json:"createdAt"`
+	LastDeliveryAt int64                   `json:"lastDeliveryAt,omitempty"`
+	LastError      string                  `json:"lastError,omitempty"`
+}
+
+type Webhook_Created struct {
+	Webhook_Item
+	Secret string                     `json:"secret"`
+	Status commonapi.FdoConfApiStatus `json:"status"`
+}
+
+type Webhook_List struct {
+	Webhooks []Webhook_Item             `json:"webhooks"`
+	Status   commonapi.FdoConfApiStatus `json:"status"`
+}
+
+type Webhook_Delivery struct {
+	Id            string `json:"id"`
+	Attempts      int    `json:"attempts"`
+	NextAttemptAt int64  `json:"nextAttemptAt"`
+	LastError     string `json:"lastError,omitempty"`
+	Failed        bool   `json:"failed"`
+}
+
+type Webhook_ListDeliveries struct {
+	Deliveries []Webhook_Delivery         `json:"deliveries"`
+	Status     commonapi.FdoConfApiStatus `json:"status"`
+}
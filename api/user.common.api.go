@@ -16,8 +16,9 @@ import (
 const ONPREM_CONFIG string = "tester@fido.local"
 
 type UserAPI struct {
-	UserDB    *dbs.UserTestDB
-	SessionDB *dbs.SessionDB
+	UserDB     *dbs.UserTestDB
+	SessionDB  *dbs.SessionDB
+	ApiTokenDB *dbs.ApiTokenDB
 }
 
 func isEmailValid(e string) bool {
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/fido-alliance/iot-fdo-conformance-tools/api/commonapi"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/dbs"
+)
+
+func apiTokenInfo(tokenEntry dbs.ApiTokenEntry) commonapi.User_ApiTokenInfo {
+	return commonapi.User_ApiTokenInfo{
+		Id:        tokenEntry.Id,
+		Name:      tokenEntry.Name,
+		Scope:     string(tokenEntry.Scope),
+		CreatedAt: tokenEntry.CreatedAt,
+		LastUsed:  tokenEntry.LastUsed,
+	}
+}
+
+// CreateApiToken creates a new personal API token. The token is only returned once. Tokens can only be managed
+// with a login session, not with another token
+func (h *UserAPI) CreateApiToken(w http.ResponseWriter, r *http.Request) {
+	if !commonapi.CheckHeaders(w, r) {
+		return
+	}
+
+	isLoggedIn, sessionInst, _ := h.isLoggedIn(r)
+	if !isLoggedIn {
+		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	bodyBytes, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Println("Failed to read body. " + err.Error())
+		commonapi.RespondError(w, "Failed to read body!", http.StatusBadRequest)
+		return
+	}
+
+	var tokenReq commonapi.User_ApiTokenReq
+	err = json.Unmarshal(bodyBytes, &tokenReq)
+	if err != nil {
+		log.Println("Failed to decode body. " + err.Error())
+		commonapi.RespondError(w, "Failed to decode body!", http.StatusBadRequest)
+		return
+	}
+
+	if tokenReq.Name == "" {
+		commonapi.RespondError(w, "Missing token name!", http.StatusBadRequest)
+		return
+	}
+
+	scope := dbs.ApiTokenScope(tokenReq.Scope)
+	if !scope.IsValid() {
+		commonapi.RespondError(w, "Unknown token scope. Expected read or execute!", http.StatusBadRequest)
+		return
+	}
+
+	token, tokenEntry, err := h.ApiTokenDB.Create(sessionInst.Email, tokenReq.Name, scope)
+	if err != nil {
+		log.Println("Failed to create api token. " + err.Error())
+		commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	commonapi.RespondSuccessStruct(w, commonapi.User_ApiTokenCreated{
+		User_ApiTokenInfo: apiTokenInfo(*tokenEntry),
+		Token:             token,
+		Status:            commonapi.FdoApiStatus_OK,
+	})
+}
+
+func (h *UserAPI) ListApiTokens(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		commonapi.RespondError(w, "Method not allowed!", http.StatusMethodNotAllowed)
+		return
+	}
+
+	isLoggedIn, sessionInst, _ := h.isLoggedIn(r)
+	if !isLoggedIn {
+		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	tokenEntries, err := h.ApiTokenDB.List(sessionInst.Email)
+	if err != nil {
+		log.Println("Failed to list api tokens. " + err.Error())
+		commonapi.RespondError(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	tokenList := commonapi.User_ApiTokenList{
+		Tokens: []commonapi.User_ApiTokenInfo{},
+		Status: commonapi.FdoApiStatus_OK,
+	}
+
+	for _, tokenEntry := range tokenEntries {
+		tokenList.Tokens = append(tokenList.Tokens, apiTokenInfo(tokenEntry))
+	}
+
+	commonapi.RespondSuccessStruct(w, tokenList)
+}
+
+func (h *UserAPI) RevokeApiToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "DELETE" {
+		commonapi.RespondError(w, "Method not allowed!", http.StatusMethodNotAllowed)
+		return
+	}
+
+	isLoggedIn, sessionInst, _ := h.isLoggedIn(r)
+	if !isLoggedIn {
+		commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	err := h.ApiTokenDB.Revoke(sessionInst.Email, mux.Vars(r)["tokenid"])
+	if err != nil {
+		log.Println("Failed to revoke api token. " + err.Error())
+		commonapi.RespondError(w, "Token not found!", http.StatusNotFound)
+		return
+	}
+
+	commonapi.RespondSuccess(w)
+}
@@ -0,0 +1,126 @@
+// Package client is a Go client for the conformance server test management API, see api/openapi.yaml
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/fido-alliance/iot-fdo-conformance-tools/api/commonapi"
+)
+
+const defaultRequestTimeout = 30 * time.Second
+
+type Client struct {
+	baseUrl  string
+	apiToken string
+
+	HTTPClient *http.Client
+
+	// RequestTimeout applies to all requests without a context deadline, except event streams
+	RequestTimeout time.Duration
+}
+
+// NewClient returns a client for the server at baseUrl, e.g. "http://localhost:8080", authenticated with a
+// personal API token
+func NewClient(baseUrl string, apiToken string) *Client {
+	return &Client{
+		baseUrl:        strings.TrimSuffix(baseUrl, "/"),
+		apiToken:       apiToken,
+		HTTPClient:     &http.Client{},
+		RequestTimeout: defaultRequestTimeout,
+	}
+}
+
+func (h *Client) newRequest(ctx context.Context, method string, path string, body interface{}) (*http.Request, error) {
+	var bodyReader io.Reader
+	if body != nil {
+		bodyBytes, err := json.Marshal(body)
+		if err != nil {
+			return nil, errors.New("Failed to encode request body. " + err.Error())
+		}
+
+		bodyReader = bytes.NewReader(bodyBytes)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, h.baseUrl+path, bodyReader)
+	if err != nil {
+		return nil, errors.New("Failed to create request. " + err.Error())
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", commonapi.CONTENT_TYPE_JSON)
+	}
+
+	if h.apiToken != "" {
+		req.Header.Set("Authorization", "Bearer "+h.apiToken)
+	}
+
+	return req, nil
+}
+
+func (h *Client) do(ctx context.Context, method string, path string, body interface{}) ([]byte, error) {
+	if _, ok := ctx.Deadline(); !ok && h.RequestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.RequestTimeout)
+		defer cancel()
+	}
+
+	req, err := h.newRequest(ctx, method, path, body)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := h.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s failed. %s", method, path, err.Error())
+	}
+	defer resp.Body.Close()
+
+	respBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s failed to read response. %s", method, path, err.Error())
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp.StatusCode, respBytes)
+	}
+
+	// Only JSON responses carry a status
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), commonapi.CONTENT_TYPE_JSON) {
+		return respBytes, nil
+	}
+
+	var apiStatus commonapi.FdoConformanceApiError
+	err = json.Unmarshal(respBytes, &apiStatus)
+	if err == nil && apiStatus.Status == commonapi.FdoApiStatus_Failed {
+		return nil, newAPIError(resp.StatusCode, respBytes)
+	}
+
+	return respBytes, nil
+}
+
+// call sends the request and decodes the JSON response into result, if set
+func (h *Client) call(ctx context.Context, method string, path string, body interface{}, result interface{}) error {
+	respBytes, err := h.do(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	err = json.Unmarshal(respBytes, result)
+	if err != nil {
+		return fmt.Errorf("%s %s failed to decode response. %s", method, path, err.Error())
+	}
+
+	return nil
+}
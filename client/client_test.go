@@ -0,0 +1,95 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fido-alliance/iot-fdo-conformance-tools/api/commonapi"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/api/testapi"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/events"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/api/rvt/testruns", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer fdoct_test" {
+			commonapi.RespondError(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		commonapi.RespondSuccessStruct(w, testapi.RVT_ListRvts{
+			RVTItems: []testapi.RVT_Item{
+				{Id: "01", To0: testapi.RVT_InstInfo{Id: "02"}, To1: testapi.RVT_InstInfo{Id: "03", InProgress: true}},
+			},
+			Status: commonapi.FdoApiStatus_OK,
+		})
+	})
+
+	mux.HandleFunc("/api/rvt/execute", func(w http.ResponseWriter, r *http.Request) {
+		commonapi.RespondError(w, "The api token does not have the execute scope", http.StatusForbidden)
+	})
+
+	mux.HandleFunc("/api/events", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, ": keepalive\n\n")
+		fmt.Fprint(w, "event: test-started\ndata: {\"type\":\"test-started\",\"instanceId\":\"02\"}\n\n")
+		fmt.Fprint(w, "event: run-finished\ndata: {\"type\":\"run-finished\",\"instanceId\":\"02\",\"summary\":{\"passed\":3,\"failed\":0,\"failedTests\":[]}}\n\n")
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestClient(t *testing.T) {
+	server := newTestServer(t)
+	defer server.Close()
+
+	ctx := context.Background()
+	client := NewClient(server.URL+"/", "fdoct_test")
+
+	instInfo, err := client.GetRVTInst(ctx, "03")
+	if err != nil {
+		t.Fatalf("Failed to get the instance. %s", err.Error())
+	}
+
+	if !instInfo.InProgress {
+		t.Errorf("Expected the TO1 instance to be in progress")
+	}
+
+	_, err = client.GetRVTInst(ctx, "04")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound. Got %v", err)
+	}
+
+	_, err = client.ExecuteRVT(ctx, "02", 0)
+	var apiErr *APIError
+	if !errors.Is(err, ErrForbidden) || !errors.As(err, &apiErr) || apiErr.Message != "The api token does not have the execute scope" {
+		t.Errorf("Expected a forbidden API error. Got %v", err)
+	}
+
+	_, err = NewClient(server.URL, "fdoct_other").ListRVT(ctx)
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Expected ErrUnauthorized. Got %v", err)
+	}
+
+	var received []events.Event
+	err = client.StreamEvents(ctx, "02", func(event events.Event) error {
+		received = append(received, event)
+		if event.Type == events.EventRunFinished {
+			return ErrStopStream
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to stream events. %s", err.Error())
+	}
+
+	if len(received) != 2 || received[1].Summary == nil || received[1].Summary.Passed != 3 {
+		t.Errorf("Unexpected events %+v", received)
+	}
+}
@@ -0,0 +1,83 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/fido-alliance/iot-fdo-conformance-tools/api/testapi"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	listenertestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/listener"
+)
+
+// CreateDeviceTest creates a device test from the PEM encoded voucher and device private key
+func (h *Client) CreateDeviceTest(ctx context.Context, name string, voucherAndPrivateKey string) error {
+	return h.call(ctx, http.MethodPost, "/api/device/create", testapi.Device_CreateTestCase{
+		Name:                 name,
+		VoucherAndPrivateKey: voucherAndPrivateKey,
+	}, nil)
+}
+
+func (h *Client) ListDeviceTests(ctx context.Context) (*testapi.Device_ListRuns, error) {
+	var deviceList testapi.Device_ListRuns
+	err := h.call(ctx, http.MethodGet, "/api/device/testruns", nil, &deviceList)
+	if err != nil {
+		return nil, err
+	}
+
+	return &deviceList, nil
+}
+
+func devicePath(toProtocol fdoshared.FdoToProtocol, instId string) string {
+	return fmt.Sprintf("/api/device/testruns/%d/%s", toProtocol, url.PathEscape(instId))
+}
+
+// StartDeviceTestRun starts a TO1 or TO2 run. The run progresses as the device under test connects to the server
+func (h *Client) StartDeviceTestRun(ctx context.Context, toProtocol fdoshared.FdoToProtocol, instId string) error {
+	return h.call(ctx, http.MethodPost, devicePath(toProtocol, instId), nil, nil)
+}
+
+func (h *Client) DeleteDeviceTestRun(ctx context.Context, toProtocol fdoshared.FdoToProtocol, instId string, runId string) error {
+	return h.call(ctx, http.MethodDelete, devicePath(toProtocol, instId)+"/"+url.PathEscape(runId), nil, nil)
+}
+
+// GetDeviceTestRuns returns the TO1 or TO2 runs of the device test. A run in progress comes first, followed
+// by the completed runs from oldest to newest
+func (h *Client) GetDeviceTestRuns(ctx context.Context, toProtocol fdoshared.FdoToProtocol, instId string) ([]listenertestsdeps.ListenerTestRun, error) {
+	deviceList, err := h.ListDeviceTests(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, deviceItem := range deviceList.DeviceItems {
+		if deviceItem.Id != instId {
+			continue
+		}
+
+		if toProtocol == fdoshared.To1 {
+			return deviceItem.To1, nil
+		}
+
+		return deviceItem.To2, nil
+	}
+
+	return nil, ErrNotFound
+}
+
+// WaitForDeviceRun polls until the device test has no TO1 or TO2 run in progress, and returns the latest run
+func (h *Client) WaitForDeviceRun(ctx context.Context, toProtocol fdoshared.FdoToProtocol, instId string, interval time.Duration) (*listenertestsdeps.ListenerTestRun, error) {
+	return poll(ctx, interval, func() (*listenertestsdeps.ListenerTestRun, bool, error) {
+		testRuns, err := h.GetDeviceTestRuns(ctx, toProtocol, instId)
+		if err != nil {
+			return nil, false, err
+		}
+
+		if len(testRuns) == 0 || !testRuns[0].Completed {
+			return nil, false, nil
+		}
+
+		return &testRuns[len(testRuns)-1], true, nil
+	})
+}
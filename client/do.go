@@ -0,0 +1,122 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/fido-alliance/iot-fdo-conformance-tools/api/testapi"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/probe"
+)
+
+// CreateDOT creates the TO2 test instance for the DO server at doUrl. privKeyPem is the owner's PEM private key
+func (h *Client) CreateDOT(ctx context.Context, doUrl string, privKeyPem string, concurrency int) error {
+	return h.call(ctx, http.MethodPost, "/api/dot/create", testapi.DOT_CreateTestCase{
+		Url:         doUrl,
+		PrivKey:     privKeyPem,
+		Concurrency: concurrency,
+	}, nil)
+}
+
+func (h *Client) ListDOT(ctx context.Context) (*testapi.DOT_ListTestEntries, error) {
+	var dotList testapi.DOT_ListTestEntries
+	err := h.call(ctx, http.MethodGet, "/api/dot/testruns", nil, &dotList)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dotList, nil
+}
+
+// DownloadVouchers returns the zip archive of the TO2 test instance's vouchers, to be loaded into the DO under test
+func (h *Client) DownloadVouchers(ctx context.Context, instId string) ([]byte, error) {
+	return h.do(ctx, http.MethodGet, "/api/dot/vouchers/"+url.PathEscape(instId), nil)
+}
+
+// ExecuteDOT starts a run of the TO2 test instance with the given id, and returns the run id without waiting for the
+// run to finish, see WaitForDOT. Concurrency 0 keeps the instance setting
+func (h *Client) ExecuteDOT(ctx context.Context, instId string, concurrency int) (string, error) {
+	return h.executeDOT(ctx, testapi.DOT_RequestInfo{
+		Id:          instId,
+		Concurrency: concurrency,
+	})
+}
+
+// ExecuteDOTWithStrictness is ExecuteDOT with the given error code strictness, see testcom.ErrorCodeStrictness
+func (h *Client) ExecuteDOTWithStrictness(ctx context.Context, instId string, concurrency int, strictness testcom.ErrorCodeStrictness) (string, error) {
+	return h.executeDOT(ctx, testapi.DOT_RequestInfo{
+		Id:                  instId,
+		Concurrency:         concurrency,
+		ErrorCodeStrictness: string(strictness),
+	})
+}
+
+// ExecuteDOTSuiteMatrix starts a run of the TO2 test instance once per crypto suite, and returns the run id. With
+// includeNegative all the TO2 tests run per suite, otherwise only the positive flow. The results are in the
+// SuiteMatrix of the run
+func (h *Client) ExecuteDOTSuiteMatrix(ctx context.Context, instId string, concurrency int, includeNegative bool) (string, error) {
+	return h.ExecuteDOTSuiteMatrixWithProfile(ctx, instId, concurrency, includeNegative, nil)
+}
+
+// ExecuteDOTSuiteMatrixWithProfile is ExecuteDOTSuiteMatrix skipping the suites the DO profile rejected, see probe.ProbeDO
+func (h *Client) ExecuteDOTSuiteMatrixWithProfile(ctx context.Context, instId string, concurrency int, includeNegative bool, profile *probe.Profile) (string, error) {
+	suiteMatrix := "positive"
+	if includeNegative {
+		suiteMatrix = "all"
+	}
+
+	return h.executeDOT(ctx, testapi.DOT_RequestInfo{
+		Id:          instId,
+		Concurrency: concurrency,
+		SuiteMatrix: suiteMatrix,
+		Profile:     profile,
+	})
+}
+
+func (h *Client) executeDOT(ctx context.Context, execReq testapi.DOT_RequestInfo) (string, error) {
+	var execResp testapi.DOT_ExecuteResponse
+	err := h.call(ctx, http.MethodPost, "/api/dot/execute", execReq, &execResp)
+	if err != nil {
+		return "", err
+	}
+
+	return execResp.RunId, nil
+}
+
+func (h *Client) CancelDOTRun(ctx context.Context, runId string) error {
+	return h.call(ctx, http.MethodDelete, "/api/dot/execute/"+url.PathEscape(runId), nil, nil)
+}
+
+func (h *Client) DeleteDOTRun(ctx context.Context, instId string, runId string) error {
+	return h.call(ctx, http.MethodDelete, "/api/dot/testruns/"+url.PathEscape(instId)+"/"+url.PathEscape(runId), nil, nil)
+}
+
+// GetDOTInst returns the TO2 test instance with the given id
+func (h *Client) GetDOTInst(ctx context.Context, instId string) (*testapi.DOT_InstInfo, error) {
+	dotList, err := h.ListDOT(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, dotItem := range dotList.TestEntries {
+		if dotItem.To2.Id == instId {
+			return &dotItem.To2, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+// WaitForDOT polls until the TO2 test instance has no run in progress, and returns it
+func (h *Client) WaitForDOT(ctx context.Context, instId string, interval time.Duration) (*testapi.DOT_InstInfo, error) {
+	return poll(ctx, interval, func() (*testapi.DOT_InstInfo, bool, error) {
+		instInfo, err := h.GetDOTInst(ctx, instId)
+		if err != nil {
+			return nil, false, err
+		}
+
+		return instInfo, !instInfo.InProgress, nil
+	})
+}
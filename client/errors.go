@@ -0,0 +1,51 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/fido-alliance/iot-fdo-conformance-tools/api/commonapi"
+)
+
+// Sentinel errors for use with errors.Is
+var (
+	ErrUnauthorized = &APIError{StatusCode: http.StatusUnauthorized}
+	ErrForbidden    = &APIError{StatusCode: http.StatusForbidden}
+	ErrNotFound     = &APIError{StatusCode: http.StatusNotFound}
+	ErrBadRequest   = &APIError{StatusCode: http.StatusBadRequest}
+)
+
+// APIError is returned for every response with a failed status
+type APIError struct {
+	StatusCode int
+	Status     commonapi.FdoConfApiStatus
+	Message    string
+}
+
+func newAPIError(statusCode int, body []byte) *APIError {
+	apiErr := APIError{
+		StatusCode: statusCode,
+		Status:     commonapi.FdoApiStatus_Failed,
+	}
+
+	var apiStatus commonapi.FdoConformanceApiError
+	if json.Unmarshal(body, &apiStatus) == nil && apiStatus.Status != "" {
+		apiErr.Status = apiStatus.Status
+		apiErr.Message = apiStatus.ErrorMessage
+	} else {
+		apiErr.Message = http.StatusText(statusCode)
+	}
+
+	return &apiErr
+}
+
+func (h *APIError) Error() string {
+	return fmt.Sprintf("API error %d: %s", h.StatusCode, h.Message)
+}
+
+// Is matches API errors by their HTTP status code
+func (h *APIError) Is(target error) bool {
+	targetErr, ok := target.(*APIError)
+	return ok && targetErr.StatusCode == h.StatusCode
+}
@@ -0,0 +1,85 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/events"
+)
+
+// ErrStopStream can be returned by a StreamEvents handler to end the stream without an error
+var ErrStopStream = errors.New("Stop stream")
+
+// StreamEvents calls handler with the live events of the user's tests, or of a single test instance if instId
+// is set. It blocks until ctx is done, the server closes the stream or handler returns an error
+func (h *Client) StreamEvents(ctx context.Context, instId string, handler func(event events.Event) error) error {
+	path := "/api/events"
+	if instId != "" {
+		path += "?id=" + url.QueryEscape(instId)
+	}
+
+	req, err := h.newRequest(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := h.HTTPClient.Do(req)
+	if err != nil {
+		return errors.New("Failed to open event stream. " + err.Error())
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBytes, _ := io.ReadAll(resp.Body)
+		return newAPIError(resp.StatusCode, respBytes)
+	}
+
+	err = readEvents(resp.Body, handler)
+	if errors.Is(err, ErrStopStream) || ctx.Err() != nil {
+		return nil
+	}
+
+	return err
+}
+
+// readEvents parses a Server-Sent Events stream. Comments, such as keep alives, are skipped
+func readEvents(body io.Reader, handler func(event events.Event) error) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var data strings.Builder
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		switch {
+		case line == "":
+			if data.Len() == 0 {
+				continue
+			}
+
+			var event events.Event
+			err := json.Unmarshal([]byte(data.String()), &event)
+			data.Reset()
+			if err != nil {
+				return errors.New("Failed to decode event. " + err.Error())
+			}
+
+			err = handler(event)
+			if err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "data:"):
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+
+	return scanner.Err()
+}
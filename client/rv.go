@@ -0,0 +1,117 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/fido-alliance/iot-fdo-conformance-tools/api/testapi"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+)
+
+// CreateRVT creates the TO0 and TO1 test instances for the RV server at rvUrl
+func (h *Client) CreateRVT(ctx context.Context, rvUrl string, concurrency int) error {
+	return h.call(ctx, http.MethodPost, "/api/rvt/create", testapi.RVT_CreateTestCase{
+		Url:         rvUrl,
+		Concurrency: concurrency,
+	}, nil)
+}
+
+func (h *Client) ListRVT(ctx context.Context) (*testapi.RVT_ListRvts, error) {
+	var rvtList testapi.RVT_ListRvts
+	err := h.call(ctx, http.MethodGet, "/api/rvt/testruns", nil, &rvtList)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rvtList, nil
+}
+
+// ExecuteRVT starts a run of the TO0 or TO1 test instance with the given id, and returns the run id without waiting
+// for the run to finish, see WaitForRVT. Concurrency 0 keeps the instance setting
+func (h *Client) ExecuteRVT(ctx context.Context, instId string, concurrency int) (string, error) {
+	return h.executeRVT(ctx, testapi.RVT_RequestInfo{
+		Id:          instId,
+		Concurrency: concurrency,
+	})
+}
+
+// ExecuteRVTWithStrictness is ExecuteRVT with the given error code strictness, see testcom.ErrorCodeStrictness
+func (h *Client) ExecuteRVTWithStrictness(ctx context.Context, instId string, concurrency int, strictness testcom.ErrorCodeStrictness) (string, error) {
+	return h.executeRVT(ctx, testapi.RVT_RequestInfo{
+		Id:                  instId,
+		Concurrency:         concurrency,
+		ErrorCodeStrictness: string(strictness),
+	})
+}
+
+func (h *Client) executeRVT(ctx context.Context, execReq testapi.RVT_RequestInfo) (string, error) {
+	var execResp testapi.RVT_ExecuteResponse
+	err := h.call(ctx, http.MethodPost, "/api/rvt/execute", execReq, &execResp)
+	if err != nil {
+		return "", err
+	}
+
+	return execResp.RunId, nil
+}
+
+func (h *Client) CancelRVTRun(ctx context.Context, runId string) error {
+	return h.call(ctx, http.MethodDelete, "/api/rvt/execute/"+url.PathEscape(runId), nil, nil)
+}
+
+func (h *Client) DeleteRVTRun(ctx context.Context, instId string, runId string) error {
+	return h.call(ctx, http.MethodDelete, "/api/rvt/testruns/"+url.PathEscape(instId)+"/"+url.PathEscape(runId), nil, nil)
+}
+
+// GetRVTInst returns the TO0 or TO1 test instance with the given id
+func (h *Client) GetRVTInst(ctx context.Context, instId string) (*testapi.RVT_InstInfo, error) {
+	rvtList, err := h.ListRVT(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rvtItem := range rvtList.RVTItems {
+		if rvtItem.To0.Id == instId {
+			return &rvtItem.To0, nil
+		}
+
+		if rvtItem.To1.Id == instId {
+			return &rvtItem.To1, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+// WaitForRVT polls until the TO0 or TO1 test instance has no run in progress, and returns it
+func (h *Client) WaitForRVT(ctx context.Context, instId string, interval time.Duration) (*testapi.RVT_InstInfo, error) {
+	return poll(ctx, interval, func() (*testapi.RVT_InstInfo, bool, error) {
+		instInfo, err := h.GetRVTInst(ctx, instId)
+		if err != nil {
+			return nil, false, err
+		}
+
+		return instInfo, !instInfo.InProgress, nil
+	})
+}
+
+// poll calls check every interval until it reports done, fails or ctx is done
+func poll[T any](ctx context.Context, interval time.Duration, check func() (T, bool, error)) (T, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		result, done, err := check()
+		if err != nil || done {
+			return result, err
+		}
+
+		select {
+		case <-ctx.Done():
+			var empty T
+			return empty, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
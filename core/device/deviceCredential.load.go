@@ -4,7 +4,7 @@ import (
 	"encoding/pem"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"strings"
 
@@ -23,7 +23,7 @@ func GetCredentials() ([]string, error) {
 
 	for _, folderEntry := range folderEntries {
 		if folderEntry.IsDir() || !strings.HasSuffix(folderEntry.Name(), ".dis.pem") {
-			log.Println("Skipping " + folderEntry.Name())
+			slog.Debug("Skipping " + folderEntry.Name())
 			continue
 		}
 		credentialFiles = append(credentialFiles, DEVICE_CREDENTIAL_LOC+folderEntry.Name())
@@ -5,7 +5,7 @@ import (
 	"encoding/pem"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"time"
 
@@ -142,7 +142,7 @@ func NewVirtualDeviceAndVoucher(newDi fdoshared.WawDeviceCredential, voucherSgTy
 		if i == 0 {
 			headerHmacBytes, err := fdoshared.CborCust.Marshal(ovHeaderHmac)
 			if err != nil {
-				log.Println("Error generating hash: ", err.Error())
+				slog.Error("Error generating hash", fdoshared.LOG_ATTR_GUID, newDi.DCGuid.GetFormatted(), "error", err.Error())
 				return nil, err
 			}
 
@@ -150,7 +150,7 @@ func NewVirtualDeviceAndVoucher(newDi fdoshared.WawDeviceCredential, voucherSgTy
 
 			prevEntryHash, err = fdoshared.GenerateFdoHash(prevEntryPayloadBytes, newDi.DCHashAlg)
 			if err != nil {
-				log.Println("Error generating hash: ", err.Error())
+				slog.Error("Error generating hash", fdoshared.LOG_ATTR_GUID, newDi.DCGuid.GetFormatted(), "error", err.Error())
 				return nil, err
 			}
 
@@ -283,9 +283,7 @@ func GenerateAndSaveDeviceCredAndVoucher(deviceCred fdoshared.WawDeviceCredentia
 		return fmt.Errorf("error saving di \"%s\". %s", disWriteLocation, err.Error())
 	}
 
-	log.Println("Successfully generate voucher and di files.")
-	log.Println(voucherWriteLocation)
-	log.Println(disWriteLocation)
+	slog.Info("Successfully generate voucher and di files.", fdoshared.LOG_ATTR_GUID, vdandv.WawDeviceCredential.DCGuid.GetFormatted(), "voucher", voucherWriteLocation, "di", disWriteLocation)
 
 	return nil
 }
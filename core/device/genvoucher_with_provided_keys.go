@@ -2,7 +2,7 @@ package device
 
 import (
 	"errors"
-	"log"
+	"log/slog"
 
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
@@ -92,7 +92,7 @@ func NewVirtualDeviceAndVoucherWithKeys(
 		if i == 0 {
 			headerHmacBytes, err := fdoshared.CborCust.Marshal(ovHeaderHmac)
 			if err != nil {
-				log.Println("Error generating hash: ", err.Error())
+				slog.Error("Error generating hash", fdoshared.LOG_ATTR_GUID, newDi.DCGuid.GetFormatted(), "error", err.Error())
 				return nil, err
 			}
 
@@ -100,7 +100,7 @@ func NewVirtualDeviceAndVoucherWithKeys(
 
 			prevEntryHash, err = fdoshared.GenerateFdoHash(prevEntryPayloadBytes, newDi.DCHashAlg)
 			if err != nil {
-				log.Println("Error generating hash: ", err.Error())
+				slog.Error("Error generating hash", fdoshared.LOG_ATTR_GUID, newDi.DCGuid.GetFormatted(), "error", err.Error())
 				return nil, err
 			}
 
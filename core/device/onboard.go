@@ -0,0 +1,145 @@
+package device
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/device/to1"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/device/to2"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+)
+
+// RunTo1 runs the TO1 exchange against the RV server at rvUrl and returns the decoded To1d payload
+func RunTo1(ctx context.Context, rvUrl string, credential fdoshared.WawDeviceCredential) (*fdoshared.To1dBlobPayload, error) {
+	to1inst := to1.NewTo1Requestor(fdoshared.SRVEntry{
+		SrvURL: rvUrl,
+	}, credential, ctx)
+
+	helloRvAck31, _, err := to1inst.HelloRV30(testcom.NULL_TEST)
+	if err != nil {
+		return nil, errors.New("Error running HelloRV30. " + err.Error())
+	}
+
+	to1d, _, err := to1inst.ProveToRV32(*helloRvAck31, testcom.NULL_TEST)
+	if err != nil {
+		return nil, errors.New("Error running ProveToRV32. " + err.Error())
+	}
+
+	var to1dPayload fdoshared.To1dBlobPayload
+	err = fdoshared.CborCust.Unmarshal(to1d.Payload, &to1dPayload)
+	if err != nil {
+		return nil, errors.New("Error decoding TO1D payload. " + err.Error())
+	}
+
+	if len(to1dPayload.To1dRV) == 0 {
+		return nil, errors.New("TO1D payload has no owner addresses")
+	}
+
+	return &to1dPayload, nil
+}
+
+// RunTo2 runs the TO2 exchange against the DO server at doUrl, sending the OS device service info.
+// Returns the finished requestor, and the service info received from the owner
+func RunTo2(ctx context.Context, doUrl string, credential fdoshared.WawDeviceCredential, kexSuite fdoshared.KexSuiteName, cipherSuite fdoshared.CipherSuiteName) (*to2.To2Requestor, fdoshared.SIMS, error) {
+	to2inst := to2.NewTo2Requestor(fdoshared.SRVEntry{
+		SrvURL: doUrl,
+	}, credential, kexSuite, cipherSuite, ctx)
+
+	// 60
+	to2proveOvhdrPayload, _, err := to2inst.HelloDevice60(testcom.NULL_TEST)
+	if err != nil {
+		return nil, nil, errors.New("Error running HelloDevice60. " + err.Error())
+	}
+
+	// 62
+	var ovEntries []fdoshared.CoseSignature
+	for i := 0; i < int(to2proveOvhdrPayload.NumOVEntries); i++ {
+		slog.Debug(fmt.Sprintf("Requesting GetOVNextEntry62 for entry %d", i))
+		nextEntry, _, err := to2inst.GetOVNextEntry62(uint8(i), testcom.NULL_TEST)
+		if err != nil {
+			return nil, nil, errors.New("Error running GetOVNextEntry62. " + err.Error())
+		}
+
+		if nextEntry.OVEntryNum != uint8(i) {
+			return nil, nil, fmt.Errorf("Server returned wrong entry. Expected %d. Got %d", i, nextEntry.OVEntryNum)
+		}
+
+		ovEntries = append(ovEntries, nextEntry.OVEntry)
+	}
+
+	if len(ovEntries) == 0 {
+		return nil, nil, errors.New("Server returned no OVEntries")
+	}
+
+	err = fdoshared.OVEntryArray(ovEntries).VerifyEntries(to2proveOvhdrPayload.OVHeader, to2proveOvhdrPayload.HMac)
+	if err != nil {
+		return nil, nil, errors.New("Error verifying OVEntries. " + err.Error())
+	}
+
+	lastOvEntry := ovEntries[len(ovEntries)-1]
+	loePubKey, err := lastOvEntry.GetOVEntryPubKey()
+	if err != nil {
+		return nil, nil, errors.New("Error decoding last OVEntry public key. " + err.Error())
+	}
+
+	err = to2inst.ProveOVHdr61PubKey.Equal(loePubKey)
+	if err != nil {
+		return nil, nil, errors.New("ProveOVHdr public key does not match the last OVEntry. " + err.Error())
+	}
+
+	// 64
+	_, _, err = to2inst.ProveDevice64(testcom.NULL_TEST)
+	if err != nil {
+		return nil, nil, errors.New("Error running ProveDevice64. " + err.Error())
+	}
+
+	// 66
+	_, _, err = to2inst.DeviceServiceInfoReady66(testcom.NULL_TEST)
+	if err != nil {
+		return nil, nil, errors.New("Error running DeviceServiceInfoReady66. " + err.Error())
+	}
+
+	// 68
+	deviceSims := fdoshared.GetDeviceOSSims()
+	var ownerSims fdoshared.SIMS
+
+	for i, deviceSim := range deviceSims {
+		slog.Debug("Sending DeviceServiceInfo68 for sim " + string(deviceSim.ServiceInfoKey))
+		_, _, err := to2inst.DeviceServiceInfo68(fdoshared.DeviceServiceInfo68{
+			ServiceInfo: []fdoshared.ServiceInfoKV{
+				deviceSim,
+			},
+			IsMoreServiceInfo: i+1 <= len(deviceSims),
+		}, testcom.NULL_TEST)
+		if err != nil {
+			return nil, nil, errors.New("Error running DeviceServiceInfo68. " + err.Error())
+		}
+	}
+
+	for {
+		ownerSim, _, err := to2inst.DeviceServiceInfo68(fdoshared.DeviceServiceInfo68{
+			ServiceInfo:       []fdoshared.ServiceInfoKV{},
+			IsMoreServiceInfo: false,
+		}, testcom.NULL_TEST)
+		if err != nil {
+			return nil, nil, errors.New("Error running DeviceServiceInfo68. " + err.Error())
+		}
+
+		ownerSims = append(ownerSims, ownerSim.ServiceInfo...)
+
+		if ownerSim.IsDone {
+			break
+		}
+	}
+
+	// 70
+	_, _, err = to2inst.Done70(testcom.NULL_TEST)
+	if err != nil {
+		return nil, nil, errors.New("Error running Done70. " + err.Error())
+	}
+
+	return &to2inst, ownerSims, nil
+}
@@ -19,6 +19,10 @@ func (h *To1Requestor) ProveToRV32(helloRVAck31 fdoshared.HelloRVAck31, fdoTestI
 		proveToRV32Payload.EatNonce = fdoshared.NewFdoNonce()
 	}
 
+	if fdoTestID == testcom.FIDO_DEVT_32_OTHER_GUID {
+		proveToRV32Payload.EatUEID = fdoshared.GenerateEatGuid(fdoshared.NewFdoGuid())
+	}
+
 	proveToRV32PayloadBytes, err := fdoshared.CborCust.Marshal(proveToRV32Payload)
 	if err != nil {
 		return nil, nil, errors.New("ProveToRV32: Error generating ProveToRV32 payload. " + err.Error())
@@ -55,7 +59,7 @@ func (h *To1Requestor) ProveToRV32(helloRVAck31 fdoshared.HelloRVAck31, fdoTestI
 
 	var rvRedirect33 fdoshared.CoseSignature
 
-	resultBytes, authzHeader, httpStatusCode, err := fdoshared.SendCborPost(h.rvEntry, fdoshared.TO1_32_PROVE_TO_RV, proveToRV32Bytes, &h.authzHeader)
+	resultBytes, authzHeader, httpStatusCode, err := fdoshared.SendCborPost(h.ctx, h.rvEntry, fdoshared.TO1_32_PROVE_TO_RV, proveToRV32Bytes, &h.authzHeader)
 	if fdoTestID != testcom.NULL_TEST {
 		testState = h.confCheckResponse(resultBytes, fdoTestID, httpStatusCode)
 		return &rvRedirect33, &testState, nil
@@ -1,6 +1,8 @@
 package to1
 
 import (
+	"context"
+
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
 )
@@ -9,23 +11,42 @@ type To1Requestor struct {
 	rvEntry     fdoshared.SRVEntry
 	credential  fdoshared.WawDeviceCredential
 	authzHeader string
+	ctx         context.Context
 }
 
-func NewTo1Requestor(srvEntry fdoshared.SRVEntry, credential fdoshared.WawDeviceCredential) To1Requestor {
+func NewTo1Requestor(srvEntry fdoshared.SRVEntry, credential fdoshared.WawDeviceCredential, ctx context.Context) To1Requestor {
+	ctx = fdoshared.NewLogContext(ctx)
+	fdoshared.SetLogGuid(ctx, credential.DCGuid)
+
 	return To1Requestor{
 		rvEntry:    srvEntry,
 		credential: credential,
+		ctx:        ctx,
 	}
 }
 
+// GetAuthzHeader returns the session authorization received from the RV
+func (h *To1Requestor) GetAuthzHeader() string {
+	return h.authzHeader
+}
+
 func (h *To1Requestor) confCheckResponse(bodyBytes []byte, fdoTestID testcom.FDOTestID, httpStatusCode int) testcom.FDOTestState {
 	switch fdoTestID {
 
 	case testcom.ExpectGroupTests(testcom.FIDO_TEST_LIST_DEVT_30, fdoTestID):
-		return testcom.ExpectAnyFdoError(bodyBytes, fdoTestID, fdoshared.MESSAGE_BODY_ERROR, httpStatusCode)
+		return testcom.ExpectFdoErrorWithStrictness(bodyBytes, fdoTestID, httpStatusCode, testcom.GetErrorCodeStrictness(h.ctx))
 
 	case testcom.ExpectGroupTests(testcom.FIDO_TEST_LIST_DEVT_32, fdoTestID):
-		return testcom.ExpectAnyFdoError(bodyBytes, fdoTestID, fdoshared.MESSAGE_BODY_ERROR, httpStatusCode)
+		return testcom.ExpectFdoErrorWithStrictness(bodyBytes, fdoTestID, httpStatusCode, testcom.GetErrorCodeStrictness(h.ctx))
+
+	case testcom.ExpectGroupTests(testcom.FIDO_TEST_LIST_DEVT_ORDER, fdoTestID):
+		return testcom.ExpectFdoErrorWithStrictness(bodyBytes, fdoTestID, httpStatusCode, testcom.GetErrorCodeStrictness(h.ctx))
+
+	case testcom.ExpectGroupTests(testcom.FIDO_TEST_LIST_DEVT_EXPIRY, fdoTestID):
+		return testcom.ExpectFdoErrorWithStrictness(bodyBytes, fdoTestID, httpStatusCode, testcom.GetErrorCodeStrictness(h.ctx))
+
+	case testcom.ExpectGroupTests(testcom.FIDO_TEST_LIST_DEVT_REPLAY, fdoTestID):
+		return testcom.ExpectFdoErrorWithStrictness(bodyBytes, fdoTestID, httpStatusCode, testcom.GetErrorCodeStrictness(h.ctx))
 
 	}
 	return testcom.NewFailTestState(fdoTestID, "Unsupported test "+string(fdoTestID))
@@ -46,6 +46,10 @@ func (h *To2Requestor) ProveDevice64(fdoTestID testcom.FDOTestID) (*fdoshared.TO
 		eatPayload.EatNonce = fdoshared.NewFdoNonce()
 	}
 
+	if fdoTestID == testcom.FIDO_DOT_64_OTHER_GUID {
+		eatPayload.EatUEID = fdoshared.GenerateEatGuid(fdoshared.NewFdoGuid())
+	}
+
 	eatPayloadBytes, _ := fdoshared.CborCust.Marshal(eatPayload)
 	if fdoTestID == testcom.FIDO_DOT_64_BAD_EAT_PAYLOAD {
 		eatPayloadBytes = fdoshared.Conf_RandomCborBufferFuzzing(eatPayloadBytes)
@@ -73,7 +77,7 @@ func (h *To2Requestor) ProveDevice64(fdoTestID testcom.FDOTestID) (*fdoshared.TO
 		proveDeviceBytes = fdoshared.Conf_RandomCborBufferFuzzing(proveDeviceBytes)
 	}
 
-	rawResultBytes, authzHeader, httpStatusCode, err := fdoshared.SendCborPost(h.SrvEntry, fdoshared.TO2_64_PROVE_DEVICE, proveDeviceBytes, &h.AuthzHeader)
+	rawResultBytes, authzHeader, httpStatusCode, err := fdoshared.SendCborPost(h.ctx, h.SrvEntry, fdoshared.TO2_64_PROVE_DEVICE, proveDeviceBytes, &h.AuthzHeader)
 	if fdoTestID != testcom.NULL_TEST {
 		testState = h.confCheckResponse(rawResultBytes, fdoTestID, httpStatusCode)
 		return nil, &testState, nil
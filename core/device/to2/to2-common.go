@@ -1,6 +1,8 @@
 package to2
 
 import (
+	"context"
+
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
 )
@@ -35,39 +37,54 @@ type To2Requestor struct {
 	CredentialReuse bool
 
 	ReplacementCredential fdoshared.TO2SetupDevicePayload
+
+	ctx context.Context
 }
 
-func NewTo2Requestor(srvEntry fdoshared.SRVEntry, credential fdoshared.WawDeviceCredential, kexSuitName fdoshared.KexSuiteName, cipherSuitName fdoshared.CipherSuiteName) To2Requestor {
+func NewTo2Requestor(srvEntry fdoshared.SRVEntry, credential fdoshared.WawDeviceCredential, kexSuitName fdoshared.KexSuiteName, cipherSuitName fdoshared.CipherSuiteName, ctx context.Context) To2Requestor {
+	ctx = fdoshared.NewLogContext(ctx)
+	fdoshared.SetLogGuid(ctx, credential.DCGuid)
+
 	return To2Requestor{
 		SrvEntry:        srvEntry,
 		Credential:      credential,
 		KexSuiteName:    kexSuitName,
 		CipherSuiteName: cipherSuitName,
+		ctx:             ctx,
 	}
 }
 
 func (h *To2Requestor) confCheckResponse(bodyBytes []byte, fdoTestID testcom.FDOTestID, httpStatusCode int) testcom.FDOTestState {
 	switch fdoTestID {
 	case testcom.ExpectGroupTests(testcom.FIDO_TEST_LIST_DOT_60, fdoTestID):
-		return testcom.ExpectAnyFdoError(bodyBytes, fdoTestID, fdoshared.MESSAGE_BODY_ERROR, httpStatusCode)
+		return testcom.ExpectFdoErrorWithStrictness(bodyBytes, fdoTestID, httpStatusCode, testcom.GetErrorCodeStrictness(h.ctx))
 
 	case testcom.ExpectGroupTests(testcom.FIDO_TEST_LIST_DOT_62, fdoTestID):
-		return testcom.ExpectAnyFdoError(bodyBytes, fdoTestID, fdoshared.MESSAGE_BODY_ERROR, httpStatusCode)
+		return testcom.ExpectFdoErrorWithStrictness(bodyBytes, fdoTestID, httpStatusCode, testcom.GetErrorCodeStrictness(h.ctx))
 
 	case testcom.ExpectGroupTests(testcom.FIDO_TEST_LIST_DOT_64, fdoTestID):
-		return testcom.ExpectAnyFdoError(bodyBytes, fdoTestID, fdoshared.MESSAGE_BODY_ERROR, httpStatusCode)
+		return testcom.ExpectFdoErrorWithStrictness(bodyBytes, fdoTestID, httpStatusCode, testcom.GetErrorCodeStrictness(h.ctx))
 
 	case testcom.ExpectGroupTests(testcom.FIDO_TEST_LIST_DOT_66, fdoTestID):
-		return testcom.ExpectAnyFdoError(bodyBytes, fdoTestID, fdoshared.MESSAGE_BODY_ERROR, httpStatusCode)
+		return testcom.ExpectFdoErrorWithStrictness(bodyBytes, fdoTestID, httpStatusCode, testcom.GetErrorCodeStrictness(h.ctx))
 
 	case testcom.ExpectGroupTests(testcom.FIDO_TEST_LIST_DOT_68, fdoTestID):
-		return testcom.ExpectAnyFdoError(bodyBytes, fdoTestID, fdoshared.MESSAGE_BODY_ERROR, httpStatusCode)
+		return testcom.ExpectFdoErrorWithStrictness(bodyBytes, fdoTestID, httpStatusCode, testcom.GetErrorCodeStrictness(h.ctx))
 
 	case testcom.ExpectGroupTests(testcom.FIDO_TEST_LIST_DOT_70, fdoTestID):
-		return testcom.ExpectAnyFdoError(bodyBytes, fdoTestID, fdoshared.MESSAGE_BODY_ERROR, httpStatusCode)
+		return testcom.ExpectFdoErrorWithStrictness(bodyBytes, fdoTestID, httpStatusCode, testcom.GetErrorCodeStrictness(h.ctx))
+
+	case testcom.ExpectGroupTests(testcom.FIDO_TEST_LIST_DOT_ORDER, fdoTestID):
+		return testcom.ExpectFdoErrorWithStrictness(bodyBytes, fdoTestID, httpStatusCode, testcom.GetErrorCodeStrictness(h.ctx))
+
+	case testcom.ExpectGroupTests(testcom.FIDO_TEST_LIST_DOT_EXPIRY, fdoTestID):
+		return testcom.ExpectFdoErrorWithStrictness(bodyBytes, fdoTestID, httpStatusCode, testcom.GetErrorCodeStrictness(h.ctx))
+
+	case testcom.ExpectGroupTests(testcom.FIDO_TEST_LIST_DOT_REPLAY, fdoTestID):
+		return testcom.ExpectFdoErrorWithStrictness(bodyBytes, fdoTestID, httpStatusCode, testcom.GetErrorCodeStrictness(h.ctx))
 
 	case testcom.ExpectGroupTests(testcom.FIDO_TEST_LIST_VOUCHER, fdoTestID):
-		return testcom.ExpectAnyFdoError(bodyBytes, fdoTestID, fdoshared.MESSAGE_BODY_ERROR, httpStatusCode)
+		return testcom.ExpectFdoErrorWithStrictness(bodyBytes, fdoTestID, httpStatusCode, testcom.GetErrorCodeStrictness(h.ctx))
 
 	default:
 		return testcom.FDOTestState{
@@ -0,0 +1,22 @@
+package dbs
+
+import (
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/records"
+)
+
+const (
+	RT_Session records.RecordType = "do_session"
+	RT_Voucher records.RecordType = "do_voucher"
+)
+
+func init() {
+	// Sessions are short lived, they are only migrated on read
+	records.Register(records.Schema{
+		Type: RT_Session,
+	})
+
+	records.Register(records.Schema{
+		Type:     RT_Voucher,
+		Prefixes: [][]byte{[]byte("voucher-")},
+	})
+}
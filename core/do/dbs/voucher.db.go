@@ -5,17 +5,17 @@ import (
 	"errors"
 	"fmt"
 
-	"github.com/dgraph-io/badger/v4"
-
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/records"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/storage"
 )
 
 type VoucherDB struct {
-	db     *badger.DB
+	db     storage.Store
 	prefix []byte
 }
 
-func NewVoucherDB(db *badger.DB) *VoucherDB {
+func NewVoucherDB(db storage.Store) *VoucherDB {
 	return &VoucherDB{
 		db:     db,
 		prefix: []byte("voucher-"),
@@ -27,7 +27,7 @@ func (h VoucherDB) getEntryID(guid fdoshared.FdoGuid) []byte {
 }
 
 func (h *VoucherDB) Save(voucherDBEntry fdoshared.VoucherDBEntry) error {
-	voucherDBBytes, err := fdoshared.CborCust.Marshal(voucherDBEntry)
+	voucherDBBytes, err := records.Marshal(RT_Voucher, voucherDBEntry)
 	if err != nil {
 		return errors.New("Failed to marshal voucher. " + err.Error())
 	}
@@ -40,8 +40,7 @@ func (h *VoucherDB) Save(voucherDBEntry fdoshared.VoucherDBEntry) error {
 	dbtxn := h.db.NewTransaction(true)
 	defer dbtxn.Discard()
 
-	entry := badger.NewEntry(h.getEntryID(ovHeader.OVGuid), voucherDBBytes)
-	err = dbtxn.SetEntry(entry)
+	err = dbtxn.Set(h.getEntryID(ovHeader.OVGuid), voucherDBBytes)
 	if err != nil {
 		return errors.New("Failed creating voucherDB entry instance. " + err.Error())
 	}
@@ -57,7 +56,7 @@ func (h *VoucherDB) Save(voucherDBEntry fdoshared.VoucherDBEntry) error {
 //
 // It's used to save a voucher with a corrupted OVHeader.
 func (h *VoucherDB) SaveByGUID(guid fdoshared.FdoGuid, voucherDBEntry fdoshared.VoucherDBEntry) error {
-	voucherDBBytes, err := fdoshared.CborCust.Marshal(voucherDBEntry)
+	voucherDBBytes, err := records.Marshal(RT_Voucher, voucherDBEntry)
 	if err != nil {
 		return errors.New("Failed to marshal voucher. " + err.Error())
 	}
@@ -65,8 +64,7 @@ func (h *VoucherDB) SaveByGUID(guid fdoshared.FdoGuid, voucherDBEntry fdoshared.
 	dbtxn := h.db.NewTransaction(true)
 	defer dbtxn.Discard()
 
-	entry := badger.NewEntry(h.getEntryID(guid), voucherDBBytes)
-	err = dbtxn.SetEntry(entry)
+	err = dbtxn.Set(h.getEntryID(guid), voucherDBBytes)
 	if err != nil {
 		return errors.New("Failed creating voucherDB entry instance. " + err.Error())
 	}
@@ -82,21 +80,16 @@ func (h *VoucherDB) Get(deviceGuid fdoshared.FdoGuid) (*fdoshared.VoucherDBEntry
 	dbtxn := h.db.NewTransaction(true)
 	defer dbtxn.Discard()
 
-	item, err := dbtxn.Get(h.getEntryID(deviceGuid))
-	if err != nil && errors.Is(err, badger.ErrKeyNotFound) {
+	itemBytes, err := dbtxn.Get(h.getEntryID(deviceGuid))
+	if err != nil && errors.Is(err, storage.ErrKeyNotFound) {
 		return nil, fmt.Errorf("The voucher entry for GUID(%s) does not exist", hex.EncodeToString(deviceGuid[:]))
 	} else if err != nil {
 		return nil, errors.New("Failed locating voucher entry. " + err.Error())
 	}
 
-	itemBytes, err := item.ValueCopy(nil)
-	if err != nil {
-		return nil, errors.New("Failed reading voucherdb entry value. " + err.Error())
-	}
-
 	var voucherDBEInst fdoshared.VoucherDBEntry
 
-	err = fdoshared.CborCust.Unmarshal(itemBytes, &voucherDBEInst)
+	err = records.Unmarshal(RT_Voucher, itemBytes, &voucherDBEInst)
 	if err != nil {
 		return nil, errors.New("Failed cbor decoding voucherdb entry " + err.Error())
 	}
@@ -110,25 +103,22 @@ func (h *VoucherDB) List() ([]fdoshared.FdoGuid, error) {
 	dbtxn := h.db.NewTransaction(true)
 	defer dbtxn.Discard()
 
-	// Create an iterator to iterate over all key-value pairs
-	it := dbtxn.NewIterator(badger.DefaultIteratorOptions)
-	defer it.Close()
-
-	// Iterate over all key-value pairs and add the keys to the list
-	for it.Seek(h.prefix); it.ValidForPrefix(h.prefix); it.Next() {
-		item := it.Item()
-		keyBytes := item.KeyCopy(nil)
-
-		guidBytes := keyBytes[len(h.prefix):]
+	// Iterate over all entries and add the keys to the list
+	err := dbtxn.Iterate(h.prefix, func(key []byte, value []byte) error {
+		guidBytes := key[len(h.prefix):]
 
 		if len(guidBytes) != 16 {
-			return nil, errors.New("invalid voucherdb entry key length")
+			return errors.New("invalid voucherdb entry key length")
 		}
 
 		var guid fdoshared.FdoGuid
 		guid.FromBytes(guidBytes[:])
 
 		result = append(result, guid)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return result, nil
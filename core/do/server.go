@@ -2,20 +2,38 @@ package do
 
 import (
 	"context"
+	"log"
 	"net/http"
 
-	"github.com/dgraph-io/badger/v4"
+	"github.com/prometheus/client_golang/prometheus"
 
+	dodbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/do/dbs"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/do/to2"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/storage"
 )
 
-func SetupServer(db *badger.DB, ctx context.Context) {
-	doto2 := to2.NewDoTo2(db, ctx)
+// SetupServer registers the DO handlers on the default mux
+func SetupServer(db storage.Store, ctx context.Context) {
+	RegisterRoutes(http.DefaultServeMux, db, ctx)
+}
+
+// RegisterRoutes registers the DO handlers on mux, so that the server can run on a private mux, e.g. in httptest
+func RegisterRoutes(mux *http.ServeMux, db storage.Store, ctx context.Context) {
+	// The handlers share the session DB, so that it counts their active sessions
+	sessionDb := dodbs.NewSessionDB(db)
+	doto2 := to2.NewDoTo2(db, sessionDb, ctx)
+
+	err := fdoshared.RegisterGaugeFunc(ctx, "active_sessions", "FDO sessions that have not ended or expired yet.", prometheus.Labels{"server": "do"}, sessionDb.CountActive)
+	if err != nil {
+		log.Println(err.Error())
+	}
 
-	http.HandleFunc("/fdo/101/msg/60", doto2.HelloDevice60)
-	http.HandleFunc("/fdo/101/msg/62", doto2.GetOVNextEntry62)
-	http.HandleFunc("/fdo/101/msg/64", doto2.ProveDevice64)
-	http.HandleFunc("/fdo/101/msg/66", doto2.DeviceServiceInfoReady66)
-	http.HandleFunc("/fdo/101/msg/68", doto2.DeviceServiceInfo68)
-	http.HandleFunc("/fdo/101/msg/70", doto2.Done70)
+	// A follow-up message rejected with an error ends the session
+	mux.HandleFunc("/fdo/101/msg/60", fdoshared.InstrumentHandler(fdoshared.TO2_60_HELLO_DEVICE, doto2.HelloDevice60))
+	mux.HandleFunc("/fdo/101/msg/62", fdoshared.InstrumentHandler(fdoshared.TO2_62_GET_OVNEXTENTRY, fdoshared.EndSessionOnError(doto2.GetOVNextEntry62, sessionDb.DeleteSessionEntry)))
+	mux.HandleFunc("/fdo/101/msg/64", fdoshared.InstrumentHandler(fdoshared.TO2_64_PROVE_DEVICE, fdoshared.EndSessionOnError(doto2.ProveDevice64, sessionDb.DeleteSessionEntry)))
+	mux.HandleFunc("/fdo/101/msg/66", fdoshared.InstrumentHandler(fdoshared.TO2_66_DEVICE_SERVICE_INFO_READY, fdoshared.EndSessionOnError(doto2.DeviceServiceInfoReady66, sessionDb.DeleteSessionEntry)))
+	mux.HandleFunc("/fdo/101/msg/68", fdoshared.InstrumentHandler(fdoshared.TO2_68_DEVICE_SERVICE_INFO, fdoshared.EndSessionOnError(doto2.DeviceServiceInfo68, sessionDb.DeleteSessionEntry)))
+	mux.HandleFunc("/fdo/101/msg/70", fdoshared.InstrumentHandler(fdoshared.TO2_70_DONE, fdoshared.EndSessionOnError(doto2.Done70, sessionDb.DeleteSessionEntry)))
 }
@@ -23,8 +23,14 @@ func NewTo0Requestor(rvEntry fdoshared.SRVEntry, voucherDBEntry fdoshared.Vouche
 	}
 }
 
+// GetAuthzHeader returns the session authorization received from the RV
+func (h *To0Requestor) GetAuthzHeader() string {
+	return h.authzHeader
+}
+
 const ServerWaitSeconds uint32 = 30 * 24 * 60 * 60 // 1 month
 
+// getRVTO2AddrEntry is the owner address announced in TO0, where the DO serves TO2
 func (h *To0Requestor) getRVTO2AddrEntry() (*fdoshared.RVTO2AddrEntry, error) {
 	servUrl := h.ctx.Value(fdoshared.CFG_ENV_FDO_SERVICE_URL).(string)
 	if servUrl == "" {
@@ -35,11 +41,6 @@ func (h *To0Requestor) getRVTO2AddrEntry() (*fdoshared.RVTO2AddrEntry, error) {
 }
 
 func (h *To0Requestor) confCheckResponse(bodyBytes []byte, fdoTestID testcom.FDOTestID, httpStatusCode int) testcom.FDOTestState {
-	expectedErrorCode, ok := testcom.FIDO_TEST_TO_FDO_ERROR_CODE[fdoTestID]
-	if !ok {
-		expectedErrorCode = fdoshared.MESSAGE_BODY_ERROR
-	}
-
 	switch fdoTestID {
 	case testcom.FIDO_RVT_21_CHECK_RESP:
 		fdoErrInst, err := fdoshared.DecodeErrorResponse(bodyBytes)
@@ -70,13 +71,19 @@ func (h *To0Requestor) confCheckResponse(bodyBytes []byte, fdoTestID testcom.FDO
 		return testcom.NewSuccessTestState(fdoTestID)
 
 	case testcom.ExpectGroupTests(testcom.FIDO_TEST_LIST_RVT_20, fdoTestID):
-		return testcom.ExpectAnyFdoError(bodyBytes, fdoTestID, expectedErrorCode, httpStatusCode)
+		return testcom.ExpectFdoErrorWithStrictness(bodyBytes, fdoTestID, httpStatusCode, testcom.GetErrorCodeStrictness(h.ctx))
 
 	case testcom.ExpectGroupTests(testcom.FIDO_TEST_LIST_RVT_22, fdoTestID):
-		return testcom.ExpectAnyFdoError(bodyBytes, fdoTestID, expectedErrorCode, httpStatusCode)
+		return testcom.ExpectFdoErrorWithStrictness(bodyBytes, fdoTestID, httpStatusCode, testcom.GetErrorCodeStrictness(h.ctx))
 
 	case testcom.ExpectGroupTests(testcom.FIDO_TEST_LIST_VOUCHER, fdoTestID):
-		return testcom.ExpectAnyFdoError(bodyBytes, fdoTestID, expectedErrorCode, httpStatusCode)
+		return testcom.ExpectFdoErrorWithStrictness(bodyBytes, fdoTestID, httpStatusCode, testcom.GetErrorCodeStrictness(h.ctx))
+
+	case testcom.ExpectGroupTests(testcom.FIDO_TEST_LIST_RVT_ORDER, fdoTestID):
+		return testcom.ExpectFdoErrorWithStrictness(bodyBytes, fdoTestID, httpStatusCode, testcom.GetErrorCodeStrictness(h.ctx))
+
+	case testcom.ExpectGroupTests(testcom.FIDO_TEST_LIST_RVT_EXPIRY, fdoTestID):
+		return testcom.ExpectFdoErrorWithStrictness(bodyBytes, fdoTestID, httpStatusCode, testcom.GetErrorCodeStrictness(h.ctx))
 	}
 
 	return testcom.NewFailTestState(fdoTestID, "Unsupported test "+string(fdoTestID))
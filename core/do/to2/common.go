@@ -8,10 +8,9 @@ import (
 	"io"
 	"net/http"
 
-	"github.com/dgraph-io/badger/v4"
-
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/do/dbs"
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/storage"
 	tdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
 	listenertestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/listener"
 )
@@ -25,9 +24,8 @@ type DoTo2 struct {
 	ctx        context.Context
 }
 
-func NewDoTo2(db *badger.DB, ctx context.Context) DoTo2 {
+func NewDoTo2(db storage.Store, sessionDb *dbs.SessionDB, ctx context.Context) DoTo2 {
 	newListenerDb := tdbs.NewListenerTestDB(db)
-	sessionDb := dbs.NewSessionDB(db)
 	voucherDb := dbs.NewVoucherDB(db)
 
 	return DoTo2{
@@ -113,11 +111,11 @@ func (h *DoTo2) GetOwnerSIMs(guid fdoshared.FdoGuid) ([]fdoshared.ServiceInfoKV,
 }
 
 func (h *DoTo2) receiveAndVerify(w http.ResponseWriter, r *http.Request, currentCmd fdoshared.FdoCmd) (*dbs.SessionEntry, []byte, string, []byte, *listenertestsdeps.RequestListenerInst, error) {
-	if !fdoshared.CheckHeaders(w, r, fdoshared.TO2_64_PROVE_DEVICE) {
+	if !fdoshared.CheckHeaders(w, r, currentCmd) {
 		return nil, []byte{}, "", []byte{}, nil, fmt.Errorf("Error checking header!")
 	}
 
-	headerIsOk, sessionId, authorizationHeader := fdoshared.ExtractAuthorizationHeader(w, r, fdoshared.TO2_66_DEVICE_SERVICE_INFO_READY)
+	headerIsOk, sessionId, authorizationHeader := fdoshared.ExtractAuthorizationHeader(w, r, currentCmd)
 	if !headerIsOk {
 		return nil, []byte{}, "", []byte{}, nil, fmt.Errorf("Error getting session header!")
 	}
@@ -129,6 +127,9 @@ func (h *DoTo2) receiveAndVerify(w http.ResponseWriter, r *http.Request, current
 	}
 
 	// Conformance
+	fdoshared.SetLogSessionId(r.Context(), sessionId)
+	fdoshared.SetLogGuid(r.Context(), session.Guid)
+
 	testcomListener, _ := h.listenerDB.GetEntryByFdoGuid(session.Guid)
 
 	bodyBytes, err := io.ReadAll(r.Body)
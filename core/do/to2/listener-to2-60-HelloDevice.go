@@ -3,7 +3,6 @@ package to2
 import (
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/do/dbs"
@@ -13,7 +12,7 @@ import (
 )
 
 func (h *DoTo2) HelloDevice60(w http.ResponseWriter, r *http.Request) {
-	log.Println("HelloDevice60: Receiving...")
+	fdoshared.Logger(r.Context()).Info("Receiving HelloDevice60")
 	var currentCmd fdoshared.FdoCmd = fdoshared.TO2_60_HELLO_DEVICE
 
 	var testcomListener *listenertestsdeps.RequestListenerInst
@@ -35,29 +34,38 @@ func (h *DoTo2) HelloDevice60(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	fdoshared.SetLogGuid(r.Context(), helloDevice.Guid)
+
 	// Test stuff
 	var fdoTestId testcom.FDOTestID = testcom.NULL_TEST
 	testcomListener, _ = h.listenerDB.GetEntryByFdoGuid(helloDevice.Guid)
 
 	if testcomListener != nil && !testcomListener.To2.CheckCmdTestingIsCompleted(currentCmd) {
-		if !testcomListener.To2.CheckExpectedCmds([]fdoshared.FdoCmd{
-			currentCmd,
-			fdoshared.TO2_62_GET_OVNEXTENTRY,
-		}) && testcomListener.To2.GetLastTestID() != testcom.FIDO_LISTENER_POSITIVE {
-			testcomListener.To2.PushFail(fmt.Sprintf("Expected TO2 %d. Got %d", testcomListener.To2.ExpectedCmd, currentCmd))
-		} else if testcomListener.To2.CurrentTestIndex != 0 {
-			testcomListener.To2.PushSuccess()
-		}
-
-		if !testcomListener.To2.CheckCmdTestingIsCompleted(currentCmd) {
-			fdoTestId = testcomListener.To2.GetNextTestID()
-		}
-
-		err := h.listenerDB.Update(testcomListener)
+		savedRunner, err := h.listenerDB.UpdateRunner(testcomListener.Uuid, fdoshared.To2, func(runner *listenertestsdeps.RequestListenerRunnerInst) error {
+			// The update is re-run on a conflict, so start from the defaults
+			fdoTestId = testcom.NULL_TEST
+
+			if !runner.CheckExpectedCmds([]fdoshared.FdoCmd{
+				currentCmd,
+				fdoshared.TO2_62_GET_OVNEXTENTRY,
+			}) && runner.GetLastTestID() != testcom.FIDO_LISTENER_POSITIVE {
+				runner.PushFail(fmt.Sprintf("Expected TO2 %d. Got %d", runner.ExpectedCmd, currentCmd))
+			} else if runner.CurrentTestIndex != 0 {
+				runner.PushSuccess()
+			}
+
+			if !runner.CheckCmdTestingIsCompleted(currentCmd) {
+				fdoTestId = runner.GetNextTestID()
+				fdoshared.SetLogTestId(r.Context(), string(fdoTestId))
+			}
+
+			return nil
+		})
 		if err != nil {
 			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Conformance module failed to save result!", http.StatusBadRequest, testcomListener, fdoshared.To2)
 			return
 		}
+		testcomListener.To2 = *savedRunner
 	}
 
 	// Getting voucher from DB
@@ -166,6 +174,7 @@ func (h *DoTo2) HelloDevice60(w http.ResponseWriter, r *http.Request) {
 		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Error saving session...", http.StatusInternalServerError, testcomListener, fdoshared.To2)
 		return
 	}
+	fdoshared.SetLogSessionId(r.Context(), sessionId)
 
 	proveOVHdrPayloadBytes, _ := fdoshared.CborCust.Marshal(proveOVHdrPayload)
 	if fdoTestId == testcom.FIDO_LISTENER_DEVICE_60_BAD_HELLOACK_PAYLOAD_ENCODING {
@@ -180,7 +189,7 @@ func (h *DoTo2) HelloDevice60(w http.ResponseWriter, r *http.Request) {
 
 	helloAck, err := fdoshared.GenerateCoseSignature(proveOVHdrPayloadBytes, fdoshared.ProtectedHeader{}, proveOVHdrUnprotectedHeader, privateKeyInst, signatureSgType)
 	if err != nil {
-		log.Println("HelloDevice60: Error generating cose signature..." + err.Error())
+		fdoshared.Logger(r.Context()).Error("HelloDevice60: Error generating cose signature", "error", err.Error())
 		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Error generating cose signature.", http.StatusInternalServerError, testcomListener, fdoshared.To2)
 		return
 	}
@@ -202,13 +211,20 @@ func (h *DoTo2) HelloDevice60(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if fdoTestId == testcom.FIDO_LISTENER_POSITIVE && testcomListener.To2.CheckExpectedCmd(currentCmd) {
-		testcomListener.To2.PushSuccess()
-		testcomListener.To2.CompleteCmdAndSetNext(fdoshared.TO2_62_GET_OVNEXTENTRY)
-		err := h.listenerDB.Update(testcomListener)
+		savedRunner, err := h.listenerDB.UpdateRunner(testcomListener.Uuid, fdoshared.To2, func(runner *listenertestsdeps.RequestListenerRunnerInst) error {
+			if !runner.CheckExpectedCmd(currentCmd) {
+				return nil
+			}
+
+			runner.PushSuccess()
+			runner.CompleteCmdAndSetNext(fdoshared.TO2_62_GET_OVNEXTENTRY)
+			return nil
+		})
 		if err != nil {
 			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Conformance module failed to save result!", http.StatusBadRequest, testcomListener, fdoshared.To2)
 			return
 		}
+		testcomListener.To2 = *savedRunner
 	}
 
 	w.Header().Set("Authorization", sessionIdToken)
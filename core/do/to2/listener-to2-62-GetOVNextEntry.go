@@ -1,7 +1,6 @@
 package to2
 
 import (
-	"log"
 	"net/http"
 
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
@@ -10,7 +9,7 @@ import (
 )
 
 func (h *DoTo2) GetOVNextEntry62(w http.ResponseWriter, r *http.Request) {
-	log.Println("GetOVNextEntry62: Receiving...")
+	fdoshared.Logger(r.Context()).Info("Receiving GetOVNextEntry62")
 	var currentCmd fdoshared.FdoCmd = fdoshared.TO2_62_GET_OVNEXTENTRY
 	var fdoTestId testcom.FDOTestID = testcom.NULL_TEST
 
@@ -27,22 +26,30 @@ func (h *DoTo2) GetOVNextEntry62(w http.ResponseWriter, r *http.Request) {
 	if testcomListener != nil && !testcomListener.To2.CheckCmdTestingIsCompleted(currentCmd) && testcomListener.To2.GetLastTestID() != "" {
 		var isLastTestFailed bool
 
-		if !testcomListener.To2.CheckExpectedCmd(currentCmd) && testcomListener.To2.GetLastTestID() != testcom.FIDO_LISTENER_POSITIVE {
-			testcomListener.To2.PushFail("Expected the device to fail, but it didn't")
-			isLastTestFailed = true
-		} else if testcomListener.To2.CurrentTestIndex != 0 {
-			testcomListener.To2.PushSuccess()
-		}
-
-		if !testcomListener.To2.CheckCmdTestingIsCompleted(currentCmd) {
-			fdoTestId = testcomListener.To2.GetNextTestID()
-		}
-
-		err := h.listenerDB.Update(testcomListener)
+		savedRunner, err := h.listenerDB.UpdateRunner(testcomListener.Uuid, fdoshared.To2, func(runner *listenertestsdeps.RequestListenerRunnerInst) error {
+			// The update is re-run on a conflict, so start from the defaults
+			fdoTestId = testcom.NULL_TEST
+			isLastTestFailed = false
+
+			if !runner.CheckExpectedCmd(currentCmd) && runner.GetLastTestID() != testcom.FIDO_LISTENER_POSITIVE {
+				runner.PushFail("Expected the device to fail, but it didn't")
+				isLastTestFailed = true
+			} else if runner.CurrentTestIndex != 0 {
+				runner.PushSuccess()
+			}
+
+			if !runner.CheckCmdTestingIsCompleted(currentCmd) {
+				fdoTestId = runner.GetNextTestID()
+				fdoshared.SetLogTestId(r.Context(), string(fdoTestId))
+			}
+
+			return nil
+		})
 		if err != nil {
 			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Conformance module failed to save result! "+err.Error(), http.StatusBadRequest, testcomListener, fdoshared.To2)
 			return
 		}
+		testcomListener.To2 = *savedRunner
 
 		if isLastTestFailed {
 			return
@@ -98,13 +105,20 @@ func (h *DoTo2) GetOVNextEntry62(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if fdoTestId == testcom.FIDO_LISTENER_POSITIVE && testcomListener.To2.CheckExpectedCmd(currentCmd) {
-		testcomListener.To2.PushSuccess()
-		testcomListener.To2.CompleteCmdAndSetNext(fdoshared.TO2_64_PROVE_DEVICE)
-		err := h.listenerDB.Update(testcomListener)
+		savedRunner, err := h.listenerDB.UpdateRunner(testcomListener.Uuid, fdoshared.To2, func(runner *listenertestsdeps.RequestListenerRunnerInst) error {
+			if !runner.CheckExpectedCmd(currentCmd) {
+				return nil
+			}
+
+			runner.PushSuccess()
+			runner.CompleteCmdAndSetNext(fdoshared.TO2_64_PROVE_DEVICE)
+			return nil
+		})
 		if err != nil {
 			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Conformance module failed to save result!", http.StatusBadRequest, testcomListener, fdoshared.To2)
 			return
 		}
+		testcomListener.To2 = *savedRunner
 	}
 
 	w.Header().Set("Authorization", authorizationHeader)
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"encoding/hex"
 	"fmt"
-	"log"
 	"net/http"
 
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
@@ -13,7 +12,7 @@ import (
 )
 
 func (h *DoTo2) ProveDevice64(w http.ResponseWriter, r *http.Request) {
-	log.Println("ProveDevice64: Receiving...")
+	fdoshared.Logger(r.Context()).Info("Receiving ProveDevice64")
 	var currentCmd fdoshared.FdoCmd = fdoshared.TO2_64_PROVE_DEVICE
 	var fdoTestId testcom.FDOTestID = testcom.NULL_TEST
 
@@ -33,22 +32,30 @@ func (h *DoTo2) ProveDevice64(w http.ResponseWriter, r *http.Request) {
 	if testcomListener != nil && !testcomListener.To2.CheckCmdTestingIsCompleted(currentCmd) && testcomListener.To2.GetLastTestID() != "" {
 		var isLastTestFailed bool
 
-		if !testcomListener.To2.CheckExpectedCmd(currentCmd) && testcomListener.To2.GetLastTestID() != testcom.FIDO_LISTENER_POSITIVE {
-			testcomListener.To2.PushFail("Expected the device to fail, but it didn't")
-			isLastTestFailed = true
-		} else if testcomListener.To2.CurrentTestIndex != 0 {
-			testcomListener.To2.PushSuccess()
-		}
-
-		if !testcomListener.To2.CheckCmdTestingIsCompleted(currentCmd) {
-			fdoTestId = testcomListener.To2.GetNextTestID()
-		}
-
-		err := h.listenerDB.Update(testcomListener)
+		savedRunner, err := h.listenerDB.UpdateRunner(testcomListener.Uuid, fdoshared.To2, func(runner *listenertestsdeps.RequestListenerRunnerInst) error {
+			// The update is re-run on a conflict, so start from the defaults
+			fdoTestId = testcom.NULL_TEST
+			isLastTestFailed = false
+
+			if !runner.CheckExpectedCmd(currentCmd) && runner.GetLastTestID() != testcom.FIDO_LISTENER_POSITIVE {
+				runner.PushFail("Expected the device to fail, but it didn't")
+				isLastTestFailed = true
+			} else if runner.CurrentTestIndex != 0 {
+				runner.PushSuccess()
+			}
+
+			if !runner.CheckCmdTestingIsCompleted(currentCmd) {
+				fdoTestId = runner.GetNextTestID()
+				fdoshared.SetLogTestId(r.Context(), string(fdoTestId))
+			}
+
+			return nil
+		})
 		if err != nil {
 			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Conformance module failed to save result! "+err.Error(), http.StatusBadRequest, testcomListener, fdoshared.To2)
 			return
 		}
+		testcomListener.To2 = *savedRunner
 
 		if isLastTestFailed {
 			return
@@ -70,7 +77,7 @@ func (h *DoTo2) ProveDevice64(w http.ResponseWriter, r *http.Request) {
 
 	pkType, ok := fdoshared.SgTypeToFdoPkType[session.EASigInfo.SgType]
 	if !ok {
-		log.Println("ProveToRV32: Unknown signature type. ")
+		fdoshared.Logger(r.Context()).Warn("ProveToRV32: Unknown signature type")
 		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INVALID_MESSAGE_ERROR, currentCmd, "Error to verify signature ProveDevice64", http.StatusBadRequest, testcomListener, fdoshared.To1)
 		return
 	}
@@ -95,6 +102,13 @@ func (h *DoTo2) ProveDevice64(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The proof must be for the device of the session
+	expectedUEID := fdoshared.GenerateEatGuid(session.Guid)
+	if !bytes.Equal(eatPayload.EatUEID[:], expectedUEID[:]) {
+		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INVALID_MESSAGE_ERROR, currentCmd, fmt.Sprintf("EatUEID does not match the session GUID. Expected %s. Got %s", hex.EncodeToString(expectedUEID[:]), hex.EncodeToString(eatPayload.EatUEID[:])), http.StatusBadRequest, testcomListener, fdoshared.To2)
+		return
+	}
+
 	// KEX
 	sessionKey, err := fdoshared.DeriveSessionKey(session.XAKex, eatPayload.EatFDO.XBKeyExchange, false, privateKeyInst)
 	if err != nil {
@@ -172,13 +186,20 @@ func (h *DoTo2) ProveDevice64(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if fdoTestId == testcom.FIDO_LISTENER_POSITIVE && testcomListener.To2.CheckExpectedCmd(currentCmd) {
-		testcomListener.To2.PushSuccess()
-		testcomListener.To2.CompleteCmdAndSetNext(fdoshared.TO2_66_DEVICE_SERVICE_INFO_READY)
-		err := h.listenerDB.Update(testcomListener)
+		savedRunner, err := h.listenerDB.UpdateRunner(testcomListener.Uuid, fdoshared.To2, func(runner *listenertestsdeps.RequestListenerRunnerInst) error {
+			if !runner.CheckExpectedCmd(currentCmd) {
+				return nil
+			}
+
+			runner.PushSuccess()
+			runner.CompleteCmdAndSetNext(fdoshared.TO2_66_DEVICE_SERVICE_INFO_READY)
+			return nil
+		})
 		if err != nil {
 			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Conformance module failed to save result!", http.StatusBadRequest, testcomListener, fdoshared.To2)
 			return
 		}
+		testcomListener.To2 = *savedRunner
 	}
 
 	w.Header().Set("Authorization", authorizationHeader)
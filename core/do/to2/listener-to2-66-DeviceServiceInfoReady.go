@@ -2,7 +2,6 @@ package to2
 
 import (
 	"fmt"
-	"log"
 	"net/http"
 
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
@@ -13,7 +12,7 @@ import (
 const MAX_DEVICE_SERVICE_INFO_SIZE uint16 = 1300
 
 func (h *DoTo2) DeviceServiceInfoReady66(w http.ResponseWriter, r *http.Request) {
-	log.Println("DeviceServiceInfoReady66: Receiving...")
+	fdoshared.Logger(r.Context()).Info("Receiving DeviceServiceInfoReady66")
 
 	var currentCmd fdoshared.FdoCmd = fdoshared.TO2_66_DEVICE_SERVICE_INFO_READY
 	var fdoTestId testcom.FDOTestID = testcom.NULL_TEST
@@ -26,22 +25,30 @@ func (h *DoTo2) DeviceServiceInfoReady66(w http.ResponseWriter, r *http.Request)
 	if testcomListener != nil && !testcomListener.To2.CheckCmdTestingIsCompleted(currentCmd) && testcomListener.To2.GetLastTestID() != "" {
 		var isLastTestFailed bool
 
-		if !testcomListener.To2.CheckExpectedCmd(currentCmd) && testcomListener.To2.GetLastTestID() != testcom.FIDO_LISTENER_POSITIVE {
-			testcomListener.To2.PushFail("Expected the device to fail, but it didn't")
-			isLastTestFailed = true
-		} else if testcomListener.To2.CurrentTestIndex != 0 {
-			testcomListener.To2.PushSuccess()
-		}
-
-		if !testcomListener.To2.CheckCmdTestingIsCompleted(currentCmd) {
-			fdoTestId = testcomListener.To2.GetNextTestID()
-		}
-
-		err := h.listenerDB.Update(testcomListener)
+		savedRunner, err := h.listenerDB.UpdateRunner(testcomListener.Uuid, fdoshared.To2, func(runner *listenertestsdeps.RequestListenerRunnerInst) error {
+			// The update is re-run on a conflict, so start from the defaults
+			fdoTestId = testcom.NULL_TEST
+			isLastTestFailed = false
+
+			if !runner.CheckExpectedCmd(currentCmd) && runner.GetLastTestID() != testcom.FIDO_LISTENER_POSITIVE {
+				runner.PushFail("Expected the device to fail, but it didn't")
+				isLastTestFailed = true
+			} else if runner.CurrentTestIndex != 0 {
+				runner.PushSuccess()
+			}
+
+			if !runner.CheckCmdTestingIsCompleted(currentCmd) {
+				fdoTestId = runner.GetNextTestID()
+				fdoshared.SetLogTestId(r.Context(), string(fdoTestId))
+			}
+
+			return nil
+		})
 		if err != nil {
 			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Conformance module failed to save result! "+err.Error(), http.StatusBadRequest, testcomListener, fdoshared.To2)
 			return
 		}
+		testcomListener.To2 = *savedRunner
 
 		if isLastTestFailed {
 			return
@@ -108,13 +115,20 @@ func (h *DoTo2) DeviceServiceInfoReady66(w http.ResponseWriter, r *http.Request)
 	}
 
 	if fdoTestId == testcom.FIDO_LISTENER_POSITIVE && testcomListener.To2.CheckExpectedCmd(currentCmd) {
-		testcomListener.To2.PushSuccess()
-		testcomListener.To2.CompleteCmdAndSetNext(fdoshared.TO2_68_DEVICE_SERVICE_INFO)
-		err := h.listenerDB.Update(testcomListener)
+		savedRunner, err := h.listenerDB.UpdateRunner(testcomListener.Uuid, fdoshared.To2, func(runner *listenertestsdeps.RequestListenerRunnerInst) error {
+			if !runner.CheckExpectedCmd(currentCmd) {
+				return nil
+			}
+
+			runner.PushSuccess()
+			runner.CompleteCmdAndSetNext(fdoshared.TO2_68_DEVICE_SERVICE_INFO)
+			return nil
+		})
 		if err != nil {
 			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Conformance module failed to save result!", http.StatusBadRequest, testcomListener, fdoshared.To2)
 			return
 		}
+		testcomListener.To2 = *savedRunner
 	}
 
 	w.Header().Set("Authorization", authorizationHeader)
@@ -2,7 +2,6 @@ package to2
 
 import (
 	"fmt"
-	"log"
 	"net/http"
 
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
@@ -13,7 +12,7 @@ import (
 const MTU_BYTES = 1500
 
 func (h *DoTo2) DeviceServiceInfo68(w http.ResponseWriter, r *http.Request) {
-	log.Println("DeviceServiceInfo68: Receiving...")
+	fdoshared.Logger(r.Context()).Info("Receiving DeviceServiceInfo68")
 
 	var currentCmd fdoshared.FdoCmd = fdoshared.TO2_68_DEVICE_SERVICE_INFO
 	var fdoTestId testcom.FDOTestID = testcom.NULL_TEST
@@ -26,25 +25,33 @@ func (h *DoTo2) DeviceServiceInfo68(w http.ResponseWriter, r *http.Request) {
 	if testcomListener != nil && !testcomListener.To2.CheckCmdTestingIsCompleted(currentCmd) && testcomListener.To2.GetLastTestID() != "" {
 		var isLastTestFailed bool
 
-		if !testcomListener.To2.CheckExpectedCmd(currentCmd) && testcomListener.To2.GetLastTestID() != testcom.FIDO_LISTENER_POSITIVE {
-			testcomListener.To2.PushFail("Expected the device to fail, but it didn't")
-			isLastTestFailed = true
-		} else if testcomListener.To2.CheckExpectedCmd(currentCmd) && testcomListener.To2.GetLastTestID() != testcom.FIDO_LISTENER_POSITIVE && session.PrevCMD == fdoshared.TO2_69_OWNER_SERVICE_INFO {
-			testcomListener.To2.PushFail("Expected the device to fail, but it didn't")
-			isLastTestFailed = true
-		} else if testcomListener.To2.CurrentTestIndex != 0 {
-			testcomListener.To2.PushSuccess()
-		}
+		savedRunner, err := h.listenerDB.UpdateRunner(testcomListener.Uuid, fdoshared.To2, func(runner *listenertestsdeps.RequestListenerRunnerInst) error {
+			// The update is re-run on a conflict, so start from the defaults
+			fdoTestId = testcom.NULL_TEST
+			isLastTestFailed = false
+
+			if !runner.CheckExpectedCmd(currentCmd) && runner.GetLastTestID() != testcom.FIDO_LISTENER_POSITIVE {
+				runner.PushFail("Expected the device to fail, but it didn't")
+				isLastTestFailed = true
+			} else if runner.CheckExpectedCmd(currentCmd) && runner.GetLastTestID() != testcom.FIDO_LISTENER_POSITIVE && session.PrevCMD == fdoshared.TO2_69_OWNER_SERVICE_INFO {
+				runner.PushFail("Expected the device to fail, but it didn't")
+				isLastTestFailed = true
+			} else if runner.CurrentTestIndex != 0 {
+				runner.PushSuccess()
+			}
 
-		if !testcomListener.To2.CheckCmdTestingIsCompleted(currentCmd) {
-			fdoTestId = testcomListener.To2.GetNextTestID()
-		}
+			if !runner.CheckCmdTestingIsCompleted(currentCmd) {
+				fdoTestId = runner.GetNextTestID()
+				fdoshared.SetLogTestId(r.Context(), string(fdoTestId))
+			}
 
-		err := h.listenerDB.Update(testcomListener)
+			return nil
+		})
 		if err != nil {
 			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Conformance module failed to save result! "+err.Error(), http.StatusBadRequest, testcomListener, fdoshared.To2)
 			return
 		}
+		testcomListener.To2 = *savedRunner
 
 		if isLastTestFailed {
 			return
@@ -87,12 +94,12 @@ func (h *DoTo2) DeviceServiceInfo68(w http.ResponseWriter, r *http.Request) {
 		if session.OwnerSIMsSendCounter == 0 {
 			resultSims, err := ValidateDeviceSIMs(session.Guid, session.DeviceSIMs)
 			if err != nil {
-				log.Println("DeviceServiceInfo68: Error validating device sims: " + err.Error())
+				fdoshared.Logger(r.Context()).Error("DeviceServiceInfo68: Error validating device sims", "error", err.Error())
 				fdoshared.RespondFDOError(w, r, fdoshared.MESSAGE_BODY_ERROR, currentCmd, "DeviceServiceInfo68: Error validating device sims: "+err.Error(), http.StatusInternalServerError)
 				return
 			}
 
-			log.Println("DeviceServiceInfo68: Validated device sims: ", *resultSims.SIM_DEVMOD_ARCH, *resultSims.SIM_DEVMOD_DEVICE, *resultSims.SIM_DEVMOD_OS)
+			fdoshared.Logger(r.Context()).Info("DeviceServiceInfo68: Validated device sims", "arch", *resultSims.SIM_DEVMOD_ARCH, "device", *resultSims.SIM_DEVMOD_DEVICE, "os", *resultSims.SIM_DEVMOD_OS)
 		}
 
 		if int(session.OwnerSIMsSendCounter+1) >= len(session.OwnerSIMs) {
@@ -124,7 +131,7 @@ func (h *DoTo2) DeviceServiceInfo68(w http.ResponseWriter, r *http.Request) {
 	// ----- MAIN BODY ENDS ----- //
 	ownerServiceInfoEncBytes, err := fdoshared.AddEncryptionWrapping(ownerServiceInfoBytes, session.SessionKey, session.CipherSuiteName)
 	if err != nil {
-		log.Println("DeviceServiceInfo68: Error encrypting..." + err.Error())
+		fdoshared.Logger(r.Context()).Error("DeviceServiceInfo68: Error encrypting", "error", err.Error())
 		fdoshared.RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Internal server error!", http.StatusInternalServerError)
 		return
 	}
@@ -132,7 +139,7 @@ func (h *DoTo2) DeviceServiceInfo68(w http.ResponseWriter, r *http.Request) {
 	if fdoTestId == testcom.FIDO_LISTENER_DEVICE_68_BAD_ENC_WRAPPING {
 		ownerServiceInfoEncBytes, err = fdoshared.Conf_Fuzz_AddWrapping(ownerServiceInfoEncBytes, session.SessionKey, session.CipherSuiteName)
 		if err != nil {
-			log.Println("DeviceServiceInfo68: Error fuzzing encryption..." + err.Error())
+			fdoshared.Logger(r.Context()).Error("DeviceServiceInfo68: Error fuzzing encryption", "error", err.Error())
 			fdoshared.RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Internal server error!", http.StatusInternalServerError)
 			return
 		}
@@ -142,19 +149,26 @@ func (h *DoTo2) DeviceServiceInfo68(w http.ResponseWriter, r *http.Request) {
 
 	err = h.session.UpdateSessionEntry(sessionId, *session)
 	if err != nil {
-		log.Println("DeviceServiceInfo68: Error saving session..." + err.Error())
+		fdoshared.Logger(r.Context()).Error("DeviceServiceInfo68: Error saving session", "error", err.Error())
 		fdoshared.RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Internal server error!", http.StatusInternalServerError)
 		return
 	}
 
 	if fdoTestId == testcom.FIDO_LISTENER_POSITIVE && testcomListener.To2.CheckExpectedCmd(currentCmd) {
-		testcomListener.To2.PushSuccess()
-		testcomListener.To2.CompleteCmdAndSetNext(fdoshared.TO2_70_DONE)
-		err := h.listenerDB.Update(testcomListener)
+		savedRunner, err := h.listenerDB.UpdateRunner(testcomListener.Uuid, fdoshared.To2, func(runner *listenertestsdeps.RequestListenerRunnerInst) error {
+			if !runner.CheckExpectedCmd(currentCmd) {
+				return nil
+			}
+
+			runner.PushSuccess()
+			runner.CompleteCmdAndSetNext(fdoshared.TO2_70_DONE)
+			return nil
+		})
 		if err != nil {
 			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Conformance module failed to save result!", http.StatusBadRequest, testcomListener, fdoshared.To2)
 			return
 		}
+		testcomListener.To2 = *savedRunner
 	}
 
 	w.Header().Set("Authorization", authorizationHeader)
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"encoding/hex"
 	"fmt"
-	"log"
 	"net/http"
 
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
@@ -13,12 +12,12 @@ import (
 )
 
 func (h *DoTo2) Done70(w http.ResponseWriter, r *http.Request) {
-	log.Println("Done70: Receiving...")
+	fdoshared.Logger(r.Context()).Info("Receiving Done70")
 
 	var currentCmd fdoshared.FdoCmd = fdoshared.TO2_70_DONE
 	var fdoTestId testcom.FDOTestID = testcom.NULL_TEST
 
-	session, _, authorizationHeader, bodyBytes, testcomListener, err := h.receiveAndDecrypt(w, r, currentCmd)
+	session, sessionId, authorizationHeader, bodyBytes, testcomListener, err := h.receiveAndDecrypt(w, r, currentCmd)
 	if err != nil {
 		return
 	}
@@ -27,22 +26,30 @@ func (h *DoTo2) Done70(w http.ResponseWriter, r *http.Request) {
 	if testcomListener != nil && !testcomListener.To2.CheckCmdTestingIsCompleted(currentCmd) && testcomListener.To2.GetLastTestID() != "" {
 		var isLastTestFailed bool
 
-		if !testcomListener.To2.CheckExpectedCmd(currentCmd) && testcomListener.To2.GetLastTestID() != testcom.FIDO_LISTENER_POSITIVE {
-			testcomListener.To2.PushFail("Expected the device to fail, but it didn't")
-			isLastTestFailed = true
-		} else if testcomListener.To2.CurrentTestIndex != 0 {
-			testcomListener.To2.PushSuccess()
-		}
-
-		if !testcomListener.To2.CheckCmdTestingIsCompleted(currentCmd) {
-			fdoTestId = testcomListener.To2.GetNextTestID()
-		}
-
-		err := h.listenerDB.Update(testcomListener)
+		savedRunner, err := h.listenerDB.UpdateRunner(testcomListener.Uuid, fdoshared.To2, func(runner *listenertestsdeps.RequestListenerRunnerInst) error {
+			// The update is re-run on a conflict, so start from the defaults
+			fdoTestId = testcom.NULL_TEST
+			isLastTestFailed = false
+
+			if !runner.CheckExpectedCmd(currentCmd) && runner.GetLastTestID() != testcom.FIDO_LISTENER_POSITIVE {
+				runner.PushFail("Expected the device to fail, but it didn't")
+				isLastTestFailed = true
+			} else if runner.CurrentTestIndex != 0 {
+				runner.PushSuccess()
+			}
+
+			if !runner.CheckCmdTestingIsCompleted(currentCmd) {
+				fdoTestId = runner.GetNextTestID()
+				fdoshared.SetLogTestId(r.Context(), string(fdoTestId))
+			}
+
+			return nil
+		})
 		if err != nil {
 			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Conformance module failed to save result! "+err.Error(), http.StatusBadRequest, testcomListener, fdoshared.To2)
 			return
 		}
+		testcomListener.To2 = *savedRunner
 
 		if isLastTestFailed {
 			return
@@ -61,7 +68,7 @@ func (h *DoTo2) Done70(w http.ResponseWriter, r *http.Request) {
 			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Failed to decode Done70. "+err.Error(), http.StatusInternalServerError, testcomListener, fdoshared.To2)
 		}
 
-		log.Println("Done70: Error decoding request..." + err.Error())
+		fdoshared.Logger(r.Context()).Error("Done70: Error decoding request", "error", err.Error())
 		fdoshared.RespondFDOError(w, r, fdoshared.MESSAGE_BODY_ERROR, currentCmd, "Failed to decode body!", http.StatusBadRequest)
 		return
 	}
@@ -99,28 +106,41 @@ func (h *DoTo2) Done70(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if fdoTestId == testcom.FIDO_LISTENER_POSITIVE {
-		testcomListener.To2.PushSuccess()
-		testcomListener.To2.CompleteTestRun()
-		err := h.listenerDB.Update(testcomListener)
+		savedRunner, err := h.listenerDB.UpdateRunner(testcomListener.Uuid, fdoshared.To2, func(runner *listenertestsdeps.RequestListenerRunnerInst) error {
+			if !runner.Running {
+				return nil
+			}
+
+			runner.PushSuccess()
+			runner.CompleteTestRun()
+			return nil
+		})
 		if err != nil {
 			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Conformance module failed to save result!", http.StatusBadRequest, testcomListener, fdoshared.To1)
 			return
 		}
+		testcomListener.To2 = *savedRunner
 	}
 
 	iopEnabled := h.ctx.Value(fdoshared.CFG_ENV_INTEROP_ENABLED).(bool)
 	if fdoTestId == testcom.NULL_TEST && iopEnabled {
 		authzHeader, err := fdoshared.IopGetAuthz(h.ctx, fdoshared.IopDO)
 		if err != nil {
-			log.Println("IOT: Error getting authz header: " + err.Error())
+			fdoshared.Logger(r.Context()).Error("IOT: Error getting authz header", "error", err.Error())
 		}
 
 		err = fdoshared.SubmitIopLoggerEvent(h.ctx, session.Guid, fdoshared.To2, session.NonceTO2SetupDv64, authzHeader)
 		if err != nil {
-			log.Println("IOT: Error sending iop log event: " + err.Error())
+			fdoshared.Logger(r.Context()).Error("IOT: Error sending iop log event", "error", err.Error())
 		}
 	} else if !iopEnabled {
-		log.Println("Interop is not enabled, skipping IOP logger event submission")
+		fdoshared.Logger(r.Context()).Debug("Interop is not enabled, skipping IOP logger event submission")
+	}
+
+	// TO2 is complete, so no message can follow in this session
+	err = h.session.DeleteSessionEntry(sessionId)
+	if err != nil {
+		fdoshared.Logger(r.Context()).Error("Done70: Error ending session", "error", err.Error())
 	}
 
 	w.Header().Set("Authorization", authorizationHeader)
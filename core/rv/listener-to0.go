@@ -4,12 +4,10 @@ import (
 	"bytes"
 	"context"
 	"io"
-	"log"
 	"net/http"
 
-	"github.com/dgraph-io/badger/v4"
-
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/storage"
 	tdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
 )
 
@@ -22,12 +20,10 @@ type RvTo0 struct {
 	ctx         context.Context
 }
 
-func NewRvTo0(db *badger.DB, ctx context.Context) RvTo0 {
+func NewRvTo0(db storage.Store, sessionDb *SessionDB, ctx context.Context) RvTo0 {
 	newListenerDb := tdbs.NewListenerTestDB(db)
 	return RvTo0{
-		session: &SessionDB{
-			db: db,
-		},
+		session: sessionDb,
 		ownersignDB: &OwnerSignDB{
 			db: db,
 		},
@@ -37,7 +33,7 @@ func NewRvTo0(db *badger.DB, ctx context.Context) RvTo0 {
 }
 
 func (h *RvTo0) Handle20Hello(w http.ResponseWriter, r *http.Request) {
-	log.Println("Receiving Hello20...")
+	fdoshared.Logger(r.Context()).Info("Receiving Hello20")
 	if !fdoshared.CheckHeaders(w, r, fdoshared.TO0_20_HELLO) {
 		return
 	}
@@ -52,7 +48,7 @@ func (h *RvTo0) Handle20Hello(w http.ResponseWriter, r *http.Request) {
 
 	err = fdoshared.CborCust.Unmarshal(bodyBytes, &helloMsg)
 	if err != nil {
-		log.Println("Error decoding Hello20. " + err.Error())
+		fdoshared.Logger(r.Context()).Error("Error decoding Hello20", "error", err.Error())
 		fdoshared.RespondFDOError(w, r, fdoshared.MESSAGE_BODY_ERROR, fdoshared.TO0_20_HELLO, "Failed to decode body!", http.StatusBadRequest)
 		return
 	}
@@ -69,6 +65,7 @@ func (h *RvTo0) Handle20Hello(w http.ResponseWriter, r *http.Request) {
 		fdoshared.RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, fdoshared.TO0_20_HELLO, "Internal Server Error!", http.StatusInternalServerError)
 		return
 	}
+	fdoshared.SetLogSessionId(r.Context(), sessionId)
 
 	helloAck := fdoshared.HelloAck21{
 		NonceTO0Sign: nonceTO0Sign,
@@ -85,7 +82,7 @@ func (h *RvTo0) Handle20Hello(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *RvTo0) Handle22OwnerSign(w http.ResponseWriter, r *http.Request) {
-	log.Println("Receiving OwnerSign22...")
+	fdoshared.Logger(r.Context()).Info("Receiving OwnerSign22")
 	if !fdoshared.CheckHeaders(w, r, fdoshared.TO0_22_OWNER_SIGN) {
 		return
 	}
@@ -94,6 +91,7 @@ func (h *RvTo0) Handle22OwnerSign(w http.ResponseWriter, r *http.Request) {
 	if !headerIsOk {
 		return
 	}
+	fdoshared.SetLogSessionId(r.Context(), sessionId)
 
 	session, err := h.session.GetSessionEntry(sessionId)
 	if err != nil {
@@ -137,7 +135,7 @@ func (h *RvTo0) Handle22OwnerSign(w http.ResponseWriter, r *http.Request) {
 	// Verify all the RVTO2AddrEntry
 	for _, rvEntry := range to1dPayload.To1dRV {
 		if rvEntry.RVDNS == nil && rvEntry.RVIP == nil {
-			log.Println("OwnerSign22: Invalid RVTO2AddrEntry, both RVDNS and RVIP are nil!")
+			fdoshared.Logger(r.Context()).Warn("OwnerSign22: Invalid RVTO2AddrEntry, both RVDNS and RVIP are nil!")
 			fdoshared.RespondFDOError(w, r, fdoshared.INVALID_MESSAGE_ERROR, fdoshared.TO0_22_OWNER_SIGN, "Failed to validate owner sign!", http.StatusBadRequest)
 			return
 		}
@@ -146,36 +144,37 @@ func (h *RvTo0) Handle22OwnerSign(w http.ResponseWriter, r *http.Request) {
 	/* ----- Verify OwnerSign ----- */
 
 	if !bytes.Equal(to0d.NonceTO0Sign[:], session.NonceTO0Sign[:]) {
-		log.Println("OwnerSign22: NonceTO0Sign does not match!")
+		fdoshared.Logger(r.Context()).Warn("OwnerSign22: NonceTO0Sign does not match!")
 		fdoshared.RespondFDOError(w, r, fdoshared.INVALID_MESSAGE_ERROR, fdoshared.TO0_22_OWNER_SIGN, "Failed to validate owner sign!", http.StatusBadRequest)
 		return
 	}
 
 	err = to0d.OwnershipVoucher.Validate()
 	if err != nil {
-		log.Println("OwnerSign22: Error verifying voucher. " + err.Error())
+		fdoshared.Logger(r.Context()).Error("OwnerSign22: Error verifying voucher", "error", err.Error())
 		fdoshared.RespondFDOError(w, r, fdoshared.MESSAGE_BODY_ERROR, fdoshared.TO0_22_OWNER_SIGN, "Failed to validate voucher!", http.StatusBadRequest)
 		return
 	}
 
 	ovHeader, err := to0d.OwnershipVoucher.GetOVHeader()
 	if err != nil {
-		log.Println("OwnerSign22: Error decoding header. " + err.Error())
+		fdoshared.Logger(r.Context()).Error("OwnerSign22: Error decoding header", "error", err.Error())
 		fdoshared.RespondFDOError(w, r, fdoshared.INVALID_MESSAGE_ERROR, fdoshared.TO0_22_OWNER_SIGN, "Failed to validate owner sign!", http.StatusBadRequest)
 		return
 	}
+	fdoshared.SetLogGuid(r.Context(), ovHeader.OVGuid)
 
 	// Verify To1D
 	finalPublicKey, err := to0d.OwnershipVoucher.GetFinalOwnerPublicKey()
 	if err != nil {
-		log.Println("OwnerSign22: Error decoding final owner public key. " + err.Error())
+		fdoshared.Logger(r.Context()).Error("OwnerSign22: Error decoding final owner public key", "error", err.Error())
 		fdoshared.RespondFDOError(w, r, fdoshared.INVALID_MESSAGE_ERROR, fdoshared.TO0_22_OWNER_SIGN, "Failed to validate owner sign!", http.StatusBadRequest)
 		return
 	}
 
 	err = fdoshared.VerifyCoseSignature(ownerSign.To1d, finalPublicKey)
 	if err != nil {
-		log.Println("OwnerSign22: Error verifying to1d. " + err.Error())
+		fdoshared.Logger(r.Context()).Error("OwnerSign22: Error verifying to1d", "error", err.Error())
 		fdoshared.RespondFDOError(w, r, fdoshared.INVALID_MESSAGE_ERROR, fdoshared.TO0_22_OWNER_SIGN, "Failed to validate owner sign 4!", http.StatusBadRequest)
 		return
 	}
@@ -183,7 +182,7 @@ func (h *RvTo0) Handle22OwnerSign(w http.ResponseWriter, r *http.Request) {
 	// Verify To0D Hash
 	err = fdoshared.VerifyHash(ownerSign.To0d, to1dPayload.To1dTo0dHash)
 	if err != nil {
-		log.Println("OwnerSign22: Error verifying to0dHash. " + err.Error())
+		fdoshared.Logger(r.Context()).Error("OwnerSign22: Error verifying to0dHash", "error", err.Error())
 		fdoshared.RespondFDOError(w, r, fdoshared.INVALID_MESSAGE_ERROR, fdoshared.TO0_22_OWNER_SIGN, "Failed to validate owner sign 6!", http.StatusBadRequest)
 		return
 	}
@@ -210,15 +209,15 @@ func (h *RvTo0) Handle22OwnerSign(w http.ResponseWriter, r *http.Request) {
 	if iopEnabled {
 		authzHeader, err := fdoshared.IopGetAuthz(h.ctx, fdoshared.IopRV)
 		if err != nil {
-			log.Println("IOT: Error getting authz header: " + err.Error())
+			fdoshared.Logger(r.Context()).Error("IOT: Error getting authz header", "error", err.Error())
 		}
 
 		err = fdoshared.SubmitIopLoggerEvent(h.ctx, ovHeader.OVGuid, fdoshared.To0, session.NonceTO0Sign, authzHeader)
 		if err != nil {
-			log.Println("IOT: Error sending iop log event: " + err.Error())
+			fdoshared.Logger(r.Context()).Error("IOT: Error sending iop log event", "error", err.Error())
 		}
 	} else if !iopEnabled {
-		log.Println("Interop is not enabled, skipping IOP logger event submission")
+		fdoshared.Logger(r.Context()).Debug("Interop is not enabled, skipping IOP logger event submission")
 	}
 
 	w.Header().Set("Authorization", authorizationHeader)
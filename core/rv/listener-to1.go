@@ -6,12 +6,10 @@ import (
 	"encoding/hex"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 
-	"github.com/dgraph-io/badger/v4"
-
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/storage"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
 	tdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
 	listenertestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/listener"
@@ -24,12 +22,10 @@ type RvTo1 struct {
 	ctx         context.Context
 }
 
-func NewRvTo1(db *badger.DB, ctx context.Context) RvTo1 {
+func NewRvTo1(db storage.Store, sessionDb *SessionDB, ctx context.Context) RvTo1 {
 	newListenerDb := tdbs.NewListenerTestDB(db)
 	return RvTo1{
-		session: &SessionDB{
-			db: db,
-		},
+		session: sessionDb,
 		ownersignDB: &OwnerSignDB{
 			db: db,
 		},
@@ -39,7 +35,7 @@ func NewRvTo1(db *badger.DB, ctx context.Context) RvTo1 {
 }
 
 func (h *RvTo1) Handle30HelloRV(w http.ResponseWriter, r *http.Request) {
-	log.Println("Receiving HelloRV30...")
+	fdoshared.Logger(r.Context()).Info("Receiving HelloRV30")
 
 	var currentCmd fdoshared.FdoCmd = fdoshared.TO1_30_HELLO_RV
 
@@ -66,26 +62,35 @@ func (h *RvTo1) Handle30HelloRV(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	fdoshared.SetLogGuid(r.Context(), helloRV30.Guid)
+
 	// Test stuff
 	var fdoTestId testcom.FDOTestID = testcom.NULL_TEST
 	testcomListener, _ = h.listenerDB.GetEntryByFdoGuid(helloRV30.Guid)
 
 	if testcomListener != nil && !testcomListener.To1.CheckCmdTestingIsCompleted(currentCmd) {
-		if !testcomListener.To1.CheckExpectedCmd(currentCmd) && testcomListener.To1.GetLastTestID() != testcom.FIDO_LISTENER_POSITIVE {
-			testcomListener.To1.PushFail(fmt.Sprintf("Expected TO1 %d. Got %d", testcomListener.To1.ExpectedCmd, currentCmd))
-		} else if testcomListener.To1.CurrentTestIndex != 0 {
-			testcomListener.To1.PushSuccess()
-		}
-
-		if !testcomListener.To1.CheckCmdTestingIsCompleted(currentCmd) {
-			fdoTestId = testcomListener.To1.GetNextTestID()
-		}
-
-		err := h.listenerDB.Update(testcomListener)
+		savedRunner, err := h.listenerDB.UpdateRunner(testcomListener.Uuid, fdoshared.To1, func(runner *listenertestsdeps.RequestListenerRunnerInst) error {
+			// The update is re-run on a conflict, so start from the defaults
+			fdoTestId = testcom.NULL_TEST
+
+			if !runner.CheckExpectedCmd(currentCmd) && runner.GetLastTestID() != testcom.FIDO_LISTENER_POSITIVE {
+				runner.PushFail(fmt.Sprintf("Expected TO1 %d. Got %d", runner.ExpectedCmd, currentCmd))
+			} else if runner.CurrentTestIndex != 0 {
+				runner.PushSuccess()
+			}
+
+			if !runner.CheckCmdTestingIsCompleted(currentCmd) {
+				fdoTestId = runner.GetNextTestID()
+				fdoshared.SetLogTestId(r.Context(), string(fdoTestId))
+			}
+
+			return nil
+		})
 		if err != nil {
 			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Conformance module failed to save result!", http.StatusBadRequest, testcomListener, fdoshared.To1)
 			return
 		}
+		testcomListener.To1 = *savedRunner
 	}
 
 	_, err = h.ownersignDB.Get(helloRV30.Guid)
@@ -108,6 +113,7 @@ func (h *RvTo1) Handle30HelloRV(w http.ResponseWriter, r *http.Request) {
 		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Internal Server Error!", http.StatusInternalServerError, testcomListener, fdoshared.To1)
 		return
 	}
+	fdoshared.SetLogSessionId(r.Context(), sessionId)
 
 	helloRVAck31 := fdoshared.HelloRVAck31{
 		NonceTO1Proof: nonceTO1Proof,
@@ -121,13 +127,20 @@ func (h *RvTo1) Handle30HelloRV(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if fdoTestId == testcom.FIDO_LISTENER_POSITIVE && testcomListener.To1.CheckExpectedCmd(currentCmd) {
-		testcomListener.To1.PushSuccess()
-		testcomListener.To1.CompleteCmdAndSetNext(fdoshared.TO1_32_PROVE_TO_RV)
-		err := h.listenerDB.Update(testcomListener)
+		savedRunner, err := h.listenerDB.UpdateRunner(testcomListener.Uuid, fdoshared.To1, func(runner *listenertestsdeps.RequestListenerRunnerInst) error {
+			if !runner.CheckExpectedCmd(currentCmd) {
+				return nil
+			}
+
+			runner.PushSuccess()
+			runner.CompleteCmdAndSetNext(fdoshared.TO1_32_PROVE_TO_RV)
+			return nil
+		})
 		if err != nil {
 			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Conformance module failed to save result!", http.StatusBadRequest, testcomListener, fdoshared.To1)
 			return
 		}
+		testcomListener.To1 = *savedRunner
 	}
 
 	sessionIdToken := "Bearer " + string(sessionId)
@@ -139,7 +152,7 @@ func (h *RvTo1) Handle30HelloRV(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *RvTo1) Handle32ProveToRV(w http.ResponseWriter, r *http.Request) {
-	log.Println("Receiving ProveToRV32...")
+	fdoshared.Logger(r.Context()).Info("Receiving ProveToRV32")
 
 	var currentCmd fdoshared.FdoCmd = fdoshared.TO1_32_PROVE_TO_RV
 
@@ -152,6 +165,7 @@ func (h *RvTo1) Handle32ProveToRV(w http.ResponseWriter, r *http.Request) {
 	if !headerIsOk {
 		return
 	}
+	fdoshared.SetLogSessionId(r.Context(), sessionId)
 
 	session, err := h.session.GetSessionEntry(sessionId)
 	if err != nil {
@@ -170,32 +184,41 @@ func (h *RvTo1) Handle32ProveToRV(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	fdoshared.SetLogGuid(r.Context(), session.Guid)
+
 	// Test stuff
 	var fdoTestId testcom.FDOTestID = testcom.NULL_TEST
 	testcomListener, _ = h.listenerDB.GetEntryByFdoGuid(session.Guid)
 
 	if testcomListener != nil && !testcomListener.To1.CheckCmdTestingIsCompleted(currentCmd) {
-		if !testcomListener.To1.CheckExpectedCmd(currentCmd) && testcomListener.To1.GetLastTestID() != testcom.FIDO_LISTENER_POSITIVE {
-			testcomListener.To1.PushFail(fmt.Sprintf("Expected TO1 %d. Got %d", testcomListener.To1.ExpectedCmd, currentCmd))
-		} else if testcomListener.To1.CurrentTestIndex != 0 {
-			testcomListener.To1.PushSuccess()
-		}
-
-		if !testcomListener.To1.CheckCmdTestingIsCompleted(currentCmd) {
-			fdoTestId = testcomListener.To1.GetNextTestID()
-		}
-
-		err := h.listenerDB.Update(testcomListener)
+		savedRunner, err := h.listenerDB.UpdateRunner(testcomListener.Uuid, fdoshared.To1, func(runner *listenertestsdeps.RequestListenerRunnerInst) error {
+			// The update is re-run on a conflict, so start from the defaults
+			fdoTestId = testcom.NULL_TEST
+
+			if !runner.CheckExpectedCmd(currentCmd) && runner.GetLastTestID() != testcom.FIDO_LISTENER_POSITIVE {
+				runner.PushFail(fmt.Sprintf("Expected TO1 %d. Got %d", runner.ExpectedCmd, currentCmd))
+			} else if runner.CurrentTestIndex != 0 {
+				runner.PushSuccess()
+			}
+
+			if !runner.CheckCmdTestingIsCompleted(currentCmd) {
+				fdoTestId = runner.GetNextTestID()
+				fdoshared.SetLogTestId(r.Context(), string(fdoTestId))
+			}
+
+			return nil
+		})
 		if err != nil {
 			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Conformance module failed to save result!", http.StatusBadRequest, testcomListener, fdoshared.To1)
 			return
 		}
+		testcomListener.To1 = *savedRunner
 	}
 
 	var proveToRV32 fdoshared.CoseSignature
 	err = fdoshared.CborCust.Unmarshal(bodyBytes, &proveToRV32)
 	if err != nil {
-		log.Println("Failed to decode proveToRV32 request: " + err.Error())
+		fdoshared.Logger(r.Context()).Error("Failed to decode proveToRV32 request", "error", err.Error())
 		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.MESSAGE_BODY_ERROR, currentCmd, "Failed to decode body!", http.StatusBadRequest, testcomListener, fdoshared.To1)
 		return
 	}
@@ -203,7 +226,7 @@ func (h *RvTo1) Handle32ProveToRV(w http.ResponseWriter, r *http.Request) {
 	var pb fdoshared.EATPayloadBase
 	err = fdoshared.CborCust.Unmarshal(proveToRV32.Payload, &pb)
 	if err != nil {
-		log.Println("Failed to decode proveToRV32 payload: " + err.Error())
+		fdoshared.Logger(r.Context()).Error("Failed to decode proveToRV32 payload", "error", err.Error())
 		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.MESSAGE_BODY_ERROR, currentCmd, "Failed to decode body payload!", http.StatusBadRequest, testcomListener, fdoshared.To1)
 		return
 	}
@@ -213,10 +236,17 @@ func (h *RvTo1) Handle32ProveToRV(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The proof must be for the device of the session
+	expectedUEID := fdoshared.GenerateEatGuid(session.Guid)
+	if !bytes.Equal(pb.EatUEID[:], expectedUEID[:]) {
+		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INVALID_MESSAGE_ERROR, currentCmd, fmt.Sprintf("EatUEID does not match the session GUID. Expected %s. Got %s", hex.EncodeToString(expectedUEID[:]), hex.EncodeToString(pb.EatUEID[:])), http.StatusBadRequest, testcomListener, fdoshared.To1)
+		return
+	}
+
 	// Get ownerSign from ownerSign storage
 	savedOwnerSign, err := h.ownersignDB.Get(session.Guid)
 	if err != nil {
-		log.Println("Couldn't find item in database with guid" + err.Error())
+		fdoshared.Logger(r.Context()).Error("Couldn't find item in database with guid", "error", err.Error())
 		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INVALID_MESSAGE_ERROR, currentCmd, "Server Error", http.StatusInternalServerError, testcomListener, fdoshared.To1)
 		return
 	}
@@ -224,7 +254,7 @@ func (h *RvTo1) Handle32ProveToRV(w http.ResponseWriter, r *http.Request) {
 	var to0d fdoshared.To0d
 	err = fdoshared.CborCust.Unmarshal(savedOwnerSign.To0d, &to0d)
 	if err != nil {
-		log.Println("Error decoding To0d" + err.Error())
+		fdoshared.Logger(r.Context()).Error("Error decoding To0d", "error", err.Error())
 
 		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.MESSAGE_BODY_ERROR, currentCmd, "Failed to decode body!", http.StatusBadRequest, testcomListener, fdoshared.To1)
 		return
@@ -232,13 +262,13 @@ func (h *RvTo1) Handle32ProveToRV(w http.ResponseWriter, r *http.Request) {
 
 	pkType, ok := fdoshared.SgTypeToFdoPkType[session.EASigInfo.SgType]
 	if !ok {
-		log.Println("ProveToRV32: Unknown signature type. ")
+		fdoshared.Logger(r.Context()).Warn("ProveToRV32: Unknown signature type")
 		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INVALID_MESSAGE_ERROR, currentCmd, "Error to verify signature ProveToRV32 ", http.StatusBadRequest, testcomListener, fdoshared.To1)
 		return
 	}
 	err = fdoshared.VerifyCoseSignatureWithCertificate(proveToRV32, pkType, *to0d.OwnershipVoucher.OVDevCertChain)
 	if err != nil {
-		log.Println("ProveToRV32: Error verifying ProveToRV32 signature. " + err.Error())
+		fdoshared.Logger(r.Context()).Error("ProveToRV32: Error verifying ProveToRV32 signature", "error", err.Error())
 		listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INVALID_MESSAGE_ERROR, currentCmd, "Error to verify signature ProveToRV32 ", http.StatusBadRequest, testcomListener, fdoshared.To1)
 		return
 	}
@@ -254,28 +284,35 @@ func (h *RvTo1) Handle32ProveToRV(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if fdoTestId == testcom.FIDO_LISTENER_POSITIVE {
-		testcomListener.To1.PushSuccess()
-		testcomListener.To1.CompleteTestRun()
-		err := h.listenerDB.Update(testcomListener)
+		savedRunner, err := h.listenerDB.UpdateRunner(testcomListener.Uuid, fdoshared.To1, func(runner *listenertestsdeps.RequestListenerRunnerInst) error {
+			if !runner.Running {
+				return nil
+			}
+
+			runner.PushSuccess()
+			runner.CompleteTestRun()
+			return nil
+		})
 		if err != nil {
 			listenertestsdeps.Conf_RespondFDOError(w, r, fdoshared.INTERNAL_SERVER_ERROR, currentCmd, "Conformance module failed to save result!", http.StatusInternalServerError, testcomListener, fdoshared.To1)
 			return
 		}
+		testcomListener.To1 = *savedRunner
 	}
 
 	iopEnabled := h.ctx.Value(fdoshared.CFG_ENV_INTEROP_ENABLED).(bool)
 	if fdoTestId == testcom.NULL_TEST && iopEnabled {
 		authzHeader, err := fdoshared.IopGetAuthz(h.ctx, fdoshared.IopRV)
 		if err != nil {
-			log.Println("IOT: Error getting authz header: " + err.Error())
+			fdoshared.Logger(r.Context()).Error("IOT: Error getting authz header", "error", err.Error())
 		}
 
 		err = fdoshared.SubmitIopLoggerEvent(h.ctx, session.Guid, fdoshared.To1, session.NonceTO1Proof, authzHeader)
 		if err != nil {
-			log.Println("IOT: Error sending iop log event: " + err.Error())
+			fdoshared.Logger(r.Context()).Error("IOT: Error sending iop log event", "error", err.Error())
 		}
 	} else if !iopEnabled {
-		log.Println("Interop is not enabled, skipping IOP logger event submission")
+		fdoshared.Logger(r.Context()).Debug("Interop is not enabled, skipping IOP logger event submission")
 	}
 
 	w.Header().Set("Authorization", authorizationHeader)
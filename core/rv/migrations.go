@@ -0,0 +1,22 @@
+package rv
+
+import (
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/records"
+)
+
+const (
+	RT_Session   records.RecordType = "rv_session"
+	RT_OwnerSign records.RecordType = "rv_owner_sign"
+)
+
+func init() {
+	// Sessions are short lived, they are only migrated on read
+	records.Register(records.Schema{
+		Type: RT_Session,
+	})
+
+	records.Register(records.Schema{
+		Type:     RT_OwnerSign,
+		Prefixes: [][]byte{[]byte("to1osstorage-")},
+	})
+}
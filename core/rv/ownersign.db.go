@@ -6,23 +6,23 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/dgraph-io/badger/v4"
-
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/records"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/storage"
 )
 
 type OwnerSignDB struct {
-	db *badger.DB
+	db storage.Store
 }
 
-func NewOwnerSignDB(db *badger.DB) OwnerSignDB {
+func NewOwnerSignDB(db storage.Store) OwnerSignDB {
 	return OwnerSignDB{
 		db: db,
 	}
 }
 
 func (h *OwnerSignDB) Save(deviceGuid fdoshared.FdoGuid, ownerSign fdoshared.OwnerSign22, ttlSec uint32) error {
-	ownerSignBytes, err := fdoshared.CborCust.Marshal(ownerSign)
+	ownerSignBytes, err := records.Marshal(RT_OwnerSign, ownerSign)
 	if err != nil {
 		return errors.New("Failed to marshal ownerSign. The error is: " + err.Error())
 	}
@@ -32,8 +32,7 @@ func (h *OwnerSignDB) Save(deviceGuid fdoshared.FdoGuid, ownerSign fdoshared.Own
 	dbtxn := h.db.NewTransaction(true)
 	defer dbtxn.Discard()
 
-	entry := badger.NewEntry(ownerSignStorageId, ownerSignBytes).WithTTL(time.Second * time.Duration(ttlSec))
-	err = dbtxn.SetEntry(entry)
+	err = dbtxn.SetWithTTL(ownerSignStorageId, ownerSignBytes, time.Second*time.Duration(ttlSec))
 	if err != nil {
 		return errors.New("Failed creating session db entry instance. The error is: " + err.Error())
 	}
@@ -51,23 +50,31 @@ func (h *OwnerSignDB) Get(deviceGuid fdoshared.FdoGuid) (*fdoshared.OwnerSign22,
 	dbtxn := h.db.NewTransaction(true)
 	defer dbtxn.Discard()
 
-	item, err := dbtxn.Get(ownerSignStorageId)
-	if err != nil && errors.Is(err, badger.ErrKeyNotFound) {
+	itemBytes, err := dbtxn.Get(ownerSignStorageId)
+	if err != nil && errors.Is(err, storage.ErrKeyNotFound) {
 		return nil, fmt.Errorf("The owner sign entry with id %s does not exist", hex.EncodeToString(deviceGuid[:]))
 	} else if err != nil {
 		return nil, errors.New("Failed locating entry. The error is: " + err.Error())
 	}
 
-	itemBytes, err := item.ValueCopy(nil)
-	if err != nil {
-		return nil, errors.New("Failed reading entry value. The error is: " + err.Error())
-	}
-
 	var ownerSignInst fdoshared.OwnerSign22
-	err = fdoshared.CborCust.Unmarshal(itemBytes, &ownerSignInst)
+	err = records.Unmarshal(RT_OwnerSign, itemBytes, &ownerSignInst)
 	if err != nil {
 		return nil, errors.New("Failed cbor decoding entry value. The error is: " + err.Error())
 	}
 
 	return &ownerSignInst, nil
 }
+
+// Count returns the number of stored TO0 registrations that have not expired yet
+func (h *OwnerSignDB) Count() (int, error) {
+	count := 0
+	err := h.db.View(func(txn storage.Txn) error {
+		return txn.Iterate([]byte("to1osstorage-"), func(key []byte, value []byte) error {
+			count++
+			return nil
+		})
+	})
+
+	return count, err
+}
@@ -2,17 +2,41 @@ package rv
 
 import (
 	"context"
+	"log"
 	"net/http"
 
-	"github.com/dgraph-io/badger/v4"
+	"github.com/prometheus/client_golang/prometheus"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/storage"
 )
 
-func SetupServer(db *badger.DB, ctx context.Context) {
-	to0 := NewRvTo0(db, ctx)
-	to1 := NewRvTo1(db, ctx)
+// SetupServer registers the RV handlers on the default mux
+func SetupServer(db storage.Store, ctx context.Context) {
+	RegisterRoutes(http.DefaultServeMux, db, ctx)
+}
+
+// RegisterRoutes registers the RV handlers on mux, so that the server can run on a private mux, e.g. in httptest
+func RegisterRoutes(mux *http.ServeMux, db storage.Store, ctx context.Context) {
+	// The handlers share the session DB, so that it counts the active sessions of both protocols
+	sessionDb := NewSessionDB(db)
+	to0 := NewRvTo0(db, &sessionDb, ctx)
+	to1 := NewRvTo1(db, &sessionDb, ctx)
+
+	ownerSignDb := NewOwnerSignDB(db)
+	err := fdoshared.RegisterGaugeFunc(ctx, "active_sessions", "FDO sessions that have not ended or expired yet.", prometheus.Labels{"server": "rv"}, sessionDb.CountActive)
+	if err != nil {
+		log.Println(err.Error())
+	}
+
+	err = fdoshared.RegisterGaugeFunc(ctx, "to0_registrations", "TO0 registrations stored by the RV server.", nil, ownerSignDb.Count)
+	if err != nil {
+		log.Println(err.Error())
+	}
 
-	http.HandleFunc("/fdo/101/msg/20", to0.Handle20Hello)
-	http.HandleFunc("/fdo/101/msg/22", to0.Handle22OwnerSign)
-	http.HandleFunc("/fdo/101/msg/30", to1.Handle30HelloRV)
-	http.HandleFunc("/fdo/101/msg/32", to1.Handle32ProveToRV)
+	// A follow-up message rejected with an error ends the session
+	mux.HandleFunc("/fdo/101/msg/20", fdoshared.InstrumentHandler(fdoshared.TO0_20_HELLO, to0.Handle20Hello))
+	mux.HandleFunc("/fdo/101/msg/22", fdoshared.InstrumentHandler(fdoshared.TO0_22_OWNER_SIGN, fdoshared.EndSessionOnError(to0.Handle22OwnerSign, sessionDb.DeleteSessionEntry)))
+	mux.HandleFunc("/fdo/101/msg/30", fdoshared.InstrumentHandler(fdoshared.TO1_30_HELLO_RV, to1.Handle30HelloRV))
+	mux.HandleFunc("/fdo/101/msg/32", fdoshared.InstrumentHandler(fdoshared.TO1_32_PROVE_TO_RV, fdoshared.EndSessionOnError(to1.Handle32ProveToRV, sessionDb.DeleteSessionEntry)))
 }
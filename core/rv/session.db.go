@@ -2,21 +2,24 @@ package rv
 
 import (
 	"errors"
-	"time"
+	"fmt"
 
-	"github.com/dgraph-io/badger/v4"
 	"github.com/google/uuid"
 
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/records"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/storage"
 )
 
 type SessionDB struct {
-	db *badger.DB
+	db     storage.Store
+	active *fdoshared.ActiveSessions
 }
 
-func NewSessionDB(db *badger.DB) SessionDB {
+func NewSessionDB(db storage.Store) SessionDB {
 	return SessionDB{
-		db: db,
+		db:     db,
+		active: fdoshared.NewActiveSessions(),
 	}
 }
 
@@ -30,7 +33,7 @@ type SessionEntry struct {
 }
 
 func (h *SessionDB) NewSessionEntry(sessionInst SessionEntry) ([]byte, error) {
-	sessionBytes, err := fdoshared.CborCust.Marshal(sessionInst)
+	sessionBytes, err := records.Marshal(RT_Session, sessionInst)
 	if err != nil {
 		return []byte{}, errors.New("Failed to marshal session. The error is: " + err.Error())
 	}
@@ -41,8 +44,7 @@ func (h *SessionDB) NewSessionEntry(sessionInst SessionEntry) ([]byte, error) {
 	dbtxn := h.db.NewTransaction(true)
 	defer dbtxn.Discard()
 
-	entry := badger.NewEntry(sessionEntryId, sessionBytes).WithTTL(time.Minute * 10) // Session entry will only exist for 10 minutes
-	err = dbtxn.SetEntry(entry)
+	err = dbtxn.SetWithTTL(sessionEntryId, sessionBytes, fdoshared.SESSION_TTL)
 	if err != nil {
 		return []byte{}, errors.New("Failed creating session db entry instance. The error is: " + err.Error())
 	}
@@ -51,6 +53,8 @@ func (h *SessionDB) NewSessionEntry(sessionInst SessionEntry) ([]byte, error) {
 		return []byte{}, errors.New("Failed saving session entry. The error is: " + err.Error())
 	}
 
+	h.active.Touch([]byte(randomEntryId.String()))
+
 	return []byte(randomEntryId.String()), nil
 }
 
@@ -60,12 +64,13 @@ func (h *SessionDB) UpdateSessionEntry(entryId []byte, sessionInst SessionEntry)
 	dbtxn := h.db.NewTransaction(true)
 	defer dbtxn.Discard()
 
-	sessionInstBytes, err := fdoshared.CborCust.Marshal(sessionInst)
+	sessionInstBytes, err := records.Marshal(RT_Session, sessionInst)
 	if err != nil {
 		return errors.New("Failed to marshal session. The error is: " + err.Error())
 	}
 
-	err = dbtxn.Set(sessionEntryId, sessionInstBytes)
+	// Every message keeps the session alive for another SESSION_TTL
+	err = dbtxn.SetWithTTL(sessionEntryId, sessionInstBytes, fdoshared.SESSION_TTL)
 	if err != nil {
 		return errors.New("Failed to create saving inst. The error is: " + err.Error())
 	}
@@ -75,6 +80,8 @@ func (h *SessionDB) UpdateSessionEntry(entryId []byte, sessionInst SessionEntry)
 		return errors.New("Failed to save session. The error is: " + err.Error())
 	}
 
+	h.active.Touch(entryId)
+
 	return nil
 }
 
@@ -84,23 +91,45 @@ func (h *SessionDB) GetSessionEntry(entryId []byte) (*SessionEntry, error) {
 	dbtxn := h.db.NewTransaction(true)
 	defer dbtxn.Discard()
 
-	item, err := dbtxn.Get(sessionEntryId)
-	if err != nil && errors.Is(err, badger.ErrKeyNotFound) {
-		return nil, nil
+	itemBytes, err := dbtxn.Get(sessionEntryId)
+	if err != nil && errors.Is(err, storage.ErrKeyNotFound) {
+		return nil, fmt.Errorf("The session entry with id %s does not exist", string(entryId))
 	} else if err != nil {
 		return nil, errors.New("Failed locating entry. The error is: " + err.Error())
 	}
 
-	itemBytes, err := item.ValueCopy(nil)
-	if err != nil {
-		return nil, errors.New("Failed reading entry value. The error is: " + err.Error())
-	}
-
 	var sessionEntryInst SessionEntry
-	err = fdoshared.CborCust.Unmarshal(itemBytes, &sessionEntryInst)
+	err = records.Unmarshal(RT_Session, itemBytes, &sessionEntryInst)
 	if err != nil {
 		return nil, errors.New("Failed cbor decoding entry value. The error is: " + err.Error())
 	}
 
 	return &sessionEntryInst, nil
 }
+
+// DeleteSessionEntry ends the session, e.g. after an error
+func (h *SessionDB) DeleteSessionEntry(entryId []byte) error {
+	sessionEntryId := append([]byte("session-"), entryId...)
+
+	dbtxn := h.db.NewTransaction(true)
+	defer dbtxn.Discard()
+
+	err := dbtxn.Delete(sessionEntryId)
+	if err != nil {
+		return errors.New("Failed initialise delete entry. The error is: " + err.Error())
+	}
+
+	err = dbtxn.Commit()
+	if err != nil {
+		return errors.New("Failed to delete session. The error is: " + err.Error())
+	}
+
+	h.active.End(entryId)
+
+	return nil
+}
+
+// CountActive returns the number of TO0 and TO1 sessions started by this server that have not ended or expired yet
+func (h *SessionDB) CountActive() (int, error) {
+	return h.active.Count()
+}
@@ -2,11 +2,13 @@ package fdoshared
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"sync"
 	"time"
 )
 
@@ -16,10 +18,76 @@ type SRVEntry struct { // TODO: Unify type with DO
 	OverrideURL bool
 }
 
-func SendCborPost(rvEntry SRVEntry, cmd FdoCmd, payload []byte, authzHeader *string) ([]byte, string, int, error) {
+// FdoHttpRequest is a raw FDO request. Method, headers and body are sent as is, so malformed requests can be sent too
+type FdoHttpRequest struct {
+	Method string
+	Header http.Header
+	Body   []byte
+}
+
+// SentMessagesRecorder keeps the last body sent for each command with the context it is attached to, so that tests can replay it
+type SentMessagesRecorder struct {
+	mu           sync.Mutex
+	sentMessages map[FdoCmd][]byte
+}
+
+func (h *SentMessagesRecorder) Record(cmd FdoCmd, body []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.sentMessages[cmd] = append([]byte{}, body...)
+}
+
+func (h *SentMessagesRecorder) GetLastSent(cmd FdoCmd) ([]byte, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	body, ok := h.sentMessages[cmd]
+	return body, ok
+}
+
+type sentMessagesRecorderKey struct{}
+
+func NewSentMessagesContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, sentMessagesRecorderKey{}, &SentMessagesRecorder{
+		sentMessages: map[FdoCmd][]byte{},
+	})
+}
+
+func GetSentMessagesRecorder(ctx context.Context) *SentMessagesRecorder {
+	if ctx == nil {
+		return nil
+	}
+
+	recorder, _ := ctx.Value(sentMessagesRecorderKey{}).(*SentMessagesRecorder)
+	return recorder
+}
+
+func SendCborPost(ctx context.Context, rvEntry SRVEntry, cmd FdoCmd, payload []byte, authzHeader *string) ([]byte, string, int, error) {
+	header := http.Header{}
+	if authzHeader != nil {
+		header.Set("Authorization", *authzHeader)
+	}
+
+	header.Set("Content-Type", CONTENT_TYPE_CBOR)
+
+	bodyBytes, respHeader, statusCode, err := SendFdoRequest(ctx, rvEntry, cmd, FdoHttpRequest{
+		Method: http.MethodPost,
+		Header: header,
+		Body:   payload,
+	})
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	return bodyBytes, respHeader.Get("Authorization"), statusCode, nil
+}
+
+// SendFdoRequest sends a raw request for cmd, and returns the response body, headers and status
+func SendFdoRequest(ctx context.Context, rvEntry SRVEntry, cmd FdoCmd, fdoReq FdoHttpRequest) ([]byte, http.Header, int, error) {
 	address, err := url.Parse(rvEntry.SrvURL)
 	if err != nil {
-		return nil, "", 0, fmt.Errorf("Error joining parsing url %s %s", rvEntry.SrvURL, err.Error())
+		return nil, nil, 0, fmt.Errorf("Error joining parsing url %s %s", rvEntry.SrvURL, err.Error())
 	}
 
 	address = address.JoinPath(FDO_101_URL_BASE, cmd.ToString())
@@ -31,26 +99,73 @@ func SendCborPost(rvEntry SRVEntry, cmd FdoCmd, payload []byte, authzHeader *str
 	httpClient := &http.Client{
 		Timeout: 30 * time.Second,
 	}
-	req, err := http.NewRequest("POST", address.String(), bytes.NewBuffer(payload))
+
+	var body io.Reader
+	if len(fdoReq.Body) > 0 {
+		body = bytes.NewBuffer(fdoReq.Body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, fdoReq.Method, address.String(), body)
 	if err != nil {
-		return nil, "", 0, errors.New("Error creating new request. " + err.Error())
+		return nil, nil, 0, errors.New("Error creating new request. " + err.Error())
 	}
 
-	if authzHeader != nil {
-		req.Header.Set("Authorization", *authzHeader)
+	for headerName, headerValues := range fdoReq.Header {
+		for _, headerValue := range headerValues {
+			req.Header.Add(headerName, headerValue)
+		}
 	}
 
-	req.Header.Set("Content-Type", "application/cbor")
+	if recorder := GetSentMessagesRecorder(ctx); recorder != nil {
+		recorder.Record(cmd, fdoReq.Body)
+	}
+
+	sentAt := time.Now()
 	resp, err := httpClient.Do(req)
 	if err != nil {
-		return nil, "", 0, fmt.Errorf("Error sending post request to %s url. %s", address, err.Error())
+		return nil, nil, 0, fmt.Errorf("Error sending %s request to %s url. %s", fdoReq.Method, address, err.Error())
 	}
 
 	defer resp.Body.Close()
-	bodyBytes, err := io.ReadAll(resp.Body)
+	bodyBytes, err := io.ReadAll(io.LimitReader(resp.Body, MAX_MESSAGE_BODY_SIZE+1))
 	if err != nil {
-		return nil, "", 0, fmt.Errorf("Error reading body bytes for %s url. %s", address, err.Error())
+		return nil, nil, 0, fmt.Errorf("Error reading body bytes for %s url. %s", address, err.Error())
+	}
+
+	if int64(len(bodyBytes)) > MAX_MESSAGE_BODY_SIZE {
+		return nil, nil, 0, fmt.Errorf("Error reading body bytes for %s url. The body is larger than %d bytes", address, MAX_MESSAGE_BODY_SIZE)
+	}
+
+	if recorder := GetTimingsRecorder(ctx); recorder != nil {
+		recorder.Record(MessageTiming{
+			Cmd:                cmd,
+			RoundTripUs:        time.Since(sentAt).Microseconds(),
+			ServerProcessingUs: ParseServerTiming(resp.Header.Get(ServerTimingHeader)),
+			RequestSize:        len(fdoReq.Body),
+			ResponseSize:       len(bodyBytes),
+		})
+	}
+
+	isErrorResponse := resp.StatusCode != http.StatusOK || resp.Header.Get("Message-Type") == TO_ERROR_255.ToString()
+	if recorder := GetReceivedErrorsRecorder(ctx); recorder != nil && isErrorResponse {
+		recorder.Record(ReceivedError{
+			SentCmd:     cmd,
+			HttpStatus:  resp.StatusCode,
+			ContentType: resp.Header.Get("Content-Type"),
+			MessageType: resp.Header.Get("Message-Type"),
+			Body:        bodyBytes,
+		})
+	}
+
+	logger := Logger(ctx).With(LOG_ATTR_MSG_TYPE, cmd.ToString())
+	if resp.Header.Get("Message-Type") == TO_ERROR_255.ToString() {
+		var fdoErrorInst FdoError
+		if CborCust.Unmarshal(bodyBytes, &fdoErrorInst) == nil {
+			logger.Warn("Received FDO error", "errorCode", fdoErrorInst.EMErrorCode, "errorStr", fdoErrorInst.EMErrorStr, LOG_ATTR_CORRELATION_ID, fdoErrorInst.EMErrorCID)
+		}
+	} else {
+		logger.Debug("Sent FDO message", "status", resp.StatusCode, "requestSize", len(fdoReq.Body), "responseSize", len(bodyBytes))
 	}
 
-	return bodyBytes, resp.Header.Get("Authorization"), resp.StatusCode, nil
+	return bodyBytes, resp.Header, resp.StatusCode, nil
 }
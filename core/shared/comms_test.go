@@ -0,0 +1,35 @@
+package fdoshared
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSendFdoRequestLimitsResponseBody(t *testing.T) {
+	for _, testCase := range []struct {
+		bodySize int64
+		fails    bool
+	}{
+		{MAX_MESSAGE_BODY_SIZE, false},
+		{MAX_MESSAGE_BODY_SIZE + 1, true},
+	} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", CONTENT_TYPE_CBOR)
+			w.Write(bytes.Repeat([]byte{0x00}, int(testCase.bodySize)))
+		}))
+
+		bodyBytes, _, _, err := SendFdoRequest(context.Background(), SRVEntry{SrvURL: server.URL}, TO1_30_HELLO_RV, FdoHttpRequest{Method: http.MethodPost})
+		server.Close()
+
+		if testCase.fails && err == nil {
+			t.Errorf("Expected a %d byte body to fail", testCase.bodySize)
+		}
+
+		if !testCase.fails && (err != nil || int64(len(bodyBytes)) != testCase.bodySize) {
+			t.Errorf("Expected a %d byte body to be read. Got %d, %v", testCase.bodySize, len(bodyBytes), err)
+		}
+	}
+}
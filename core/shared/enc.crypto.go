@@ -29,6 +29,19 @@ const (
 	CIPHER_COSE_AES256_CTR    CipherSuiteName = -17760706 // CS_AES256_CBC_HMAC-SHA384
 )
 
+var CipherSuiteNames map[CipherSuiteName]string = map[CipherSuiteName]string{
+	CIPHER_A128GCM:            "A128GCM",
+	CIPHER_A256GCM:            "A256GCM",
+	CIPHER_AES_CCM_16_128_128: "AES-CCM-16-128-128",
+	CIPHER_AES_CCM_16_128_256: "AES-CCM-16-128-256",
+	CIPHER_AES_CCM_64_128_128: "AES-CCM-64-128-128",
+	CIPHER_AES_CCM_64_128_256: "AES-CCM-64-128-256",
+	CIPHER_COSE_AES128_CBC:    "AES128-CBC",
+	CIPHER_COSE_AES128_CTR:    "AES128-CTR",
+	CIPHER_COSE_AES256_CBC:    "AES256-CBC",
+	CIPHER_COSE_AES256_CTR:    "AES256-CTR",
+}
+
 type CipherInfo struct {
 	CryptoAlg  CipherSuiteName
 	HmacAlg    HashType
@@ -542,6 +555,17 @@ func decryptEMB(encrypted []byte, sessionKeyInfo SessionKeyInfo, cipherSuite Cip
 	return plaintext, nil
 }
 
+// IsCipherSuiteImplemented reports whether AddEncryptionWrapping can encrypt with the cipher suite.
+// Of the encrypt-then-MAC suites only AES128-CTR is implemented
+func IsCipherSuiteImplemented(cipherSuite CipherSuiteName) bool {
+	switch cipherSuite {
+	case CIPHER_COSE_AES128_CTR, CIPHER_A128GCM, CIPHER_A256GCM, CIPHER_AES_CCM_16_128_128, CIPHER_AES_CCM_16_128_256, CIPHER_AES_CCM_64_128_128, CIPHER_AES_CCM_64_128_256:
+		return true
+	default:
+		return false
+	}
+}
+
 func AddEncryptionWrapping(payload []byte, sessionKeyInfo SessionKeyInfo, cipherSuite CipherSuiteName) ([]byte, error) {
 	switch cipherSuite {
 	case CIPHER_COSE_AES128_CBC, CIPHER_COSE_AES128_CTR, CIPHER_COSE_AES256_CBC, CIPHER_COSE_AES256_CTR:
@@ -6,9 +6,25 @@ const (
 	CFG_ENV_FDO_SERVICE_URL CONFIG_ENTRY = "FDO_SERVICE_URL"
 	CFG_ENV_MODE            CONFIG_ENTRY = "MODE"
 
+	// Public URLs of the RV and of the API, when they are not served at FDO_SERVICE_URL
+	CFG_ENV_FDO_RV_URL  CONFIG_ENTRY = "FDO_RV_URL"
+	CFG_ENV_FDO_API_URL CONFIG_ENTRY = "FDO_API_URL"
+
 	CFG_DEV_ENV  CONFIG_ENTRY = "DEV"
 	CFG_ENV_PORT CONFIG_ENTRY = "PORT"
 
+	// Logging
+	CFG_ENV_LOG_LEVEL  CONFIG_ENTRY = "LOG_LEVEL"
+	CFG_ENV_LOG_FORMAT CONFIG_ENTRY = "LOG_FORMAT"
+	CFG_ENV_LOG_OUTPUT CONFIG_ENTRY = "LOG_OUTPUT"
+
+	// Storage
+	CFG_ENV_STORAGE_BACKEND CONFIG_ENTRY = "STORAGE_BACKEND"
+	CFG_ENV_STORAGE_PATH    CONFIG_ENTRY = "STORAGE_PATH"
+
+	// Set by serve --ephemeral
+	CFG_ENV_EPHEMERAL CONFIG_ENTRY = "EPHEMERAL"
+
 	// For conformance testing
 	CFG_ENV_INTEROP_ENABLED            CONFIG_ENTRY = "INTEROP_ENABLED"
 	CFG_ENV_INTEROP_DASHBOARD_URL      CONFIG_ENTRY = "INTEROP_DASHBOARD_URL"
@@ -1,8 +1,10 @@
 package fdoshared
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
+	"sync"
 	"time"
 )
 
@@ -68,3 +70,60 @@ const (
 	// All
 	INTERNAL_SERVER_ERROR FdoErrorCode = 500
 )
+
+var FdoErrorCodeNames map[FdoErrorCode]string = map[FdoErrorCode]string{
+	INVALID_JWT_TOKEN:         "INVALID_JWT_TOKEN",
+	INVALID_OWNERSHIP_VOUCHER: "INVALID_OWNERSHIP_VOUCHER",
+	INVALID_OWNER_SIGN_BODY:   "INVALID_OWNER_SIGN_BODY",
+	INVALID_IP_ADDRESS:        "INVALID_IP_ADDRESS",
+	INVALID_GUID:              "INVALID_GUID",
+	RESOURCE_NOT_FOUND:        "RESOURCE_NOT_FOUND",
+	MESSAGE_BODY_ERROR:        "MESSAGE_BODY_ERROR",
+	INVALID_MESSAGE_ERROR:     "INVALID_MESSAGE_ERROR",
+	CRED_REUSE_ERROR:          "CRED_REUSE_ERROR",
+	INTERNAL_SERVER_ERROR:     "INTERNAL_SERVER_ERROR",
+}
+
+// ReceivedError is an error response as received by SendCborPost, kept so that tests can validate its structure
+type ReceivedError struct {
+	SentCmd     FdoCmd
+	HttpStatus  int
+	ContentType string
+	MessageType string
+	Body        []byte
+}
+
+// ReceivedErrorsRecorder collects the error responses to all messages sent with the context it is attached to
+type ReceivedErrorsRecorder struct {
+	mu             sync.Mutex
+	receivedErrors []ReceivedError
+}
+
+func (h *ReceivedErrorsRecorder) Record(receivedError ReceivedError) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.receivedErrors = append(h.receivedErrors, receivedError)
+}
+
+func (h *ReceivedErrorsRecorder) GetReceivedErrors() []ReceivedError {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return append([]ReceivedError{}, h.receivedErrors...)
+}
+
+type receivedErrorsRecorderKey struct{}
+
+func NewReceivedErrorsContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, receivedErrorsRecorderKey{}, &ReceivedErrorsRecorder{})
+}
+
+func GetReceivedErrorsRecorder(ctx context.Context) *ReceivedErrorsRecorder {
+	if ctx == nil {
+		return nil
+	}
+
+	recorder, _ := ctx.Value(receivedErrorsRecorderKey{}).(*ReceivedErrorsRecorder)
+	return recorder
+}
@@ -0,0 +1,211 @@
+package fdoshared
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+)
+
+const (
+	LOG_FORMAT_JSON   string = "json"
+	LOG_FORMAT_LOGFMT string = "logfmt"
+
+	LOG_OUTPUT_STDOUT string = "stdout"
+	LOG_OUTPUT_STDERR string = "stderr"
+)
+
+// Attributes carried by every log line of an FDO message exchange
+const (
+	LOG_ATTR_MSG_TYPE       string = "msgType"
+	LOG_ATTR_GUID           string = "guid"
+	LOG_ATTR_SESSION_ID     string = "sessionId"
+	LOG_ATTR_TEST_ID        string = "testId"
+	LOG_ATTR_CORRELATION_ID string = "correlationId"
+)
+
+// InitLogger configures the default logger. The standard "log" package is redirected to it as well.
+// Empty values fall back to info level, logfmt format and stderr output.
+func InitLogger(level string, format string, output string) error {
+	var logLevel slog.Level
+	if level != "" {
+		err := logLevel.UnmarshalText([]byte(level))
+		if err != nil {
+			return fmt.Errorf("Unknown log level \"%s\"", level)
+		}
+	}
+
+	var logWriter io.Writer
+	switch strings.ToLower(output) {
+	case "", LOG_OUTPUT_STDERR:
+		logWriter = os.Stderr
+	case LOG_OUTPUT_STDOUT:
+		logWriter = os.Stdout
+	default:
+		logFile, err := os.OpenFile(output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return fmt.Errorf("Failed to open log file \"%s\". %s", output, err.Error())
+		}
+
+		logWriter = logFile
+	}
+
+	handlerOptions := &slog.HandlerOptions{Level: logLevel}
+
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "", LOG_FORMAT_LOGFMT:
+		handler = slog.NewTextHandler(logWriter, handlerOptions)
+	case LOG_FORMAT_JSON:
+		handler = slog.NewJSONHandler(logWriter, handlerOptions)
+	default:
+		return fmt.Errorf("Unknown log format \"%s\". Expected \"%s\" or \"%s\"", format, LOG_FORMAT_JSON, LOG_FORMAT_LOGFMT)
+	}
+
+	slog.SetDefault(slog.New(handler))
+
+	return nil
+}
+
+// logFields are filled in while an exchange progresses, e.g. the GUID is only known once the session is loaded
+type logFields struct {
+	mu            sync.Mutex
+	msgType       *FdoCmd
+	guid          string
+	sessionId     string
+	testId        string
+	correlationId uint
+}
+
+func (h *logFields) clone() *logFields {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return &logFields{
+		msgType:       h.msgType,
+		guid:          h.guid,
+		sessionId:     h.sessionId,
+		testId:        h.testId,
+		correlationId: h.correlationId,
+	}
+}
+
+func (h *logFields) attrs() []any {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var attrs []any
+	if h.msgType != nil {
+		attrs = append(attrs, LOG_ATTR_MSG_TYPE, h.msgType.ToString())
+	}
+
+	if h.guid != "" {
+		attrs = append(attrs, LOG_ATTR_GUID, h.guid)
+	}
+
+	if h.sessionId != "" {
+		attrs = append(attrs, LOG_ATTR_SESSION_ID, h.sessionId)
+	}
+
+	if h.testId != "" {
+		attrs = append(attrs, LOG_ATTR_TEST_ID, h.testId)
+	}
+
+	if h.correlationId != 0 {
+		attrs = append(attrs, LOG_ATTR_CORRELATION_ID, h.correlationId)
+	}
+
+	return attrs
+}
+
+type logFieldsKey struct{}
+
+func getLogFields(ctx context.Context) *logFields {
+	if ctx == nil {
+		return nil
+	}
+
+	fields, _ := ctx.Value(logFieldsKey{}).(*logFields)
+	return fields
+}
+
+// NewLogContext returns a context with its own set of log fields, inheriting the fields of the parent context
+func NewLogContext(ctx context.Context) context.Context {
+	fields := &logFields{}
+	if parentFields := getLogFields(ctx); parentFields != nil {
+		fields = parentFields.clone()
+	}
+
+	return context.WithValue(ctx, logFieldsKey{}, fields)
+}
+
+// NewRequestLogContext starts the log context of a received FDO message and assigns it a correlation ID
+func NewRequestLogContext(ctx context.Context, msgType FdoCmd) context.Context {
+	ctx = NewLogContext(ctx)
+
+	fields := getLogFields(ctx)
+	fields.msgType = &msgType
+	fields.correlationId = uint(rand.Uint32())
+
+	return ctx
+}
+
+func SetLogMsgType(ctx context.Context, msgType FdoCmd) {
+	if fields := getLogFields(ctx); fields != nil {
+		fields.mu.Lock()
+		fields.msgType = &msgType
+		fields.mu.Unlock()
+	}
+}
+
+func SetLogGuid(ctx context.Context, guid FdoGuid) {
+	if fields := getLogFields(ctx); fields != nil {
+		fields.mu.Lock()
+		fields.guid = guid.GetFormatted()
+		fields.mu.Unlock()
+	}
+}
+
+func SetLogSessionId(ctx context.Context, sessionId []byte) {
+	if fields := getLogFields(ctx); fields != nil {
+		fields.mu.Lock()
+		fields.sessionId = hex.EncodeToString(sessionId)
+		fields.mu.Unlock()
+	}
+}
+
+func SetLogTestId(ctx context.Context, testId string) {
+	if fields := getLogFields(ctx); fields != nil {
+		fields.mu.Lock()
+		fields.testId = testId
+		fields.mu.Unlock()
+	}
+}
+
+// GetCorrelationId returns the correlation ID of the current FDO message exchange, or 0 if there is none
+func GetCorrelationId(ctx context.Context) uint {
+	fields := getLogFields(ctx)
+	if fields == nil {
+		return 0
+	}
+
+	fields.mu.Lock()
+	defer fields.mu.Unlock()
+
+	return fields.correlationId
+}
+
+// Logger returns the default logger annotated with the log fields of ctx
+func Logger(ctx context.Context) *slog.Logger {
+	fields := getLogFields(ctx)
+	if fields == nil {
+		return slog.Default()
+	}
+
+	return slog.Default().With(fields.attrs()...)
+}
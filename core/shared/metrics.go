@@ -0,0 +1,190 @@
+package fdoshared
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const METRICS_NAMESPACE string = "fdo"
+
+var (
+	metricsRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "requests_total",
+		Help:      "FDO messages received, by command and HTTP status code.",
+	}, []string{"cmd", "code"})
+
+	metricsErrorResponses = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "error_responses_total",
+		Help:      "FDO error messages sent, by error code and the command that caused them.",
+	}, []string{"error_code", "cmd"})
+
+	metricsHandlerDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: METRICS_NAMESPACE,
+		Name:      "handler_duration_seconds",
+		Help:      "FDO message handler latency, by command.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"cmd"})
+)
+
+type metricsWriter struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (h *metricsWriter) WriteHeader(statusCode int) {
+	if h.statusCode == 0 {
+		h.statusCode = statusCode
+	}
+
+	h.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (h *metricsWriter) Write(body []byte) (int, error) {
+	if h.statusCode == 0 {
+		h.statusCode = http.StatusOK
+	}
+
+	return h.ResponseWriter.Write(body)
+}
+
+// WithMetrics counts the requests to an FDO message handler and observes its latency
+func WithMetrics(cmd FdoCmd, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		mw := &metricsWriter{ResponseWriter: w}
+
+		handler(mw, r)
+
+		if mw.statusCode == 0 {
+			mw.statusCode = http.StatusOK
+		}
+
+		metricsRequests.WithLabelValues(cmd.ToString(), strconv.Itoa(mw.statusCode)).Inc()
+		metricsHandlerDuration.WithLabelValues(cmd.ToString()).Observe(time.Since(start).Seconds())
+	}
+}
+
+// InstrumentHandler wraps an FDO message handler with metrics, the Server-Timing header and a request log context
+func InstrumentHandler(cmd FdoCmd, handler http.HandlerFunc) http.HandlerFunc {
+	instrumentedHandler := WithMetrics(cmd, WithServerTiming(handler))
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		instrumentedHandler(w, r.WithContext(NewRequestLogContext(r.Context(), cmd)))
+	}
+}
+
+func countErrorResponse(errorCode FdoErrorCode, prevMsgId FdoCmd) {
+	metricsErrorResponses.WithLabelValues(strconv.FormatUint(uint64(errorCode), 10), prevMsgId.ToString()).Inc()
+}
+
+type metricsRegistryKey struct{}
+
+// WithMetricsRegistry returns a ctx whose servers register their gauges in registry, see RegisterGaugeFunc
+func WithMetricsRegistry(ctx context.Context, registry *prometheus.Registry) context.Context {
+	return context.WithValue(ctx, metricsRegistryKey{}, registry)
+}
+
+// MetricsHandler serves the process wide metrics together with the gauges of the servers registered in registry
+func MetricsHandler(registry *prometheus.Registry) http.Handler {
+	return promhttp.HandlerFor(prometheus.Gatherers{prometheus.DefaultGatherer, registry}, promhttp.HandlerOpts{})
+}
+
+// RegisterGaugeFunc registers a gauge that is evaluated on every scrape, in the registry set by WithMetricsRegistry.
+// Without a registry the gauge is not collected. Registering the same gauge twice in a registry fails
+func RegisterGaugeFunc(ctx context.Context, name string, help string, constLabels prometheus.Labels, valueFunc func() (int, error)) error {
+	registry, ok := ctx.Value(metricsRegistryKey{}).(*prometheus.Registry)
+	if !ok {
+		return nil
+	}
+
+	gauge := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace:   METRICS_NAMESPACE,
+		Name:        name,
+		Help:        help,
+		ConstLabels: constLabels,
+	}, func() float64 {
+		value, err := valueFunc()
+		if err != nil {
+			log.Printf("Failed to collect %s metric. %s", name, err.Error())
+			return 0
+		}
+
+		return float64(value)
+	})
+
+	err := registry.Register(gauge)
+	if err != nil {
+		return fmt.Errorf("Failed to register %s metric. %s", name, err.Error())
+	}
+
+	return nil
+}
+
+// activeSessionsSweepInterval is how often Touch removes the expired sessions, so that servers that are never scraped
+// do not keep them
+const activeSessionsSweepInterval = time.Minute
+
+// ActiveSessions counts the sessions a server started and did not end yet. A session that is never ended is dropped
+// SESSION_TTL after its last message, as it expires in the store. It is kept in memory, so scrapes never read the store
+type ActiveSessions struct {
+	mu        sync.Mutex
+	lastSeen  map[string]time.Time
+	lastSweep time.Time
+}
+
+func NewActiveSessions() *ActiveSessions {
+	return &ActiveSessions{
+		lastSeen:  map[string]time.Time{},
+		lastSweep: time.Now(),
+	}
+}
+
+// Touch counts a new session, or keeps counting an existing one for another SESSION_TTL
+func (h *ActiveSessions) Touch(sessionId []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(h.lastSweep) >= activeSessionsSweepInterval {
+		h.removeExpired(now)
+	}
+
+	h.lastSeen[string(sessionId)] = now
+}
+
+func (h *ActiveSessions) End(sessionId []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.lastSeen, string(sessionId))
+}
+
+func (h *ActiveSessions) Count() (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.removeExpired(time.Now())
+
+	return len(h.lastSeen), nil
+}
+
+func (h *ActiveSessions) removeExpired(now time.Time) {
+	for sessionId, lastSeen := range h.lastSeen {
+		if now.Sub(lastSeen) >= SESSION_TTL {
+			delete(h.lastSeen, sessionId)
+		}
+	}
+
+	h.lastSweep = now
+}
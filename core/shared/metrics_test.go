@@ -0,0 +1,71 @@
+package fdoshared
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestRegisterGaugeFuncPerRegistry(t *testing.T) {
+	valueFunc := func() (int, error) {
+		return 1, nil
+	}
+
+	// Servers of the same kind on two addresses, e.g. in tests, each register in their own registry
+	for i := 0; i < 2; i++ {
+		ctx := WithMetricsRegistry(context.Background(), prometheus.NewRegistry())
+
+		err := RegisterGaugeFunc(ctx, "test_gauge", "Test gauge.", nil, valueFunc)
+		if err != nil {
+			t.Fatalf("Failed to register gauge. %s", err.Error())
+		}
+
+		err = RegisterGaugeFunc(ctx, "test_gauge", "Test gauge.", nil, valueFunc)
+		if err == nil {
+			t.Errorf("Expected registering the same gauge twice in a registry to fail")
+		}
+	}
+
+	err := RegisterGaugeFunc(context.Background(), "test_gauge", "Test gauge.", nil, valueFunc)
+	if err != nil {
+		t.Errorf("Expected no error without a registry. Got %s", err.Error())
+	}
+}
+
+func TestActiveSessions(t *testing.T) {
+	activeSessions := NewActiveSessions()
+
+	activeSessions.Touch([]byte("a"))
+	activeSessions.Touch([]byte("b"))
+	activeSessions.Touch([]byte("a"))
+
+	count, _ := activeSessions.Count()
+	if count != 2 {
+		t.Errorf("Expected 2 active sessions. Got %d", count)
+	}
+
+	activeSessions.End([]byte("a"))
+
+	count, _ = activeSessions.Count()
+	if count != 1 {
+		t.Errorf("Expected 1 active session. Got %d", count)
+	}
+
+	activeSessions.lastSeen["b"] = time.Now().Add(-SESSION_TTL)
+
+	count, _ = activeSessions.Count()
+	if count != 0 {
+		t.Errorf("Expected the expired session to be dropped. Got %d", count)
+	}
+
+	// Without scrapes, new sessions remove the expired ones
+	activeSessions.lastSeen["c"] = time.Now().Add(-SESSION_TTL)
+	activeSessions.lastSweep = time.Now().Add(-activeSessionsSweepInterval)
+	activeSessions.Touch([]byte("d"))
+
+	if _, ok := activeSessions.lastSeen["c"]; ok || len(activeSessions.lastSeen) != 1 {
+		t.Errorf("Expected Touch to remove the expired session. Got %d sessions", len(activeSessions.lastSeen))
+	}
+}
@@ -131,11 +131,14 @@ func (h FdoGuidList) GetRandomSelection(size int) FdoGuidList {
 
 type FdoSeedIDs map[SgType]FdoGuidList
 
-func (h *FdoSeedIDs) GetTestBatch(size int) FdoSeedIDs {
+// GetTestBatch returns a random batch of up to size guids for each of the given signature types
+func (h *FdoSeedIDs) GetTestBatch(sgTypes []SgType, size int) FdoSeedIDs {
 	var newTestBatch FdoSeedIDs = FdoSeedIDs{}
 
-	for k, v := range *h {
-		newTestBatch[k] = v.GetRandomBatch(size)
+	for _, sgType := range sgTypes {
+		if guids, ok := (*h)[sgType]; ok {
+			newTestBatch[sgType] = guids.GetRandomBatch(size)
+		}
 	}
 
 	return newTestBatch
@@ -157,6 +160,17 @@ func (h *FdoSeedIDs) GetRandomTestGuid() FdoGuid {
 	return randomGuids[randLoc]
 }
 
+// GetUniqueTestGuids returns up to size distinct guids across all signature types
+func (h *FdoSeedIDs) GetUniqueTestGuids(size int) FdoGuidList {
+	var allGuids FdoGuidList = FdoGuidList{}
+
+	for _, v := range *h {
+		allGuids = append(allGuids, v...)
+	}
+
+	return allGuids.GetRandomSelection(size)
+}
+
 func (h *FdoSeedIDs) GetRandomTestGuidForSgType(sgType SgType) FdoGuid {
 	sh := *h
 	var randomGuids []FdoGuid = sh[sgType]
@@ -397,3 +411,27 @@ func GenerateEatGuid(fdoGuid FdoGuid) [17]byte {
 
 	return result
 }
+
+// TOAddrEntryToUrl is the reverse of UrlToTOAddrEntry. Only HTTP and HTTPS entries are supported
+func TOAddrEntryToUrl(entry RVTO2AddrEntry) (string, error) {
+	scheme := ""
+	switch entry.RVProtocol {
+	case ProtHTTP:
+		scheme = "http"
+	case ProtHTTPS:
+		scheme = "https"
+	default:
+		return "", fmt.Errorf("unsupported transport protocol %d", entry.RVProtocol)
+	}
+
+	host := ""
+	if entry.RVDNS != nil {
+		host = *entry.RVDNS
+	} else if entry.RVIP != nil {
+		host = entry.RVIP.String()
+	} else {
+		return "", errors.New("address entry has neither DNS nor IP")
+	}
+
+	return scheme + "://" + net.JoinHostPort(host, strconv.Itoa(int(entry.RVPort))), nil
+}
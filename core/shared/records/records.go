@@ -0,0 +1,377 @@
+package records
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/storage"
+)
+
+// RECORD_ENVELOPE_TAG marks a versioned record, "fdor" in ASCII. Records stored before versioning was added
+// have no envelope and are treated as version 0 of their type
+const RECORD_ENVELOPE_TAG uint64 = 0x66646f72
+
+// ErrUnexpectedRecord is returned by migrations when a record does not have the expected shape
+var ErrUnexpectedRecord = errors.New("Unexpected record structure")
+
+type RecordType string
+
+// Migration upgrades the CBOR encoding of a record by one version
+type Migration func(record cbor.RawMessage) (cbor.RawMessage, error)
+
+type Schema struct {
+	Type RecordType
+
+	// Key prefixes of the stored records, used by MigrateStore. Leave empty for short lived records, they are only migrated on read
+	Prefixes        [][]byte
+	ExcludePrefixes [][]byte
+
+	// Migrations[i] upgrades version i to i+1. The current version is len(Migrations)
+	Migrations []Migration
+}
+
+func (h Schema) Version() int {
+	return len(h.Migrations)
+}
+
+func (h Schema) matches(key []byte) bool {
+	for _, prefix := range h.ExcludePrefixes {
+		if bytes.HasPrefix(key, prefix) {
+			return false
+		}
+	}
+
+	for _, prefix := range h.Prefixes {
+		if bytes.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+var (
+	schemasMu sync.RWMutex
+	schemas   = map[RecordType]Schema{}
+)
+
+// Register adds the schema of a record type. Every change to a stored struct must add a migration to its schema
+func Register(schema Schema) {
+	schemasMu.Lock()
+	defer schemasMu.Unlock()
+
+	if _, ok := schemas[schema.Type]; ok {
+		panic("records: schema registered twice for " + schema.Type)
+	}
+
+	schemas[schema.Type] = schema
+}
+
+func getSchema(recordType RecordType) (Schema, error) {
+	schemasMu.RLock()
+	defer schemasMu.RUnlock()
+
+	schema, ok := schemas[recordType]
+	if !ok {
+		return Schema{}, fmt.Errorf("No schema registered for record type %s", recordType)
+	}
+
+	return schema, nil
+}
+
+type envelope struct {
+	_       struct{} `cbor:",toarray"`
+	Type    RecordType
+	Version int
+	Record  cbor.RawMessage
+}
+
+// Marshal encodes v as the current version of the record type
+func Marshal(recordType RecordType, v interface{}) ([]byte, error) {
+	schema, err := getSchema(recordType)
+	if err != nil {
+		return nil, err
+	}
+
+	recordBytes, err := fdoshared.CborCust.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return wrap(schema, recordBytes)
+}
+
+func wrap(schema Schema, record cbor.RawMessage) ([]byte, error) {
+	envelopeBytes, err := fdoshared.CborCust.Marshal(envelope{
+		Type:    schema.Type,
+		Version: schema.Version(),
+		Record:  record,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return fdoshared.CborCust.Marshal(cbor.RawTag{
+		Number:  RECORD_ENVELOPE_TAG,
+		Content: envelopeBytes,
+	})
+}
+
+func isEnveloped(data []byte) bool {
+	// Major type 6 is a tag
+	return len(data) > 0 && data[0]>>5 == 6
+}
+
+func openEnvelope(recordType RecordType, data []byte) (int, cbor.RawMessage, error) {
+	if !isEnveloped(data) {
+		return 0, data, nil
+	}
+
+	var tag cbor.RawTag
+	err := fdoshared.CborCust.Unmarshal(data, &tag)
+	if err != nil {
+		return 0, nil, errors.New("Failed decoding record envelope. " + err.Error())
+	}
+
+	if tag.Number != RECORD_ENVELOPE_TAG {
+		return 0, data, nil
+	}
+
+	var recordEnvelope envelope
+	err = fdoshared.CborCust.Unmarshal(tag.Content, &recordEnvelope)
+	if err != nil {
+		return 0, nil, errors.New("Failed decoding record envelope. " + err.Error())
+	}
+
+	if recordEnvelope.Type != recordType {
+		return 0, nil, fmt.Errorf("Expected a %s record. Got %s", recordType, recordEnvelope.Type)
+	}
+
+	return recordEnvelope.Version, recordEnvelope.Record, nil
+}
+
+// upgrade returns the record migrated to the current version of its schema
+func upgrade(schema Schema, version int, record cbor.RawMessage) (cbor.RawMessage, error) {
+	if version > schema.Version() {
+		return nil, fmt.Errorf("%s record version %d was written by a newer version of the tools. Latest supported is %d", schema.Type, version, schema.Version())
+	}
+
+	var err error
+	for ; version < schema.Version(); version++ {
+		record, err = schema.Migrations[version](record)
+		if err != nil {
+			return nil, fmt.Errorf("Failed migrating %s record from version %d. %s", schema.Type, version, err.Error())
+		}
+	}
+
+	return record, nil
+}
+
+// Unmarshal decodes a stored record into v, migrating it first if it was stored by an older version
+func Unmarshal(recordType RecordType, data []byte, v interface{}) error {
+	schema, err := getSchema(recordType)
+	if err != nil {
+		return err
+	}
+
+	version, record, err := openEnvelope(recordType, data)
+	if err != nil {
+		return err
+	}
+
+	record, err = upgrade(schema, version, record)
+	if err != nil {
+		return err
+	}
+
+	return fdoshared.CborCust.Unmarshal(record, v)
+}
+
+// CheckVersion returns an error if data is a record of an unknown type or of a version newer than this build supports.
+// Unversioned records are accepted, they are migrated on read
+func CheckVersion(data []byte) error {
+	if !isEnveloped(data) {
+		return nil
+	}
+
+	var tag cbor.RawTag
+	err := fdoshared.CborCust.Unmarshal(data, &tag)
+	if err != nil || tag.Number != RECORD_ENVELOPE_TAG {
+		return nil
+	}
+
+	var recordEnvelope envelope
+	err = fdoshared.CborCust.Unmarshal(tag.Content, &recordEnvelope)
+	if err != nil {
+		return errors.New("Failed decoding record envelope. " + err.Error())
+	}
+
+	schema, err := getSchema(recordEnvelope.Type)
+	if err != nil {
+		return err
+	}
+
+	if recordEnvelope.Version > schema.Version() {
+		return fmt.Errorf("%s record version %d was written by a newer version of the tools. Latest supported is %d", schema.Type, recordEnvelope.Version, schema.Version())
+	}
+
+	return nil
+}
+
+// Records are rewritten in batches, to stay below the transaction size limits
+const migrationBatchSize int = 100
+
+// MigrateStore rewrites all stored records older than their schema. Returns the number of migrated records
+func MigrateStore(store storage.Store) (int, error) {
+	schemasMu.RLock()
+	var pending []Schema
+	for _, schema := range schemas {
+		// Records of version 0 schemas never need rewriting
+		if schema.Version() > 0 {
+			pending = append(pending, schema)
+		}
+	}
+	schemasMu.RUnlock()
+
+	migrated := 0
+	for _, schema := range pending {
+		var outdatedKeys [][]byte
+		err := store.View(func(txn storage.Txn) error {
+			for _, prefix := range schema.Prefixes {
+				err := txn.Iterate(prefix, func(key []byte, value []byte) error {
+					if !schema.matches(key) {
+						return nil
+					}
+
+					version, _, err := openEnvelope(schema.Type, value)
+					if err != nil {
+						return fmt.Errorf("%s: %s", hex.EncodeToString(key), err.Error())
+					}
+
+					if version != schema.Version() {
+						outdatedKeys = append(outdatedKeys, key)
+					}
+
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+		if err != nil {
+			return migrated, fmt.Errorf("Failed reading %s records. %s", schema.Type, err.Error())
+		}
+
+		for start := 0; start < len(outdatedKeys); start += migrationBatchSize {
+			batch := outdatedKeys[start:min(start+migrationBatchSize, len(outdatedKeys))]
+
+			err = store.Update(func(txn storage.Txn) error {
+				for _, key := range batch {
+					err := migrateEntry(txn, schema, key)
+					if err != nil {
+						return fmt.Errorf("%s: %s", hex.EncodeToString(key), err.Error())
+					}
+				}
+
+				return nil
+			})
+			if err != nil {
+				return migrated, fmt.Errorf("Failed migrating %s records. %s", schema.Type, err.Error())
+			}
+
+			migrated += len(batch)
+		}
+	}
+
+	return migrated, nil
+}
+
+func migrateEntry(txn storage.Txn, schema Schema, key []byte) error {
+	value, err := txn.Get(key)
+	if errors.Is(err, storage.ErrKeyNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	expiresAt, err := txn.ExpiresAt(key)
+	if err != nil {
+		return err
+	}
+
+	version, record, err := openEnvelope(schema.Type, value)
+	if err != nil {
+		return err
+	}
+
+	record, err = upgrade(schema, version, record)
+	if err != nil {
+		return err
+	}
+
+	newValue, err := wrap(schema, record)
+	if err != nil {
+		return err
+	}
+
+	if expiresAt.IsZero() {
+		return txn.Set(key, newValue)
+	}
+
+	return txn.SetWithTTL(key, newValue, time.Until(expiresAt))
+}
+
+/* ----- Migration helpers ----- */
+
+// DecodeArray splits a CBOR array, such as a toarray struct, into its encoded elements
+func DecodeArray(data cbor.RawMessage) ([]cbor.RawMessage, error) {
+	var elements []cbor.RawMessage
+	err := fdoshared.CborCust.Unmarshal(data, &elements)
+	return elements, err
+}
+
+// DecodeMap splits a CBOR map with text keys into its encoded values
+func DecodeMap(data cbor.RawMessage) (map[string]cbor.RawMessage, error) {
+	var entries map[string]cbor.RawMessage
+	err := fdoshared.CborCust.Unmarshal(data, &entries)
+	return entries, err
+}
+
+// Encode returns the CBOR encoding of v, for use in migrations
+func Encode(v interface{}) (cbor.RawMessage, error) {
+	return fdoshared.CborCust.Marshal(v)
+}
+
+// PadArray appends the encoded defaults for the fields missing at the end of a toarray struct of length fields
+func PadArray(data cbor.RawMessage, length int, defaults ...interface{}) (cbor.RawMessage, error) {
+	elements, err := DecodeArray(data)
+	if err != nil {
+		return nil, err
+	}
+
+	missing := length - len(elements)
+	if missing < 0 || missing > len(defaults) {
+		return nil, fmt.Errorf("Unexpected array length %d. Expected %d to %d", len(elements), length-len(defaults), length)
+	}
+
+	for _, defaultValue := range defaults[len(defaults)-missing:] {
+		encodedDefault, err := Encode(defaultValue)
+		if err != nil {
+			return nil, err
+		}
+
+		elements = append(elements, encodedDefault)
+	}
+
+	return Encode(elements)
+}
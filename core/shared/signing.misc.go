@@ -117,6 +117,13 @@ const (
 	COSEKEY FdoPkEnc = 3
 )
 
+var FdoPkEncNames map[FdoPkEnc]string = map[FdoPkEnc]string{
+	Crypto:  "Crypto",
+	X509:    "X509",
+	X5CHAIN: "X5CHAIN",
+	COSEKEY: "COSEKEY",
+}
+
 var FdoPkEnc_List []FdoPkEnc = []FdoPkEnc{
 	// Crypto, // TODO: EPID
 	X509,
@@ -171,6 +178,15 @@ const (
 	StEPID11    SgType = 91
 )
 
+var SgTypeNames map[SgType]string = map[SgType]string{
+	StSECP256R1: "SECP256R1",
+	StSECP384R1: "SECP384R1",
+	StRSA2048:   "RSA2048",
+	StRSA3072:   "RSA3072",
+	StEPID10:    "EPID10",
+	StEPID11:    "EPID11",
+}
+
 var SgTypeList []SgType = []SgType{
 	StSECP256R1,
 	StSECP384R1,
@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"errors"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+type BadgerStore struct {
+	db *badger.DB
+}
+
+func NewBadgerStore(db *badger.DB) *BadgerStore {
+	return &BadgerStore{
+		db: db,
+	}
+}
+
+func OpenBadgerStore(path string) (*BadgerStore, error) {
+	options := badger.DefaultOptions(path)
+	options.Logger = nil
+
+	db, err := badger.Open(options)
+	if err != nil {
+		return nil, errors.New("Error opening Badger DB. " + err.Error())
+	}
+
+	return NewBadgerStore(db), nil
+}
+
+func (h *BadgerStore) NewTransaction(update bool) Txn {
+	return &badgerTxn{
+		txn: h.db.NewTransaction(update),
+	}
+}
+
+func (h *BadgerStore) View(fn func(txn Txn) error) error {
+	return view(h, fn)
+}
+
+func (h *BadgerStore) Update(fn func(txn Txn) error) error {
+	return update(h, fn)
+}
+
+func (h *BadgerStore) Close() error {
+	return h.db.Close()
+}
+
+type badgerTxn struct {
+	txn *badger.Txn
+}
+
+func (h *badgerTxn) Get(key []byte) ([]byte, error) {
+	item, err := h.txn.Get(key)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, ErrKeyNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return item.ValueCopy(nil)
+}
+
+func (h *badgerTxn) Set(key []byte, value []byte) error {
+	return h.txn.Set(key, value)
+}
+
+func (h *badgerTxn) SetWithTTL(key []byte, value []byte, ttl time.Duration) error {
+	return h.txn.SetEntry(badger.NewEntry(key, value).WithTTL(ttl))
+}
+
+func (h *badgerTxn) ExpiresAt(key []byte) (time.Time, error) {
+	item, err := h.txn.Get(key)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return time.Time{}, ErrKeyNotFound
+	} else if err != nil {
+		return time.Time{}, err
+	}
+
+	if item.ExpiresAt() == 0 {
+		return time.Time{}, nil
+	}
+
+	return time.Unix(int64(item.ExpiresAt()), 0), nil
+}
+
+func (h *badgerTxn) Delete(key []byte) error {
+	return h.txn.Delete(key)
+}
+
+func (h *badgerTxn) Iterate(prefix []byte, fn func(key []byte, value []byte) error) error {
+	iter := h.txn.NewIterator(badger.IteratorOptions{
+		Prefix:         prefix,
+		PrefetchValues: true,
+		PrefetchSize:   100,
+	})
+	defer iter.Close()
+
+	for iter.Rewind(); iter.Valid(); iter.Next() {
+		item := iter.Item()
+
+		value, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+
+		err = fn(item.KeyCopy(nil), value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (h *badgerTxn) Commit() error {
+	err := h.txn.Commit()
+	if errors.Is(err, badger.ErrConflict) {
+		return ErrConflict
+	}
+
+	return err
+}
+
+func (h *badgerTxn) Discard() {
+	h.txn.Discard()
+}
@@ -0,0 +1,32 @@
+package storage
+
+import (
+	"sync"
+	"time"
+)
+
+// VirtualClock is a clock that only moves when advanced. It lets tests expire entries without waiting, see NewMemoryStoreWithClock
+type VirtualClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func NewVirtualClock() *VirtualClock {
+	return &VirtualClock{
+		now: time.Now(),
+	}
+}
+
+func (h *VirtualClock) Now() time.Time {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.now
+}
+
+func (h *VirtualClock) Advance(d time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.now = h.now.Add(d)
+}
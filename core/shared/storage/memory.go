@@ -0,0 +1,303 @@
+package storage
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type memoryEntry struct {
+	value     []byte
+	expiresAt time.Time
+	version   uint64
+}
+
+func (h *memoryEntry) isExpired(now time.Time) bool {
+	return !h.expiresAt.IsZero() && !now.Before(h.expiresAt)
+}
+
+// Expired entries are purged every memoryPurgeInterval commits
+const memoryPurgeInterval uint64 = 1024
+
+// MemoryStore keeps all entries in memory. Transactions are optimistic, like Badger's: a commit fails
+// with ErrConflict if any key read by the transaction was changed since it was read.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	entries map[string]memoryEntry
+	version uint64
+	commits uint64
+	closed  bool
+
+	// now is the clock TTLs are checked against
+	now func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return NewMemoryStoreWithClock(time.Now)
+}
+
+// NewMemoryStoreWithClock returns a store that expires entries as per now, e.g. a VirtualClock
+func NewMemoryStoreWithClock(now func() time.Time) *MemoryStore {
+	return &MemoryStore{
+		entries: map[string]memoryEntry{},
+		now:     now,
+	}
+}
+
+func (h *MemoryStore) NewTransaction(update bool) Txn {
+	return &memoryTxn{
+		store:  h,
+		update: update,
+		reads:  map[string]uint64{},
+		writes: map[string]*memoryEntry{},
+	}
+}
+
+func (h *MemoryStore) View(fn func(txn Txn) error) error {
+	return view(h, fn)
+}
+
+func (h *MemoryStore) Update(fn func(txn Txn) error) error {
+	return update(h, fn)
+}
+
+func (h *MemoryStore) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	h.entries = map[string]memoryEntry{}
+
+	return nil
+}
+
+// getVersion returns the version of a live entry, or 0 if it does not exist. Must be called with the lock held
+func (h *MemoryStore) getVersion(key string, now time.Time) uint64 {
+	entry, ok := h.entries[key]
+	if !ok || entry.isExpired(now) {
+		return 0
+	}
+
+	return entry.version
+}
+
+// purgeExpired drops expired entries. Must be called with the lock held
+func (h *MemoryStore) purgeExpired(now time.Time) {
+	for key, entry := range h.entries {
+		if entry.isExpired(now) {
+			delete(h.entries, key)
+		}
+	}
+}
+
+type memoryTxn struct {
+	store     *MemoryStore
+	update    bool
+	discarded bool
+
+	// Versions of the keys read by the transaction, checked on commit
+	reads map[string]uint64
+
+	// Pending writes. A nil entry is a delete
+	writes map[string]*memoryEntry
+}
+
+var errTxnDiscarded = errors.New("Transaction has been discarded")
+var errTxnReadOnly = errors.New("No sets or deletes are allowed in a read-only transaction")
+
+func (h *memoryTxn) Get(key []byte) ([]byte, error) {
+	if h.discarded {
+		return nil, errTxnDiscarded
+	}
+
+	keyString := string(key)
+	if pending, ok := h.writes[keyString]; ok {
+		if pending == nil || pending.isExpired(h.store.now()) {
+			return nil, ErrKeyNotFound
+		}
+
+		return append([]byte{}, pending.value...), nil
+	}
+
+	h.store.mu.RLock()
+	defer h.store.mu.RUnlock()
+
+	now := h.store.now()
+	h.reads[keyString] = h.store.getVersion(keyString, now)
+
+	entry, ok := h.store.entries[keyString]
+	if !ok || entry.isExpired(now) {
+		return nil, ErrKeyNotFound
+	}
+
+	return append([]byte{}, entry.value...), nil
+}
+
+func (h *memoryTxn) set(key []byte, value []byte, ttl time.Duration) error {
+	if h.discarded {
+		return errTxnDiscarded
+	}
+
+	if !h.update {
+		return errTxnReadOnly
+	}
+
+	entry := &memoryEntry{
+		value: append([]byte{}, value...),
+	}
+
+	if ttl > 0 {
+		entry.expiresAt = h.store.now().Add(ttl)
+	}
+
+	h.writes[string(key)] = entry
+
+	return nil
+}
+
+func (h *memoryTxn) Set(key []byte, value []byte) error {
+	return h.set(key, value, 0)
+}
+
+func (h *memoryTxn) SetWithTTL(key []byte, value []byte, ttl time.Duration) error {
+	return h.set(key, value, ttl)
+}
+
+func (h *memoryTxn) ExpiresAt(key []byte) (time.Time, error) {
+	if h.discarded {
+		return time.Time{}, errTxnDiscarded
+	}
+
+	keyString := string(key)
+	if pending, ok := h.writes[keyString]; ok {
+		if pending == nil || pending.isExpired(h.store.now()) {
+			return time.Time{}, ErrKeyNotFound
+		}
+
+		return pending.expiresAt, nil
+	}
+
+	h.store.mu.RLock()
+	defer h.store.mu.RUnlock()
+
+	entry, ok := h.store.entries[keyString]
+	if !ok || entry.isExpired(h.store.now()) {
+		return time.Time{}, ErrKeyNotFound
+	}
+
+	return entry.expiresAt, nil
+}
+
+func (h *memoryTxn) Delete(key []byte) error {
+	if h.discarded {
+		return errTxnDiscarded
+	}
+
+	if !h.update {
+		return errTxnReadOnly
+	}
+
+	h.writes[string(key)] = nil
+
+	return nil
+}
+
+func (h *memoryTxn) Iterate(prefix []byte, fn func(key []byte, value []byte) error) error {
+	if h.discarded {
+		return errTxnDiscarded
+	}
+
+	prefixString := string(prefix)
+	now := h.store.now()
+	snapshot := map[string][]byte{}
+
+	h.store.mu.RLock()
+	for key, entry := range h.store.entries {
+		if !strings.HasPrefix(key, prefixString) {
+			continue
+		}
+
+		h.reads[key] = h.store.getVersion(key, now)
+		if !entry.isExpired(now) {
+			snapshot[key] = entry.value
+		}
+	}
+	h.store.mu.RUnlock()
+
+	for key, pending := range h.writes {
+		if !strings.HasPrefix(key, prefixString) {
+			continue
+		}
+
+		if pending == nil || pending.isExpired(now) {
+			delete(snapshot, key)
+		} else {
+			snapshot[key] = pending.value
+		}
+	}
+
+	keys := make([]string, 0, len(snapshot))
+	for key := range snapshot {
+		keys = append(keys, key)
+	}
+
+	// Go strings compare byte-wise, same as Badger keys
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		err := fn([]byte(key), append([]byte{}, snapshot[key]...))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (h *memoryTxn) Commit() error {
+	if h.discarded {
+		return errTxnDiscarded
+	}
+
+	if !h.update || len(h.writes) == 0 {
+		return nil
+	}
+
+	h.store.mu.Lock()
+	defer h.store.mu.Unlock()
+
+	if h.store.closed {
+		return errors.New("Store is closed")
+	}
+
+	now := h.store.now()
+	for key, version := range h.reads {
+		if h.store.getVersion(key, now) != version {
+			return ErrConflict
+		}
+	}
+
+	for key, pending := range h.writes {
+		if pending == nil {
+			delete(h.store.entries, key)
+			continue
+		}
+
+		h.store.version++
+		pending.version = h.store.version
+		h.store.entries[key] = *pending
+	}
+
+	h.store.commits++
+	if h.store.commits%memoryPurgeInterval == 0 {
+		h.store.purgeExpired(now)
+	}
+
+	return nil
+}
+
+func (h *memoryTxn) Discard() {
+	h.discarded = true
+}
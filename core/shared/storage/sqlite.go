@@ -0,0 +1,233 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const sqliteSchema string = `CREATE TABLE IF NOT EXISTS kv (
+	key        BLOB PRIMARY KEY,
+	value      BLOB NOT NULL,
+	expires_at INTEGER
+) WITHOUT ROWID`
+
+// SQLiteStore keeps the entries in a single SQLite table, so several processes can share one database file.
+// Read-write transactions take the write lock when they begin, so they never conflict on commit.
+type SQLiteStore struct {
+	readDB  *sql.DB
+	writeDB *sql.DB
+}
+
+func OpenSQLiteStore(path string) (*SQLiteStore, error) {
+	dsn := fmt.Sprintf("file:%s?_pragma=busy_timeout(10000)&_pragma=journal_mode(WAL)", path)
+
+	writeDB, err := sql.Open("sqlite", dsn+"&_txlock=immediate")
+	if err != nil {
+		return nil, errors.New("Error opening SQLite DB. " + err.Error())
+	}
+
+	_, err = writeDB.Exec(sqliteSchema)
+	if err != nil {
+		writeDB.Close()
+		return nil, errors.New("Error creating SQLite schema. " + err.Error())
+	}
+
+	_, err = writeDB.Exec("DELETE FROM kv WHERE expires_at IS NOT NULL AND expires_at <= ?", time.Now().UnixNano())
+	if err != nil {
+		writeDB.Close()
+		return nil, errors.New("Error purging expired SQLite entries. " + err.Error())
+	}
+
+	readDB, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		writeDB.Close()
+		return nil, errors.New("Error opening SQLite DB. " + err.Error())
+	}
+
+	return &SQLiteStore{
+		readDB:  readDB,
+		writeDB: writeDB,
+	}, nil
+}
+
+func (h *SQLiteStore) NewTransaction(update bool) Txn {
+	db := h.readDB
+	if update {
+		db = h.writeDB
+	}
+
+	tx, err := db.Begin()
+
+	return &sqliteTxn{
+		tx:     tx,
+		err:    err,
+		update: update,
+	}
+}
+
+func (h *SQLiteStore) View(fn func(txn Txn) error) error {
+	return view(h, fn)
+}
+
+func (h *SQLiteStore) Update(fn func(txn Txn) error) error {
+	return update(h, fn)
+}
+
+func (h *SQLiteStore) Close() error {
+	readErr := h.readDB.Close()
+	writeErr := h.writeDB.Close()
+
+	return errors.Join(readErr, writeErr)
+}
+
+type sqliteTxn struct {
+	tx     *sql.Tx
+	err    error // Error starting the transaction, returned by every call
+	update bool
+}
+
+func (h *sqliteTxn) Get(key []byte) ([]byte, error) {
+	if h.err != nil {
+		return nil, h.err
+	}
+
+	var value []byte
+	err := h.tx.QueryRow("SELECT value FROM kv WHERE key = ? AND (expires_at IS NULL OR expires_at > ?)", key, time.Now().UnixNano()).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrKeyNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+func (h *sqliteTxn) set(key []byte, value []byte, expiresAt *int64) error {
+	if h.err != nil {
+		return h.err
+	}
+
+	if !h.update {
+		return errTxnReadOnly
+	}
+
+	_, err := h.tx.Exec("INSERT INTO kv (key, value, expires_at) VALUES (?, ?, ?) ON CONFLICT (key) DO UPDATE SET value = excluded.value, expires_at = excluded.expires_at", key, value, expiresAt)
+
+	return err
+}
+
+func (h *sqliteTxn) Set(key []byte, value []byte) error {
+	return h.set(key, value, nil)
+}
+
+func (h *sqliteTxn) SetWithTTL(key []byte, value []byte, ttl time.Duration) error {
+	expiresAt := time.Now().Add(ttl).UnixNano()
+
+	return h.set(key, value, &expiresAt)
+}
+
+func (h *sqliteTxn) ExpiresAt(key []byte) (time.Time, error) {
+	if h.err != nil {
+		return time.Time{}, h.err
+	}
+
+	var expiresAt sql.NullInt64
+	err := h.tx.QueryRow("SELECT expires_at FROM kv WHERE key = ? AND (expires_at IS NULL OR expires_at > ?)", key, time.Now().UnixNano()).Scan(&expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, ErrKeyNotFound
+	} else if err != nil {
+		return time.Time{}, err
+	}
+
+	if !expiresAt.Valid {
+		return time.Time{}, nil
+	}
+
+	return time.Unix(0, expiresAt.Int64), nil
+}
+
+func (h *sqliteTxn) Delete(key []byte) error {
+	if h.err != nil {
+		return h.err
+	}
+
+	if !h.update {
+		return errTxnReadOnly
+	}
+
+	_, err := h.tx.Exec("DELETE FROM kv WHERE key = ?", key)
+
+	return err
+}
+
+func (h *sqliteTxn) Iterate(prefix []byte, fn func(key []byte, value []byte) error) error {
+	if h.err != nil {
+		return h.err
+	}
+
+	query := "SELECT key, value FROM kv WHERE key >= ? AND (expires_at IS NULL OR expires_at > ?)"
+	args := []any{append([]byte{}, prefix...), time.Now().UnixNano()}
+
+	if upperBound := prefixUpperBound(prefix); upperBound != nil {
+		query += " AND key < ?"
+		args = append(args, upperBound)
+	}
+
+	rows, err := h.tx.Query(query+" ORDER BY key", args...)
+	if err != nil {
+		return err
+	}
+
+	// Rows are read out before calling fn, so that fn can write to the same transaction
+	type kvEntry struct {
+		key   []byte
+		value []byte
+	}
+
+	var entries []kvEntry
+	for rows.Next() {
+		var entry kvEntry
+		err = rows.Scan(&entry.key, &entry.value)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		err = fn(entry.key, entry.value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (h *sqliteTxn) Commit() error {
+	if h.err != nil {
+		return h.err
+	}
+
+	return h.tx.Commit()
+}
+
+func (h *sqliteTxn) Discard() {
+	if h.err != nil {
+		return
+	}
+
+	// Rollback after a commit is a no-op returning sql.ErrTxDone
+	h.tx.Rollback()
+}
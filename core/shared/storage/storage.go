@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	BACKEND_BADGER string = "badger"
+	BACKEND_MEMORY string = "memory"
+	BACKEND_SQLITE string = "sqlite"
+)
+
+var (
+	ErrKeyNotFound = errors.New("Key not found")
+
+	// ErrConflict is returned on commit when a key read by the transaction was changed by a concurrent writer
+	ErrConflict = errors.New("Transaction conflict, please retry")
+)
+
+// Store is an ordered key-value store with transactions and per-entry TTL.
+// Keys are compared byte-wise, iteration always happens in key order.
+type Store interface {
+	// NewTransaction starts a transaction. It must always be discarded, committing is only needed for read-write transactions
+	NewTransaction(update bool) Txn
+
+	// View runs fn in a read-only transaction
+	View(fn func(txn Txn) error) error
+
+	// Update runs fn in a read-write transaction and commits it if fn succeeds
+	Update(fn func(txn Txn) error) error
+
+	Close() error
+}
+
+type Txn interface {
+	// Get returns a copy of the value stored under key, or ErrKeyNotFound
+	Get(key []byte) ([]byte, error)
+
+	Set(key []byte, value []byte) error
+
+	// SetWithTTL stores an entry that expires after ttl
+	SetWithTTL(key []byte, value []byte, ttl time.Duration) error
+
+	// ExpiresAt returns the expiry time of the entry, or a zero time if it never expires
+	ExpiresAt(key []byte) (time.Time, error)
+
+	Delete(key []byte) error
+
+	// Iterate calls fn for every entry with the given prefix, in key order. Entries may be deleted from fn
+	Iterate(prefix []byte, fn func(key []byte, value []byte) error) error
+
+	Commit() error
+	Discard()
+}
+
+func view(store Store, fn func(txn Txn) error) error {
+	txn := store.NewTransaction(false)
+	defer txn.Discard()
+
+	return fn(txn)
+}
+
+func update(store Store, fn func(txn Txn) error) error {
+	txn := store.NewTransaction(true)
+	defer txn.Discard()
+
+	err := fn(txn)
+	if err != nil {
+		return err
+	}
+
+	return txn.Commit()
+}
+
+// Open opens the store of the given backend. The path is ignored by the in-memory backend
+func Open(backend string, path string) (Store, error) {
+	switch backend {
+	case "", BACKEND_BADGER:
+		return OpenBadgerStore(path)
+	case BACKEND_MEMORY:
+		return NewMemoryStore(), nil
+	case BACKEND_SQLITE:
+		return OpenSQLiteStore(path)
+	default:
+		return nil, fmt.Errorf("Unknown storage backend \"%s\". Expected %s, %s or %s", backend, BACKEND_BADGER, BACKEND_MEMORY, BACKEND_SQLITE)
+	}
+}
+
+// prefixUpperBound returns the smallest key greater than all keys with the given prefix, or nil if there is none
+func prefixUpperBound(prefix []byte) []byte {
+	upperBound := append([]byte{}, prefix...)
+	for i := len(upperBound) - 1; i >= 0; i-- {
+		if upperBound[i] < 0xff {
+			upperBound[i]++
+			return upperBound[:i+1]
+		}
+	}
+
+	return nil
+}
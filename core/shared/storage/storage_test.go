@@ -0,0 +1,220 @@
+package storage
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+func testStores(t *testing.T) map[string]Store {
+	badgerDb, err := badger.Open(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+	if err != nil {
+		t.Fatalf("Failed to open badger. %s", err.Error())
+	}
+
+	sqliteStore, err := OpenSQLiteStore(filepath.Join(t.TempDir(), "test.sqlite"))
+	if err != nil {
+		t.Fatalf("Failed to open sqlite. %s", err.Error())
+	}
+
+	stores := map[string]Store{
+		BACKEND_BADGER: NewBadgerStore(badgerDb),
+		BACKEND_MEMORY: NewMemoryStore(),
+		BACKEND_SQLITE: sqliteStore,
+	}
+
+	t.Cleanup(func() {
+		for _, store := range stores {
+			store.Close()
+		}
+	})
+
+	return stores
+}
+
+func TestStoreGetSetDelete(t *testing.T) {
+	for backend, store := range testStores(t) {
+		err := store.Update(func(txn Txn) error {
+			return txn.Set([]byte("key"), []byte("value"))
+		})
+		if err != nil {
+			t.Fatalf("%s: failed to set. %s", backend, err.Error())
+		}
+
+		var value []byte
+		err = store.View(func(txn Txn) error {
+			value, err = txn.Get([]byte("key"))
+			return err
+		})
+		if err != nil || string(value) != "value" {
+			t.Errorf("%s: expected \"value\". Got \"%s\", %v", backend, value, err)
+		}
+
+		err = store.Update(func(txn Txn) error {
+			return txn.Delete([]byte("key"))
+		})
+		if err != nil {
+			t.Fatalf("%s: failed to delete. %s", backend, err.Error())
+		}
+
+		err = store.View(func(txn Txn) error {
+			_, err := txn.Get([]byte("key"))
+			return err
+		})
+		if !errors.Is(err, ErrKeyNotFound) {
+			t.Errorf("%s: expected ErrKeyNotFound. Got %v", backend, err)
+		}
+	}
+}
+
+func TestStoreIteratePrefix(t *testing.T) {
+	for backend, store := range testStores(t) {
+		err := store.Update(func(txn Txn) error {
+			for _, key := range []string{"b-2", "a-1", "b-1", "b-\xff", "c-1"} {
+				err := txn.Set([]byte(key), []byte(key))
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%s: failed to set. %s", backend, err.Error())
+		}
+
+		var keys []string
+		err = store.View(func(txn Txn) error {
+			return txn.Iterate([]byte("b-"), func(key []byte, value []byte) error {
+				keys = append(keys, string(key))
+				return nil
+			})
+		})
+		if err != nil {
+			t.Fatalf("%s: failed to iterate. %s", backend, err.Error())
+		}
+
+		expected := []string{"b-1", "b-2", "b-\xff"}
+		if len(keys) != len(expected) {
+			t.Fatalf("%s: expected keys %q. Got %q", backend, expected, keys)
+		}
+
+		for i := range expected {
+			if keys[i] != expected[i] {
+				t.Errorf("%s: expected keys %q. Got %q", backend, expected, keys)
+				break
+			}
+		}
+
+		// Deleting from the iteration callback
+		err = store.Update(func(txn Txn) error {
+			return txn.Iterate([]byte("b-"), func(key []byte, value []byte) error {
+				return txn.Delete(key)
+			})
+		})
+		if err != nil {
+			t.Fatalf("%s: failed to delete while iterating. %s", backend, err.Error())
+		}
+
+		count := 0
+		store.View(func(txn Txn) error {
+			return txn.Iterate(nil, func(key []byte, value []byte) error {
+				count++
+				return nil
+			})
+		})
+		if count != 2 {
+			t.Errorf("%s: expected 2 entries left. Got %d", backend, count)
+		}
+	}
+}
+
+func TestStoreTTL(t *testing.T) {
+	stores := testStores(t)
+	for backend, store := range stores {
+		err := store.Update(func(txn Txn) error {
+			return txn.SetWithTTL([]byte("ttl"), []byte("value"), time.Second)
+		})
+		if err != nil {
+			t.Fatalf("%s: failed to set. %s", backend, err.Error())
+		}
+
+		var expiresAt time.Time
+		err = store.View(func(txn Txn) error {
+			expiresAt, err = txn.ExpiresAt([]byte("ttl"))
+			return err
+		})
+		if err != nil || expiresAt.IsZero() || time.Until(expiresAt) > 2*time.Second {
+			t.Errorf("%s: unexpected expiry %s, %v", backend, expiresAt, err)
+		}
+	}
+
+	// Badger expiry has a one second resolution
+	time.Sleep(2 * time.Second)
+
+	for backend, store := range stores {
+		err := store.View(func(txn Txn) error {
+			_, err := txn.Get([]byte("ttl"))
+			return err
+		})
+		if !errors.Is(err, ErrKeyNotFound) {
+			t.Errorf("%s: expected entry to expire. Got %v", backend, err)
+		}
+	}
+}
+
+func TestMemoryStoreVirtualClock(t *testing.T) {
+	clock := NewVirtualClock()
+	store := NewMemoryStoreWithClock(clock.Now)
+
+	err := store.Update(func(txn Txn) error {
+		return txn.SetWithTTL([]byte("ttl"), []byte("value"), time.Minute)
+	})
+	if err != nil {
+		t.Fatalf("Failed to set. %s", err.Error())
+	}
+
+	get := func() error {
+		return store.View(func(txn Txn) error {
+			_, err := txn.Get([]byte("ttl"))
+			return err
+		})
+	}
+
+	clock.Advance(59 * time.Second)
+	if err := get(); err != nil {
+		t.Errorf("Expected entry to be live. Got %v", err)
+	}
+
+	clock.Advance(time.Second)
+	if err := get(); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Expected entry to expire. Got %v", err)
+	}
+}
+
+func TestMemoryStoreConflict(t *testing.T) {
+	store := NewMemoryStore()
+
+	first := store.NewTransaction(true)
+	defer first.Discard()
+
+	second := store.NewTransaction(true)
+	defer second.Discard()
+
+	first.Get([]byte("counter"))
+	second.Get([]byte("counter"))
+
+	first.Set([]byte("counter"), []byte("1"))
+	second.Set([]byte("counter"), []byte("1"))
+
+	if err := first.Commit(); err != nil {
+		t.Fatalf("Expected first commit to succeed. %s", err.Error())
+	}
+
+	if err := second.Commit(); !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected ErrConflict. Got %v", err)
+	}
+}
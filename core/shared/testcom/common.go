@@ -1,10 +1,25 @@
 package testcom
 
+import (
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+)
+
 type FDOTestState struct {
-	_      struct{}  `cbor:",toarray"`
-	Passed bool      `json:"passed"`
-	Error  string    `json:"error"`
-	TestID FDOTestID `json:"testId"`
+	_        struct{}                  `cbor:",toarray"`
+	Passed   bool                      `json:"passed"`
+	Error    string                    `json:"error"`
+	TestID   FDOTestID                 `json:"testId"`
+	Timings  []fdoshared.MessageTiming `json:"timings,omitempty"`
+	Warnings []string                  `json:"warnings,omitempty"`
+}
+
+func (h FDOTestState) IsEmpty() bool {
+	return !h.Passed && h.Error == "" && h.TestID == "" && len(h.Timings) == 0 && len(h.Warnings) == 0
+}
+
+// PassedWithWarnings is true for a passed test that did not fully match the spec, see ECS_Warn
+func (h FDOTestState) PassedWithWarnings() bool {
+	return h.Passed && len(h.Warnings) > 0
 }
 
 func NewSuccessTestState(testId FDOTestID) FDOTestState {
@@ -14,6 +29,14 @@ func NewSuccessTestState(testId FDOTestID) FDOTestState {
 	}
 }
 
+func NewWarnTestState(testId FDOTestID, warnings []string) FDOTestState {
+	return FDOTestState{
+		Passed:   true,
+		TestID:   testId,
+		Warnings: warnings,
+	}
+}
+
 func NewFailTestState(testId FDOTestID, errorMsg string) FDOTestState {
 	return FDOTestState{
 		Passed: false,
@@ -21,3 +44,32 @@ func NewFailTestState(testId FDOTestID, errorMsg string) FDOTestState {
 		TestID: testId,
 	}
 }
+
+type RunSummary struct {
+	Passed      int         `json:"passed"`
+	Failed      int         `json:"failed"`
+	FailedTests []FDOTestID `json:"failedTests"`
+
+	// Included in Passed
+	PassedWithWarnings int `json:"passedWithWarnings"`
+}
+
+func NewRunSummary(testStates []FDOTestState) RunSummary {
+	summary := RunSummary{
+		FailedTests: []FDOTestID{},
+	}
+
+	for _, testState := range testStates {
+		if testState.Passed {
+			summary.Passed++
+			if testState.PassedWithWarnings() {
+				summary.PassedWithWarnings++
+			}
+		} else {
+			summary.Failed++
+			summary.FailedTests = append(summary.FailedTests, testState.TestID)
+		}
+	}
+
+	return summary
+}
@@ -7,20 +7,20 @@ import (
 	"log"
 	"time"
 
-	"github.com/dgraph-io/badger/v4"
-
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/records"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/storage"
 	listenertestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/listener"
 )
 
 type ListenerTestDB struct {
-	db               *badger.DB
+	db               storage.Store
 	prefix           []byte
 	mapperGuidPrefix []byte
 	ttl              int
 }
 
-func NewListenerTestDB(db *badger.DB) *ListenerTestDB {
+func NewListenerTestDB(db storage.Store) *ListenerTestDB {
 	return &ListenerTestDB{
 		db:               db,
 		prefix:           []byte("lstdb-"),
@@ -30,42 +30,48 @@ func NewListenerTestDB(db *badger.DB) *ListenerTestDB {
 }
 
 func (h *ListenerTestDB) getEntryId(entryUuid []byte) []byte {
-	return append(h.prefix, entryUuid...)
+	return append(append([]byte{}, h.prefix...), entryUuid...)
 }
 
 func (h *ListenerTestDB) getMappingEntryId(guid fdoshared.FdoGuid) []byte {
-	return append(h.mapperGuidPrefix, guid[:]...)
+	return append(append([]byte{}, h.mapperGuidPrefix...), guid[:]...)
 }
 
-func (h *ListenerTestDB) Save(reqListener listenertestsdeps.RequestListenerInst) error {
-	structBytes, err := fdoshared.CborCust.Marshal(reqListener)
-	if err != nil {
-		return errors.New("Failed to marshal listener entry." + err.Error())
+func (h *ListenerTestDB) getTxn(txn storage.Txn, entryUuid []byte) (*listenertestsdeps.RequestListenerInst, error) {
+	itemBytes, err := txn.Get(h.getEntryId(entryUuid))
+	if err != nil && errors.Is(err, storage.ErrKeyNotFound) {
+		return nil, fmt.Errorf("The rvte entry with id %s does not exist", hex.EncodeToString(h.getEntryId(entryUuid)))
+	} else if err != nil {
+		return nil, errors.New("Failed locating rvte entry." + err.Error())
 	}
 
-	dbtxn := h.db.NewTransaction(true)
-	defer dbtxn.Discard()
-
-	entry := badger.NewEntry(h.getEntryId(reqListener.Uuid), structBytes).WithTTL(time.Second * time.Duration(h.ttl))
-	err = dbtxn.SetEntry(entry)
+	var reqListInst listenertestsdeps.RequestListenerInst
+	err = records.Unmarshal(RT_ListenerInst, itemBytes, &reqListInst)
 	if err != nil {
-		return errors.New("Failed creating listener db entry instance." + err.Error())
+		return nil, errors.New("Failed cbor decoding rvte entry value." + err.Error())
 	}
 
-	err = dbtxn.Commit()
+	reqListInst.BindRunners()
+
+	return &reqListInst, nil
+}
+
+func (h *ListenerTestDB) setTxn(txn storage.Txn, reqListener listenertestsdeps.RequestListenerInst) error {
+	structBytes, err := records.Marshal(RT_ListenerInst, reqListener)
 	if err != nil {
-		return errors.New("Failed saving listener entry." + err.Error())
+		return errors.New("Failed to marshal listener entry." + err.Error())
 	}
 
-	if err := h.SaveMapping(reqListener.Guid, reqListener.Uuid); err != nil {
-		return errors.New("Failed saving listener entry guid mapping." + err.Error())
+	err = txn.SetWithTTL(h.getEntryId(reqListener.Uuid), structBytes, time.Second*time.Duration(h.ttl))
+	if err != nil {
+		return errors.New("Failed creating listener db entry instance." + err.Error())
 	}
 
 	return nil
 }
 
-func (h *ListenerTestDB) Update(reqListener *listenertestsdeps.RequestListenerInst) error {
-	structBytes, err := fdoshared.CborCust.Marshal(reqListener)
+func (h *ListenerTestDB) Save(reqListener listenertestsdeps.RequestListenerInst) error {
+	structBytes, err := records.Marshal(RT_ListenerInst, reqListener)
 	if err != nil {
 		return errors.New("Failed to marshal listener entry." + err.Error())
 	}
@@ -73,43 +79,71 @@ func (h *ListenerTestDB) Update(reqListener *listenertestsdeps.RequestListenerIn
 	dbtxn := h.db.NewTransaction(true)
 	defer dbtxn.Discard()
 
-	entry := badger.NewEntry(h.getEntryId(reqListener.Uuid), structBytes).WithTTL(time.Second * time.Duration(h.ttl))
-	err = dbtxn.SetEntry(entry)
+	err = dbtxn.SetWithTTL(h.getEntryId(reqListener.Uuid), structBytes, time.Second*time.Duration(h.ttl))
 	if err != nil {
-		return errors.New("Failed creating rvte db entry instance." + err.Error())
+		return errors.New("Failed creating listener db entry instance." + err.Error())
 	}
 
 	err = dbtxn.Commit()
 	if err != nil {
-		return errors.New("Failed saving rvte entry." + err.Error())
+		return errors.New("Failed saving listener entry." + err.Error())
+	}
+
+	if err := h.SaveMapping(reqListener.Guid, reqListener.Uuid); err != nil {
+		return errors.New("Failed saving listener entry guid mapping." + err.Error())
 	}
 
 	return nil
 }
 
-func (h *ListenerTestDB) Get(entryUuid []byte) (*listenertestsdeps.RequestListenerInst, error) {
-	dbtxn := h.db.NewTransaction(true)
-	defer dbtxn.Discard()
+// UpdateRunner applies update to the stored runner of the given protocol, and returns the saved runner. update runs
+// inside the transaction, and is re-run on fresh data when a concurrent writer conflicts, so it must only change the runner
+func (h *ListenerTestDB) UpdateRunner(entryUuid []byte, toProtocol fdoshared.FdoToProtocol, update func(runner *listenertestsdeps.RequestListenerRunnerInst) error) (*listenertestsdeps.RequestListenerRunnerInst, error) {
+	var savedRunner listenertestsdeps.RequestListenerRunnerInst
+	err := updateWithRetry(h.db, func(txn storage.Txn) error {
+		testInst, err := h.getTxn(txn, entryUuid)
+		if err != nil {
+			return err
+		}
 
-	item, err := dbtxn.Get(h.getEntryId(entryUuid))
-	if err != nil && errors.Is(err, badger.ErrKeyNotFound) {
-		return nil, fmt.Errorf("The rvte entry with id %s does not exist", hex.EncodeToString(h.getEntryId(entryUuid)))
-	} else if err != nil {
-		return nil, errors.New("Failed locating rvte entry." + err.Error())
-	}
+		runner, err := testInst.GetProtocolInst(int(toProtocol))
+		if err != nil {
+			return err
+		}
 
-	itemBytes, err := item.ValueCopy(nil)
+		err = update(runner)
+		if err != nil {
+			return err
+		}
+
+		savedRunner = *runner
+		return h.setTxn(txn, *testInst)
+	})
 	if err != nil {
-		return nil, errors.New("Failed reading rvte entry value." + err.Error())
+		return nil, errors.New("Failed saving listener runner." + err.Error())
 	}
 
-	var reqListInst listenertestsdeps.RequestListenerInst
-	err = fdoshared.CborCust.Unmarshal(itemBytes, &reqListInst)
+	// Only the last, committed, run of update is published
+	savedRunner.PublishEvents()
+
+	return &savedRunner, nil
+}
+
+// Get returns the listener instance with the timing summaries of its runs computed
+func (h *ListenerTestDB) Get(entryUuid []byte) (*listenertestsdeps.RequestListenerInst, error) {
+	var reqListInst *listenertestsdeps.RequestListenerInst
+
+	err := h.db.View(func(txn storage.Txn) error {
+		var err error
+		reqListInst, err = h.getTxn(txn, entryUuid)
+		return err
+	})
 	if err != nil {
-		return nil, errors.New("Failed cbor decoding rvte entry value." + err.Error())
+		return nil, err
 	}
 
-	return &reqListInst, nil
+	reqListInst.ComputeTimingSummaries()
+	return reqListInst, nil
 }
 
 func (h *ListenerTestDB) DeleteMapping(guid fdoshared.FdoGuid) error {
@@ -166,30 +200,19 @@ func (h *ListenerTestDB) Delete(entryUuid []byte) error {
 }
 
 func (h *ListenerTestDB) ResetDB() error {
-	dbtxn := h.db.NewTransaction(true)
-	defer dbtxn.Discard()
-
-	iterTxn := dbtxn.NewIterator(badger.IteratorOptions{})
-	defer iterTxn.Close()
-	for iterTxn.Rewind(); iterTxn.ValidForPrefix(h.prefix) || iterTxn.ValidForPrefix(h.mapperGuidPrefix); iterTxn.Next() {
-		item := iterTxn.Item()
-		k := item.Key()
-
-		log.Println("Deleting... " + hex.EncodeToString(k))
-
-		err := dbtxn.Delete(k)
-		if err != nil {
-			log.Println("Error creating delete mapping... " + hex.EncodeToString(k))
-		}
-
-		err = dbtxn.Commit()
-		if err != nil {
-			log.Println("Failed to commit delete mapping... " + hex.EncodeToString(k))
-		}
-
-	}
-
-	return nil
+	return h.db.Update(func(txn storage.Txn) error {
+		// The guid mappings share the entries prefix
+		return txn.Iterate(h.prefix, func(key []byte, value []byte) error {
+			log.Println("Deleting... " + hex.EncodeToString(key))
+
+			err := txn.Delete(key)
+			if err != nil {
+				log.Println("Error deleting entry... " + hex.EncodeToString(key))
+			}
+
+			return nil
+		})
+	})
 }
 
 /* ---- Test mgmt menthods ----- */
@@ -210,38 +233,9 @@ func (h *ListenerTestDB) GetMany(entriesIds [][]byte) (*[]listenertestsdeps.Requ
 }
 
 func (h *ListenerTestDB) RemoveTestRun(toProtocol fdoshared.FdoToProtocol, testInstId []byte, testRunId string) error {
-	testInst, err := h.Get(testInstId)
-	if err != nil {
-		return fmt.Errorf("%s test entry can not be found. %s", hex.EncodeToString(testInstId), err.Error())
-	}
-
-	var chosenReqListRunner listenertestsdeps.RequestListenerRunnerInst
-	switch toProtocol {
-	case fdoshared.To0:
-		chosenReqListRunner = testInst.To0
-	case fdoshared.To1:
-		chosenReqListRunner = testInst.To1
-	case fdoshared.To2:
-		chosenReqListRunner = testInst.To2
-	default:
-		return fmt.Errorf("Unknown FDO protocol %d", toProtocol)
-	}
-
-	err = chosenReqListRunner.RemoveTestRun(testRunId)
-	if err != nil {
-		return err
-	}
-
-	switch toProtocol {
-	case fdoshared.To0:
-		testInst.To0 = chosenReqListRunner
-	case fdoshared.To1:
-		testInst.To1 = chosenReqListRunner
-	case fdoshared.To2:
-		testInst.To2 = chosenReqListRunner
-	}
-
-	err = h.Save(*testInst)
+	_, err := h.UpdateRunner(testInstId, toProtocol, func(runner *listenertestsdeps.RequestListenerRunnerInst) error {
+		return runner.RemoveTestRun(testRunId)
+	})
 	if err != nil {
 		log.Printf("%s error saving test entry. %s", hex.EncodeToString(testInstId), err.Error())
 		return err
@@ -254,8 +248,7 @@ func (h *ListenerTestDB) SaveMapping(guid fdoshared.FdoGuid, uuid []byte) error
 	dbtxn := h.db.NewTransaction(true)
 	defer dbtxn.Discard()
 
-	entry := badger.NewEntry(h.getMappingEntryId(guid), uuid)
-	err := dbtxn.SetEntry(entry)
+	err := dbtxn.Set(h.getMappingEntryId(guid), uuid)
 	if err != nil {
 		return errors.New("Failed creating listener db mapping entry instance." + err.Error())
 	}
@@ -272,18 +265,13 @@ func (h *ListenerTestDB) GetMappingEntry(guid fdoshared.FdoGuid) ([]byte, error)
 	dbtxn := h.db.NewTransaction(true)
 	defer dbtxn.Discard()
 
-	item, err := dbtxn.Get(h.getMappingEntryId(guid))
-	if err != nil && errors.Is(err, badger.ErrKeyNotFound) {
+	itemBytes, err := dbtxn.Get(h.getMappingEntryId(guid))
+	if err != nil && errors.Is(err, storage.ErrKeyNotFound) {
 		return nil, fmt.Errorf("The mapping entry with id %s does not exist", hex.EncodeToString(h.getMappingEntryId(guid)))
 	} else if err != nil {
 		return nil, errors.New("Failed locating mapping entry." + err.Error())
 	}
 
-	itemBytes, err := item.ValueCopy(nil)
-	if err != nil {
-		return nil, errors.New("Failed reading mapping entry value." + err.Error())
-	}
-
 	return itemBytes, nil
 }
 
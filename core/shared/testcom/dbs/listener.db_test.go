@@ -0,0 +1,176 @@
+package dbs
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/storage"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/events"
+	listenertestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/listener"
+)
+
+func TestListenerTestDBParallelRunnerUpdates(t *testing.T) {
+	db := storage.NewMemoryStore()
+	defer db.Close()
+
+	listenerDB := NewListenerTestDB(db)
+
+	listenerInst := listenertestsdeps.NewDevice_RequestListenerInst(fdoshared.VoucherDBEntry{}, fdoshared.NewFdoGuid())
+	err := listenerDB.Save(listenerInst)
+	if err != nil {
+		t.Fatalf("Failed to save listener. %s", err.Error())
+	}
+
+	for _, toProtocol := range []fdoshared.FdoToProtocol{fdoshared.To1, fdoshared.To2} {
+		_, err = listenerDB.UpdateRunner(listenerInst.Uuid, toProtocol, func(runner *listenertestsdeps.RequestListenerRunnerInst) error {
+			runner.StartNewTestRun()
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Failed to start run. %s", err.Error())
+		}
+	}
+
+	resultsCount := 25
+
+	var wg sync.WaitGroup
+	for i := 0; i < resultsCount; i++ {
+		for _, toProtocol := range []fdoshared.FdoToProtocol{fdoshared.To1, fdoshared.To2} {
+			wg.Add(1)
+			go func(toProtocol fdoshared.FdoToProtocol) {
+				defer wg.Done()
+
+				_, err := listenerDB.UpdateRunner(listenerInst.Uuid, toProtocol, func(runner *listenertestsdeps.RequestListenerRunnerInst) error {
+					runner.LastTestID = testcom.FIDO_LISTENER_POSITIVE
+					runner.PushSuccess()
+					return nil
+				})
+				if err != nil {
+					t.Errorf("Failed to update runner. %s", err.Error())
+				}
+			}(toProtocol)
+		}
+	}
+	wg.Wait()
+
+	storedInst, err := listenerDB.Get(listenerInst.Uuid)
+	if err != nil {
+		t.Fatalf("Failed to get listener. %s", err.Error())
+	}
+
+	if len(storedInst.To1.CurrentTestRun.TestRuns) != resultsCount || len(storedInst.To2.CurrentTestRun.TestRuns) != resultsCount {
+		t.Errorf("Expected %d results per protocol. Got %d TO1 and %d TO2", resultsCount, len(storedInst.To1.CurrentTestRun.TestRuns), len(storedInst.To2.CurrentTestRun.TestRuns))
+	}
+}
+
+func TestListenerTestDBPublishesSavedResultsOnly(t *testing.T) {
+	db := storage.NewMemoryStore()
+	defer db.Close()
+
+	listenerDB := NewListenerTestDB(db)
+
+	listenerInst := listenertestsdeps.NewDevice_RequestListenerInst(fdoshared.VoucherDBEntry{}, fdoshared.NewFdoGuid())
+	err := listenerDB.Save(listenerInst)
+	if err != nil {
+		t.Fatalf("Failed to save listener. %s", err.Error())
+	}
+
+	sub := events.DefaultBroker.Subscribe([][]byte{listenerInst.Uuid})
+	defer events.DefaultBroker.Unsubscribe(sub)
+
+	pushSuccess := func(runner *listenertestsdeps.RequestListenerRunnerInst) {
+		runner.StartNewTestRun()
+		runner.LastTestID = testcom.FIDO_LISTENER_POSITIVE
+		runner.PushSuccess()
+	}
+
+	_, err = listenerDB.UpdateRunner(listenerInst.Uuid, fdoshared.To2, func(runner *listenertestsdeps.RequestListenerRunnerInst) error {
+		pushSuccess(runner)
+		return errors.New("not saved")
+	})
+	if err == nil {
+		t.Fatalf("Expected the update to fail")
+	}
+
+	// The first run conflicts with a concurrent writer, and is re-run
+	attempts := 0
+	_, err = listenerDB.UpdateRunner(listenerInst.Uuid, fdoshared.To2, func(runner *listenertestsdeps.RequestListenerRunnerInst) error {
+		attempts++
+		pushSuccess(runner)
+
+		if attempts == 1 {
+			_, err := listenerDB.UpdateRunner(listenerInst.Uuid, fdoshared.To1, func(runner *listenertestsdeps.RequestListenerRunnerInst) error {
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to update runner. %s", err.Error())
+	}
+
+	if attempts != 2 {
+		t.Fatalf("Expected the update to be re-run once. Got %d attempts", attempts)
+	}
+
+	if len(sub.Events) != 1 {
+		t.Fatalf("Expected one event. Got %d", len(sub.Events))
+	}
+
+	event := <-sub.Events
+	if event.Type != events.EventTestFinished || event.TestId != testcom.FIDO_LISTENER_POSITIVE {
+		t.Errorf("Unexpected event %+v", event)
+	}
+}
+
+func TestListenerTestDBRecordsTimings(t *testing.T) {
+	db := storage.NewMemoryStore()
+	defer db.Close()
+
+	listenerDB := NewListenerTestDB(db)
+
+	listenerInst := listenertestsdeps.NewDevice_RequestListenerInst(fdoshared.VoucherDBEntry{}, fdoshared.NewFdoGuid())
+	err := listenerDB.Save(listenerInst)
+	if err != nil {
+		t.Fatalf("Failed to save listener. %s", err.Error())
+	}
+
+	// The test starts on HelloDevice60, and passes with the next message of the device
+	_, err = listenerDB.UpdateRunner(listenerInst.Uuid, fdoshared.To2, func(runner *listenertestsdeps.RequestListenerRunnerInst) error {
+		runner.StartNewTestRun()
+		runner.GetNextTestID()
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to start test. %s", err.Error())
+	}
+
+	_, err = listenerDB.UpdateRunner(listenerInst.Uuid, fdoshared.To2, func(runner *listenertestsdeps.RequestListenerRunnerInst) error {
+		runner.PushSuccess()
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to push result. %s", err.Error())
+	}
+
+	storedInst, err := listenerDB.Get(listenerInst.Uuid)
+	if err != nil {
+		t.Fatalf("Failed to get listener. %s", err.Error())
+	}
+
+	testRun := storedInst.To2.CurrentTestRun
+	if len(testRun.TestRuns) != 1 || len(testRun.TestRuns[0].Timings) != 1 || testRun.TestRuns[0].Timings[0].Cmd != fdoshared.TO2_60_HELLO_DEVICE {
+		t.Fatalf("Expected a HelloDevice60 timing. Got %+v", testRun.TestRuns)
+	}
+
+	if testRun.TimingSummary.Messages != 1 {
+		t.Errorf("Expected the timing summary to count one message. Got %d", testRun.TimingSummary.Messages)
+	}
+}
@@ -0,0 +1,299 @@
+package dbs
+
+import (
+	"github.com/fxamacker/cbor/v2"
+
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/records"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+)
+
+const (
+	RT_RequestTestInst   records.RecordType = "request_test_inst"
+	RT_RequestTestResult records.RecordType = "request_test_result"
+	RT_ListenerInst      records.RecordType = "listener_inst"
+	RT_Webhook           records.RecordType = "webhook"
+	RT_WebhookDelivery   records.RecordType = "webhook_delivery"
+)
+
+func init() {
+	records.Register(records.Schema{
+		Type:     RT_RequestTestInst,
+		Prefixes: [][]byte{[]byte("rvte-")},
+		Migrations: []records.Migration{
+			migrateRequestTestInstV1,
+			migrateRequestTestInstV2,
+		},
+	})
+
+	records.Register(records.Schema{
+		Type:     RT_RequestTestResult,
+		Prefixes: [][]byte{[]byte("rvteres-")},
+		Migrations: []records.Migration{
+			migrateRequestTestResultV1,
+		},
+	})
+
+	records.Register(records.Schema{
+		Type:            RT_ListenerInst,
+		Prefixes:        [][]byte{[]byte("lstdb-")},
+		ExcludePrefixes: [][]byte{[]byte("lstdb-guid-map-")},
+		Migrations: []records.Migration{
+			migrateListenerInstV1,
+			migrateListenerInstV2,
+		},
+	})
+
+	records.Register(records.Schema{
+		Type:     RT_Webhook,
+		Prefixes: [][]byte{[]byte("webhook-")},
+	})
+
+	records.Register(records.Schema{
+		Type:     RT_WebhookDelivery,
+		Prefixes: [][]byte{[]byte("webhookdlv-")},
+	})
+}
+
+// FDOTestState gained Timings
+func migrateTestStateV1(testState cbor.RawMessage) (cbor.RawMessage, error) {
+	return records.PadArray(testState, 4, nil)
+}
+
+// FDOTestState gained Warnings
+func migrateTestStateV2(testState cbor.RawMessage) (cbor.RawMessage, error) {
+	return records.PadArray(testState, 5, nil)
+}
+
+// migrateRequestTestRunTests applies migrateTestState to every test state of a RequestTestRun
+func migrateRequestTestRunTests(testRun cbor.RawMessage, migrateTestState records.Migration) (cbor.RawMessage, error) {
+	fields, err := records.DecodeArray(testRun)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(fields) < 4 {
+		return nil, records.ErrUnexpectedRecord
+	}
+
+	tests, err := records.DecodeMap(fields[2])
+	if err != nil {
+		return nil, err
+	}
+
+	for testId, testState := range tests {
+		tests[testId], err = migrateTestState(testState)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	fields[2], err = records.Encode(tests)
+	if err != nil {
+		return nil, err
+	}
+
+	return records.Encode(fields)
+}
+
+// RequestTestRun gained Cancelled, and its test states gained Timings
+func migrateRequestTestRunV1(testRun cbor.RawMessage) (cbor.RawMessage, error) {
+	runBytes, err := migrateRequestTestRunTests(testRun, migrateTestStateV1)
+	if err != nil {
+		return nil, err
+	}
+
+	return records.PadArray(runBytes, 5, false)
+}
+
+// RequestTestRun gained ErrorCodeStrictness, and its test states gained Warnings
+func migrateRequestTestRunV2(testRun cbor.RawMessage) (cbor.RawMessage, error) {
+	runBytes, err := migrateRequestTestRunTests(testRun, migrateTestStateV2)
+	if err != nil {
+		return nil, err
+	}
+
+	return records.PadArray(runBytes, 6, string(testcom.ECS_Lenient))
+}
+
+// migrateRequestTestInstRuns applies migrateTestRun to the current run and the run history of a RequestTestInst
+func migrateRequestTestInstRuns(record cbor.RawMessage, migrateTestRun records.Migration) ([]cbor.RawMessage, error) {
+	fields, err := records.DecodeArray(record)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(fields) < 8 {
+		return nil, records.ErrUnexpectedRecord
+	}
+
+	fields[5], err = migrateTestRun(fields[5])
+	if err != nil {
+		return nil, err
+	}
+
+	testsHistory, err := records.DecodeArray(fields[6])
+	if err != nil {
+		return nil, err
+	}
+
+	for i, testRun := range testsHistory {
+		testsHistory[i], err = migrateTestRun(testRun)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	fields[6], err = records.Encode(testsHistory)
+	if err != nil {
+		return nil, err
+	}
+
+	return fields, nil
+}
+
+// RequestTestInst gained Concurrency, older versions always ran tests sequentially
+func migrateRequestTestInstV1(record cbor.RawMessage) (cbor.RawMessage, error) {
+	fields, err := migrateRequestTestInstRuns(record, migrateRequestTestRunV1)
+	if err != nil {
+		return nil, err
+	}
+
+	instBytes, err := records.Encode(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	return records.PadArray(instBytes, 9, 1)
+}
+
+// Runs of older versions treated any FDO error as expected, the same as lenient strictness
+func migrateRequestTestInstV2(record cbor.RawMessage) (cbor.RawMessage, error) {
+	fields, err := migrateRequestTestInstRuns(record, migrateRequestTestRunV2)
+	if err != nil {
+		return nil, err
+	}
+
+	return records.Encode(fields)
+}
+
+// The test state of RequestTestResultEntry gained Warnings. Results stored before it gained Timings are padded too
+func migrateRequestTestResultV1(record cbor.RawMessage) (cbor.RawMessage, error) {
+	fields, err := records.DecodeArray(record)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(fields) != 3 {
+		return nil, records.ErrUnexpectedRecord
+	}
+
+	fields[2], err = migrateTestStateV1(fields[2])
+	if err != nil {
+		return nil, err
+	}
+
+	fields[2], err = migrateTestStateV2(fields[2])
+	if err != nil {
+		return nil, err
+	}
+
+	return records.Encode(fields)
+}
+
+// migrateListenerTestRunTests applies migrateTestState to every test state of a ListenerTestRun
+func migrateListenerTestRunTests(testRun cbor.RawMessage, migrateTestState records.Migration) (cbor.RawMessage, error) {
+	fields, err := records.DecodeArray(testRun)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(fields) < 3 {
+		return nil, records.ErrUnexpectedRecord
+	}
+
+	testStates, err := records.DecodeArray(fields[2])
+	if err != nil {
+		return nil, err
+	}
+
+	for i, testState := range testStates {
+		testStates[i], err = migrateTestState(testState)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	fields[2], err = records.Encode(testStates)
+	if err != nil {
+		return nil, err
+	}
+
+	return records.Encode(fields)
+}
+
+// migrateListenerInstRuns applies migrateTestRun to the current run and the run history of every runner of a listener
+func migrateListenerInstRuns(record cbor.RawMessage, migrateTestRun records.Migration) (cbor.RawMessage, error) {
+	listenerInst, err := records.DecodeMap(record)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, runnerKey := range []string{"to0", "to1", "to2"} {
+		runnerBytes, ok := listenerInst[runnerKey]
+		if !ok {
+			continue
+		}
+
+		runner, err := records.DecodeMap(runnerBytes)
+		if err != nil {
+			return nil, err
+		}
+
+		if currentTestRun, ok := runner["currentTestRun"]; ok {
+			runner["currentTestRun"], err = migrateTestRun(currentTestRun)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if testRunHistoryBytes, ok := runner["testRunHistory"]; ok {
+			testRunHistory, err := records.DecodeArray(testRunHistoryBytes)
+			if err != nil {
+				return nil, err
+			}
+
+			for i, testRun := range testRunHistory {
+				testRunHistory[i], err = migrateTestRun(testRun)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+			runner["testRunHistory"], err = records.Encode(testRunHistory)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		listenerInst[runnerKey], err = records.Encode(runner)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return records.Encode(listenerInst)
+}
+
+// The test states of ListenerTestRun gained Timings
+func migrateListenerInstV1(record cbor.RawMessage) (cbor.RawMessage, error) {
+	return migrateListenerInstRuns(record, func(testRun cbor.RawMessage) (cbor.RawMessage, error) {
+		return migrateListenerTestRunTests(testRun, migrateTestStateV1)
+	})
+}
+
+// The test states of ListenerTestRun gained Warnings
+func migrateListenerInstV2(record cbor.RawMessage) (cbor.RawMessage, error) {
+	return migrateListenerInstRuns(record, func(testRun cbor.RawMessage) (cbor.RawMessage, error) {
+		return migrateListenerTestRunTests(testRun, migrateTestStateV2)
+	})
+}
@@ -0,0 +1,195 @@
+package dbs
+
+import (
+	"testing"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/records"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/storage"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
+)
+
+// Record layouts as stored by v0.7.0, before versioning
+
+type legacyTestState struct {
+	_      struct{} `cbor:",toarray"`
+	Passed bool
+	Error  string
+	TestID testcom.FDOTestID
+}
+
+type legacyTestRun struct {
+	_         struct{} `cbor:",toarray"`
+	Uuid      string
+	Timestamp int64
+	Tests     map[testcom.FDOTestID]legacyTestState
+	Protocol  fdoshared.FdoToProtocol
+}
+
+type legacyRequestTestInst struct {
+	_              struct{} `cbor:",toarray"`
+	Uuid           []byte
+	URL            string
+	Protocol       fdoshared.FdoToProtocol
+	FdoSeedIDs     fdoshared.FdoSeedIDs
+	InProgress     bool
+	CurrentTestRun legacyTestRun
+	TestsHistory   []legacyTestRun
+	TestVouchers   map[string]interface{}
+}
+
+func TestRequestTestInstMigration(t *testing.T) {
+	db := storage.NewMemoryStore()
+	defer db.Close()
+
+	testRun := legacyTestRun{
+		Uuid:      "run",
+		Timestamp: 1,
+		Tests: map[testcom.FDOTestID]legacyTestState{
+			testcom.FIDO_RVT_20_POSITIVE: {Passed: true, TestID: testcom.FIDO_RVT_20_POSITIVE},
+		},
+		Protocol: fdoshared.To0,
+	}
+
+	legacyBytes, err := fdoshared.CborCust.Marshal(legacyRequestTestInst{
+		Uuid:           []byte("legacy"),
+		URL:            "http://localhost:8080",
+		CurrentTestRun: testRun,
+		TestsHistory:   []legacyTestRun{testRun},
+		TestVouchers:   map[string]interface{}{},
+	})
+	if err != nil {
+		t.Fatalf("Failed to encode legacy record. %s", err.Error())
+	}
+
+	db.Update(func(txn storage.Txn) error {
+		return txn.Set([]byte("rvte-legacy"), legacyBytes)
+	})
+
+	reqtDB := NewRequestTestDB(db)
+
+	rvte, err := reqtDB.Get([]byte("legacy"))
+	if err != nil {
+		t.Fatalf("Failed to read legacy record. %s", err.Error())
+	}
+
+	if rvte.Concurrency != 1 || len(rvte.TestsHistory) != 1 || !rvte.TestsHistory[0].Tests[testcom.FIDO_RVT_20_POSITIVE].Passed {
+		t.Errorf("Unexpected migrated record %+v", rvte)
+	}
+
+	if rvte.TestsHistory[0].ErrorCodeStrictness != testcom.ECS_Lenient {
+		t.Errorf("Expected legacy runs to be lenient. Got %s", rvte.TestsHistory[0].ErrorCodeStrictness)
+	}
+
+	migrated, err := records.MigrateStore(db)
+	if err != nil || migrated != 1 {
+		t.Fatalf("Expected one migrated record. Got %d, %v", migrated, err)
+	}
+
+	migrated, err = records.MigrateStore(db)
+	if err != nil || migrated != 0 {
+		t.Errorf("Expected the record to be up to date. Got %d, %v", migrated, err)
+	}
+
+	rvte, err = reqtDB.Get([]byte("legacy"))
+	if err != nil || rvte.URL != "http://localhost:8080" {
+		t.Errorf("Failed to read migrated record. %v", err)
+	}
+}
+
+// Layout stored by builds that ran tests in parallel, before versioning
+type unversionedConcurrentRequestTestInst struct {
+	_              struct{} `cbor:",toarray"`
+	Uuid           []byte
+	URL            string
+	Protocol       fdoshared.FdoToProtocol
+	FdoSeedIDs     fdoshared.FdoSeedIDs
+	InProgress     bool
+	CurrentTestRun legacyTestRun
+	TestsHistory   []legacyTestRun
+	TestVouchers   map[string]interface{}
+	Concurrency    int
+}
+
+func TestRequestTestInstMigrationKeepsConcurrency(t *testing.T) {
+	db := storage.NewMemoryStore()
+	defer db.Close()
+
+	unversionedBytes, err := fdoshared.CborCust.Marshal(unversionedConcurrentRequestTestInst{
+		Uuid:         []byte("concurrent"),
+		URL:          "http://localhost:8080",
+		TestsHistory: []legacyTestRun{},
+		TestVouchers: map[string]interface{}{},
+		Concurrency:  4,
+	})
+	if err != nil {
+		t.Fatalf("Failed to encode unversioned record. %s", err.Error())
+	}
+
+	db.Update(func(txn storage.Txn) error {
+		return txn.Set([]byte("rvte-concurrent"), unversionedBytes)
+	})
+
+	rvte, err := NewRequestTestDB(db).Get([]byte("concurrent"))
+	if err != nil {
+		t.Fatalf("Failed to read unversioned record. %s", err.Error())
+	}
+
+	if rvte.Concurrency != 4 {
+		t.Errorf("Expected the stored concurrency 4. Got %d", rvte.Concurrency)
+	}
+}
+
+// Layout of test results stored before test states gained Timings
+type legacyRequestTestResultEntry struct {
+	_         struct{} `cbor:",toarray"`
+	RunUuid   string
+	TestID    testcom.FDOTestID
+	TestState legacyTestState
+}
+
+func TestRequestTestResultMigration(t *testing.T) {
+	db := storage.NewMemoryStore()
+	defer db.Close()
+
+	reqtDB := NewRequestTestDB(db)
+
+	reqte := reqtestsdeps.NewRequestTestInst("http://localhost:8080", fdoshared.To0, 1)
+	err := reqtDB.Save(reqte)
+	if err != nil {
+		t.Fatalf("Failed to save test instance. %s", err.Error())
+	}
+
+	runUuid, err := reqtDB.StartNewRun(reqte.Uuid, testcom.ECS_Lenient)
+	if err != nil {
+		t.Fatalf("Failed to start run. %s", err.Error())
+	}
+
+	legacyBytes, err := fdoshared.CborCust.Marshal(legacyRequestTestResultEntry{
+		RunUuid:   runUuid,
+		TestID:    testcom.FIDO_RVT_20_POSITIVE,
+		TestState: legacyTestState{Passed: true, TestID: testcom.FIDO_RVT_20_POSITIVE},
+	})
+	if err != nil {
+		t.Fatalf("Failed to encode legacy record. %s", err.Error())
+	}
+
+	db.Update(func(txn storage.Txn) error {
+		return txn.Set(reqtDB.getResultId(reqte.Uuid, runUuid, testcom.FIDO_RVT_20_POSITIVE), legacyBytes)
+	})
+
+	storedReqte, err := reqtDB.Get(reqte.Uuid)
+	if err != nil {
+		t.Fatalf("Failed to read legacy result. %s", err.Error())
+	}
+
+	if !storedReqte.CurrentTestRun.Tests[testcom.FIDO_RVT_20_POSITIVE].Passed {
+		t.Errorf("Unexpected migrated run %+v", storedReqte.CurrentTestRun)
+	}
+
+	migrated, err := records.MigrateStore(db)
+	if err != nil || migrated != 1 {
+		t.Errorf("Expected one migrated record. Got %d, %v", migrated, err)
+	}
+}
@@ -7,102 +7,163 @@ import (
 	"log"
 	"time"
 
-	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/records"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/storage"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/events"
 	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
-
-	"github.com/dgraph-io/badger/v4"
 )
 
 type RequestTestDB struct {
-	db     *badger.DB
-	prefix []byte
-	ttl    int
+	db           storage.Store
+	prefix       []byte
+	resultPrefix []byte
+	ttl          int
 }
 
-func NewRequestTestDB(db *badger.DB) *RequestTestDB {
+func NewRequestTestDB(db storage.Store) *RequestTestDB {
 	return &RequestTestDB{
-		db:     db,
-		prefix: []byte("rvte-"),
-		ttl:    60 * 60 * 24 * 183, // 6months storage
+		db:           db,
+		prefix:       []byte("rvte-"),
+		resultPrefix: []byte("rvteres-"),
+		ttl:          60 * 60 * 24 * 183, // 6months storage
 	}
 }
 
+func (h *RequestTestDB) getEntryId(rvtId []byte) []byte {
+	return append(append([]byte{}, h.prefix...), rvtId...)
+}
+
+func (h *RequestTestDB) getResultsPrefix(rvtId []byte, runUuid string) []byte {
+	resultsPrefix := append([]byte{}, h.resultPrefix...)
+	resultsPrefix = append(resultsPrefix, rvtId...)
+
+	return append(resultsPrefix, []byte(runUuid)...)
+}
+
+func (h *RequestTestDB) getResultId(rvtId []byte, runUuid string, testId testcom.FDOTestID) []byte {
+	return append(h.getResultsPrefix(rvtId, runUuid), []byte(testId)...)
+}
+
+// Save stores a new test instance. Existing instances are changed with Update, so a stale copy never replaces them
 func (h *RequestTestDB) Save(rvte reqtestsdeps.RequestTestInst) error {
-	rvteBytes, err := fdoshared.CborCust.Marshal(rvte)
+	err := updateWithRetry(h.db, func(txn storage.Txn) error {
+		_, err := txn.Get(h.getEntryId(rvte.Uuid))
+		if err == nil {
+			return fmt.Errorf("The rvte entry with id %s already exists", hex.EncodeToString(rvte.Uuid))
+		} else if !errors.Is(err, storage.ErrKeyNotFound) {
+			return errors.New("Failed locating rvte entry. The error is: " + err.Error())
+		}
+
+		return h.setTxn(txn, rvte)
+	})
 	if err != nil {
-		return errors.New("Failed to marshal rvte. The error is: " + err.Error())
+		return errors.New("Failed saving rvte entry. The error is: " + err.Error())
 	}
 
-	rvteStorageId := append(h.prefix, rvte.Uuid...)
+	return nil
+}
 
-	dbtxn := h.db.NewTransaction(true)
-	defer dbtxn.Discard()
+// Update applies update to the stored test instance, inside a transaction. update is re-run on fresh data when a
+// concurrent writer conflicts, so runs and results recorded meanwhile are never overwritten by a stale copy
+func (h *RequestTestDB) Update(rvtId []byte, update func(rvte *reqtestsdeps.RequestTestInst) error) error {
+	err := updateWithRetry(h.db, func(txn storage.Txn) error {
+		rvte, err := h.getTxn(txn, rvtId)
+		if err != nil {
+			return err
+		}
 
-	entry := badger.NewEntry(rvteStorageId, rvteBytes).WithTTL(time.Second * time.Duration(h.ttl)) // Session entry will only exist for 10 minutes
-	err = dbtxn.SetEntry(entry)
-	if err != nil {
-		return errors.New("Failed creating rvte db entry instance. The error is: " + err.Error())
-	}
+		err = update(rvte)
+		if err != nil {
+			return err
+		}
 
-	err = dbtxn.Commit()
+		return h.setTxn(txn, *rvte)
+	})
 	if err != nil {
-		return errors.New("Failed saving rvte entry. The error is: " + err.Error())
+		return fmt.Errorf("%s error updating rvte entry. %s", hex.EncodeToString(rvtId), err.Error())
 	}
 
 	return nil
 }
 
-func (h *RequestTestDB) Update(rvtId []byte, rvte reqtestsdeps.RequestTestInst) error {
-	rvteBytes, err := fdoshared.CborCust.Marshal(rvte)
-	if err != nil {
-		return errors.New("Failed to marshal rvte. The error is: " + err.Error())
+func (h *RequestTestDB) getTxn(txn storage.Txn, rvtId []byte) (*reqtestsdeps.RequestTestInst, error) {
+	rvteStorageId := h.getEntryId(rvtId)
+
+	itemBytes, err := txn.Get(rvteStorageId)
+	if err != nil && errors.Is(err, storage.ErrKeyNotFound) {
+		return nil, fmt.Errorf("The rvte entry with id %s does not exist", hex.EncodeToString(rvtId))
+	} else if err != nil {
+		return nil, errors.New("Failed locating rvte entry. The error is: " + err.Error())
 	}
 
-	rvteStorageId := append(h.prefix, rvtId...)
+	var rvteInst reqtestsdeps.RequestTestInst
+	err = records.Unmarshal(RT_RequestTestInst, itemBytes, &rvteInst)
+	if err != nil {
+		return nil, errors.New("Failed cbor decoding rvte entry value. The error is: " + err.Error())
+	}
 
-	dbtxn := h.db.NewTransaction(true)
-	defer dbtxn.Discard()
+	return &rvteInst, nil
+}
 
-	entry := badger.NewEntry(rvteStorageId, rvteBytes).WithTTL(time.Second * time.Duration(h.ttl)) // Session entry will only exist for 10 minutes
-	err = dbtxn.SetEntry(entry)
+func (h *RequestTestDB) setTxn(txn storage.Txn, rvte reqtestsdeps.RequestTestInst) error {
+	rvteBytes, err := records.Marshal(RT_RequestTestInst, rvte)
 	if err != nil {
-		return errors.New("Failed creating rvte db entry instance. The error is: " + err.Error())
+		return errors.New("Failed to marshal rvte. The error is: " + err.Error())
 	}
 
-	err = dbtxn.Commit()
+	err = txn.SetWithTTL(h.getEntryId(rvte.Uuid), rvteBytes, time.Second*time.Duration(h.ttl))
 	if err != nil {
-		return errors.New("Failed saving rvte entry. The error is: " + err.Error())
+		return errors.New("Failed creating rvte db entry instance. The error is: " + err.Error())
 	}
 
 	return nil
 }
 
-func (h *RequestTestDB) Get(rvtId []byte) (*reqtestsdeps.RequestTestInst, error) {
-	rvteStorageId := append(h.prefix, rvtId...)
-
-	dbtxn := h.db.NewTransaction(true)
-	defer dbtxn.Discard()
+func (h *RequestTestDB) getResultsTxn(txn storage.Txn, resultsPrefix []byte) ([]reqtestsdeps.RequestTestResultEntry, error) {
+	var results []reqtestsdeps.RequestTestResultEntry
 
-	item, err := dbtxn.Get(rvteStorageId)
-	if err != nil && errors.Is(err, badger.ErrKeyNotFound) {
-		return nil, fmt.Errorf("The rvte entry with id %s does not exist", hex.EncodeToString(rvtId))
-	} else if err != nil {
-		return nil, errors.New("Failed locating rvte entry. The error is: " + err.Error())
-	}
+	err := txn.Iterate(resultsPrefix, func(key []byte, value []byte) error {
+		var result reqtestsdeps.RequestTestResultEntry
+		err := records.Unmarshal(RT_RequestTestResult, value, &result)
+		if err != nil {
+			return errors.New("Failed cbor decoding test result value. The error is: " + err.Error())
+		}
 
-	itemBytes, err := item.ValueCopy(nil)
+		results = append(results, result)
+		return nil
+	})
 	if err != nil {
-		return nil, errors.New("Failed reading rvte entry value. The error is: " + err.Error())
+		return nil, err
 	}
 
-	var rvteInst reqtestsdeps.RequestTestInst
-	err = fdoshared.CborCust.Unmarshal(itemBytes, &rvteInst)
+	return results, nil
+}
+
+// Get returns the test instance with all the recorded test results merged into its runs
+func (h *RequestTestDB) Get(rvtId []byte) (*reqtestsdeps.RequestTestInst, error) {
+	var rvteInst *reqtestsdeps.RequestTestInst
+
+	err := h.db.View(func(txn storage.Txn) error {
+		var err error
+		rvteInst, err = h.getTxn(txn, rvtId)
+		if err != nil {
+			return err
+		}
+
+		results, err := h.getResultsTxn(txn, h.getResultsPrefix(rvtId, ""))
+		if err != nil {
+			return err
+		}
+
+		rvteInst.ApplyResults(results)
+		return nil
+	})
 	if err != nil {
-		return nil, errors.New("Failed cbor decoding rvte entry value. The error is: " + err.Error())
+		return nil, err
 	}
 
-	return &rvteInst, nil
+	return rvteInst, nil
 }
 
 func (h *RequestTestDB) GetMany(rvtids [][]byte) (*[]reqtestsdeps.RequestTestInst, error) {
@@ -120,73 +181,158 @@ func (h *RequestTestDB) GetMany(rvtids [][]byte) (*[]reqtestsdeps.RequestTestIns
 	return &rvts, nil
 }
 
-func (h *RequestTestDB) StartNewRun(rvteid []byte) {
+// StartNewRun creates a new test run and returns its id. strictness is recorded with the run, see testcom.ErrorCodeStrictness
+func (h *RequestTestDB) StartNewRun(rvteid []byte, strictness testcom.ErrorCodeStrictness) (string, error) {
 	log.Printf("----- Starting New Run For %s -----", hex.EncodeToString(rvteid))
-	rvte, err := h.Get(rvteid)
-	if err != nil {
-		log.Printf("%s test entry can not be found.", hex.EncodeToString(rvteid))
-	}
 
-	newRVTestRun := reqtestsdeps.NewRVTestRun(rvte.Protocol)
+	var runUuid string
+	err := updateWithRetry(h.db, func(txn storage.Txn) error {
+		rvte, err := h.getTxn(txn, rvteid)
+		if err != nil {
+			return err
+		}
 
-	rvte.InProgress = true
-	rvte.CurrentTestRun = newRVTestRun
-	rvte.TestsHistory = append([]reqtestsdeps.RequestTestRun{newRVTestRun}, rvte.TestsHistory...)
+		newRVTestRun := reqtestsdeps.NewRVTestRun(rvte.Protocol, strictness)
 
-	err = h.Save(*rvte)
+		rvte.InProgress = true
+		rvte.CurrentTestRun = newRVTestRun
+		rvte.TestsHistory = append([]reqtestsdeps.RequestTestRun{newRVTestRun}, rvte.TestsHistory...)
+		runUuid = newRVTestRun.Uuid
+
+		return h.setTxn(txn, *rvte)
+	})
 	if err != nil {
-		log.Printf("%s error saving test entry.", hex.EncodeToString(rvteid))
+		return "", fmt.Errorf("%s error starting new run. %s", hex.EncodeToString(rvteid), err.Error())
 	}
+
+	return runUuid, nil
 }
 
-func (h *RequestTestDB) FinishRun(rvteid []byte) {
-	rvte, err := h.Get(rvteid)
-	if err != nil {
-		log.Printf("%s test entry can not be found.", hex.EncodeToString(rvteid))
+// getRun returns the run with the given id from the history of the test instance
+func getRun(rvte *reqtestsdeps.RequestTestInst, runUuid string) (*reqtestsdeps.RequestTestRun, error) {
+	for i, testRun := range rvte.TestsHistory {
+		if testRun.Uuid == runUuid {
+			return &rvte.TestsHistory[i], nil
+		}
 	}
 
-	rvte.InProgress = false
+	return nil, fmt.Errorf("The run %s does not exist", runUuid)
+}
+
+// FinishRun marks the run as completed. Cancelled runs keep the results reported so far. The test instance stays in
+// progress when a newer run was started meanwhile
+func (h *RequestTestDB) FinishRun(rvteid []byte, runUuid string, cancelled bool) error {
+	var finishedRun reqtestsdeps.RequestTestRun
+	var finishedStates []testcom.FDOTestState
+	err := updateWithRetry(h.db, func(txn storage.Txn) error {
+		rvte, err := h.getTxn(txn, rvteid)
+		if err != nil {
+			return err
+		}
+
+		testRun, err := getRun(rvte, runUuid)
+		if err != nil {
+			return err
+		}
+
+		results, err := h.getResultsTxn(txn, h.getResultsPrefix(rvteid, runUuid))
+		if err != nil {
+			return err
+		}
+
+		finishedStates = make([]testcom.FDOTestState, 0, len(results))
+		for _, result := range results {
+			finishedStates = append(finishedStates, result.TestState)
+		}
+
+		testRun.Cancelled = cancelled
+		finishedRun = *testRun
+
+		if rvte.CurrentTestRun.Uuid == runUuid {
+			rvte.InProgress = false
+			rvte.CurrentTestRun.Cancelled = cancelled
+		}
 
-	err = h.Save(*rvte)
+		return h.setTxn(txn, *rvte)
+	})
 	if err != nil {
-		log.Printf("%s error saving test entry.", hex.EncodeToString(rvteid))
+		return fmt.Errorf("%s error finishing run. %s", hex.EncodeToString(rvteid), err.Error())
 	}
 
+	events.Publish(events.NewRunFinishedEvent(rvteid, finishedRun.Uuid, finishedRun.Protocol, cancelled, testcom.NewRunSummary(finishedStates)))
+
 	log.Printf("----- Finishing Run For %s -----", hex.EncodeToString(rvteid))
+	return nil
 }
 
-func (h *RequestTestDB) ReportTest(rvteid []byte, testID testcom.FDOTestID, testResult testcom.FDOTestState) {
-	rvte, err := h.Get(rvteid)
-	if err != nil {
-		log.Printf("%s test entry can not be found.", hex.EncodeToString(rvteid))
-	}
+// ReportTest stores the result of the run as its own record, so parallel tests of the same run never overwrite each other
+func (h *RequestTestDB) ReportTest(rvteid []byte, runUuid string, testID testcom.FDOTestID, testResult testcom.FDOTestState) error {
+	var testRun *reqtestsdeps.RequestTestRun
+	err := updateWithRetry(h.db, func(txn storage.Txn) error {
+		rvte, err := h.getTxn(txn, rvteid)
+		if err != nil {
+			return err
+		}
 
-	rvte.CurrentTestRun.Tests[testID] = testResult
-	rvte.TestsHistory[0] = rvte.CurrentTestRun
+		testRun, err = getRun(rvte, runUuid)
+		if err != nil {
+			return err
+		}
 
-	err = h.Save(*rvte)
+		resultBytes, err := records.Marshal(RT_RequestTestResult, reqtestsdeps.RequestTestResultEntry{
+			RunUuid:   runUuid,
+			TestID:    testID,
+			TestState: testResult,
+		})
+		if err != nil {
+			return errors.New("Failed to marshal test result. The error is: " + err.Error())
+		}
+
+		return txn.SetWithTTL(h.getResultId(rvteid, runUuid, testID), resultBytes, time.Second*time.Duration(h.ttl))
+	})
 	if err != nil {
-		log.Printf("%s error saving test entry.", hex.EncodeToString(rvteid))
+		log.Printf("%s error saving %s test result. %s", hex.EncodeToString(rvteid), testID, err.Error())
+		return err
 	}
+
+	events.Publish(events.NewTestFinishedEvent(rvteid, runUuid, testRun.Protocol, testID, testResult))
+
+	return nil
 }
 
-func (h *RequestTestDB) RemoveTestRun(rvteid []byte, testRunId string) {
-	rvte, err := h.Get(rvteid)
-	if err != nil {
-		log.Printf("%s test entry can not be found.", hex.EncodeToString(rvteid))
-	}
+func (h *RequestTestDB) RemoveTestRun(rvteid []byte, testRunId string) error {
+	err := updateWithRetry(h.db, func(txn storage.Txn) error {
+		rvte, err := h.getTxn(txn, rvteid)
+		if err != nil {
+			return err
+		}
 
-	var updatedTestsHistory []reqtestsdeps.RequestTestRun = []reqtestsdeps.RequestTestRun{}
-	for _, testRunEntry := range rvte.TestsHistory {
-		if testRunEntry.Uuid != testRunId {
-			updatedTestsHistory = append(updatedTestsHistory, testRunEntry)
+		var updatedTestsHistory []reqtestsdeps.RequestTestRun = []reqtestsdeps.RequestTestRun{}
+		for _, testRunEntry := range rvte.TestsHistory {
+			if testRunEntry.Uuid != testRunId {
+				updatedTestsHistory = append(updatedTestsHistory, testRunEntry)
+			}
 		}
-	}
 
-	rvte.TestsHistory = updatedTestsHistory
+		rvte.TestsHistory = updatedTestsHistory
 
-	err = h.Save(*rvte)
+		results, err := h.getResultsTxn(txn, h.getResultsPrefix(rvteid, testRunId))
+		if err != nil {
+			return err
+		}
+
+		for _, result := range results {
+			err = txn.Delete(h.getResultId(rvteid, result.RunUuid, result.TestID))
+			if err != nil {
+				return errors.New("Failed deleting test result. The error is: " + err.Error())
+			}
+		}
+
+		return h.setTxn(txn, *rvte)
+	})
 	if err != nil {
-		log.Printf("%s error saving test entry.", hex.EncodeToString(rvteid))
+		return fmt.Errorf("%s error removing test run %s. %s", hex.EncodeToString(rvteid), testRunId, err.Error())
 	}
+
+	return nil
 }
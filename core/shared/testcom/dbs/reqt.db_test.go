@@ -0,0 +1,220 @@
+package dbs
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/storage"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
+)
+
+func TestRequestTestDBParallelReports(t *testing.T) {
+	db := storage.NewMemoryStore()
+	defer db.Close()
+
+	reqtDB := NewRequestTestDB(db)
+
+	reqte := reqtestsdeps.NewRequestTestInst("http://localhost:8080", fdoshared.To2, 1)
+	err := reqtDB.Save(reqte)
+	if err != nil {
+		t.Fatalf("Failed to save test instance. %s", err.Error())
+	}
+
+	runUuid, err := reqtDB.StartNewRun(reqte.Uuid, testcom.ECS_Lenient)
+	if err != nil {
+		t.Fatalf("Failed to start run. %s", err.Error())
+	}
+
+	testsCount := 50
+
+	var wg sync.WaitGroup
+	for i := 0; i < testsCount; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			testId := testcom.FDOTestID(fmt.Sprintf("test-%d", i))
+			err := reqtDB.ReportTest(reqte.Uuid, runUuid, testId, testcom.NewSuccessTestState(testId))
+			if err != nil {
+				t.Errorf("Failed to report test %s. %s", testId, err.Error())
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	err = reqtDB.FinishRun(reqte.Uuid, runUuid, false)
+	if err != nil {
+		t.Fatalf("Failed to finish run. %s", err.Error())
+	}
+
+	storedReqte, err := reqtDB.Get(reqte.Uuid)
+	if err != nil {
+		t.Fatalf("Failed to get test instance. %s", err.Error())
+	}
+
+	if storedReqte.InProgress {
+		t.Errorf("Expected run to be finished")
+	}
+
+	if len(storedReqte.TestsHistory) != 1 || len(storedReqte.TestsHistory[0].Tests) != testsCount {
+		t.Errorf("Expected %d results in the run history", testsCount)
+	}
+
+	if len(storedReqte.CurrentTestRun.Tests) != testsCount {
+		t.Errorf("Expected %d results. Got %d", testsCount, len(storedReqte.CurrentTestRun.Tests))
+	}
+
+	err = reqtDB.RemoveTestRun(reqte.Uuid, storedReqte.CurrentTestRun.Uuid)
+	if err != nil {
+		t.Fatalf("Failed to remove test run. %s", err.Error())
+	}
+
+	storedReqte, err = reqtDB.Get(reqte.Uuid)
+	if err != nil {
+		t.Fatalf("Failed to get test instance. %s", err.Error())
+	}
+
+	if len(storedReqte.TestsHistory) != 0 {
+		t.Errorf("Expected run history to be empty")
+	}
+}
+
+func TestRequestTestDBOverlappingRuns(t *testing.T) {
+	db := storage.NewMemoryStore()
+	defer db.Close()
+
+	reqtDB := NewRequestTestDB(db)
+
+	reqte := reqtestsdeps.NewRequestTestInst("http://localhost:8080", fdoshared.To2, 1)
+	err := reqtDB.Save(reqte)
+	if err != nil {
+		t.Fatalf("Failed to save test instance. %s", err.Error())
+	}
+
+	firstRunUuid, err := reqtDB.StartNewRun(reqte.Uuid, testcom.ECS_Lenient)
+	if err != nil {
+		t.Fatalf("Failed to start first run. %s", err.Error())
+	}
+
+	secondRunUuid, err := reqtDB.StartNewRun(reqte.Uuid, testcom.ECS_Lenient)
+	if err != nil {
+		t.Fatalf("Failed to start second run. %s", err.Error())
+	}
+
+	// Both runs report at the same time, and the first one finishes while the second one is still running
+	var wg sync.WaitGroup
+	for _, runUuid := range []string{firstRunUuid, secondRunUuid} {
+		wg.Add(1)
+		go func(runUuid string) {
+			defer wg.Done()
+
+			testId := testcom.FDOTestID("test-" + runUuid)
+			err := reqtDB.ReportTest(reqte.Uuid, runUuid, testId, testcom.NewSuccessTestState(testId))
+			if err != nil {
+				t.Errorf("Failed to report test %s. %s", testId, err.Error())
+			}
+		}(runUuid)
+	}
+	wg.Wait()
+
+	err = reqtDB.FinishRun(reqte.Uuid, firstRunUuid, true)
+	if err != nil {
+		t.Fatalf("Failed to finish first run. %s", err.Error())
+	}
+
+	storedReqte, err := reqtDB.Get(reqte.Uuid)
+	if err != nil {
+		t.Fatalf("Failed to get test instance. %s", err.Error())
+	}
+
+	if !storedReqte.InProgress || storedReqte.CurrentTestRun.Uuid != secondRunUuid || storedReqte.CurrentTestRun.Cancelled {
+		t.Errorf("Expected the second run to still be in progress")
+	}
+
+	err = reqtDB.FinishRun(reqte.Uuid, secondRunUuid, false)
+	if err != nil {
+		t.Fatalf("Failed to finish second run. %s", err.Error())
+	}
+
+	storedReqte, err = reqtDB.Get(reqte.Uuid)
+	if err != nil {
+		t.Fatalf("Failed to get test instance. %s", err.Error())
+	}
+
+	if storedReqte.InProgress {
+		t.Errorf("Expected both runs to be finished")
+	}
+
+	for _, testRun := range storedReqte.TestsHistory {
+		testId := testcom.FDOTestID("test-" + testRun.Uuid)
+		if _, ok := testRun.Tests[testId]; !ok || len(testRun.Tests) != 1 {
+			t.Errorf("Expected run %s to only hold its own result. Got %v", testRun.Uuid, testRun.Tests)
+		}
+
+		if testRun.Cancelled != (testRun.Uuid == firstRunUuid) {
+			t.Errorf("Expected only the first run to be cancelled")
+		}
+	}
+
+	err = reqtDB.ReportTest(reqte.Uuid, "unknown", testcom.FIDO_RVT_20_POSITIVE, testcom.NewSuccessTestState(testcom.FIDO_RVT_20_POSITIVE))
+	if err == nil {
+		t.Errorf("Expected reporting to an unknown run to fail")
+	}
+}
+
+func TestRequestTestDBUpdateKeepsConcurrentChanges(t *testing.T) {
+	db := storage.NewMemoryStore()
+	defer db.Close()
+
+	reqtDB := NewRequestTestDB(db)
+
+	reqte := reqtestsdeps.NewRequestTestInst("http://localhost:8080", fdoshared.To2, 1)
+	err := reqtDB.Save(reqte)
+	if err != nil {
+		t.Fatalf("Failed to save test instance. %s", err.Error())
+	}
+
+	err = reqtDB.Save(reqte)
+	if err == nil {
+		t.Errorf("Expected saving an existing test instance to fail")
+	}
+
+	runsCount := 20
+
+	var wg sync.WaitGroup
+	for i := 0; i < runsCount; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+
+			_, err := reqtDB.StartNewRun(reqte.Uuid, testcom.ECS_Lenient)
+			if err != nil {
+				t.Errorf("Failed to start run. %s", err.Error())
+			}
+		}()
+		go func() {
+			defer wg.Done()
+
+			err := reqtDB.Update(reqte.Uuid, func(rvte *reqtestsdeps.RequestTestInst) error {
+				rvte.Concurrency++
+				return nil
+			})
+			if err != nil {
+				t.Errorf("Failed to update test instance. %s", err.Error())
+			}
+		}()
+	}
+	wg.Wait()
+
+	storedReqte, err := reqtDB.Get(reqte.Uuid)
+	if err != nil {
+		t.Fatalf("Failed to get test instance. %s", err.Error())
+	}
+
+	if len(storedReqte.TestsHistory) != runsCount || storedReqte.Concurrency != 1+runsCount {
+		t.Errorf("Expected %d runs and concurrency %d. Got %d and %d", runsCount, 1+runsCount, len(storedReqte.TestsHistory), storedReqte.Concurrency)
+	}
+}
@@ -0,0 +1,23 @@
+package dbs
+
+import (
+	"errors"
+
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/storage"
+)
+
+const maxTxnRetries int = 10
+
+// updateWithRetry runs fn in a read-write transaction. The store rejects the commit with ErrConflict
+// when a key read by fn was changed by a concurrent writer, in that case fn is re-run on fresh data.
+func updateWithRetry(db storage.Store, fn func(txn storage.Txn) error) error {
+	var err error
+	for i := 0; i < maxTxnRetries; i++ {
+		err = db.Update(fn)
+		if !errors.Is(err, storage.ErrConflict) {
+			return err
+		}
+	}
+
+	return errors.New("Failed to commit transaction after retries. The error is: " + err.Error())
+}
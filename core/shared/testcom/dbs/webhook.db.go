@@ -0,0 +1,294 @@
+package dbs
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/records"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/storage"
+)
+
+type WebhookTestType string
+
+const (
+	WTT_RVT    WebhookTestType = "rvt"
+	WTT_DOT    WebhookTestType = "dot"
+	WTT_Device WebhookTestType = "device"
+)
+
+type WebhookEntry struct {
+	_          struct{} `cbor:",toarray"`
+	Id         string
+	Email      string
+	TestType   WebhookTestType
+	TestInstId []byte
+
+	// Ids of the RV, DO and listener test instances that publish the run events of the test
+	InstanceIds [][]byte
+
+	Url       string
+	Secret    string
+	CreatedAt int64
+
+	LastDeliveryAt int64
+	LastError      string
+}
+
+func (h *WebhookEntry) HasInstance(instanceId []byte) bool {
+	for _, webhookInstanceId := range h.InstanceIds {
+		if hex.EncodeToString(webhookInstanceId) == hex.EncodeToString(instanceId) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type WebhookDeliveryEntry struct {
+	_             struct{} `cbor:",toarray"`
+	Id            string
+	WebhookId     string
+	Payload       []byte
+	Attempts      int
+	NextAttemptAt int64
+	LastError     string
+	Failed        bool
+}
+
+type WebhookDB struct {
+	db             storage.Store
+	prefix         []byte
+	deliveryPrefix []byte
+	failedTtl      time.Duration
+}
+
+func NewWebhookDB(db storage.Store) *WebhookDB {
+	return &WebhookDB{
+		db:             db,
+		prefix:         []byte("webhook-"),
+		deliveryPrefix: []byte("webhookdlv-"),
+		failedTtl:      7 * 24 * time.Hour, // Failed deliveries are kept a week for inspection
+	}
+}
+
+func (h *WebhookDB) getEntryId(webhookId string) []byte {
+	return append(append([]byte{}, h.prefix...), []byte(webhookId)...)
+}
+
+func (h *WebhookDB) getDeliveryId(deliveryId string) []byte {
+	return append(append([]byte{}, h.deliveryPrefix...), []byte(deliveryId)...)
+}
+
+func (h *WebhookDB) getTxn(txn storage.Txn, webhookId string) (*WebhookEntry, error) {
+	itemBytes, err := txn.Get(h.getEntryId(webhookId))
+	if err != nil && errors.Is(err, storage.ErrKeyNotFound) {
+		return nil, fmt.Errorf("The webhook with id %s does not exist", webhookId)
+	} else if err != nil {
+		return nil, errors.New("Failed locating webhook entry. The error is: " + err.Error())
+	}
+
+	var webhookInst WebhookEntry
+	err = records.Unmarshal(RT_Webhook, itemBytes, &webhookInst)
+	if err != nil {
+		return nil, errors.New("Failed cbor decoding webhook entry value. The error is: " + err.Error())
+	}
+
+	return &webhookInst, nil
+}
+
+func (h *WebhookDB) setTxn(txn storage.Txn, webhookInst WebhookEntry) error {
+	webhookBytes, err := records.Marshal(RT_Webhook, webhookInst)
+	if err != nil {
+		return errors.New("Failed to marshal webhook. The error is: " + err.Error())
+	}
+
+	return txn.Set(h.getEntryId(webhookInst.Id), webhookBytes)
+}
+
+func (h *WebhookDB) iterateTxn(txn storage.Txn, fn func(webhookInst WebhookEntry) error) error {
+	return txn.Iterate(h.prefix, func(key []byte, value []byte) error {
+		var webhookInst WebhookEntry
+		err := records.Unmarshal(RT_Webhook, value, &webhookInst)
+		if err != nil {
+			return errors.New("Failed cbor decoding webhook entry value. The error is: " + err.Error())
+		}
+
+		return fn(webhookInst)
+	})
+}
+
+func (h *WebhookDB) Save(webhookInst WebhookEntry) error {
+	err := h.db.Update(func(txn storage.Txn) error {
+		return h.setTxn(txn, webhookInst)
+	})
+	if err != nil {
+		return errors.New("Failed saving webhook entry. The error is: " + err.Error())
+	}
+
+	return nil
+}
+
+func (h *WebhookDB) Get(webhookId string) (*WebhookEntry, error) {
+	var webhookInst *WebhookEntry
+	err := h.db.View(func(txn storage.Txn) error {
+		var err error
+		webhookInst, err = h.getTxn(txn, webhookId)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return webhookInst, nil
+}
+
+// List returns the user's webhooks. If testInstId is set, only the webhooks of that test instance are returned
+func (h *WebhookDB) List(email string, testInstId []byte) ([]WebhookEntry, error) {
+	webhooks := []WebhookEntry{}
+	err := h.db.View(func(txn storage.Txn) error {
+		return h.iterateTxn(txn, func(webhookInst WebhookEntry) error {
+			if webhookInst.Email != email {
+				return nil
+			}
+
+			if testInstId != nil && hex.EncodeToString(webhookInst.TestInstId) != hex.EncodeToString(testInstId) {
+				return nil
+			}
+
+			webhooks = append(webhooks, webhookInst)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+// FindByInstance returns the webhooks notified about the runs of the RV, DO or listener test instance
+func (h *WebhookDB) FindByInstance(instanceId []byte) ([]WebhookEntry, error) {
+	webhooks := []WebhookEntry{}
+	err := h.db.View(func(txn storage.Txn) error {
+		return h.iterateTxn(txn, func(webhookInst WebhookEntry) error {
+			if webhookInst.HasInstance(instanceId) {
+				webhooks = append(webhooks, webhookInst)
+			}
+
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+// Delete removes the user's webhook. Pending deliveries are dropped by the dispatcher
+func (h *WebhookDB) Delete(email string, webhookId string) error {
+	err := h.db.Update(func(txn storage.Txn) error {
+		webhookInst, err := h.getTxn(txn, webhookId)
+		if err != nil {
+			return err
+		}
+
+		if webhookInst.Email != email {
+			return fmt.Errorf("The webhook with id %s does not exist", webhookId)
+		}
+
+		return txn.Delete(h.getEntryId(webhookId))
+	})
+	if err != nil {
+		return errors.New("Failed deleting webhook. The error is: " + err.Error())
+	}
+
+	return nil
+}
+
+// RecordAttempt stores the outcome of the last delivery attempt on the webhook
+func (h *WebhookDB) RecordAttempt(webhookId string, attemptErr error) error {
+	return updateWithRetry(h.db, func(txn storage.Txn) error {
+		webhookInst, err := h.getTxn(txn, webhookId)
+		if err != nil {
+			return err
+		}
+
+		webhookInst.LastDeliveryAt = time.Now().Unix()
+		webhookInst.LastError = ""
+		if attemptErr != nil {
+			webhookInst.LastError = attemptErr.Error()
+		}
+
+		return h.setTxn(txn, *webhookInst)
+	})
+}
+
+/* ----- Deliveries ----- */
+
+// SaveDelivery stores a pending delivery, or a failed one for a week
+func (h *WebhookDB) SaveDelivery(delivery WebhookDeliveryEntry) error {
+	deliveryBytes, err := records.Marshal(RT_WebhookDelivery, delivery)
+	if err != nil {
+		return errors.New("Failed to marshal webhook delivery. The error is: " + err.Error())
+	}
+
+	err = h.db.Update(func(txn storage.Txn) error {
+		if delivery.Failed {
+			return txn.SetWithTTL(h.getDeliveryId(delivery.Id), deliveryBytes, h.failedTtl)
+		}
+
+		return txn.Set(h.getDeliveryId(delivery.Id), deliveryBytes)
+	})
+	if err != nil {
+		return errors.New("Failed saving webhook delivery. The error is: " + err.Error())
+	}
+
+	return nil
+}
+
+func (h *WebhookDB) DeleteDelivery(deliveryId string) error {
+	return h.db.Update(func(txn storage.Txn) error {
+		return txn.Delete(h.getDeliveryId(deliveryId))
+	})
+}
+
+func (h *WebhookDB) listDeliveries(filter func(delivery WebhookDeliveryEntry) bool) ([]WebhookDeliveryEntry, error) {
+	deliveries := []WebhookDeliveryEntry{}
+	err := h.db.View(func(txn storage.Txn) error {
+		return txn.Iterate(h.deliveryPrefix, func(key []byte, value []byte) error {
+			var delivery WebhookDeliveryEntry
+			err := records.Unmarshal(RT_WebhookDelivery, value, &delivery)
+			if err != nil {
+				return errors.New("Failed cbor decoding webhook delivery value. The error is: " + err.Error())
+			}
+
+			if filter(delivery) {
+				deliveries = append(deliveries, delivery)
+			}
+
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// DueDeliveries returns the pending deliveries that should be attempted now
+func (h *WebhookDB) DueDeliveries(now time.Time) ([]WebhookDeliveryEntry, error) {
+	return h.listDeliveries(func(delivery WebhookDeliveryEntry) bool {
+		return !delivery.Failed && delivery.NextAttemptAt <= now.Unix()
+	})
+}
+
+// ListDeliveries returns the pending and failed deliveries of the webhook
+func (h *WebhookDB) ListDeliveries(webhookId string) ([]WebhookDeliveryEntry, error) {
+	return h.listDeliveries(func(delivery WebhookDeliveryEntry) bool {
+		return delivery.WebhookId == webhookId
+	})
+}
@@ -0,0 +1,167 @@
+package testcom
+
+import (
+	"fmt"
+	"mime"
+	"strings"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+)
+
+// Checks of the ErrorMessages received during a test. Each is reported as a separate result, see NewErrorMessageTestID
+const (
+	FIDO_ERRMSG_CONTENT_TYPE   FDOTestID = "FIDO_ERRMSG_CONTENT_TYPE"
+	FIDO_ERRMSG_MESSAGE_TYPE   FDOTestID = "FIDO_ERRMSG_MESSAGE_TYPE"
+	FIDO_ERRMSG_ENCODING       FDOTestID = "FIDO_ERRMSG_ENCODING"
+	FIDO_ERRMSG_ERROR_CODE     FDOTestID = "FIDO_ERRMSG_ERROR_CODE"
+	FIDO_ERRMSG_PREV_MSG_ID    FDOTestID = "FIDO_ERRMSG_PREV_MSG_ID"
+	FIDO_ERRMSG_TIMESTAMP      FDOTestID = "FIDO_ERRMSG_TIMESTAMP"
+	FIDO_ERRMSG_CORRELATION_ID FDOTestID = "FIDO_ERRMSG_CORRELATION_ID"
+)
+
+var FIDO_TEST_LIST_ERRMSG []FDOTestID = []FDOTestID{
+	FIDO_ERRMSG_CONTENT_TYPE,
+	FIDO_ERRMSG_MESSAGE_TYPE,
+	FIDO_ERRMSG_ENCODING,
+	FIDO_ERRMSG_ERROR_CODE,
+	FIDO_ERRMSG_PREV_MSG_ID,
+	FIDO_ERRMSG_TIMESTAMP,
+	FIDO_ERRMSG_CORRELATION_ID,
+}
+
+// NewErrorMessageTestID names the result of an ErrorMessage check, e.g. FIDO_DOT_62_BAD_ENCODING#FIDO_ERRMSG_TIMESTAMP
+func NewErrorMessageTestID(testId FDOTestID, check FDOTestID) FDOTestID {
+	return FDOTestID(string(testId) + "#" + string(check))
+}
+
+// IsErrorMessageTestID is true for the results created by ValidateErrorMessages
+func IsErrorMessageTestID(testId FDOTestID) bool {
+	return strings.Contains(string(testId), "#")
+}
+
+// isValidTimestamp accepts null, an RFC 3339 string or a non negative epoch time, optionally tagged 0 or 1
+func isValidTimestamp(timestampBytes cbor.RawMessage) bool {
+	var timestamp interface{}
+	err := fdoshared.CborCust.Unmarshal(timestampBytes, &timestamp)
+	if err != nil {
+		return false
+	}
+
+	if tag, ok := timestamp.(cbor.Tag); ok {
+		if tag.Number != 0 && tag.Number != 1 {
+			return false
+		}
+
+		timestamp = tag.Content
+	}
+
+	switch timestampValue := timestamp.(type) {
+	case nil, uint64, time.Time:
+		return true
+	case float64:
+		return timestampValue >= 0
+	case string:
+		_, err := time.Parse(time.RFC3339, timestampValue)
+		return err == nil
+	}
+
+	return false
+}
+
+type errorMessageViolations map[FDOTestID][]string
+
+// validateErrorMessage returns the checks that apply to the received error, and the violations found
+func validateErrorMessage(receivedError fdoshared.ReceivedError) ([]FDOTestID, errorMessageViolations) {
+	violations := errorMessageViolations{}
+	checked := []FDOTestID{FIDO_ERRMSG_CONTENT_TYPE, FIDO_ERRMSG_MESSAGE_TYPE, FIDO_ERRMSG_ENCODING}
+
+	mediaType, _, err := mime.ParseMediaType(receivedError.ContentType)
+	if err != nil || mediaType != fdoshared.CONTENT_TYPE_CBOR {
+		violations[FIDO_ERRMSG_CONTENT_TYPE] = append(violations[FIDO_ERRMSG_CONTENT_TYPE], fmt.Sprintf("Expected Content-Type %s, got \"%s\"", fdoshared.CONTENT_TYPE_CBOR, receivedError.ContentType))
+	}
+
+	if receivedError.MessageType != fdoshared.TO_ERROR_255.ToString() {
+		violations[FIDO_ERRMSG_MESSAGE_TYPE] = append(violations[FIDO_ERRMSG_MESSAGE_TYPE], fmt.Sprintf("Expected Message-Type %s, got \"%s\"", fdoshared.TO_ERROR_255.ToString(), receivedError.MessageType))
+	}
+
+	var fields []cbor.RawMessage
+	err = fdoshared.CborCust.Unmarshal(receivedError.Body, &fields)
+	if err != nil || len(fields) != 5 {
+		violations[FIDO_ERRMSG_ENCODING] = append(violations[FIDO_ERRMSG_ENCODING], "Body is not an ErrorMessage array of 5 fields")
+		return checked, violations
+	}
+
+	var errorStr string
+	if fdoshared.CborCust.Unmarshal(fields[2], &errorStr) != nil {
+		violations[FIDO_ERRMSG_ENCODING] = append(violations[FIDO_ERRMSG_ENCODING], "EMErrorStr is not a text string")
+	}
+
+	checked = append(checked, FIDO_ERRMSG_ERROR_CODE, FIDO_ERRMSG_PREV_MSG_ID, FIDO_ERRMSG_TIMESTAMP, FIDO_ERRMSG_CORRELATION_ID)
+
+	var errorCode uint64
+	err = fdoshared.CborCust.Unmarshal(fields[0], &errorCode)
+	if err != nil {
+		violations[FIDO_ERRMSG_ERROR_CODE] = append(violations[FIDO_ERRMSG_ERROR_CODE], "EMErrorCode is not an unsigned integer")
+	} else if _, ok := fdoshared.FdoErrorCodeNames[fdoshared.FdoErrorCode(errorCode)]; errorCode > 0xffff || !ok {
+		violations[FIDO_ERRMSG_ERROR_CODE] = append(violations[FIDO_ERRMSG_ERROR_CODE], fmt.Sprintf("Unknown error code %d", errorCode))
+	}
+
+	var prevMsgId uint64
+	err = fdoshared.CborCust.Unmarshal(fields[1], &prevMsgId)
+	if err != nil {
+		violations[FIDO_ERRMSG_PREV_MSG_ID] = append(violations[FIDO_ERRMSG_PREV_MSG_ID], "EMPrevMsgID is not an unsigned integer")
+	} else if prevMsgId != uint64(receivedError.SentCmd) {
+		violations[FIDO_ERRMSG_PREV_MSG_ID] = append(violations[FIDO_ERRMSG_PREV_MSG_ID], fmt.Sprintf("Expected EMPrevMsgID %d, got %d", receivedError.SentCmd, prevMsgId))
+	}
+
+	if !isValidTimestamp(fields[3]) {
+		violations[FIDO_ERRMSG_TIMESTAMP] = append(violations[FIDO_ERRMSG_TIMESTAMP], "EMErrorTs is not null, a UTC string or an epoch time")
+	}
+
+	var correlationId uint64
+	err = fdoshared.CborCust.Unmarshal(fields[4], &correlationId)
+	if err != nil {
+		violations[FIDO_ERRMSG_CORRELATION_ID] = append(violations[FIDO_ERRMSG_CORRELATION_ID], "EMErrorCID is missing or not an unsigned integer")
+	}
+
+	return checked, violations
+}
+
+// ValidateErrorMessages checks the headers and fields of every ErrorMessage received during a test. Returns a result per check,
+// failed if any of the messages violates it. Checks of fields that could not be decoded are not reported
+func ValidateErrorMessages(testId FDOTestID, receivedErrors []fdoshared.ReceivedError) []FDOTestState {
+	checked := map[FDOTestID]bool{}
+	violations := errorMessageViolations{}
+
+	for _, receivedError := range receivedErrors {
+		errorChecks, errorViolations := validateErrorMessage(receivedError)
+		for _, check := range errorChecks {
+			checked[check] = true
+		}
+
+		for check, messages := range errorViolations {
+			for _, message := range messages {
+				violations[check] = append(violations[check], fmt.Sprintf("Response to %d: %s", receivedError.SentCmd, message))
+			}
+		}
+	}
+
+	var testStates []FDOTestState
+	for _, check := range FIDO_TEST_LIST_ERRMSG {
+		if !checked[check] {
+			continue
+		}
+
+		checkTestId := NewErrorMessageTestID(testId, check)
+		if len(violations[check]) > 0 {
+			testStates = append(testStates, NewFailTestState(checkTestId, strings.Join(violations[check], ". ")))
+		} else {
+			testStates = append(testStates, NewSuccessTestState(checkTestId))
+		}
+	}
+
+	return testStates
+}
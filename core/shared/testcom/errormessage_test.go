@@ -0,0 +1,81 @@
+package testcom
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+)
+
+func TestValidateErrorMessages(t *testing.T) {
+	encode := func(v interface{}) []byte {
+		encoded, err := fdoshared.CborCust.Marshal(v)
+		if err != nil {
+			t.Fatalf("Failed to encode. %s", err.Error())
+		}
+		return encoded
+	}
+
+	validError := fdoshared.ReceivedError{
+		SentCmd:     fdoshared.TO2_62_GET_OVNEXTENTRY,
+		HttpStatus:  http.StatusBadRequest,
+		ContentType: fdoshared.CONTENT_TYPE_CBOR,
+		MessageType: fdoshared.TO_ERROR_255.ToString(),
+		Body:        encode(fdoshared.NewFdoError(fdoshared.MESSAGE_BODY_ERROR, fdoshared.TO2_62_GET_OVNEXTENTRY, "error")),
+	}
+
+	testStates := ValidateErrorMessages(FIDO_DOT_62_BAD_ENCODING, []fdoshared.ReceivedError{validError})
+	if len(testStates) != len(FIDO_TEST_LIST_ERRMSG) {
+		t.Fatalf("Expected a result per check. Got %d", len(testStates))
+	}
+
+	for _, testState := range testStates {
+		if !testState.Passed {
+			t.Errorf("Expected %s to pass. Got %s", testState.TestID, testState.Error)
+		}
+	}
+
+	for _, timestamp := range []interface{}{nil, uint64(1700000000), "2024-01-01T00:00:00Z", cbor.Tag{Number: 1, Content: 1700000000}, cbor.Tag{Number: 0, Content: "2024-01-01T00:00:00Z"}} {
+		if !isValidTimestamp(encode(timestamp)) {
+			t.Errorf("Expected timestamp %v to be valid", timestamp)
+		}
+	}
+
+	for _, timestamp := range []interface{}{"yesterday", -1, []byte{1}, cbor.Tag{Number: 2, Content: 1}} {
+		if isValidTimestamp(encode(timestamp)) {
+			t.Errorf("Expected timestamp %v to be invalid", timestamp)
+		}
+	}
+
+	badError := validError
+	badError.ContentType = "text/plain"
+	badError.MessageType = ""
+	badError.Body = encode([]interface{}{999, 60, "error", "yesterday"})
+
+	expectedResults := map[FDOTestID]bool{
+		NewErrorMessageTestID(FIDO_DOT_62_BAD_ENCODING, FIDO_ERRMSG_CONTENT_TYPE): false,
+		NewErrorMessageTestID(FIDO_DOT_62_BAD_ENCODING, FIDO_ERRMSG_MESSAGE_TYPE): false,
+		NewErrorMessageTestID(FIDO_DOT_62_BAD_ENCODING, FIDO_ERRMSG_ENCODING):     false,
+	}
+
+	testStates = ValidateErrorMessages(FIDO_DOT_62_BAD_ENCODING, []fdoshared.ReceivedError{badError})
+	if len(testStates) != len(expectedResults) {
+		t.Fatalf("Expected the field checks to be skipped for an undecodable body. Got %+v", testStates)
+	}
+
+	for _, testState := range testStates {
+		passed, ok := expectedResults[testState.TestID]
+		if !ok || testState.Passed != passed {
+			t.Errorf("Unexpected result %+v", testState)
+		}
+	}
+
+	badError.Body = encode([]interface{}{999, 60, "error", "yesterday", -1})
+	for _, testState := range ValidateErrorMessages(FIDO_DOT_62_BAD_ENCODING, []fdoshared.ReceivedError{badError}) {
+		if testState.Passed && testState.TestID != NewErrorMessageTestID(FIDO_DOT_62_BAD_ENCODING, FIDO_ERRMSG_ENCODING) {
+			t.Errorf("Expected %s to fail", testState.TestID)
+		}
+	}
+}
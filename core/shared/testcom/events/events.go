@@ -0,0 +1,208 @@
+package events
+
+import (
+	"encoding/hex"
+	"sync"
+	"time"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+)
+
+type EventType string
+
+const (
+	EventTestStarted  EventType = "test-started"
+	EventTestFinished EventType = "test-finished"
+	EventRunFinished  EventType = "run-finished"
+)
+
+// Subscribers that do not keep up lose events instead of blocking the test executors
+const subscriptionBufferSize int = 64
+
+// runFinishedTimeout is how long a run finished event waits for a full subscription. The event ends the run, so a
+// subscription that can not take it in time is closed, instead of silently missing it
+var runFinishedTimeout time.Duration = 2 * time.Second
+
+type Event struct {
+	Type       EventType               `json:"type"`
+	InstanceId string                  `json:"instanceId"`
+	RunId      string                  `json:"runId"`
+	Protocol   fdoshared.FdoToProtocol `json:"protocol"`
+	TestId     testcom.FDOTestID       `json:"testId,omitempty"`
+	TestState  *testcom.FDOTestState   `json:"testState,omitempty"`
+	Cancelled  bool                    `json:"cancelled,omitempty"`
+	Summary    *testcom.RunSummary     `json:"summary,omitempty"`
+	Timestamp  int64                   `json:"timestamp"`
+}
+
+func NewTestStartedEvent(instanceId []byte, runId string, protocol fdoshared.FdoToProtocol, testId testcom.FDOTestID) Event {
+	return Event{
+		Type:       EventTestStarted,
+		InstanceId: hex.EncodeToString(instanceId),
+		RunId:      runId,
+		Protocol:   protocol,
+		TestId:     testId,
+		Timestamp:  time.Now().Unix(),
+	}
+}
+
+func NewTestFinishedEvent(instanceId []byte, runId string, protocol fdoshared.FdoToProtocol, testId testcom.FDOTestID, testState testcom.FDOTestState) Event {
+	return Event{
+		Type:       EventTestFinished,
+		InstanceId: hex.EncodeToString(instanceId),
+		RunId:      runId,
+		Protocol:   protocol,
+		TestId:     testId,
+		TestState:  &testState,
+		Timestamp:  time.Now().Unix(),
+	}
+}
+
+func NewRunFinishedEvent(instanceId []byte, runId string, protocol fdoshared.FdoToProtocol, cancelled bool, summary testcom.RunSummary) Event {
+	return Event{
+		Type:       EventRunFinished,
+		InstanceId: hex.EncodeToString(instanceId),
+		RunId:      runId,
+		Protocol:   protocol,
+		Cancelled:  cancelled,
+		Summary:    &summary,
+		Timestamp:  time.Now().Unix(),
+	}
+}
+
+type Subscription struct {
+	InstanceIds []string
+	Events      chan Event
+}
+
+func (h *Subscription) matches(event Event) bool {
+	for _, instanceId := range h.InstanceIds {
+		if instanceId == event.InstanceId {
+			return true
+		}
+	}
+
+	return false
+}
+
+type Broker struct {
+	mu            sync.Mutex
+	subscriptions map[*Subscription]struct{}
+	hooks         map[*func(event Event)]struct{}
+}
+
+func NewBroker() *Broker {
+	return &Broker{
+		subscriptions: map[*Subscription]struct{}{},
+		hooks:         map[*func(event Event)]struct{}{},
+	}
+}
+
+// Subscribe returns a subscription receiving the events of the given test instances
+func (h *Broker) Subscribe(instanceIds [][]byte) *Subscription {
+	sub := &Subscription{
+		Events: make(chan Event, subscriptionBufferSize),
+	}
+
+	for _, instanceId := range instanceIds {
+		sub.InstanceIds = append(sub.InstanceIds, hex.EncodeToString(instanceId))
+	}
+
+	h.mu.Lock()
+	h.subscriptions[sub] = struct{}{}
+	h.mu.Unlock()
+
+	return sub
+}
+
+func (h *Broker) Unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subscriptions[sub]; ok {
+		delete(h.subscriptions, sub)
+		close(sub.Events)
+	}
+}
+
+// AddHook registers a function called synchronously with every event. Unlike subscriptions, hooks never miss
+// events, so they must return quickly. The returned function removes the hook
+func (h *Broker) AddHook(hook func(event Event)) func() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	hookRef := &hook
+	h.hooks[hookRef] = struct{}{}
+
+	return func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		delete(h.hooks, hookRef)
+	}
+}
+
+func (h *Broker) Publish(event Event) {
+	h.mu.Lock()
+	hooks := make([]func(event Event), 0, len(h.hooks))
+	for hook := range h.hooks {
+		hooks = append(hooks, *hook)
+	}
+	h.mu.Unlock()
+
+	for _, hook := range hooks {
+		hook(event)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.subscriptions {
+		if !sub.matches(event) {
+			continue
+		}
+
+		select {
+		case sub.Events <- event:
+			continue
+		default:
+		}
+
+		if event.Type != EventRunFinished {
+			continue
+		}
+
+		timer := time.NewTimer(runFinishedTimeout)
+		select {
+		case sub.Events <- event:
+		case <-timer.C:
+			delete(h.subscriptions, sub)
+			close(sub.Events)
+		}
+		timer.Stop()
+	}
+}
+
+// HooksCount returns the number of registered hooks
+func (h *Broker) HooksCount() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return len(h.hooks)
+}
+
+// SubscriptionsCount returns the number of open subscriptions
+func (h *Broker) SubscriptionsCount() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return len(h.subscriptions)
+}
+
+// DefaultBroker is shared by the executors, the listeners and the events API
+var DefaultBroker *Broker = NewBroker()
+
+func Publish(event Event) {
+	DefaultBroker.Publish(event)
+}
@@ -0,0 +1,69 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+)
+
+func TestBrokerRunFinishedIsNotDropped(t *testing.T) {
+	broker := NewBroker()
+	instanceId := []byte{0x01}
+
+	// The subscriber reads only after its buffer is full
+	sub := broker.Subscribe([][]byte{instanceId})
+	for i := 0; i < subscriptionBufferSize+10; i++ {
+		broker.Publish(NewTestStartedEvent(instanceId, "run", fdoshared.To2, testcom.FIDO_DOT_70_POSITIVE))
+	}
+
+	go broker.Publish(NewRunFinishedEvent(instanceId, "run", fdoshared.To2, false, testcom.RunSummary{}))
+
+	var lastEvent Event
+	for i := 0; i < subscriptionBufferSize+1; i++ {
+		lastEvent = <-sub.Events
+	}
+
+	if lastEvent.Type != EventRunFinished {
+		t.Errorf("Expected the run finished event after the buffered events. Got %s", lastEvent.Type)
+	}
+
+	broker.Unsubscribe(sub)
+
+	// A subscriber that never reads is closed, instead of missing the end of the run
+	defer func(timeout time.Duration) { runFinishedTimeout = timeout }(runFinishedTimeout)
+	runFinishedTimeout = 10 * time.Millisecond
+
+	sub = broker.Subscribe([][]byte{instanceId})
+	for i := 0; i < subscriptionBufferSize; i++ {
+		broker.Publish(NewTestStartedEvent(instanceId, "run", fdoshared.To2, testcom.FIDO_DOT_70_POSITIVE))
+	}
+	broker.Publish(NewRunFinishedEvent(instanceId, "run", fdoshared.To2, false, testcom.RunSummary{}))
+
+	if broker.SubscriptionsCount() != 0 {
+		t.Errorf("Expected the full subscription to be closed")
+	}
+
+	for range sub.Events {
+	}
+
+	broker.Unsubscribe(sub)
+}
+
+func TestBrokerRemoveHook(t *testing.T) {
+	broker := NewBroker()
+
+	var calls int
+	removeHook := broker.AddHook(func(event Event) {
+		calls++
+	})
+
+	broker.Publish(NewTestStartedEvent([]byte{0x01}, "run", fdoshared.To2, testcom.FIDO_DOT_70_POSITIVE))
+	removeHook()
+	broker.Publish(NewTestStartedEvent([]byte{0x01}, "run", fdoshared.To2, testcom.FIDO_DOT_70_POSITIVE))
+
+	if calls != 1 {
+		t.Errorf("Expected the hook to be called once. Got %d", calls)
+	}
+}
@@ -0,0 +1,76 @@
+package testcom
+
+import (
+	"context"
+	"time"
+)
+
+// Session expiry tests. Each pauses within a session for the wait set with WithSessionExpiry, expects the next message
+// to be rejected, and then a new session to succeed
+const (
+	FIDO_RVT_22_EXPIRED_SESSION  FDOTestID = "FIDO_RVT_22_EXPIRED_SESSION"
+	FIDO_DEVT_32_EXPIRED_SESSION FDOTestID = "FIDO_DEVT_32_EXPIRED_SESSION"
+	FIDO_DOT_62_EXPIRED_SESSION  FDOTestID = "FIDO_DOT_62_EXPIRED_SESSION"
+	FIDO_DOT_68_EXPIRED_SESSION  FDOTestID = "FIDO_DOT_68_EXPIRED_SESSION"
+)
+
+var FIDO_TEST_LIST_RVT_EXPIRY []FDOTestID = []FDOTestID{
+	FIDO_RVT_22_EXPIRED_SESSION,
+}
+
+var FIDO_TEST_LIST_DEVT_EXPIRY []FDOTestID = []FDOTestID{
+	FIDO_DEVT_32_EXPIRED_SESSION,
+}
+
+var FIDO_TEST_LIST_DOT_EXPIRY []FDOTestID = []FDOTestID{
+	FIDO_DOT_62_EXPIRED_SESSION,
+	FIDO_DOT_68_EXPIRED_SESSION,
+}
+
+// Sleeper pauses a test for d. It returns early with the error of ctx if ctx is done
+type Sleeper func(ctx context.Context, d time.Duration) error
+
+// SleepContext is the Sleeper that waits in real time
+func SleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// SessionExpiry is the pause of the session expiry tests. Sleep can be replaced with a virtual clock for local targets
+type SessionExpiry struct {
+	Wait  time.Duration
+	Sleep Sleeper
+}
+
+type sessionExpiryCtxKey struct{}
+
+// WithSessionExpiry enables the session expiry tests. A nil sleep waits in real time.
+// A virtual sleep only expires sessions of local servers on an in memory store driven by the same clock, see
+// storage.NewMemoryStoreWithClock. The Badger and SQLite stores always expire entries on the wall clock
+func WithSessionExpiry(ctx context.Context, wait time.Duration, sleep Sleeper) context.Context {
+	if sleep == nil {
+		sleep = SleepContext
+	}
+
+	return context.WithValue(ctx, sessionExpiryCtxKey{}, SessionExpiry{
+		Wait:  wait,
+		Sleep: sleep,
+	})
+}
+
+// GetSessionExpiry returns the pause set in ctx. The tests are disabled, and ok is false, if there is none
+func GetSessionExpiry(ctx context.Context) (SessionExpiry, bool) {
+	expiry, ok := ctx.Value(sessionExpiryCtxKey{}).(SessionExpiry)
+	if !ok || expiry.Wait <= 0 {
+		return SessionExpiry{}, false
+	}
+
+	return expiry, true
+}
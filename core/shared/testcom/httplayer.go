@@ -0,0 +1,118 @@
+package testcom
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+)
+
+// HTTP layer tests. Each sends every message of the protocol as a malformed request, see NewHttpTestRequest
+const (
+	FIDO_HTTP_GET_METHOD              FDOTestID = "FIDO_HTTP_GET_METHOD"
+	FIDO_HTTP_WRONG_CONTENT_TYPE      FDOTestID = "FIDO_HTTP_WRONG_CONTENT_TYPE"
+	FIDO_HTTP_MISSING_CONTENT_TYPE    FDOTestID = "FIDO_HTTP_MISSING_CONTENT_TYPE"
+	FIDO_HTTP_OVERSIZE_BODY           FDOTestID = "FIDO_HTTP_OVERSIZE_BODY"
+	FIDO_HTTP_EMPTY_BODY              FDOTestID = "FIDO_HTTP_EMPTY_BODY"
+	FIDO_HTTP_MISSING_AUTHORIZATION   FDOTestID = "FIDO_HTTP_MISSING_AUTHORIZATION"
+	FIDO_HTTP_DUPLICATE_AUTHORIZATION FDOTestID = "FIDO_HTTP_DUPLICATE_AUTHORIZATION"
+)
+
+// FIDO_TEST_LIST_HTTP runs against the RV
+var FIDO_TEST_LIST_HTTP []FDOTestID = []FDOTestID{
+	FIDO_HTTP_GET_METHOD,
+	FIDO_HTTP_WRONG_CONTENT_TYPE,
+	FIDO_HTTP_MISSING_CONTENT_TYPE,
+	FIDO_HTTP_OVERSIZE_BODY,
+	FIDO_HTTP_EMPTY_BODY,
+}
+
+// FIDO_TEST_LIST_HTTP_TO2 runs against the DO, and also checks the authorization of the TO2 follow-up messages
+var FIDO_TEST_LIST_HTTP_TO2 []FDOTestID = append(append([]FDOTestID{}, FIDO_TEST_LIST_HTTP...),
+	FIDO_HTTP_MISSING_AUTHORIZATION,
+	FIDO_HTTP_DUPLICATE_AUTHORIZATION,
+)
+
+var httpTestExpectedStatus map[FDOTestID]int = map[FDOTestID]int{
+	FIDO_HTTP_GET_METHOD:              http.StatusMethodNotAllowed,
+	FIDO_HTTP_WRONG_CONTENT_TYPE:      http.StatusUnsupportedMediaType,
+	FIDO_HTTP_MISSING_CONTENT_TYPE:    http.StatusUnsupportedMediaType,
+	FIDO_HTTP_OVERSIZE_BODY:           http.StatusRequestEntityTooLarge,
+	FIDO_HTTP_EMPTY_BODY:              http.StatusBadRequest,
+	FIDO_HTTP_MISSING_AUTHORIZATION:   http.StatusUnauthorized,
+	FIDO_HTTP_DUPLICATE_AUTHORIZATION: http.StatusUnauthorized,
+}
+
+// IsHttpAuthorizationTest is true for the tests that only apply to messages sent within a session
+func IsHttpAuthorizationTest(testId FDOTestID) bool {
+	return testId == FIDO_HTTP_MISSING_AUTHORIZATION || testId == FIDO_HTTP_DUPLICATE_AUTHORIZATION
+}
+
+// NewHttpTestRequest returns the malformed request of the test. authzHeader is the session of follow-up messages, empty for the first message
+func NewHttpTestRequest(testId FDOTestID, authzHeader string) fdoshared.FdoHttpRequest {
+	// An empty CBOR array. Servers must reject the request before looking at it
+	body := []byte{0x80}
+
+	header := http.Header{}
+	header.Set("Content-Type", fdoshared.CONTENT_TYPE_CBOR)
+	if authzHeader != "" {
+		header.Set("Authorization", authzHeader)
+	}
+
+	method := http.MethodPost
+
+	switch testId {
+	case FIDO_HTTP_GET_METHOD:
+		method = http.MethodGet
+		body = nil
+	case FIDO_HTTP_WRONG_CONTENT_TYPE:
+		header.Set("Content-Type", "application/json")
+	case FIDO_HTTP_MISSING_CONTENT_TYPE:
+		header.Del("Content-Type")
+	case FIDO_HTTP_OVERSIZE_BODY:
+		body = bytes.Repeat([]byte{0x00}, int(fdoshared.MAX_MESSAGE_BODY_SIZE)+1)
+	case FIDO_HTTP_EMPTY_BODY:
+		body = nil
+	case FIDO_HTTP_MISSING_AUTHORIZATION:
+		header.Del("Authorization")
+	case FIDO_HTTP_DUPLICATE_AUTHORIZATION:
+		header.Add("Authorization", "Bearer "+fdoshared.NewFdoGuid().GetFormatted())
+	}
+
+	return fdoshared.FdoHttpRequest{
+		Method: method,
+		Header: header,
+		Body:   body,
+	}
+}
+
+// ExpectHttpError expects the rejection of a malformed request to be an FDO error with Message-Type 255.
+// A status other than the one CheckHeaders or ExtractAuthorizationHeader would respond with is handled as per strictness
+func ExpectHttpError(testId FDOTestID, cmd fdoshared.FdoCmd, httpStatus int, respHeader http.Header, bodyBytes []byte, strictness ErrorCodeStrictness) FDOTestState {
+	expectedStatus := httpTestExpectedStatus[testId]
+
+	if httpStatus == http.StatusOK {
+		return NewFailTestState(testId, fmt.Sprintf("%d: Server return HTTP 200OK. Expected HTTP %d", cmd, expectedStatus))
+	}
+
+	if messageType := respHeader.Get("Message-Type"); messageType != fdoshared.TO_ERROR_255.ToString() {
+		return NewFailTestState(testId, fmt.Sprintf("%d: Expected Message-Type %s, got \"%s\"", cmd, fdoshared.TO_ERROR_255.ToString(), messageType))
+	}
+
+	_, err := fdoshared.DecodeErrorResponse(bodyBytes)
+	if err != nil {
+		return NewFailTestState(testId, fmt.Sprintf("%d: Could not decode FDO Error", cmd))
+	}
+
+	if httpStatus == expectedStatus || strictness == ECS_Lenient {
+		return NewSuccessTestState(testId)
+	}
+
+	mismatch := fmt.Sprintf("%d: Expected HTTP %d, got %d", cmd, expectedStatus, httpStatus)
+	if strictness == ECS_Warn {
+		return NewWarnTestState(testId, []string{mismatch})
+	}
+
+	return NewFailTestState(testId, mismatch)
+}
@@ -2,9 +2,11 @@ package listener
 
 import (
 	"fmt"
+	"time"
 
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/events"
 )
 
 type RequestListenerRunnerInst struct {
@@ -19,6 +21,15 @@ type RequestListenerRunnerInst struct {
 	Completed        bool                                     `cbor:"completed,omitempty"`
 	CurrentTestRun   ListenerTestRun                          `cbor:"currentTestRun,omitempty"`
 	TestRunHistory   []ListenerTestRun                        `cbor:"testRunHistory,omitempty"`
+
+	// The message the last test started on, and when it was received in microseconds. Used for the test timings
+	LastTestCmd       fdoshared.FdoCmd `cbor:"lastTestCmd,omitempty"`
+	LastTestStartedAt int64            `cbor:"lastTestStartedAt,omitempty"`
+
+	// Not stored, set by BindRunners. Used to publish run events
+	InstanceUuid []byte `cbor:"-"`
+	// Not stored. Events of the changes made since the runner was loaded, published once they are saved
+	pendingEvents []events.Event
 }
 
 type RequestListenerInst struct {
@@ -31,6 +42,23 @@ type RequestListenerInst struct {
 	To2         RequestListenerRunnerInst        `cbor:"to2,omitempty"`
 }
 
+// BindRunners links the protocol runners to their listener instance
+func (h *RequestListenerInst) BindRunners() {
+	h.To0.InstanceUuid = h.Uuid
+	h.To1.InstanceUuid = h.Uuid
+	h.To2.InstanceUuid = h.Uuid
+}
+
+// ComputeTimingSummaries computes the timing summaries of the runs of every protocol
+func (h *RequestListenerInst) ComputeTimingSummaries() {
+	for _, runner := range []*RequestListenerRunnerInst{&h.To0, &h.To1, &h.To2} {
+		runner.CurrentTestRun.ComputeTimingSummary()
+		for i := range runner.TestRunHistory {
+			runner.TestRunHistory[i].ComputeTimingSummary()
+		}
+	}
+}
+
 func (h *RequestListenerInst) GetProtocolInst(toProtocol int) (*RequestListenerRunnerInst, error) {
 	switch fdoshared.FdoToProtocol(toProtocol) {
 	case fdoshared.To0:
@@ -122,6 +150,9 @@ func (h *RequestListenerRunnerInst) GetNextTestID() testcom.FDOTestID {
 	selectedTestID := h.Tests[h.ExpectedCmd][h.CurrentTestIndex]
 
 	h.LastTestID = selectedTestID
+	h.LastTestCmd = h.ExpectedCmd
+	h.LastTestStartedAt = time.Now().UnixMicro()
+	h.pendingEvents = append(h.pendingEvents, events.NewTestStartedEvent(h.InstanceUuid, h.CurrentTestRun.Uuid, h.Protocol, selectedTestID))
 
 	if h.CurrentTestIndex+1 < len(h.Tests[h.ExpectedCmd]) {
 		h.CurrentTestIndex = h.CurrentTestIndex + 1
@@ -147,12 +178,38 @@ func (h *RequestListenerRunnerInst) CompleteTestRun() {
 
 	h.CurrentTestRun.Complete()
 	h.TestRunHistory = append(h.TestRunHistory, h.CurrentTestRun)
+
+	h.pendingEvents = append(h.pendingEvents, events.NewRunFinishedEvent(h.InstanceUuid, h.CurrentTestRun.Uuid, h.Protocol, false, testcom.NewRunSummary(h.CurrentTestRun.TestRuns)))
 }
 
 func (h *RequestListenerRunnerInst) PushFail(errorMsg string) {
-	h.CurrentTestRun.TestRuns = append(h.CurrentTestRun.TestRuns, testcom.NewFailTestState(h.GetLastTestID(), errorMsg))
+	h.pushResult(testcom.NewFailTestState(h.GetLastTestID(), errorMsg))
 }
 
 func (h *RequestListenerRunnerInst) PushSuccess() {
-	h.CurrentTestRun.TestRuns = append(h.CurrentTestRun.TestRuns, testcom.NewSuccessTestState(h.GetLastTestID()))
+	h.pushResult(testcom.NewSuccessTestState(h.GetLastTestID()))
+}
+
+// pushResult records the time from the message the test started on until its result, which for most tests is the
+// next message of the device. It covers our response, the network and the processing on the device
+func (h *RequestListenerRunnerInst) pushResult(testState testcom.FDOTestState) {
+	if h.LastTestStartedAt != 0 {
+		testState.Timings = []fdoshared.MessageTiming{{
+			Cmd:         h.LastTestCmd,
+			RoundTripUs: time.Now().UnixMicro() - h.LastTestStartedAt,
+		}}
+	}
+
+	h.CurrentTestRun.TestRuns = append(h.CurrentTestRun.TestRuns, testState)
+
+	h.pendingEvents = append(h.pendingEvents, events.NewTestFinishedEvent(h.InstanceUuid, h.CurrentTestRun.Uuid, h.Protocol, testState.TestID, testState))
+}
+
+// PublishEvents publishes the events of the changes made to the runner. Call it once the runner is saved
+func (h *RequestListenerRunnerInst) PublishEvents() {
+	for _, event := range h.pendingEvents {
+		events.Publish(event)
+	}
+
+	h.pendingEvents = nil
 }
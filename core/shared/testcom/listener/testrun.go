@@ -16,6 +16,9 @@ type ListenerTestRun struct {
 	TestRuns  []testcom.FDOTestState  `json:"tests"`
 	Protocol  fdoshared.FdoToProtocol `json:"protocol"`
 	Completed bool                    `json:"completed"`
+
+	// Computed on read, not stored
+	TimingSummary testcom.TimingSummary `cbor:"-" json:"timingSummary"`
 }
 
 func NewListenerTestRun(protocol fdoshared.FdoToProtocol) ListenerTestRun {
@@ -34,3 +37,7 @@ func NewListenerTestRun(protocol fdoshared.FdoToProtocol) ListenerTestRun {
 func (h *ListenerTestRun) Complete() {
 	h.Completed = true
 }
+
+func (h *ListenerTestRun) ComputeTimingSummary() {
+	h.TimingSummary = testcom.NewTimingSummary(h.TestRuns)
+}
@@ -3,6 +3,7 @@ package testcom
 import (
 	"fmt"
 	"net/http"
+	"strings"
 
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
 )
@@ -37,6 +38,73 @@ func ExpectAnyFdoError(bodyBytes []byte, testId FDOTestID, expectedFdoError fdos
 	return NewSuccessTestState(testId)
 }
 
+// GetExpectedFdoError returns the error code the spec requires for a negative test, MESSAGE_BODY_ERROR if none is listed
+func GetExpectedFdoError(testId FDOTestID) fdoshared.FdoErrorCode {
+	expectedFdoError, ok := FIDO_TEST_TO_FDO_ERROR_CODE[testId]
+	if !ok {
+		return fdoshared.MESSAGE_BODY_ERROR
+	}
+
+	return expectedFdoError
+}
+
+// ExpectFdoErrorWithStrictness expects a decodable FDO error with the error code of the test and a 4xx HTTP status.
+// A wrong code or status is ignored, reported as a warning or fails the test, depending on strictness
+func ExpectFdoErrorWithStrictness(bodyBytes []byte, testId FDOTestID, httpStatus int, strictness ErrorCodeStrictness) FDOTestState {
+	if httpStatus == http.StatusOK {
+		return NewFailTestState(testId, "Server return HTTP 200OK. Expected error.")
+	}
+
+	fdoErrInst, err := fdoshared.DecodeErrorResponse(bodyBytes)
+	if err != nil {
+		return NewFailTestState(testId, "Could not decode FDO Error")
+	}
+
+	var mismatches []string
+
+	expectedFdoError := GetExpectedFdoError(testId)
+	if fdoErrInst.EMErrorCode != expectedFdoError {
+		mismatches = append(mismatches, fmt.Sprintf("Expected error code %d, got %d", expectedFdoError, fdoErrInst.EMErrorCode))
+	}
+
+	if httpStatus < 400 || httpStatus > 499 {
+		mismatches = append(mismatches, fmt.Sprintf("Expected a 4xx HTTP status, got %d", httpStatus))
+	}
+
+	if len(mismatches) == 0 || strictness == ECS_Lenient {
+		return NewSuccessTestState(testId)
+	}
+
+	if strictness == ECS_Warn {
+		return NewWarnTestState(testId, mismatches)
+	}
+
+	return NewFailTestState(testId, strings.Join(mismatches, ". "))
+}
+
+// MergeTestStates combines the results of the steps of a test. It fails if any step failed
+func MergeTestStates(testId FDOTestID, testStates []FDOTestState) FDOTestState {
+	var errs []string
+	var warnings []string
+	for _, testState := range testStates {
+		if !testState.Passed {
+			errs = append(errs, testState.Error)
+		}
+
+		warnings = append(warnings, testState.Warnings...)
+	}
+
+	if len(errs) > 0 {
+		return NewFailTestState(testId, strings.Join(errs, ". "))
+	}
+
+	if len(warnings) > 0 {
+		return NewWarnTestState(testId, warnings)
+	}
+
+	return NewSuccessTestState(testId)
+}
+
 func ExpectedFdoSuccess(testId FDOTestID, httpStatus int) FDOTestState {
 	if httpStatus != http.StatusOK {
 		return NewFailTestState(testId, fmt.Sprintf("Server return HTTP Error %d. Expected HTTP 200OK", httpStatus))
@@ -0,0 +1,45 @@
+package testcom
+
+import (
+	"net/http"
+	"testing"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+)
+
+func TestExpectFdoErrorWithStrictness(t *testing.T) {
+	// FIDO_DEVT_30_BAD_UNKNOWN_GUID expects RESOURCE_NOT_FOUND
+	testId := FIDO_DEVT_30_BAD_UNKNOWN_GUID
+
+	encodeError := func(errorCode fdoshared.FdoErrorCode) []byte {
+		fdoError := fdoshared.NewFdoError(errorCode, fdoshared.TO1_30_HELLO_RV, "error")
+		errorBytes, _ := fdoshared.CborCust.Marshal(fdoError)
+		return errorBytes
+	}
+
+	for _, tc := range []struct {
+		name         string
+		errorCode    fdoshared.FdoErrorCode
+		httpStatus   int
+		strictness   ErrorCodeStrictness
+		passed       bool
+		warningCount int
+	}{
+		{"expected error", fdoshared.RESOURCE_NOT_FOUND, http.StatusBadRequest, ECS_Strict, true, 0},
+		{"lenient wrong code", fdoshared.MESSAGE_BODY_ERROR, http.StatusBadRequest, ECS_Lenient, true, 0},
+		{"warn wrong code", fdoshared.MESSAGE_BODY_ERROR, http.StatusBadRequest, ECS_Warn, true, 1},
+		{"warn wrong code and status", fdoshared.MESSAGE_BODY_ERROR, http.StatusInternalServerError, ECS_Warn, true, 2},
+		{"strict wrong code", fdoshared.MESSAGE_BODY_ERROR, http.StatusBadRequest, ECS_Strict, false, 0},
+		{"strict wrong status", fdoshared.RESOURCE_NOT_FOUND, http.StatusInternalServerError, ECS_Strict, false, 0},
+	} {
+		testState := ExpectFdoErrorWithStrictness(encodeError(tc.errorCode), testId, tc.httpStatus, tc.strictness)
+		if testState.Passed != tc.passed || len(testState.Warnings) != tc.warningCount {
+			t.Errorf("%s: unexpected test state %+v", tc.name, testState)
+		}
+	}
+
+	testState := ExpectFdoErrorWithStrictness([]byte{}, testId, http.StatusOK, ECS_Lenient)
+	if testState.Passed {
+		t.Errorf("Expected HTTP 200 to fail")
+	}
+}
@@ -67,6 +67,26 @@ const (
 	FIDO_DOT_70_BAD_NONCE_PROVE_DV_61 FDOTestID = "FIDO_DOT_70_BAD_NONCE_PROVE_DV_61"
 	FIDO_DOT_70_POSITIVE              FDOTestID = "FIDO_DOT_70_POSITIVE"
 
+	// Out of order messages. The target must reject the message, and end the session if there is one
+	FIDO_RVT_22_WITHOUT_20  FDOTestID = "FIDO_RVT_22_WITHOUT_20"
+	FIDO_DEVT_32_WITHOUT_30 FDOTestID = "FIDO_DEVT_32_WITHOUT_30"
+	FIDO_DOT_64_BEFORE_62   FDOTestID = "FIDO_DOT_64_BEFORE_62"
+	FIDO_DOT_66_TWICE       FDOTestID = "FIDO_DOT_66_TWICE"
+	FIDO_DOT_70_AFTER_60    FDOTestID = "FIDO_DOT_70_AFTER_60"
+
+	// Replays and session binding. Nonces and tokens must only be accepted in the session they belong to
+	FIDO_DEVT_32_REPLAY           FDOTestID = "FIDO_DEVT_32_REPLAY"
+	FIDO_DEVT_32_OTHER_GUID       FDOTestID = "FIDO_DEVT_32_OTHER_GUID"
+	FIDO_DEVT_32_PARALLEL_SESSION FDOTestID = "FIDO_DEVT_32_PARALLEL_SESSION"
+	FIDO_DOT_64_REPLAY            FDOTestID = "FIDO_DOT_64_REPLAY"
+	FIDO_DOT_64_OTHER_GUID        FDOTestID = "FIDO_DOT_64_OTHER_GUID"
+	FIDO_DOT_64_PARALLEL_SESSION  FDOTestID = "FIDO_DOT_64_PARALLEL_SESSION"
+	FIDO_DOT_70_AFTER_DONE        FDOTestID = "FIDO_DOT_70_AFTER_DONE"
+
+	// Concurrent sessions. Every session of many simultaneous flows must complete with its own state
+	FIDO_DEVT_STRESS_SESSION_ISOLATION FDOTestID = "FIDO_DEVT_STRESS_SESSION_ISOLATION"
+	FIDO_DOT_STRESS_SESSION_ISOLATION  FDOTestID = "FIDO_DOT_STRESS_SESSION_ISOLATION"
+
 	// Voucher tests
 	FIDO_TEST_VOUCHER_HEADER_BAD_PROT_VERSION     FDOTestID = "FIDO_TEST_VOUCHER_HEADER_BAD_PROT_VERSION"
 	FIDO_TEST_VOUCHER_HEADER_BAD_RVINFO_EMPTY     FDOTestID = "FIDO_TEST_VOUCHER_HEADER_BAD_RVINFO_EMPTY"
@@ -160,6 +180,33 @@ var FIDO_TEST_LIST_DOT_70 []FDOTestID = []FDOTestID{
 	FIDO_DOT_70_POSITIVE,
 }
 
+var FIDO_TEST_LIST_RVT_ORDER []FDOTestID = []FDOTestID{
+	FIDO_RVT_22_WITHOUT_20,
+}
+
+var FIDO_TEST_LIST_DEVT_ORDER []FDOTestID = []FDOTestID{
+	FIDO_DEVT_32_WITHOUT_30,
+}
+
+var FIDO_TEST_LIST_DOT_ORDER []FDOTestID = []FDOTestID{
+	FIDO_DOT_64_BEFORE_62,
+	FIDO_DOT_66_TWICE,
+	FIDO_DOT_70_AFTER_60,
+}
+
+var FIDO_TEST_LIST_DEVT_REPLAY []FDOTestID = []FDOTestID{
+	FIDO_DEVT_32_REPLAY,
+	FIDO_DEVT_32_OTHER_GUID,
+	FIDO_DEVT_32_PARALLEL_SESSION,
+}
+
+var FIDO_TEST_LIST_DOT_REPLAY []FDOTestID = []FDOTestID{
+	FIDO_DOT_64_REPLAY,
+	FIDO_DOT_64_OTHER_GUID,
+	FIDO_DOT_64_PARALLEL_SESSION,
+	FIDO_DOT_70_AFTER_DONE,
+}
+
 var FIDO_TEST_LIST_VOUCHER []FDOTestID = []FDOTestID{
 	FIDO_TEST_VOUCHER_HEADER_BAD_PROT_VERSION,
 	FIDO_TEST_VOUCHER_HEADER_BAD_RVINFO_EMPTY,
@@ -216,6 +263,13 @@ var FIDO_TEST_TO_FDO_ERROR_CODE map[FDOTestID]fdoshared.FdoErrorCode = map[FDOTe
 	FIDO_DOT_70_BAD_ENCRYPTION:        fdoshared.MESSAGE_BODY_ERROR,
 	FIDO_DOT_70_BAD_NONCE_PROVE_DV_61: fdoshared.INVALID_MESSAGE_ERROR,
 
+	FIDO_DEVT_32_REPLAY:           fdoshared.INVALID_MESSAGE_ERROR,
+	FIDO_DEVT_32_OTHER_GUID:       fdoshared.INVALID_MESSAGE_ERROR,
+	FIDO_DEVT_32_PARALLEL_SESSION: fdoshared.INVALID_MESSAGE_ERROR,
+	FIDO_DOT_64_REPLAY:            fdoshared.INVALID_MESSAGE_ERROR,
+	FIDO_DOT_64_OTHER_GUID:        fdoshared.INVALID_MESSAGE_ERROR,
+	FIDO_DOT_64_PARALLEL_SESSION:  fdoshared.INVALID_MESSAGE_ERROR,
+
 	FIDO_TEST_VOUCHER_HEADER_BAD_PROT_VERSION:     fdoshared.INVALID_OWNERSHIP_VOUCHER,
 	FIDO_TEST_VOUCHER_HEADER_BAD_RVINFO_EMPTY:     fdoshared.INVALID_OWNERSHIP_VOUCHER,
 	FIDO_TEST_VOUCHER_HEADER_BAD_DEVICEINFO_EMPTY: fdoshared.INVALID_OWNERSHIP_VOUCHER,
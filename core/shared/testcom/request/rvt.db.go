@@ -24,6 +24,20 @@ func (h *TestVouchers) GetVoucher(testId testcom.FDOTestID) (*fdoshared.DeviceCr
 	return nil, fmt.Errorf("No vouchers found for the id %s", testId)
 }
 
+func (h *TestVouchers) Count(testId testcom.FDOTestID) int {
+	return len((*h)[testId])
+}
+
+// GetVoucherForWorker returns the same voucher for the same worker, so that parallel tests never share a device
+func (h *TestVouchers) GetVoucherForWorker(testId testcom.FDOTestID, workerId int) (*fdoshared.DeviceCredAndVoucher, error) {
+	vouchers, ok := (*h)[testId]
+	if !ok || len(vouchers) == 0 {
+		return nil, fmt.Errorf("No vouchers found for the id %s", testId)
+	}
+
+	return &vouchers[workerId%len(vouchers)], nil
+}
+
 type RequestTestInst struct {
 	_              struct{} `cbor:",toarray"`
 	Uuid           []byte
@@ -34,9 +48,10 @@ type RequestTestInst struct {
 	CurrentTestRun RequestTestRun
 	TestsHistory   []RequestTestRun
 	TestVouchers   TestVouchers
+	Concurrency    int
 }
 
-func NewRequestTestInst(url string, protocol fdoshared.FdoToProtocol) RequestTestInst {
+func NewRequestTestInst(url string, protocol fdoshared.FdoToProtocol, concurrency int) RequestTestInst {
 	newUuid, _ := uuid.NewRandom()
 	uuidBytes, _ := newUuid.MarshalBinary()
 
@@ -46,6 +61,7 @@ func NewRequestTestInst(url string, protocol fdoshared.FdoToProtocol) RequestTes
 		TestsHistory: make([]RequestTestRun, 0),
 		Protocol:     protocol,
 		TestVouchers: make(TestVouchers),
+		Concurrency:  concurrency,
 	}
 }
 
@@ -57,6 +73,27 @@ type RequestTestRun struct {
 	Timestamp int64                   `json:"timestamp"`
 	Tests     RequestTestResultMap    `json:"tests"`
 	Protocol  fdoshared.FdoToProtocol `json:"protocol"`
+	Cancelled bool                    `json:"cancelled"`
+
+	ErrorCodeStrictness testcom.ErrorCodeStrictness `json:"errorCodeStrictness"`
+
+	// Computed on read, not stored
+	TimingSummary testcom.TimingSummary      `cbor:"-" json:"timingSummary"`
+	SuiteMatrix   []testcom.SuiteMatrixEntry `cbor:"-" json:"suiteMatrix,omitempty"`
+}
+
+func (h *RequestTestRun) ComputeTimingSummary() {
+	testStates := make([]testcom.FDOTestState, 0, len(h.Tests))
+	for _, testState := range h.Tests {
+		testStates = append(testStates, testState)
+	}
+
+	h.TimingSummary = testcom.NewTimingSummary(testStates)
+}
+
+// ComputeSuiteMatrix summarises the results of a crypto suite matrix run. Empty for other runs
+func (h *RequestTestRun) ComputeSuiteMatrix() {
+	h.SuiteMatrix = testcom.NewSuiteMatrix(h.Tests)
 }
 
 func (h *RequestTestRun) PassingAllTests() bool {
@@ -78,7 +115,7 @@ func (h *RequestTestRun) GetAllTestIDs() []testcom.FDOTestID {
 	return result
 }
 
-func NewRVTestRun(protocol fdoshared.FdoToProtocol) RequestTestRun {
+func NewRVTestRun(protocol fdoshared.FdoToProtocol, strictness testcom.ErrorCodeStrictness) RequestTestRun {
 	newUuid, _ := uuid.NewRandom()
 	uuidStr, _ := newUuid.MarshalText()
 	newRVTestRun := RequestTestRun{
@@ -86,7 +123,47 @@ func NewRVTestRun(protocol fdoshared.FdoToProtocol) RequestTestRun {
 		Timestamp: time.Now().Unix(),
 		Tests:     RequestTestResultMap{},
 		Protocol:  protocol,
+
+		ErrorCodeStrictness: strictness,
 	}
 
 	return newRVTestRun
 }
+
+// RequestTestResultEntry is a single test result, stored separately from the test instance so that parallel writers never overwrite each other
+type RequestTestResultEntry struct {
+	_         struct{} `cbor:",toarray"`
+	RunUuid   string
+	TestID    testcom.FDOTestID
+	TestState testcom.FDOTestState
+}
+
+// ApplyResults merges the stored per-test results into the matching test runs
+func (h *RequestTestInst) ApplyResults(results []RequestTestResultEntry) {
+	for _, result := range results {
+		if h.CurrentTestRun.Uuid == result.RunUuid {
+			if h.CurrentTestRun.Tests == nil {
+				h.CurrentTestRun.Tests = RequestTestResultMap{}
+			}
+
+			h.CurrentTestRun.Tests[result.TestID] = result.TestState
+		}
+
+		for i, testRun := range h.TestsHistory {
+			if testRun.Uuid == result.RunUuid {
+				if testRun.Tests == nil {
+					h.TestsHistory[i].Tests = RequestTestResultMap{}
+				}
+
+				h.TestsHistory[i].Tests[result.TestID] = result.TestState
+			}
+		}
+	}
+
+	h.CurrentTestRun.ComputeTimingSummary()
+	h.CurrentTestRun.ComputeSuiteMatrix()
+	for i := range h.TestsHistory {
+		h.TestsHistory[i].ComputeTimingSummary()
+		h.TestsHistory[i].ComputeSuiteMatrix()
+	}
+}
@@ -0,0 +1,17 @@
+package testcom
+
+import "context"
+
+type stressTestsCtxKey struct{}
+
+// WithStressTests enables the session isolation stress tests. They run many sessions at the same time after the
+// other tests, so they only run when asked for
+func WithStressTests(ctx context.Context) context.Context {
+	return context.WithValue(ctx, stressTestsCtxKey{}, true)
+}
+
+// GetStressTests returns true if the stress tests are enabled in ctx
+func GetStressTests(ctx context.Context) bool {
+	enabled, _ := ctx.Value(stressTestsCtxKey{}).(bool)
+	return enabled
+}
@@ -0,0 +1,43 @@
+package testcom
+
+import "context"
+
+// ErrorCodeStrictness sets how negative tests treat an FDO error with an unexpected error code or HTTP status
+type ErrorCodeStrictness string
+
+const (
+	// Any decodable FDO error passes
+	ECS_Lenient ErrorCodeStrictness = "lenient"
+	// An unexpected error code or HTTP status passes with warnings
+	ECS_Warn ErrorCodeStrictness = "warn"
+	// An unexpected error code or HTTP status fails the test
+	ECS_Strict ErrorCodeStrictness = "strict"
+)
+
+// ParseErrorCodeStrictness accepts lenient, warn or strict. An empty value is lenient
+func ParseErrorCodeStrictness(strictness string) (ErrorCodeStrictness, bool) {
+	switch ErrorCodeStrictness(strictness) {
+	case "":
+		return ECS_Lenient, true
+	case ECS_Lenient, ECS_Warn, ECS_Strict:
+		return ErrorCodeStrictness(strictness), true
+	}
+
+	return "", false
+}
+
+type errorCodeStrictnessCtxKey struct{}
+
+func WithErrorCodeStrictness(ctx context.Context, strictness ErrorCodeStrictness) context.Context {
+	return context.WithValue(ctx, errorCodeStrictnessCtxKey{}, strictness)
+}
+
+// GetErrorCodeStrictness returns the strictness set in ctx, lenient by default
+func GetErrorCodeStrictness(ctx context.Context) ErrorCodeStrictness {
+	strictness, ok := ctx.Value(errorCodeStrictnessCtxKey{}).(ErrorCodeStrictness)
+	if !ok || strictness == "" {
+		return ECS_Lenient
+	}
+
+	return strictness
+}
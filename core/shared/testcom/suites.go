@@ -0,0 +1,143 @@
+package testcom
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+)
+
+// CryptoSuite is a TO2 key exchange and cipher suite pair
+type CryptoSuite struct {
+	Kex    fdoshared.KexSuiteName
+	Cipher fdoshared.CipherSuiteName
+}
+
+func (h CryptoSuite) Name() string {
+	return string(h.Kex) + "/" + fdoshared.CipherSuiteNames[h.Cipher]
+}
+
+// GetCryptoSuites returns every key exchange and cipher pair usable with the owner key type.
+// ASYMKEX needs an RSA owner key of the matching size, the other key exchanges work with any owner key.
+// Ciphers the requestor can not encrypt with are left out, see fdoshared.IsCipherSuiteImplemented
+func GetCryptoSuites(ownerSgType fdoshared.SgType) []CryptoSuite {
+	var ciphers []fdoshared.CipherSuiteName
+	for cipher := range fdoshared.CipherSuitesInfoMap {
+		if fdoshared.IsCipherSuiteImplemented(cipher) {
+			ciphers = append(ciphers, cipher)
+		}
+	}
+	sort.Slice(ciphers, func(i, j int) bool { return ciphers[i] < ciphers[j] })
+
+	var suites []CryptoSuite
+	for _, kex := range fdoshared.KexSuitNames {
+		if kex == fdoshared.KEX_ASYMKEX2048 && ownerSgType != fdoshared.StRSA2048 {
+			continue
+		}
+
+		if kex == fdoshared.KEX_ASYMKEX3072 && ownerSgType != fdoshared.StRSA3072 {
+			continue
+		}
+
+		for _, cipher := range ciphers {
+			suites = append(suites, CryptoSuite{Kex: kex, Cipher: cipher})
+		}
+	}
+
+	return suites
+}
+
+// CryptoSuiteFilter returns the suites a run should keep, e.g. probe.Profile.FilterCryptoSuites
+type CryptoSuiteFilter func(suites []CryptoSuite) []CryptoSuite
+
+type cryptoSuiteFilterCtxKey struct{}
+
+// WithCryptoSuiteFilter makes suite matrix runs skip the suites the filter drops, instead of failing them
+func WithCryptoSuiteFilter(ctx context.Context, filter CryptoSuiteFilter) context.Context {
+	return context.WithValue(ctx, cryptoSuiteFilterCtxKey{}, filter)
+}
+
+// GetCryptoSuiteFilter returns the crypto suite filter in ctx, if any
+func GetCryptoSuiteFilter(ctx context.Context) (CryptoSuiteFilter, bool) {
+	filter, ok := ctx.Value(cryptoSuiteFilterCtxKey{}).(CryptoSuiteFilter)
+	return filter, ok
+}
+
+// NewSuiteTestID tags a test ID with the crypto suite it ran with, e.g. FIDO_DOT_70_POSITIVE@ECDH256/A128GCM
+func NewSuiteTestID(testId FDOTestID, suite CryptoSuite) FDOTestID {
+	return FDOTestID(string(testId) + "@" + suite.Name())
+}
+
+// ParseSuiteTestID is the reverse of NewSuiteTestID. Returns false for test IDs without a suite
+func ParseSuiteTestID(suiteTestId FDOTestID) (FDOTestID, CryptoSuite, bool) {
+	testId, suiteName, found := strings.Cut(string(suiteTestId), "@")
+	if !found {
+		return suiteTestId, CryptoSuite{}, false
+	}
+
+	kexName, cipherName, found := strings.Cut(suiteName, "/")
+	if !found {
+		return suiteTestId, CryptoSuite{}, false
+	}
+
+	for cipher, name := range fdoshared.CipherSuiteNames {
+		if name == cipherName {
+			return FDOTestID(testId), CryptoSuite{Kex: fdoshared.KexSuiteName(kexName), Cipher: cipher}, true
+		}
+	}
+
+	return suiteTestId, CryptoSuite{}, false
+}
+
+type SuiteMatrixEntry struct {
+	Kex         string      `json:"kex"`
+	Cipher      string      `json:"cipher"`
+	Passed      int         `json:"passed"`
+	Failed      int         `json:"failed"`
+	FailedTests []FDOTestID `json:"failedTests"`
+}
+
+// NewSuiteMatrix groups the results of suite tagged tests by crypto suite. Untagged tests and ErrorMessage checks are ignored
+func NewSuiteMatrix(testStates map[FDOTestID]FDOTestState) []SuiteMatrixEntry {
+	entries := map[CryptoSuite]*SuiteMatrixEntry{}
+	for suiteTestId, testState := range testStates {
+		testId, suite, ok := ParseSuiteTestID(suiteTestId)
+		if !ok || IsErrorMessageTestID(testId) {
+			continue
+		}
+
+		entry, ok := entries[suite]
+		if !ok {
+			entry = &SuiteMatrixEntry{
+				Kex:         string(suite.Kex),
+				Cipher:      fdoshared.CipherSuiteNames[suite.Cipher],
+				FailedTests: []FDOTestID{},
+			}
+			entries[suite] = entry
+		}
+
+		if testState.Passed {
+			entry.Passed++
+		} else {
+			entry.Failed++
+			entry.FailedTests = append(entry.FailedTests, testId)
+		}
+	}
+
+	var matrix []SuiteMatrixEntry
+	for _, entry := range entries {
+		sort.Slice(entry.FailedTests, func(i, j int) bool { return entry.FailedTests[i] < entry.FailedTests[j] })
+		matrix = append(matrix, *entry)
+	}
+
+	sort.Slice(matrix, func(i, j int) bool {
+		if matrix[i].Kex != matrix[j].Kex {
+			return matrix[i].Kex < matrix[j].Kex
+		}
+
+		return matrix[i].Cipher < matrix[j].Cipher
+	})
+
+	return matrix
+}
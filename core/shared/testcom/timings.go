@@ -0,0 +1,81 @@
+package testcom
+
+import (
+	"sort"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+)
+
+// TimingPercentiles are in microseconds
+type TimingPercentiles struct {
+	P50 int64 `json:"p50"`
+	P90 int64 `json:"p90"`
+	P95 int64 `json:"p95"`
+	P99 int64 `json:"p99"`
+	Max int64 `json:"max"`
+}
+
+func newTimingPercentiles(durations []int64) TimingPercentiles {
+	if len(durations) == 0 {
+		return TimingPercentiles{}
+	}
+
+	sorted := append([]int64{}, durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	percentile := func(p int) int64 {
+		// Nearest-rank method
+		rank := (p*len(sorted) + 99) / 100
+		if rank < 1 {
+			rank = 1
+		}
+
+		return sorted[rank-1]
+	}
+
+	return TimingPercentiles{
+		P50: percentile(50),
+		P90: percentile(90),
+		P95: percentile(95),
+		P99: percentile(99),
+		Max: sorted[len(sorted)-1],
+	}
+}
+
+type TimingSummary struct {
+	Messages         int                                    `json:"messages"`
+	RoundTrip        TimingPercentiles                      `json:"roundTrip"`
+	ServerProcessing TimingPercentiles                      `json:"serverProcessing"`
+	RoundTripPerCmd  map[fdoshared.FdoCmd]TimingPercentiles `json:"roundTripPerCmd"`
+}
+
+// NewTimingSummary computes percentiles over all the message timings recorded by the test states
+func NewTimingSummary(testStates []FDOTestState) TimingSummary {
+	var roundTrips []int64
+	var serverProcessing []int64
+	roundTripsPerCmd := map[fdoshared.FdoCmd][]int64{}
+
+	for _, testState := range testStates {
+		for _, timing := range testState.Timings {
+			roundTrips = append(roundTrips, timing.RoundTripUs)
+			roundTripsPerCmd[timing.Cmd] = append(roundTripsPerCmd[timing.Cmd], timing.RoundTripUs)
+
+			if timing.ServerProcessingUs != 0 {
+				serverProcessing = append(serverProcessing, timing.ServerProcessingUs)
+			}
+		}
+	}
+
+	summary := TimingSummary{
+		Messages:         len(roundTrips),
+		RoundTrip:        newTimingPercentiles(roundTrips),
+		ServerProcessing: newTimingPercentiles(serverProcessing),
+		RoundTripPerCmd:  map[fdoshared.FdoCmd]TimingPercentiles{},
+	}
+
+	for cmd, cmdRoundTrips := range roundTripsPerCmd {
+		summary.RoundTripPerCmd[cmd] = newTimingPercentiles(cmdRoundTrips)
+	}
+
+	return summary
+}
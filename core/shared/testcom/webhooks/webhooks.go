@@ -0,0 +1,256 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+	testdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/events"
+)
+
+const (
+	HEADER_WEBHOOK_ID = "X-FDO-Webhook-Id"
+	HEADER_DELIVERY   = "X-FDO-Delivery"
+	HEADER_EVENT      = "X-FDO-Event"
+	HEADER_TIMESTAMP  = "X-FDO-Timestamp"
+	HEADER_SIGNATURE  = "X-FDO-Signature"
+)
+
+const (
+	MaxDeliveryAttempts int = 8
+
+	deliveryRetryBase    = 10 * time.Second
+	deliveryRetryMax     = time.Hour
+	deliveryPollInterval = 10 * time.Second
+	deliveryTimeout      = 10 * time.Second
+)
+
+// Payload is the JSON body posted to the webhook URL when a test run finishes
+type Payload struct {
+	Event      events.EventType        `json:"event"`
+	WebhookId  string                  `json:"webhookId"`
+	TestType   testdbs.WebhookTestType `json:"testType"`
+	TestInstId string                  `json:"testInstId"`
+	InstanceId string                  `json:"instanceId"`
+	RunId      string                  `json:"runId"`
+	Protocol   fdoshared.FdoToProtocol `json:"protocol"`
+	Cancelled  bool                    `json:"cancelled"`
+
+	testcom.RunSummary
+
+	RunUrl    string `json:"runUrl"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// Sign returns the signature header value, a HMAC-SHA256 of "timestamp.body" keyed with the webhook secret
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a received signature. Receivers should also reject old timestamps
+func Verify(secret string, timestamp string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+func retryDelay(attempts int) time.Duration {
+	delay := deliveryRetryBase
+	for i := 1; i < attempts && delay < deliveryRetryMax; i++ {
+		delay *= 2
+	}
+
+	return min(delay, deliveryRetryMax)
+}
+
+type Dispatcher struct {
+	db      *testdbs.WebhookDB
+	client  *http.Client
+	baseUrl string
+	wake    chan struct{}
+}
+
+func NewDispatcher(db *testdbs.WebhookDB, baseUrl string) *Dispatcher {
+	return &Dispatcher{
+		db:      db,
+		client:  &http.Client{Timeout: deliveryTimeout},
+		baseUrl: strings.TrimSuffix(baseUrl, "/"),
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+func (h *Dispatcher) runUrl(testType testdbs.WebhookTestType) string {
+	switch testType {
+	case testdbs.WTT_RVT:
+		return h.baseUrl + "/#/test/rv"
+	case testdbs.WTT_DOT:
+		return h.baseUrl + "/#/test/do"
+	default:
+		return h.baseUrl + "/#/test/device"
+	}
+}
+
+// HandleEvent queues a delivery to every webhook of the finished run's test. Used as an events broker hook
+func (h *Dispatcher) HandleEvent(event events.Event) {
+	if event.Type != events.EventRunFinished {
+		return
+	}
+
+	instanceId, err := hex.DecodeString(event.InstanceId)
+	if err != nil {
+		return
+	}
+
+	webhooks, err := h.db.FindByInstance(instanceId)
+	if err != nil {
+		log.Println("Failed to load webhooks. " + err.Error())
+		return
+	}
+
+	summary := testcom.NewRunSummary(nil)
+	if event.Summary != nil {
+		summary = *event.Summary
+	}
+
+	for _, webhookInst := range webhooks {
+		payloadBytes, _ := json.Marshal(Payload{
+			Event:      event.Type,
+			WebhookId:  webhookInst.Id,
+			TestType:   webhookInst.TestType,
+			TestInstId: hex.EncodeToString(webhookInst.TestInstId),
+			InstanceId: event.InstanceId,
+			RunId:      event.RunId,
+			Protocol:   event.Protocol,
+			Cancelled:  event.Cancelled,
+			RunSummary: summary,
+			RunUrl:     h.runUrl(webhookInst.TestType),
+			Timestamp:  event.Timestamp,
+		})
+
+		deliveryId, _ := uuid.NewRandom()
+		err = h.db.SaveDelivery(testdbs.WebhookDeliveryEntry{
+			Id:            deliveryId.String(),
+			WebhookId:     webhookInst.Id,
+			Payload:       payloadBytes,
+			NextAttemptAt: time.Now().Unix(),
+		})
+		if err != nil {
+			log.Println("Failed to queue webhook delivery. " + err.Error())
+		}
+	}
+
+	if len(webhooks) > 0 {
+		select {
+		case h.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Run delivers the queued webhooks until ctx is done. Deliveries queued before a restart are picked up again
+func (h *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(deliveryPollInterval)
+	defer ticker.Stop()
+
+	for {
+		h.DeliverDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-h.wake:
+		}
+	}
+}
+
+// DeliverDue attempts all deliveries that are due. Returns the number of successful deliveries
+func (h *Dispatcher) DeliverDue(ctx context.Context) int {
+	deliveries, err := h.db.DueDeliveries(time.Now())
+	if err != nil {
+		log.Println("Failed to load webhook deliveries. " + err.Error())
+		return 0
+	}
+
+	delivered := 0
+	for _, delivery := range deliveries {
+		if ctx.Err() != nil {
+			break
+		}
+
+		webhookInst, err := h.db.Get(delivery.WebhookId)
+		if err != nil {
+			// The webhook was deleted
+			h.db.DeleteDelivery(delivery.Id)
+			continue
+		}
+
+		err = h.send(ctx, *webhookInst, delivery)
+		h.db.RecordAttempt(webhookInst.Id, err)
+
+		if err == nil {
+			delivered++
+			h.db.DeleteDelivery(delivery.Id)
+			continue
+		}
+
+		delivery.Attempts++
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = time.Now().Add(retryDelay(delivery.Attempts)).Unix()
+		delivery.Failed = delivery.Attempts >= MaxDeliveryAttempts
+
+		log.Printf("Webhook %s delivery %s attempt %d failed. %s", webhookInst.Id, delivery.Id, delivery.Attempts, err.Error())
+
+		err = h.db.SaveDelivery(delivery)
+		if err != nil {
+			log.Println("Failed to save webhook delivery. " + err.Error())
+		}
+	}
+
+	return delivered
+}
+
+func (h *Dispatcher) send(ctx context.Context, webhookInst testdbs.WebhookEntry, delivery testdbs.WebhookDeliveryEntry) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", webhookInst.Url, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return errors.New("Failed to create request. " + err.Error())
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HEADER_WEBHOOK_ID, webhookInst.Id)
+	req.Header.Set(HEADER_DELIVERY, delivery.Id)
+	req.Header.Set(HEADER_EVENT, string(events.EventRunFinished))
+	req.Header.Set(HEADER_TIMESTAMP, timestamp)
+	req.Header.Set(HEADER_SIGNATURE, Sign(webhookInst.Secret, timestamp, delivery.Payload))
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return errors.New("Failed to send request. " + err.Error())
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("Receiver responded with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
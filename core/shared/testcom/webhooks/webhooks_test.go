@@ -0,0 +1,93 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/storage"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+	testdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/events"
+)
+
+func TestDeliveryIsSignedAndRetried(t *testing.T) {
+	const secret = "test-secret"
+
+	received := make(chan Payload, 1)
+	requests := 0
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		if !Verify(secret, r.Header.Get(HEADER_TIMESTAMP), body, r.Header.Get(HEADER_SIGNATURE)) {
+			t.Errorf("Invalid signature %s", r.Header.Get(HEADER_SIGNATURE))
+		}
+
+		var payload Payload
+		json.Unmarshal(body, &payload)
+		received <- payload
+	}))
+	defer receiver.Close()
+
+	webhookDb := testdbs.NewWebhookDB(storage.NewMemoryStore())
+	instanceId := []byte{0x01, 0x02}
+
+	err := webhookDb.Save(testdbs.WebhookEntry{
+		Id:          "hook",
+		Email:       "tester@fido.local",
+		TestType:    testdbs.WTT_RVT,
+		TestInstId:  []byte{0xaa},
+		InstanceIds: [][]byte{instanceId},
+		Url:         receiver.URL,
+		Secret:      secret,
+	})
+	if err != nil {
+		t.Fatalf("Failed to save webhook. %s", err.Error())
+	}
+
+	dispatcher := NewDispatcher(webhookDb, "http://localhost:8080")
+	dispatcher.HandleEvent(events.NewRunFinishedEvent(instanceId, "run", fdoshared.To0, false, testcom.NewRunSummary([]testcom.FDOTestState{
+		testcom.NewSuccessTestState("FIDO-TO0-20-01"),
+		testcom.NewFailTestState("FIDO-TO0-22-01", "failed"),
+	})))
+
+	if delivered := dispatcher.DeliverDue(context.Background()); delivered != 0 {
+		t.Fatalf("Expected the first attempt to fail. Got %d deliveries", delivered)
+	}
+
+	deliveries, _ := webhookDb.ListDeliveries("hook")
+	if len(deliveries) != 1 || deliveries[0].Attempts != 1 || deliveries[0].Failed {
+		t.Fatalf("Expected one pending retry. Got %+v", deliveries)
+	}
+
+	// Skip the retry delay
+	deliveries[0].NextAttemptAt = 0
+	webhookDb.SaveDelivery(deliveries[0])
+
+	if delivered := dispatcher.DeliverDue(context.Background()); delivered != 1 {
+		t.Fatalf("Expected the retry to be delivered. Got %d deliveries", delivered)
+	}
+
+	payload := <-received
+	if payload.Passed != 1 || payload.Failed != 1 || len(payload.FailedTests) != 1 || payload.FailedTests[0] != "FIDO-TO0-22-01" {
+		t.Errorf("Unexpected summary %+v", payload.RunSummary)
+	}
+
+	if payload.TestInstId != "aa" || payload.RunUrl != "http://localhost:8080/#/test/rv" {
+		t.Errorf("Unexpected payload %+v", payload)
+	}
+
+	deliveries, _ = webhookDb.ListDeliveries("hook")
+	if len(deliveries) != 0 {
+		t.Errorf("Expected no pending deliveries. Got %d", len(deliveries))
+	}
+}
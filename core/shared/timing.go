@@ -0,0 +1,116 @@
+package fdoshared
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const ServerTimingHeader string = "Server-Timing"
+
+// MessageTiming describes a single request/response exchange. Durations are in microseconds
+type MessageTiming struct {
+	_                  struct{} `cbor:",toarray"`
+	Cmd                FdoCmd   `json:"cmd"`
+	RoundTripUs        int64    `json:"roundTripUs"`
+	ServerProcessingUs int64    `json:"serverProcessingUs"`
+	RequestSize        int      `json:"requestSize"`
+	ResponseSize       int      `json:"responseSize"`
+}
+
+// TimingsRecorder collects the timings of all messages sent with the context it is attached to
+type TimingsRecorder struct {
+	mu      sync.Mutex
+	timings []MessageTiming
+}
+
+func (h *TimingsRecorder) Record(timing MessageTiming) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.timings = append(h.timings, timing)
+}
+
+func (h *TimingsRecorder) GetTimings() []MessageTiming {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return append([]MessageTiming{}, h.timings...)
+}
+
+type timingsRecorderKey struct{}
+
+func NewTimingsContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, timingsRecorderKey{}, &TimingsRecorder{})
+}
+
+func GetTimingsRecorder(ctx context.Context) *TimingsRecorder {
+	if ctx == nil {
+		return nil
+	}
+
+	recorder, _ := ctx.Value(timingsRecorderKey{}).(*TimingsRecorder)
+	return recorder
+}
+
+// ParseServerTiming returns the "app" duration of a Server-Timing header in microseconds, or 0 if not present
+func ParseServerTiming(headerValue string) int64 {
+	for _, metric := range strings.Split(headerValue, ",") {
+		params := strings.Split(metric, ";")
+		if strings.TrimSpace(params[0]) != "app" {
+			continue
+		}
+
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if !strings.HasPrefix(param, "dur=") {
+				continue
+			}
+
+			durMs, err := strconv.ParseFloat(strings.TrimPrefix(param, "dur="), 64)
+			if err != nil {
+				return 0
+			}
+
+			return int64(durMs * 1000)
+		}
+	}
+
+	return 0
+}
+
+type serverTimingWriter struct {
+	http.ResponseWriter
+	start       time.Time
+	wroteHeader bool
+}
+
+func (h *serverTimingWriter) WriteHeader(statusCode int) {
+	if !h.wroteHeader {
+		h.wroteHeader = true
+
+		durMs := float64(time.Since(h.start).Microseconds()) / 1000
+		h.Header().Set(ServerTimingHeader, fmt.Sprintf("app;dur=%.3f", durMs))
+	}
+
+	h.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (h *serverTimingWriter) Write(body []byte) (int, error) {
+	if !h.wroteHeader {
+		h.WriteHeader(http.StatusOK)
+	}
+
+	return h.ResponseWriter.Write(body)
+}
+
+// WithServerTiming reports the handler processing time to the client in the Server-Timing header
+func WithServerTiming(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		handler(&serverTimingWriter{ResponseWriter: w, start: time.Now()}, r)
+	}
+}
@@ -4,15 +4,27 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 )
 
 const (
 	CONTENT_TYPE_CBOR string = "application/cbor"
 	FDO_101_URL_BASE  string = "/fdo/101/msg/"
+
+	MAX_MESSAGE_BODY_SIZE int64 = 1 << 20 // 1 MiB
+
+	// SESSION_TTL is how long RV and DO sessions stay alive without a message
+	SESSION_TTL time.Duration = 10 * time.Minute
 )
 
 func RespondFDOError(w http.ResponseWriter, r *http.Request, errorCode FdoErrorCode, prevMsgId FdoCmd, messageStr string, httpStatusCode int) {
 	fdoErrorInst := NewFdoError(errorCode, prevMsgId, messageStr)
+	if correlationId := GetCorrelationId(r.Context()); correlationId != 0 {
+		fdoErrorInst.EMErrorCID = correlationId
+	}
+
+	Logger(r.Context()).Warn("Responding with FDO error", "errorCode", errorCode, "prevMsgId", prevMsgId, "errorStr", messageStr, LOG_ATTR_CORRELATION_ID, fdoErrorInst.EMErrorCID)
+	countErrorResponse(errorCode, prevMsgId)
 
 	fdoErrorBytes, _ := CborCust.Marshal(fdoErrorInst)
 
@@ -29,6 +41,11 @@ func ExtractAuthorizationHeader(w http.ResponseWriter, r *http.Request, currentC
 		return false, nil, ""
 	}
 
+	if len(r.Header.Values("Authorization")) > 1 {
+		RespondFDOError(w, r, MESSAGE_BODY_ERROR, currentCmd, "Unauthorized! Multiple authorization headers!", http.StatusUnauthorized)
+		return false, nil, ""
+	}
+
 	authorizationHeaderParts := strings.Split(authorizationHeader, " ")
 	if len(authorizationHeaderParts) != 2 {
 		RespondFDOError(w, r, MESSAGE_BODY_ERROR, currentCmd, "Unauthorized! Invalid authorization header!", http.StatusUnauthorized)
@@ -55,5 +72,34 @@ func CheckHeaders(w http.ResponseWriter, r *http.Request, currentCmd FdoCmd) boo
 		return false
 	}
 
+	if r.ContentLength > MAX_MESSAGE_BODY_SIZE {
+		RespondFDOError(w, r, MESSAGE_BODY_ERROR, currentCmd, fmt.Sprintf("Message body is larger than %d bytes!", MAX_MESSAGE_BODY_SIZE), http.StatusRequestEntityTooLarge)
+		return false
+	}
+
+	// Bodies without a declared length fail to read past the limit
+	r.Body = http.MaxBytesReader(w, r.Body, MAX_MESSAGE_BODY_SIZE)
+
 	return true
 }
+
+// EndSessionOnError ends the session of a request the handler rejected with an FDO error, so the protocol has to restart from its first message
+func EndSessionOnError(handler http.HandlerFunc, endSession func(sessionId []byte) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		handler(w, r)
+
+		if w.Header().Get("Message-Type") != TO_ERROR_255.ToString() {
+			return
+		}
+
+		authorizationHeaderParts := strings.Split(r.Header.Get("Authorization"), " ")
+		if len(authorizationHeaderParts) != 2 || authorizationHeaderParts[0] != "Bearer" {
+			return
+		}
+
+		err := endSession([]byte(authorizationHeaderParts[1]))
+		if err != nil {
+			Logger(r.Context()).Error("Failed to end session", "error", err.Error())
+		}
+	}
+}
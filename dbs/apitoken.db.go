@@ -0,0 +1,247 @@
+package dbs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/records"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/storage"
+)
+
+// API_TOKEN_PREFIX makes the tokens easy to find in logs and secret scanners
+const API_TOKEN_PREFIX string = "fdoct_"
+
+type ApiTokenScope string
+
+const (
+	ATS_Read    ApiTokenScope = "read"
+	ATS_Execute ApiTokenScope = "execute"
+)
+
+func (h ApiTokenScope) IsValid() bool {
+	return h == ATS_Read || h == ATS_Execute
+}
+
+// Allows returns true if a token of this scope can be used for the required scope. Execute tokens can also read
+func (h ApiTokenScope) Allows(required ApiTokenScope) bool {
+	return h == ATS_Execute || h == required
+}
+
+type ApiTokenEntry struct {
+	_         struct{} `cbor:",toarray"`
+	Id        string
+	Name      string
+	Email     string
+	Scope     ApiTokenScope
+	CreatedAt int64
+	LastUsed  int64
+}
+
+type ApiTokenDB struct {
+	db          storage.Store
+	prefix      []byte
+	indexPrefix []byte
+}
+
+func NewApiTokenDB(db storage.Store) *ApiTokenDB {
+	return &ApiTokenDB{
+		db:          db,
+		prefix:      []byte("apitoken-"),
+		indexPrefix: []byte("apitokenidx-"),
+	}
+}
+
+// Only the token hash is stored, the token itself is shown once on creation
+func (h *ApiTokenDB) getEntryId(token string) []byte {
+	tokenHash := sha256.Sum256([]byte(token))
+	return append(append([]byte{}, h.prefix...), tokenHash[:]...)
+}
+
+// The per user index maps the user's token ids to the token entry keys. Emails can not contain "/" after the "@"
+func (h *ApiTokenDB) getUserIndexPrefix(email string) []byte {
+	return append(append([]byte{}, h.indexPrefix...), []byte(email+"/")...)
+}
+
+func (h *ApiTokenDB) getIndexId(email string, tokenId string) []byte {
+	return append(h.getUserIndexPrefix(email), []byte(tokenId)...)
+}
+
+// Create generates a new token for the user and returns it together with its entry
+func (h *ApiTokenDB) Create(email string, name string, scope ApiTokenScope) (string, *ApiTokenEntry, error) {
+	if !scope.IsValid() {
+		return "", nil, errors.New("Unknown token scope " + string(scope))
+	}
+
+	tokenId, _ := uuid.NewRandom()
+	token := API_TOKEN_PREFIX + hex.EncodeToString(fdoshared.NewRandomBuffer(32))
+
+	tokenEntry := ApiTokenEntry{
+		Id:        tokenId.String(),
+		Name:      name,
+		Email:     strings.ToLower(email),
+		Scope:     scope,
+		CreatedAt: time.Now().Unix(),
+	}
+
+	tokenBytes, err := records.Marshal(RT_ApiToken, tokenEntry)
+	if err != nil {
+		return "", nil, errors.New("Failed to marshal api token. The error is: " + err.Error())
+	}
+
+	err = h.db.Update(func(txn storage.Txn) error {
+		err := txn.Set(h.getEntryId(token), tokenBytes)
+		if err != nil {
+			return err
+		}
+
+		return txn.Set(h.getIndexId(tokenEntry.Email, tokenEntry.Id), h.getEntryId(token))
+	})
+	if err != nil {
+		return "", nil, errors.New("Failed saving api token. The error is: " + err.Error())
+	}
+
+	return token, &tokenEntry, nil
+}
+
+// Authenticate returns the entry of a valid token and records its use
+func (h *ApiTokenDB) Authenticate(token string) (*ApiTokenEntry, error) {
+	if !strings.HasPrefix(token, API_TOKEN_PREFIX) {
+		return nil, errors.New("Malformed api token")
+	}
+
+	var tokenEntry ApiTokenEntry
+	err := h.db.Update(func(txn storage.Txn) error {
+		tokenBytes, err := txn.Get(h.getEntryId(token))
+		if errors.Is(err, storage.ErrKeyNotFound) {
+			return errors.New("Unknown or revoked api token")
+		} else if err != nil {
+			return errors.New("Failed locating api token. The error is: " + err.Error())
+		}
+
+		err = records.Unmarshal(RT_ApiToken, tokenBytes, &tokenEntry)
+		if err != nil {
+			return errors.New("Failed cbor decoding api token. The error is: " + err.Error())
+		}
+
+		// Last use is only tracked to the minute, to avoid a write on every request
+		now := time.Now().Unix()
+		if now-tokenEntry.LastUsed < 60 {
+			return nil
+		}
+
+		tokenEntry.LastUsed = now
+		tokenBytes, err = records.Marshal(RT_ApiToken, tokenEntry)
+		if err != nil {
+			return errors.New("Failed to marshal api token. The error is: " + err.Error())
+		}
+
+		return txn.Set(h.getEntryId(token), tokenBytes)
+	})
+	if errors.Is(err, storage.ErrConflict) {
+		// A concurrent request updated the last use
+		return &tokenEntry, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &tokenEntry, nil
+}
+
+// List returns the tokens of the user
+func (h *ApiTokenDB) List(email string) ([]ApiTokenEntry, error) {
+	email = strings.ToLower(email)
+
+	tokens := []ApiTokenEntry{}
+	err := h.db.View(func(txn storage.Txn) error {
+		return txn.Iterate(h.getUserIndexPrefix(email), func(key []byte, entryId []byte) error {
+			tokenBytes, err := txn.Get(entryId)
+			if errors.Is(err, storage.ErrKeyNotFound) {
+				return nil
+			} else if err != nil {
+				return errors.New("Failed locating api token. The error is: " + err.Error())
+			}
+
+			var tokenEntry ApiTokenEntry
+			err = records.Unmarshal(RT_ApiToken, tokenBytes, &tokenEntry)
+			if err != nil {
+				return errors.New("Failed cbor decoding api token. The error is: " + err.Error())
+			}
+
+			tokens = append(tokens, tokenEntry)
+
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// Revoke deletes the user's token with the given id
+func (h *ApiTokenDB) Revoke(email string, tokenId string) error {
+	email = strings.ToLower(email)
+
+	err := h.db.Update(func(txn storage.Txn) error {
+		indexId := h.getIndexId(email, tokenId)
+
+		entryId, err := txn.Get(indexId)
+		if errors.Is(err, storage.ErrKeyNotFound) {
+			return errors.New("The api token " + tokenId + " does not exist")
+		} else if err != nil {
+			return errors.New("Failed locating api token. The error is: " + err.Error())
+		}
+
+		err = txn.Delete(entryId)
+		if err != nil {
+			return err
+		}
+
+		return txn.Delete(indexId)
+	})
+	if err != nil {
+		return errors.New("Failed revoking api token. The error is: " + err.Error())
+	}
+
+	return nil
+}
+
+// IndexTokens adds the missing per user index entries of tokens created before the index existed, or restored from an archive. Returns the number of indexed tokens
+func (h *ApiTokenDB) IndexTokens() (int, error) {
+	indexed := 0
+	err := h.db.Update(func(txn storage.Txn) error {
+		indexed = 0
+
+		return txn.Iterate(h.prefix, func(key []byte, value []byte) error {
+			var tokenEntry ApiTokenEntry
+			err := records.Unmarshal(RT_ApiToken, value, &tokenEntry)
+			if err != nil {
+				return errors.New("Failed cbor decoding api token. The error is: " + err.Error())
+			}
+
+			indexId := h.getIndexId(tokenEntry.Email, tokenEntry.Id)
+
+			_, err = txn.Get(indexId)
+			if err == nil {
+				return nil
+			} else if !errors.Is(err, storage.ErrKeyNotFound) {
+				return err
+			}
+
+			indexed++
+			return txn.Set(indexId, append([]byte{}, key...))
+		})
+	})
+	if err != nil {
+		return 0, errors.New("Failed indexing api tokens. The error is: " + err.Error())
+	}
+
+	return indexed, nil
+}
@@ -0,0 +1,102 @@
+package dbs
+
+import (
+	"testing"
+
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/records"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/storage"
+)
+
+func TestApiTokenLifecycle(t *testing.T) {
+	tokenDb := NewApiTokenDB(storage.NewMemoryStore())
+
+	token, tokenEntry, err := tokenDb.Create("Tester@fido.local", "ci", ATS_Read)
+	if err != nil {
+		t.Fatalf("Failed to create token. %s", err.Error())
+	}
+
+	authEntry, err := tokenDb.Authenticate(token)
+	if err != nil {
+		t.Fatalf("Failed to authenticate token. %s", err.Error())
+	}
+
+	if authEntry.Email != "tester@fido.local" || authEntry.Scope.Allows(ATS_Execute) || !authEntry.Scope.Allows(ATS_Read) {
+		t.Errorf("Unexpected token entry %+v", authEntry)
+	}
+
+	_, err = tokenDb.Authenticate(token + "0")
+	if err == nil {
+		t.Errorf("Expected an unknown token to fail")
+	}
+
+	tokens, err := tokenDb.List("tester@fido.local")
+	if err != nil || len(tokens) != 1 || tokens[0].Id != tokenEntry.Id {
+		t.Fatalf("Expected the token to be listed. Got %v %v", tokens, err)
+	}
+
+	err = tokenDb.Revoke("other@fido.local", tokenEntry.Id)
+	if err == nil {
+		t.Errorf("Expected another user's revoke to fail")
+	}
+
+	err = tokenDb.Revoke("tester@fido.local", tokenEntry.Id)
+	if err != nil {
+		t.Fatalf("Failed to revoke token. %s", err.Error())
+	}
+
+	_, err = tokenDb.Authenticate(token)
+	if err == nil {
+		t.Errorf("Expected a revoked token to fail")
+	}
+}
+
+func TestApiTokenIndex(t *testing.T) {
+	db := storage.NewMemoryStore()
+	tokenDb := NewApiTokenDB(db)
+
+	_, _, err := tokenDb.Create("tester@fido.local", "ci", ATS_Read)
+	if err != nil {
+		t.Fatalf("Failed to create token. %s", err.Error())
+	}
+
+	_, _, err = tokenDb.Create("tester@fido.local.other", "other", ATS_Read)
+	if err != nil {
+		t.Fatalf("Failed to create token. %s", err.Error())
+	}
+
+	// A token stored before the per user index existed
+	legacyBytes, _ := records.Marshal(RT_ApiToken, ApiTokenEntry{Id: "legacy", Email: "tester@fido.local", Scope: ATS_Read})
+	db.Update(func(txn storage.Txn) error {
+		return txn.Set(tokenDb.getEntryId(API_TOKEN_PREFIX+"legacy"), legacyBytes)
+	})
+
+	tokens, _ := tokenDb.List("tester@fido.local")
+	if len(tokens) != 1 {
+		t.Fatalf("Expected only the indexed token of the user. Got %v", tokens)
+	}
+
+	indexed, err := tokenDb.IndexTokens()
+	if err != nil || indexed != 1 {
+		t.Fatalf("Expected the legacy token to be indexed. Got %d, %v", indexed, err)
+	}
+
+	tokens, _ = tokenDb.List("tester@fido.local")
+	if len(tokens) != 2 {
+		t.Fatalf("Expected both tokens of the user. Got %v", tokens)
+	}
+
+	err = tokenDb.Revoke("tester@fido.local", "legacy")
+	if err != nil {
+		t.Fatalf("Failed to revoke the legacy token. %s", err.Error())
+	}
+
+	_, err = tokenDb.Authenticate(API_TOKEN_PREFIX + "legacy")
+	if err == nil {
+		t.Errorf("Expected the revoked legacy token to fail")
+	}
+
+	indexed, _ = tokenDb.IndexTokens()
+	if indexed != 0 {
+		t.Errorf("Expected no tokens to index. Got %d", indexed)
+	}
+}
@@ -0,0 +1,468 @@
+package dbs
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/records"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/storage"
+	testdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
+	listenertestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/listener"
+	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
+)
+
+// The archive is JSON lines: an ArchiveHeader followed by one ArchiveEntry per stored entry.
+// Values are kept in their stored CBOR encoding.
+const (
+	ARCHIVE_FORMAT  string = "fdo-conformance-tools-db"
+	ARCHIVE_VERSION int    = 1
+
+	archiveImportBatchSize int = 500
+)
+
+type ArchiveKind string
+
+const (
+	AK_User             ArchiveKind = "user"
+	AK_TestInstance     ArchiveKind = "test_instance"
+	AK_TestResult       ArchiveKind = "test_result"
+	AK_Listener         ArchiveKind = "listener"
+	AK_ListenerMapping  ArchiveKind = "listener_mapping"
+	AK_DOVoucher        ArchiveKind = "do_voucher"
+	AK_RVOwnerSign      ArchiveKind = "rv_owner_sign"
+	AK_DeviceCredential ArchiveKind = "device_credential"
+	AK_Config           ArchiveKind = "config"
+	AK_ApiToken         ArchiveKind = "api_token"
+	AK_ApiTokenIndex    ArchiveKind = "api_token_index"
+	AK_Webhook          ArchiveKind = "webhook"
+	AK_WebhookDelivery  ArchiveKind = "webhook_delivery"
+)
+
+type archiveKindPrefix struct {
+	kind   ArchiveKind
+	prefix []byte
+}
+
+// Key prefixes of the exported DBs. Sessions and verification links are short lived and are not exported.
+// More specific prefixes go first, "lstdb-guid-map-" is also matched by "lstdb-"
+var archiveKindPrefixes = []archiveKindPrefix{
+	{AK_User, []byte("usere-")},
+	{AK_TestInstance, []byte("rvte-")},
+	{AK_TestResult, []byte("rvteres-")},
+	{AK_ListenerMapping, []byte("lstdb-guid-map-")},
+	{AK_Listener, []byte("lstdb-")},
+	{AK_DOVoucher, []byte("voucher-")},
+	{AK_RVOwnerSign, []byte("to1osstorage-")},
+	{AK_DeviceCredential, []byte("devbasecreds-")},
+	{AK_Config, []byte("config-")},
+	{AK_ApiToken, []byte("apitoken-")},
+	{AK_ApiTokenIndex, []byte("apitokenidx-")},
+	{AK_Webhook, []byte("webhook-")},
+	{AK_WebhookDelivery, []byte("webhookdlv-")},
+}
+
+func getArchiveKind(key []byte) (ArchiveKind, bool) {
+	for _, kindPrefix := range archiveKindPrefixes {
+		if bytes.HasPrefix(key, kindPrefix.prefix) {
+			return kindPrefix.kind, true
+		}
+	}
+
+	return "", false
+}
+
+type ArchiveHeader struct {
+	Format    string    `json:"format"`
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	User      string    `json:"user,omitempty"`
+}
+
+type ArchiveEntry struct {
+	Kind      ArchiveKind `json:"kind"`
+	Key       []byte      `json:"key"`
+	Value     []byte      `json:"value"`
+	ExpiresAt *time.Time  `json:"expires_at,omitempty"`
+}
+
+type archiveWriter struct {
+	txn     storage.Txn
+	encoder *json.Encoder
+	written map[string]bool
+	count   int
+}
+
+func (h *archiveWriter) writeEntry(key []byte, value []byte) error {
+	if h.written[string(key)] {
+		return nil
+	}
+
+	kind, ok := getArchiveKind(key)
+	if !ok {
+		return fmt.Errorf("Unknown archive kind for key %s", key)
+	}
+
+	entry := ArchiveEntry{
+		Kind:  kind,
+		Key:   key,
+		Value: value,
+	}
+
+	expiresAt, err := h.txn.ExpiresAt(key)
+	if err != nil {
+		return errors.New("Failed reading entry expiry. The error is: " + err.Error())
+	}
+
+	if !expiresAt.IsZero() {
+		entry.ExpiresAt = &expiresAt
+	}
+
+	err = h.encoder.Encode(entry)
+	if err != nil {
+		return errors.New("Failed writing archive entry. The error is: " + err.Error())
+	}
+
+	h.written[string(key)] = true
+	h.count++
+
+	return nil
+}
+
+// writeKey exports a single entry, missing entries are skipped
+func (h *archiveWriter) writeKey(key []byte) error {
+	value, err := h.txn.Get(key)
+	if errors.Is(err, storage.ErrKeyNotFound) {
+		return nil
+	} else if err != nil {
+		return errors.New("Failed reading entry. The error is: " + err.Error())
+	}
+
+	return h.writeEntry(key, value)
+}
+
+func (h *archiveWriter) writePrefix(prefix []byte) error {
+	return h.txn.Iterate(prefix, h.writeEntry)
+}
+
+// ExportDB writes the archive of the whole DB, or only of the given user's entries and everything their tests reference
+func ExportDB(db storage.Store, w io.Writer, userEmail string) (int, error) {
+	encoder := json.NewEncoder(w)
+
+	err := encoder.Encode(ArchiveHeader{
+		Format:    ARCHIVE_FORMAT,
+		Version:   ARCHIVE_VERSION,
+		CreatedAt: time.Now().UTC(),
+		User:      strings.ToLower(userEmail),
+	})
+	if err != nil {
+		return 0, errors.New("Failed writing archive header. The error is: " + err.Error())
+	}
+
+	aw := archiveWriter{
+		encoder: encoder,
+		written: map[string]bool{},
+	}
+
+	err = db.View(func(txn storage.Txn) error {
+		aw.txn = txn
+
+		if userEmail == "" {
+			for _, kindPrefix := range archiveKindPrefixes {
+				err := aw.writePrefix(kindPrefix.prefix)
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}
+
+		return exportUser(&aw, strings.ToLower(userEmail))
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return aw.count, nil
+}
+
+func exportUser(aw *archiveWriter, email string) error {
+	userKey := append([]byte("usere-"), []byte(email)...)
+
+	userBytes, err := aw.txn.Get(userKey)
+	if errors.Is(err, storage.ErrKeyNotFound) {
+		return fmt.Errorf("The user %s does not exist", email)
+	} else if err != nil {
+		return errors.New("Failed locating user entry. The error is: " + err.Error())
+	}
+
+	var userInst UserTestDBEntry
+	err = records.Unmarshal(RT_User, userBytes, &userInst)
+	if err != nil {
+		return errors.New("Failed cbor decoding user entry. The error is: " + err.Error())
+	}
+
+	err = aw.writeEntry(userKey, userBytes)
+	if err != nil {
+		return err
+	}
+
+	var testInstIds [][]byte
+	var listenerIds [][]byte
+	for _, rvt := range userInst.RVTestInsts {
+		testInstIds = append(testInstIds, rvt.To0, rvt.To1)
+	}
+
+	for _, dot := range userInst.DOTestInsts {
+		testInstIds = append(testInstIds, dot.To2)
+		listenerIds = append(listenerIds, dot.ListenerTo0)
+	}
+
+	for _, devt := range userInst.DeviceTestInsts {
+		listenerIds = append(listenerIds, devt.ListenerUuid)
+	}
+
+	for _, testInstId := range testInstIds {
+		err = exportTestInst(aw, testInstId)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, listenerId := range listenerIds {
+		err = exportListener(aw, listenerId)
+		if err != nil {
+			return err
+		}
+	}
+
+	return exportUserOwned(aw, email)
+}
+
+// exportUserOwned exports the API tokens and webhooks of the user. Webhook deliveries are short lived and are only in full exports
+func exportUserOwned(aw *archiveWriter, email string) error {
+	err := aw.txn.Iterate(append([]byte("apitokenidx-"), []byte(email+"/")...), func(key []byte, entryId []byte) error {
+		err := aw.writeEntry(key, entryId)
+		if err != nil {
+			return err
+		}
+
+		return aw.writeKey(entryId)
+	})
+	if err != nil {
+		return err
+	}
+
+	return aw.txn.Iterate([]byte("webhook-"), func(key []byte, value []byte) error {
+		var webhookInst testdbs.WebhookEntry
+		err := records.Unmarshal(testdbs.RT_Webhook, value, &webhookInst)
+		if err != nil {
+			return errors.New("Failed cbor decoding webhook entry. The error is: " + err.Error())
+		}
+
+		if webhookInst.Email != email {
+			return nil
+		}
+
+		return aw.writeEntry(key, value)
+	})
+}
+
+// exportTestInst exports a test instance, its results and the seeded credentials it uses
+func exportTestInst(aw *archiveWriter, testInstId []byte) error {
+	if len(testInstId) == 0 {
+		return nil
+	}
+
+	testInstKey := append([]byte("rvte-"), testInstId...)
+
+	testInstBytes, err := aw.txn.Get(testInstKey)
+	if errors.Is(err, storage.ErrKeyNotFound) {
+		return nil
+	} else if err != nil {
+		return errors.New("Failed reading test instance. The error is: " + err.Error())
+	}
+
+	err = aw.writeEntry(testInstKey, testInstBytes)
+	if err != nil {
+		return err
+	}
+
+	err = aw.writePrefix(append([]byte("rvteres-"), testInstId...))
+	if err != nil {
+		return err
+	}
+
+	var testInst reqtestsdeps.RequestTestInst
+	err = records.Unmarshal(testdbs.RT_RequestTestInst, testInstBytes, &testInst)
+	if err != nil {
+		return errors.New("Failed cbor decoding test instance. The error is: " + err.Error())
+	}
+
+	for _, guids := range testInst.FdoSeedIDs {
+		for _, guid := range guids {
+			err = aw.writeKey(append([]byte("devbasecreds-"), guid[:]...))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// exportListener exports a listener test instance together with the voucher and the TO0 registration of its device
+func exportListener(aw *archiveWriter, listenerId []byte) error {
+	if len(listenerId) == 0 {
+		return nil
+	}
+
+	listenerKey := append([]byte("lstdb-"), listenerId...)
+
+	listenerBytes, err := aw.txn.Get(listenerKey)
+	if errors.Is(err, storage.ErrKeyNotFound) {
+		return nil
+	} else if err != nil {
+		return errors.New("Failed reading listener entry. The error is: " + err.Error())
+	}
+
+	err = aw.writeEntry(listenerKey, listenerBytes)
+	if err != nil {
+		return err
+	}
+
+	var listenerInst listenertestsdeps.RequestListenerInst
+	err = records.Unmarshal(testdbs.RT_ListenerInst, listenerBytes, &listenerInst)
+	if err != nil {
+		return errors.New("Failed cbor decoding listener entry. The error is: " + err.Error())
+	}
+
+	guid := listenerInst.Guid
+	for _, key := range [][]byte{
+		append([]byte("lstdb-guid-map-"), guid[:]...),
+		append([]byte("voucher-"), guid[:]...),
+		append([]byte("to1osstorage-"), guid[:]...),
+	} {
+		err = aw.writeKey(key)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ImportDB loads an archive written by ExportDB. Existing entries are kept unless overwrite is set. Returns the number of imported and skipped entries
+func ImportDB(db storage.Store, r io.Reader, overwrite bool) (int, int, error) {
+	decoder := json.NewDecoder(r)
+
+	var header ArchiveHeader
+	err := decoder.Decode(&header)
+	if err != nil {
+		return 0, 0, errors.New("Failed reading archive header. The error is: " + err.Error())
+	}
+
+	if header.Format != ARCHIVE_FORMAT {
+		return 0, 0, fmt.Errorf("Unknown archive format \"%s\"", header.Format)
+	}
+
+	if header.Version < 1 || header.Version > ARCHIVE_VERSION {
+		return 0, 0, fmt.Errorf("Unsupported archive version %d. This build supports up to version %d", header.Version, ARCHIVE_VERSION)
+	}
+
+	imported := 0
+	skipped := 0
+
+	var batch []ArchiveEntry
+	flush := func() error {
+		batchImported := 0
+		batchSkipped := 0
+
+		err := db.Update(func(txn storage.Txn) error {
+			batchImported = 0
+			batchSkipped = 0
+
+			for _, entry := range batch {
+				if !overwrite {
+					_, err := txn.Get(entry.Key)
+					if err == nil {
+						batchSkipped++
+						continue
+					} else if !errors.Is(err, storage.ErrKeyNotFound) {
+						return err
+					}
+				}
+
+				var err error
+				if entry.ExpiresAt == nil {
+					err = txn.Set(entry.Key, entry.Value)
+				} else {
+					err = txn.SetWithTTL(entry.Key, entry.Value, time.Until(*entry.ExpiresAt))
+				}
+				if err != nil {
+					return errors.New("Failed saving entry. The error is: " + err.Error())
+				}
+
+				batchImported++
+			}
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		imported += batchImported
+		skipped += batchSkipped
+		batch = batch[:0]
+
+		return nil
+	}
+
+	for {
+		var entry ArchiveEntry
+		err = decoder.Decode(&entry)
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return imported, skipped, fmt.Errorf("Failed reading archive entry %d. The error is: %s", imported+skipped+len(batch)+1, err.Error())
+		}
+
+		kind, ok := getArchiveKind(entry.Key)
+		if !ok || kind != entry.Kind {
+			return imported, skipped, fmt.Errorf("Archive entry of kind \"%s\" has an unexpected key %s", entry.Kind, entry.Key)
+		}
+
+		err = records.CheckVersion(entry.Value)
+		if err != nil {
+			return imported, skipped, fmt.Errorf("Archive entry %s can not be imported. %s", entry.Key, err.Error())
+		}
+
+		if entry.ExpiresAt != nil && !entry.ExpiresAt.After(time.Now()) {
+			skipped++
+			continue
+		}
+
+		batch = append(batch, entry)
+		if len(batch) >= archiveImportBatchSize {
+			err = flush()
+			if err != nil {
+				return imported, skipped, err
+			}
+		}
+	}
+
+	if len(batch) > 0 {
+		err = flush()
+		if err != nil {
+			return imported, skipped, err
+		}
+	}
+
+	return imported, skipped, nil
+}
@@ -0,0 +1,183 @@
+package dbs
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/records"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/storage"
+	testdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
+)
+
+func TestArchiveRoundTrip(t *testing.T) {
+	source := storage.NewMemoryStore()
+
+	err := source.Update(func(txn storage.Txn) error {
+		txn.Set([]byte("config-main"), []byte{0x80})
+		txn.Set([]byte("devbasecreds-guid"), []byte{0x01})
+		txn.SetWithTTL([]byte("lstdb-guid-map-guid"), []byte("uuid"), time.Hour)
+		txn.Set([]byte("session-skipped"), []byte{0x02})
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to seed store. %s", err.Error())
+	}
+
+	var archive bytes.Buffer
+	count, err := ExportDB(source, &archive, "")
+	if err != nil {
+		t.Fatalf("Failed to export. %s", err.Error())
+	}
+
+	if count != 3 {
+		t.Errorf("Expected 3 exported entries. Got %d", count)
+	}
+
+	target := storage.NewMemoryStore()
+	target.Update(func(txn storage.Txn) error {
+		return txn.Set([]byte("config-main"), []byte{0xa0})
+	})
+
+	imported, skipped, err := ImportDB(target, bytes.NewReader(archive.Bytes()), false)
+	if err != nil {
+		t.Fatalf("Failed to import. %s", err.Error())
+	}
+
+	if imported != 2 || skipped != 1 {
+		t.Errorf("Expected 2 imported and 1 skipped entries. Got %d and %d", imported, skipped)
+	}
+
+	target.View(func(txn storage.Txn) error {
+		config, _ := txn.Get([]byte("config-main"))
+		if !bytes.Equal(config, []byte{0xa0}) {
+			t.Errorf("Expected the existing config to be kept")
+		}
+
+		expiresAt, err := txn.ExpiresAt([]byte("lstdb-guid-map-guid"))
+		if err != nil || expiresAt.IsZero() {
+			t.Errorf("Expected the mapping expiry to be kept. Got %s, %v", expiresAt, err)
+		}
+
+		return nil
+	})
+
+	_, _, err = ImportDB(target, bytes.NewReader([]byte(`{"format":"fdo-conformance-tools-db","version":99}`)), false)
+	if err == nil {
+		t.Errorf("Expected an error for an unsupported archive version")
+	}
+}
+
+func TestArchiveTokensAndWebhooks(t *testing.T) {
+	source := storage.NewMemoryStore()
+
+	userBytes, _ := records.Marshal(RT_User, UserTestDBEntry{Email: "a@example.com"})
+	tokenBytes, _ := records.Marshal(RT_ApiToken, ApiTokenEntry{Id: "t1", Email: "a@example.com"})
+	otherTokenBytes, _ := records.Marshal(RT_ApiToken, ApiTokenEntry{Id: "t2", Email: "b@example.com"})
+	webhookBytes, _ := records.Marshal(testdbs.RT_Webhook, testdbs.WebhookEntry{Id: "w1", Email: "a@example.com"})
+	deliveryBytes, _ := records.Marshal(testdbs.RT_WebhookDelivery, testdbs.WebhookDeliveryEntry{Id: "d1", WebhookId: "w1"})
+
+	source.Update(func(txn storage.Txn) error {
+		txn.Set([]byte("usere-a@example.com"), userBytes)
+		txn.Set([]byte("apitoken-hash1"), tokenBytes)
+		txn.Set([]byte("apitokenidx-a@example.com/t1"), []byte("apitoken-hash1"))
+		txn.Set([]byte("apitoken-hash2"), otherTokenBytes)
+		txn.Set([]byte("webhook-w1"), webhookBytes)
+		txn.Set([]byte("webhookdlv-d1"), deliveryBytes)
+		return nil
+	})
+
+	var archive bytes.Buffer
+	count, err := ExportDB(source, &archive, "")
+	if err != nil {
+		t.Fatalf("Failed to export. %s", err.Error())
+	}
+
+	if count != 6 {
+		t.Errorf("Expected 6 exported entries. Got %d", count)
+	}
+
+	kinds := map[string]ArchiveKind{}
+	decoder := json.NewDecoder(&archive)
+	decoder.Decode(&ArchiveHeader{})
+	for decoder.More() {
+		var entry ArchiveEntry
+		decoder.Decode(&entry)
+		kinds[string(entry.Key)] = entry.Kind
+	}
+
+	if kinds["apitoken-hash1"] != AK_ApiToken || kinds["apitokenidx-a@example.com/t1"] != AK_ApiTokenIndex || kinds["webhook-w1"] != AK_Webhook || kinds["webhookdlv-d1"] != AK_WebhookDelivery {
+		t.Errorf("Unexpected archive kinds %v", kinds)
+	}
+
+	archive.Reset()
+	count, err = ExportDB(source, &archive, "A@example.com")
+	if err != nil {
+		t.Fatalf("Failed to export user. %s", err.Error())
+	}
+
+	// The user, their token with its index entry and their webhook
+	if count != 4 {
+		t.Errorf("Expected 4 exported entries for the user. Got %d", count)
+	}
+
+	target := storage.NewMemoryStore()
+	imported, _, err := ImportDB(target, bytes.NewReader(archive.Bytes()), false)
+	if err != nil {
+		t.Fatalf("Failed to import. %s", err.Error())
+	}
+
+	if imported != 4 {
+		t.Errorf("Expected 4 imported entries. Got %d", imported)
+	}
+}
+
+func TestArchiveRejectsNewerRecords(t *testing.T) {
+	source := storage.NewMemoryStore()
+
+	configBytes, _ := records.Marshal(RT_MainConfig, MainConfig{})
+
+	// A config record written by a build with 99 config migrations
+	envelopeBytes, _ := fdoshared.CborCust.Marshal([]interface{}{RT_MainConfig, 99, cbor.RawMessage{0x80}})
+	newerBytes, _ := fdoshared.CborCust.Marshal(cbor.RawTag{Number: records.RECORD_ENVELOPE_TAG, Content: envelopeBytes})
+
+	source.Update(func(txn storage.Txn) error {
+		return txn.Set([]byte("config-main"), newerBytes)
+	})
+
+	var archive bytes.Buffer
+	_, err := ExportDB(source, &archive, "")
+	if err != nil {
+		t.Fatalf("Failed to export. %s", err.Error())
+	}
+
+	target := storage.NewMemoryStore()
+	_, _, err = ImportDB(target, bytes.NewReader(archive.Bytes()), false)
+	if err == nil {
+		t.Fatalf("Expected importing a record newer than this build to fail")
+	}
+
+	target.View(func(txn storage.Txn) error {
+		_, err := txn.Get([]byte("config-main"))
+		if err == nil {
+			t.Errorf("Expected the newer record not to be imported")
+		}
+
+		return nil
+	})
+
+	archive.Reset()
+	source.Update(func(txn storage.Txn) error {
+		return txn.Set([]byte("config-main"), configBytes)
+	})
+
+	ExportDB(source, &archive, "")
+	_, _, err = ImportDB(target, bytes.NewReader(archive.Bytes()), false)
+	if err != nil {
+		t.Errorf("Expected a current record to be imported. Got %s", err.Error())
+	}
+}
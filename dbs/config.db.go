@@ -3,18 +3,25 @@ package dbs
 import (
 	"errors"
 	"fmt"
-
-	"github.com/dgraph-io/badger/v4"
+	"log"
+	"runtime"
+	"sync"
 
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/records"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/storage"
 )
 
 type ConfigDB struct {
-	db     *badger.DB
+	db     storage.Store
 	prefix []byte
+
+	// Set in the ephemeral mode, see EnableLazySeeding
+	lazySeedDevBase *DeviceBaseDB
+	lazySeedMu      sync.Mutex
 }
 
-func NewConfigDB(db *badger.DB) *ConfigDB {
+func NewConfigDB(db storage.Store) *ConfigDB {
 	return &ConfigDB{
 		db:     db,
 		prefix: []byte("config-"),
@@ -27,7 +34,7 @@ type MainConfig struct {
 }
 
 func (h *ConfigDB) Save(mainCfg MainConfig) error {
-	payloadBytes, err := fdoshared.CborCust.Marshal(mainCfg)
+	payloadBytes, err := records.Marshal(RT_MainConfig, mainCfg)
 	if err != nil {
 		return errors.New("Failed to marshal MainConfig. The error is: " + err.Error())
 	}
@@ -37,8 +44,7 @@ func (h *ConfigDB) Save(mainCfg MainConfig) error {
 	dbtxn := h.db.NewTransaction(true)
 	defer dbtxn.Discard()
 
-	entry := badger.NewEntry(storageId, payloadBytes)
-	err = dbtxn.SetEntry(entry)
+	err = dbtxn.Set(storageId, payloadBytes)
 	if err != nil {
 		return errors.New("Failed creating MainConfig db entry instance. The error is: " + err.Error())
 	}
@@ -57,23 +63,121 @@ func (h *ConfigDB) Get() (*MainConfig, error) {
 	dbtxn := h.db.NewTransaction(true)
 	defer dbtxn.Discard()
 
-	item, err := dbtxn.Get(storageId)
-	if err != nil && errors.Is(err, badger.ErrKeyNotFound) {
+	itemBytes, err := dbtxn.Get(storageId)
+	if err != nil && errors.Is(err, storage.ErrKeyNotFound) {
 		return nil, fmt.Errorf("The MainConfig entry with does not exist")
 	} else if err != nil {
 		return nil, errors.New("Failed locating MainConfig entry. The error is: " + err.Error())
 	}
 
-	itemBytes, err := item.ValueCopy(nil)
-	if err != nil {
-		return nil, errors.New("Failed reading MainConfig entry value. The error is: " + err.Error())
-	}
-
 	var mainConfig MainConfig
-	err = fdoshared.CborCust.Unmarshal(itemBytes, &mainConfig)
+	err = records.Unmarshal(RT_MainConfig, itemBytes, &mainConfig)
 	if err != nil {
 		return nil, errors.New("Failed cbor decoding MainConfig entry value. The error is: " + err.Error())
 	}
 
 	return &mainConfig, nil
 }
+
+// EnableLazySeeding makes EnsureSeeded generate missing device credentials on demand, instead of relying on a pre-seeded pool
+func (h *ConfigDB) EnableLazySeeding(devBaseDB *DeviceBaseDB) {
+	h.lazySeedDevBase = devBaseDB
+}
+
+// EnsureSeeded returns the main config with at least count seeded guids for each of the given signature types.
+// Without lazy seeding it is the same as Get
+func (h *ConfigDB) EnsureSeeded(sgTypes []fdoshared.SgType, count int) (*MainConfig, error) {
+	if h.lazySeedDevBase == nil {
+		return h.Get()
+	}
+
+	h.lazySeedMu.Lock()
+	defer h.lazySeedMu.Unlock()
+
+	mainConfig, err := h.Get()
+	if err != nil {
+		mainConfig = &MainConfig{
+			SeededGuids: fdoshared.FdoSeedIDs{},
+		}
+	}
+
+	type seedJob struct {
+		sgTypeIndex int
+		sgType      fdoshared.SgType
+	}
+
+	var jobs []seedJob
+	for i, sgType := range sgTypes {
+		if sgType == fdoshared.StEPID10 || sgType == fdoshared.StEPID11 {
+			continue
+		}
+
+		missing := count - len(mainConfig.SeededGuids[sgType])
+		if missing <= 0 {
+			continue
+		}
+
+		log.Printf("Seeding %d device credentials for sgType %d", missing, sgType)
+
+		for j := 0; j < missing; j++ {
+			jobs = append(jobs, seedJob{sgTypeIndex: i, sgType: sgType})
+		}
+	}
+
+	// Credentials are generated by a pool of workers, so that slow RSA keys of one sgType are generated in parallel too
+	var wg sync.WaitGroup
+	var newCredsMu sync.Mutex
+	var newCreds = make([][]fdoshared.WawDeviceCredential, len(sgTypes))
+	var seedErrs = make([]error, len(jobs))
+	jobsChan := make(chan int, len(jobs))
+	for j := range jobs {
+		jobsChan <- j
+	}
+	close(jobsChan)
+
+	for w := 0; w < min(runtime.NumCPU(), len(jobs)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobsChan {
+				newCred, err := fdoshared.NewWawDeviceCredential(jobs[j].sgType)
+				if err != nil {
+					seedErrs[j] = fmt.Errorf("Error generating device base for sgType %d. %s", jobs[j].sgType, err.Error())
+					continue
+				}
+
+				newCredsMu.Lock()
+				newCreds[jobs[j].sgTypeIndex] = append(newCreds[jobs[j].sgTypeIndex], *newCred)
+				newCredsMu.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if err := errors.Join(seedErrs...); err != nil {
+		return nil, err
+	}
+
+	seeded := false
+	for i, sgType := range sgTypes {
+		for _, newCred := range newCreds[i] {
+			err := h.lazySeedDevBase.Save(newCred)
+			if err != nil {
+				return nil, errors.New("Error saving device base. " + err.Error())
+			}
+
+			mainConfig.SeededGuids[sgType] = append(mainConfig.SeededGuids[sgType], newCred.DCGuid)
+			seeded = true
+		}
+	}
+
+	if seeded {
+		err = h.Save(*mainConfig)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return mainConfig, nil
+}
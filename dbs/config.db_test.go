@@ -0,0 +1,58 @@
+package dbs
+
+import (
+	"testing"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/storage"
+)
+
+func TestConfigDBLazySeeding(t *testing.T) {
+	db := storage.NewMemoryStore()
+	defer db.Close()
+
+	configDB := NewConfigDB(db)
+	devBaseDB := NewDeviceBaseDB(db)
+	configDB.EnableLazySeeding(devBaseDB)
+
+	mainConfig, err := configDB.EnsureSeeded([]fdoshared.SgType{fdoshared.StSECP256R1}, 2)
+	if err != nil {
+		t.Fatalf("Failed to seed. %s", err.Error())
+	}
+
+	if len(mainConfig.SeededGuids) != 1 || len(mainConfig.SeededGuids[fdoshared.StSECP256R1]) != 2 {
+		t.Fatalf("Expected only 2 SECP256R1 guids to be seeded. Got %v", mainConfig.SeededGuids)
+	}
+
+	mainConfig, err = configDB.EnsureSeeded([]fdoshared.SgType{fdoshared.StSECP256R1, fdoshared.StSECP384R1}, 4)
+	if err != nil {
+		t.Fatalf("Failed to seed. %s", err.Error())
+	}
+
+	for _, sgType := range []fdoshared.SgType{fdoshared.StSECP256R1, fdoshared.StSECP384R1} {
+		if len(mainConfig.SeededGuids[sgType]) != 4 {
+			t.Errorf("Expected 4 guids for sgType %d. Got %d", sgType, len(mainConfig.SeededGuids[sgType]))
+		}
+
+		for _, guid := range mainConfig.SeededGuids[sgType] {
+			deviceCredential, err := devBaseDB.Get(guid)
+			if err != nil {
+				t.Fatalf("Failed to get seeded credential. %s", err.Error())
+			}
+
+			if deviceCredential.DCSigInfo.SgType != sgType {
+				t.Errorf("Expected a credential of sgType %d. Got %d", sgType, deviceCredential.DCSigInfo.SgType)
+			}
+		}
+	}
+
+	count, err := devBaseDB.Count()
+	if err != nil || count != 8 {
+		t.Errorf("Expected 8 stored credentials. Got %d, %v", count, err)
+	}
+
+	batch := mainConfig.SeededGuids.GetTestBatch([]fdoshared.SgType{fdoshared.StSECP256R1}, 3)
+	if len(batch) != 1 || len(batch[fdoshared.StSECP256R1]) != 3 {
+		t.Errorf("Expected a batch of 3 SECP256R1 guids. Got %v", batch)
+	}
+}
@@ -6,19 +6,19 @@ import (
 	"fmt"
 	"log"
 
-	"github.com/dgraph-io/badger/v4"
-
 	fdodeviceimplementation "github.com/fido-alliance/iot-fdo-conformance-tools/core/device"
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/records"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/storage"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
 )
 
 type DeviceBaseDB struct {
-	db     *badger.DB
+	db     storage.Store
 	prefix []byte
 }
 
-func NewDeviceBaseDB(db *badger.DB) *DeviceBaseDB {
+func NewDeviceBaseDB(db storage.Store) *DeviceBaseDB {
 	return &DeviceBaseDB{
 		db:     db,
 		prefix: []byte("devbasecreds-"),
@@ -26,7 +26,7 @@ func NewDeviceBaseDB(db *badger.DB) *DeviceBaseDB {
 }
 
 func (h *DeviceBaseDB) Save(deviceBaseDB fdoshared.WawDeviceCredential) error {
-	rvteBytes, err := fdoshared.CborCust.Marshal(deviceBaseDB)
+	rvteBytes, err := records.Marshal(RT_DeviceCredential, deviceBaseDB)
 	if err != nil {
 		return errors.New("Failed to marshal DeviceBase. The error is: " + err.Error())
 	}
@@ -36,8 +36,7 @@ func (h *DeviceBaseDB) Save(deviceBaseDB fdoshared.WawDeviceCredential) error {
 	dbtxn := h.db.NewTransaction(true)
 	defer dbtxn.Discard()
 
-	entry := badger.NewEntry(storageId, rvteBytes)
-	err = dbtxn.SetEntry(entry)
+	err = dbtxn.Set(storageId, rvteBytes)
 	if err != nil {
 		return errors.New("Failed creating rvte db entry instance. The error is: " + err.Error())
 	}
@@ -56,20 +55,15 @@ func (h *DeviceBaseDB) Get(guid fdoshared.FdoGuid) (*fdoshared.WawDeviceCredenti
 	dbtxn := h.db.NewTransaction(true)
 	defer dbtxn.Discard()
 
-	item, err := dbtxn.Get(storageId)
-	if err != nil && errors.Is(err, badger.ErrKeyNotFound) {
+	itemBytes, err := dbtxn.Get(storageId)
+	if err != nil && errors.Is(err, storage.ErrKeyNotFound) {
 		return nil, fmt.Errorf("The devCred entry with id %s does not exist", hex.EncodeToString(guid[:]))
 	} else if err != nil {
 		return nil, errors.New("Failed locating devCred entry. The error is: " + err.Error())
 	}
 
-	itemBytes, err := item.ValueCopy(nil)
-	if err != nil {
-		return nil, errors.New("Failed reading devCred entry value. The error is: " + err.Error())
-	}
-
 	var devCred fdoshared.WawDeviceCredential
-	err = fdoshared.CborCust.Unmarshal(itemBytes, &devCred)
+	err = records.Unmarshal(RT_DeviceCredential, itemBytes, &devCred)
 	if err != nil {
 		return nil, errors.New("Failed cbor decoding devCred entry value. The error is: " + err.Error())
 	}
@@ -83,20 +77,15 @@ func (h *DeviceBaseDB) GetVANDV(guid fdoshared.FdoGuid, testid testcom.FDOTestID
 	dbtxn := h.db.NewTransaction(true)
 	defer dbtxn.Discard()
 
-	item, err := dbtxn.Get(storageId)
-	if err != nil && errors.Is(err, badger.ErrKeyNotFound) {
+	itemBytes, err := dbtxn.Get(storageId)
+	if err != nil && errors.Is(err, storage.ErrKeyNotFound) {
 		return nil, fmt.Errorf("The DevBase entry with id %s does not exist", hex.EncodeToString(guid[:]))
 	} else if err != nil {
 		return nil, errors.New("Failed locating DevBase entry. The error is: " + err.Error())
 	}
 
-	itemBytes, err := item.ValueCopy(nil)
-	if err != nil {
-		return nil, errors.New("Failed reading DevBase entry value. The error is: " + err.Error())
-	}
-
 	var devCred fdoshared.WawDeviceCredential
-	err = fdoshared.CborCust.Unmarshal(itemBytes, &devCred)
+	err = records.Unmarshal(RT_DeviceCredential, itemBytes, &devCred)
 	if err != nil {
 		return nil, errors.New("Failed cbor decoding DevBase entry value. The error is: " + err.Error())
 	}
@@ -119,20 +108,15 @@ func (h *DeviceBaseDB) GetVANDVWithWithKeys(guid fdoshared.FdoGuid, ownerPrivKey
 	dbtxn := h.db.NewTransaction(true)
 	defer dbtxn.Discard()
 
-	item, err := dbtxn.Get(storageId)
-	if err != nil && errors.Is(err, badger.ErrKeyNotFound) {
+	itemBytes, err := dbtxn.Get(storageId)
+	if err != nil && errors.Is(err, storage.ErrKeyNotFound) {
 		return nil, fmt.Errorf("The DevBase entry with id %s does not exist", hex.EncodeToString(guid[:]))
 	} else if err != nil {
 		return nil, errors.New("Failed locating DevBase entry. The error is: " + err.Error())
 	}
 
-	itemBytes, err := item.ValueCopy(nil)
-	if err != nil {
-		return nil, errors.New("Failed reading DevBase entry value. The error is: " + err.Error())
-	}
-
 	var devCred fdoshared.WawDeviceCredential
-	err = fdoshared.CborCust.Unmarshal(itemBytes, &devCred)
+	err = records.Unmarshal(RT_DeviceCredential, itemBytes, &devCred)
 	if err != nil {
 		return nil, errors.New("Failed cbor decoding DevBase entry value. The error is: " + err.Error())
 	}
@@ -177,3 +161,16 @@ func (h *DeviceBaseDB) GetGuids(guids []fdoshared.FdoGuid) (*[]fdoshared.WawDevi
 
 	return &devCredsList, nil
 }
+
+// Count returns the size of the seeded device credentials pool
+func (h *DeviceBaseDB) Count() (int, error) {
+	count := 0
+	err := h.db.View(func(txn storage.Txn) error {
+		return txn.Iterate(h.prefix, func(key []byte, value []byte) error {
+			count++
+			return nil
+		})
+	})
+
+	return count, err
+}
@@ -0,0 +1,45 @@
+package dbs
+
+import (
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/records"
+)
+
+const (
+	RT_User             records.RecordType = "user"
+	RT_Session          records.RecordType = "api_session"
+	RT_Verify           records.RecordType = "verify"
+	RT_MainConfig       records.RecordType = "main_config"
+	RT_DeviceCredential records.RecordType = "device_credential"
+	RT_ApiToken         records.RecordType = "api_token"
+)
+
+func init() {
+	records.Register(records.Schema{
+		Type:     RT_User,
+		Prefixes: [][]byte{[]byte("usere-")},
+	})
+
+	// Sessions and verification links are short lived, they are only migrated on read
+	records.Register(records.Schema{
+		Type: RT_Session,
+	})
+
+	records.Register(records.Schema{
+		Type: RT_Verify,
+	})
+
+	records.Register(records.Schema{
+		Type:     RT_MainConfig,
+		Prefixes: [][]byte{[]byte("config-")},
+	})
+
+	records.Register(records.Schema{
+		Type:     RT_DeviceCredential,
+		Prefixes: [][]byte{[]byte("devbasecreds-")},
+	})
+
+	records.Register(records.Schema{
+		Type:     RT_ApiToken,
+		Prefixes: [][]byte{[]byte("apitoken-")},
+	})
+}
@@ -6,18 +6,18 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/dgraph-io/badger/v4"
 	"github.com/google/uuid"
 
-	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/records"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/storage"
 )
 
 type SessionDB struct {
-	db     *badger.DB
+	db     storage.Store
 	prefix []byte
 }
 
-func NewSessionDB(db *badger.DB) *SessionDB {
+func NewSessionDB(db storage.Store) *SessionDB {
 	return &SessionDB{
 		db:     db,
 		prefix: []byte("session-"),
@@ -42,7 +42,7 @@ type SessionEntry struct {
 }
 
 func (h *SessionDB) NewSessionEntry(sessionInst SessionEntry) ([]byte, error) {
-	sessionBytes, err := fdoshared.CborCust.Marshal(sessionInst)
+	sessionBytes, err := records.Marshal(RT_Session, sessionInst)
 	if err != nil {
 		return []byte{}, errors.New("Failed to marshal session. The error is: " + err.Error())
 	}
@@ -54,8 +54,7 @@ func (h *SessionDB) NewSessionEntry(sessionInst SessionEntry) ([]byte, error) {
 	dbtxn := h.db.NewTransaction(true)
 	defer dbtxn.Discard()
 
-	entry := badger.NewEntry(sessionEntryId, sessionBytes).WithTTL(MAX_SESSION_TIME)
-	err = dbtxn.SetEntry(entry)
+	err = dbtxn.SetWithTTL(sessionEntryId, sessionBytes, MAX_SESSION_TIME)
 	if err != nil {
 		return []byte{}, errors.New("Failed creating session db entry instance. The error is: " + err.Error())
 	}
@@ -74,7 +73,7 @@ func (h *SessionDB) UpdateSessionEntry(entryId []byte, sessionInst SessionEntry)
 	dbtxn := h.db.NewTransaction(true)
 	defer dbtxn.Discard()
 
-	sessionInstBytes, err := fdoshared.CborCust.Marshal(sessionInst)
+	sessionInstBytes, err := records.Marshal(RT_Session, sessionInst)
 	if err != nil {
 		return errors.New("Failed to marshal session. The error is: " + err.Error())
 	}
@@ -98,20 +97,15 @@ func (h *SessionDB) GetSessionEntry(entryId []byte) (*SessionEntry, error) {
 	dbtxn := h.db.NewTransaction(true)
 	defer dbtxn.Discard()
 
-	item, err := dbtxn.Get(sessionEntryId)
-	if err != nil && errors.Is(err, badger.ErrKeyNotFound) {
+	itemBytes, err := dbtxn.Get(sessionEntryId)
+	if err != nil && errors.Is(err, storage.ErrKeyNotFound) {
 		return nil, fmt.Errorf("The session entry with id %s does not exist", hex.EncodeToString(entryId))
 	} else if err != nil {
 		return nil, errors.New("Failed locating entry. The error is: " + err.Error())
 	}
 
-	itemBytes, err := item.ValueCopy(nil)
-	if err != nil {
-		return nil, errors.New("Failed reading entry value. The error is: " + err.Error())
-	}
-
 	var sessionEntryInst SessionEntry
-	err = fdoshared.CborCust.Unmarshal(itemBytes, &sessionEntryInst)
+	err = records.Unmarshal(RT_Session, itemBytes, &sessionEntryInst)
 	if err != nil {
 		return nil, errors.New("Failed cbor decoding entry value. The error is: " + err.Error())
 	}
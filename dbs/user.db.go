@@ -7,13 +7,12 @@ import (
 	"log"
 	"strings"
 
-	"github.com/dgraph-io/badger/v4"
-
-	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/records"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/storage"
 )
 
 // DB Methods
-func NewUserTestDB(db *badger.DB) *UserTestDB {
+func NewUserTestDB(db storage.Store) *UserTestDB {
 	return &UserTestDB{
 		db:     db,
 		prefix: []byte("usere-"),
@@ -23,7 +22,7 @@ func NewUserTestDB(db *badger.DB) *UserTestDB {
 func (h *UserTestDB) Save(usere UserTestDBEntry) error {
 	email := strings.ToLower(usere.Email)
 
-	usereBytes, err := fdoshared.CborCust.Marshal(usere)
+	usereBytes, err := records.Marshal(RT_User, usere)
 	if err != nil {
 		return errors.New("Failed to marshal User entry. The error is: " + err.Error())
 	}
@@ -33,8 +32,7 @@ func (h *UserTestDB) Save(usere UserTestDBEntry) error {
 	dbtxn := h.db.NewTransaction(true)
 	defer dbtxn.Discard()
 
-	entry := badger.NewEntry(userEStorageId, usereBytes)
-	err = dbtxn.SetEntry(entry)
+	err = dbtxn.Set(userEStorageId, usereBytes)
 	if err != nil {
 		return errors.New("Failed creating User db entry instance. The error is: " + err.Error())
 	}
@@ -55,20 +53,15 @@ func (h *UserTestDB) Get(email string) (*UserTestDBEntry, error) {
 	dbtxn := h.db.NewTransaction(true)
 	defer dbtxn.Discard()
 
-	item, err := dbtxn.Get(userEStorageId)
-	if err != nil && errors.Is(err, badger.ErrKeyNotFound) {
+	itemBytes, err := dbtxn.Get(userEStorageId)
+	if err != nil && errors.Is(err, storage.ErrKeyNotFound) {
 		return nil, fmt.Errorf("The user entry with id %s does not exist", email)
 	} else if err != nil {
 		return nil, errors.New("Failed locating entry. The error is: " + err.Error())
 	}
 
-	itemBytes, err := item.ValueCopy(nil)
-	if err != nil {
-		return nil, errors.New("Failed reading entry value. The error is: " + err.Error())
-	}
-
 	var usertEntryInst UserTestDBEntry
-	err = fdoshared.CborCust.Unmarshal(itemBytes, &usertEntryInst)
+	err = records.Unmarshal(RT_User, itemBytes, &usertEntryInst)
 	if err != nil {
 		return nil, errors.New("Failed cbor decoding entry value. The error is: " + err.Error())
 	}
@@ -77,29 +70,16 @@ func (h *UserTestDB) Get(email string) (*UserTestDBEntry, error) {
 }
 
 func (h *UserTestDB) ResetUsers() error {
-	dbtxn := h.db.NewTransaction(true)
-	defer dbtxn.Discard()
+	return h.db.Update(func(txn storage.Txn) error {
+		return txn.Iterate(h.prefix, func(key []byte, value []byte) error {
+			log.Println("Deleting... " + hex.EncodeToString(key))
 
-	iterTxn := dbtxn.NewIterator(badger.IteratorOptions{
-		Prefix: h.prefix,
-	})
-	defer iterTxn.Close()
-	for iterTxn.Rewind(); iterTxn.Valid(); iterTxn.Next() {
-		item := iterTxn.Item()
-		k := item.Key()
-
-		log.Println("Deleting... " + hex.EncodeToString(k))
-
-		err := dbtxn.Delete(k)
-		if err != nil {
-			log.Println("Error creater delete req... " + hex.EncodeToString(k))
-		}
-
-		err = dbtxn.Commit()
-		if err != nil {
-			log.Println("Failed to commit delete req... " + hex.EncodeToString(k))
-		}
-	}
+			err := txn.Delete(key)
+			if err != nil {
+				log.Println("Error creater delete req... " + hex.EncodeToString(key))
+			}
 
-	return nil
+			return nil
+		})
+	})
 }
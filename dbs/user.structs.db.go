@@ -3,14 +3,14 @@ package dbs
 import (
 	"bytes"
 
-	"github.com/dgraph-io/badger/v4"
 	"github.com/google/uuid"
 
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/storage"
 )
 
 type UserTestDB struct {
-	db     *badger.DB
+	db     storage.Store
 	prefix []byte
 }
 
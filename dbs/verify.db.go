@@ -6,18 +6,18 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/dgraph-io/badger/v4"
 	"github.com/google/uuid"
 
-	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/records"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/storage"
 )
 
 type VerifyDB struct {
-	db     *badger.DB
+	db     storage.Store
 	prefix []byte
 }
 
-func NewVerifyDB(db *badger.DB) *VerifyDB {
+func NewVerifyDB(db storage.Store) *VerifyDB {
 	return &VerifyDB{
 		db:     db,
 		prefix: []byte("verifydb-"),
@@ -43,7 +43,7 @@ type VerifyEntry struct {
 }
 
 func (h *VerifyDB) SaveEntry(verifyEntry VerifyEntry) ([]byte, error) {
-	vtBytes, err := fdoshared.CborCust.Marshal(verifyEntry)
+	vtBytes, err := records.Marshal(RT_Verify, verifyEntry)
 	if err != nil {
 		return []byte{}, errors.New("Failed to marshal vt. The error is: " + err.Error())
 	}
@@ -55,8 +55,7 @@ func (h *VerifyDB) SaveEntry(verifyEntry VerifyEntry) ([]byte, error) {
 	dbtxn := h.db.NewTransaction(true)
 	defer dbtxn.Discard()
 
-	entry := badger.NewEntry(vtEntryId, vtBytes).WithTTL(MAX_VERIFY_TIME)
-	err = dbtxn.SetEntry(entry)
+	err = dbtxn.SetWithTTL(vtEntryId, vtBytes, MAX_VERIFY_TIME)
 	if err != nil {
 		return []byte{}, errors.New("Failed creating vt db entry instance. The error is: " + err.Error())
 	}
@@ -75,20 +74,15 @@ func (h *VerifyDB) GetEntry(entryId []byte) (*VerifyEntry, error) {
 	dbtxn := h.db.NewTransaction(true)
 	defer dbtxn.Discard()
 
-	item, err := dbtxn.Get(entryDbId)
-	if err != nil && errors.Is(err, badger.ErrKeyNotFound) {
+	itemBytes, err := dbtxn.Get(entryDbId)
+	if err != nil && errors.Is(err, storage.ErrKeyNotFound) {
 		return nil, fmt.Errorf("The session entry with id %s does not exist", hex.EncodeToString(entryId))
 	} else if err != nil {
 		return nil, errors.New("Failed locating entry. The error is: " + err.Error())
 	}
 
-	itemBytes, err := item.ValueCopy(nil)
-	if err != nil {
-		return nil, errors.New("Failed reading entry value. The error is: " + err.Error())
-	}
-
 	var sessionEntryInst VerifyEntry
-	err = fdoshared.CborCust.Unmarshal(itemBytes, &sessionEntryInst)
+	err = records.Unmarshal(RT_Verify, itemBytes, &sessionEntryInst)
 	if err != nil {
 		return nil, errors.New("Failed cbor decoding entry value. The error is: " + err.Error())
 	}
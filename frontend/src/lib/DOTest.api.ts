@@ -45,7 +45,7 @@ export const addNewDo = async (url, privKey): Promise<Array<any>> => {
     return resultJson.rvts;
 };
 
-export const executeDoTests = async (id): Promise<Array<any>> => {
+export const executeDoTests = async (id): Promise<string> => {
     let result = await fetch("/api/dot/execute", {
         method: "POST",
         headers: {
@@ -66,7 +66,7 @@ export const executeDoTests = async (id): Promise<Array<any>> => {
         return Promise.reject(`Error sending request: ${statusText}`);
     }
 
-    return resultJson.rvts;
+    return resultJson.runId;
 };
 
 export const removeTestRun = async (
@@ -48,7 +48,7 @@ export const addNewRv = async (url): Promise<Array<any>> => {
 
 
 
-export const executeRvTests = async (id): Promise<Array<any>> => {
+export const executeRvTests = async (id): Promise<string> => {
     let result = await fetch("/api/rvt/execute", {
         method: "POST",
         headers: {
@@ -69,7 +69,7 @@ export const executeRvTests = async (id): Promise<Array<any>> => {
         return Promise.reject(`Error sending request: ${statusText}`)
     }
 
-    return resultJson.rvts
+    return resultJson.runId
 }
 
 export const removeTestRun = async (id: string, testRunId: string): Promise<Array<any>> => {
@@ -0,0 +1,164 @@
+// Package harness runs the RV and DO servers and a virtual device in process, for use in Go tests
+package harness
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	fdodevice "github.com/fido-alliance/iot-fdo-conformance-tools/core/device"
+	fdodo "github.com/fido-alliance/iot-fdo-conformance-tools/core/do"
+	dodbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/do/dbs"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/do/to0"
+	fdorv "github.com/fido-alliance/iot-fdo-conformance-tools/core/rv"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/storage"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+)
+
+// Harness is a RV and a DO server, each on its own httptest server and private mux
+type Harness struct {
+	RV *httptest.Server
+	DO *httptest.Server
+
+	// Db backs both servers. It is an in memory store unless one is given to NewHarness
+	Db  storage.Store
+	Ctx context.Context
+
+	// Clock drives the expiry of the in memory store, so that tests can expire sessions without waiting.
+	// It is nil when a store is given to NewHarness, Badger and SQLite stores expire on the wall clock
+	Clock *storage.VirtualClock
+
+	voucherDB *dodbs.VoucherDB
+}
+
+// NewRVHandler returns the RV message handlers on a private mux
+func NewRVHandler(db storage.Store, ctx context.Context) http.Handler {
+	mux := http.NewServeMux()
+	fdorv.RegisterRoutes(mux, db, ctx)
+	return mux
+}
+
+// NewDOHandler returns the DO message handlers on a private mux. The DO address sent in TO0 is read from
+// fdoshared.CFG_ENV_FDO_SERVICE_URL in ctx
+func NewDOHandler(db storage.Store, ctx context.Context) http.Handler {
+	mux := http.NewServeMux()
+	fdodo.RegisterRoutes(mux, db, ctx)
+	return mux
+}
+
+// NewHarness starts the RV and DO servers. db can be nil, in which case an in memory store is used.
+// Call Close when done
+func NewHarness(db storage.Store) *Harness {
+	var clock *storage.VirtualClock
+	if db == nil {
+		clock = storage.NewVirtualClock()
+		db = storage.NewMemoryStoreWithClock(clock.Now)
+	}
+
+	rvServer := httptest.NewUnstartedServer(nil)
+	doServer := httptest.NewUnstartedServer(nil)
+
+	// The DO address is sent to the RV in TO0, so it must be known before the handlers are created
+	doUrl := "http://" + doServer.Listener.Addr().String()
+	rvUrl := "http://" + rvServer.Listener.Addr().String()
+
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, fdoshared.CFG_ENV_INTEROP_ENABLED, false)
+	ctx = context.WithValue(ctx, fdoshared.CFG_ENV_FDO_SERVICE_URL, doUrl)
+	ctx = context.WithValue(ctx, fdoshared.CFG_ENV_FDO_RV_URL, rvUrl)
+
+	rvServer.Config.Handler = NewRVHandler(db, ctx)
+	doServer.Config.Handler = NewDOHandler(db, ctx)
+
+	rvServer.Start()
+	doServer.Start()
+
+	return &Harness{
+		RV:        rvServer,
+		DO:        doServer,
+		Db:        db,
+		Ctx:       ctx,
+		Clock:     clock,
+		voucherDB: dodbs.NewVoucherDB(db),
+	}
+}
+
+// NewDevice generates a device credential and its voucher, pointing at the harness RV, and loads the voucher into the DO
+func (h *Harness) NewDevice(sgType fdoshared.SgType) (*fdoshared.DeviceCredAndVoucher, error) {
+	credential, err := fdoshared.NewWawDeviceCredential(sgType)
+	if err != nil {
+		return nil, errors.New("Failed to generate device credential. " + err.Error())
+	}
+
+	rvInfo, err := fdoshared.UrlsToRendezvousInfo([]string{h.RV.URL})
+	if err != nil {
+		return nil, errors.New("Failed to generate RV info. " + err.Error())
+	}
+
+	credAndVoucher, err := fdodevice.NewVirtualDeviceAndVoucher(*credential, sgType, rvInfo, testcom.NULL_TEST)
+	if err != nil {
+		return nil, errors.New("Failed to generate voucher. " + err.Error())
+	}
+
+	credAndVoucher.VoucherDBEntry.SgType = sgType
+
+	err = h.voucherDB.Save(credAndVoucher.VoucherDBEntry)
+	if err != nil {
+		return nil, err
+	}
+
+	return credAndVoucher, nil
+}
+
+// RegisterOwner runs TO0 from the DO to the RV for the voucher
+func (h *Harness) RegisterOwner(voucherDBEntry fdoshared.VoucherDBEntry) error {
+	to0inst := to0.NewTo0Requestor(fdoshared.SRVEntry{
+		SrvURL: h.RV.URL,
+	}, voucherDBEntry, h.Ctx)
+
+	helloAck21, _, err := to0inst.Hello20(testcom.NULL_TEST)
+	if err != nil {
+		return errors.New("Error running Hello20. " + err.Error())
+	}
+
+	_, _, err = to0inst.OwnerSign22(helloAck21.NonceTO0Sign, testcom.NULL_TEST)
+	if err != nil {
+		return errors.New("Error running OwnerSign22. " + err.Error())
+	}
+
+	return nil
+}
+
+// Onboard runs TO0, TO1 and TO2 for the device end to end. The device finds the DO through the RV,
+// and the service info received from the owner is returned
+func (h *Harness) Onboard(ctx context.Context, credAndVoucher *fdoshared.DeviceCredAndVoucher) (fdoshared.SIMS, error) {
+	err := h.RegisterOwner(credAndVoucher.VoucherDBEntry)
+	if err != nil {
+		return nil, errors.New("TO0 failed. " + err.Error())
+	}
+
+	to1dPayload, err := fdodevice.RunTo1(ctx, h.RV.URL, credAndVoucher.WawDeviceCredential)
+	if err != nil {
+		return nil, errors.New("TO1 failed. " + err.Error())
+	}
+
+	doUrl, err := fdoshared.TOAddrEntryToUrl(to1dPayload.To1dRV[0])
+	if err != nil {
+		return nil, errors.New("TO1 failed. " + err.Error())
+	}
+
+	kexSuite := fdoshared.SgTypeToKexSuitName[credAndVoucher.VoucherDBEntry.SgType]
+	_, ownerSims, err := fdodevice.RunTo2(ctx, doUrl, credAndVoucher.WawDeviceCredential, kexSuite, fdoshared.CIPHER_A128GCM)
+	if err != nil {
+		return nil, errors.New("TO2 failed. " + err.Error())
+	}
+
+	return ownerSims, nil
+}
+
+func (h *Harness) Close() {
+	h.RV.Close()
+	h.DO.Close()
+}
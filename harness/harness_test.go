@@ -0,0 +1,25 @@
+package harness
+
+import (
+	"context"
+	"testing"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+)
+
+func TestOnboard(t *testing.T) {
+	h := NewHarness(nil)
+	defer h.Close()
+
+	for _, sgType := range []fdoshared.SgType{fdoshared.StSECP256R1, fdoshared.StSECP384R1} {
+		credAndVoucher, err := h.NewDevice(sgType)
+		if err != nil {
+			t.Fatalf("Failed to create device. %s", err.Error())
+		}
+
+		_, err = h.Onboard(context.Background(), credAndVoucher)
+		if err != nil {
+			t.Fatalf("Failed to onboard %d device. %s", sgType, err.Error())
+		}
+	}
+}
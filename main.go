@@ -3,38 +3,36 @@ package main
 import (
 	"context"
 	"encoding/hex"
+	"encoding/json"
 	"encoding/pem"
 	"fmt"
 	"log"
-	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/dgraph-io/badger/v4"
 	"github.com/joho/godotenv"
 	"github.com/urfave/cli/v2"
 
-	"github.com/fido-alliance/iot-fdo-conformance-tools/api"
 	fdodeviceimplementation "github.com/fido-alliance/iot-fdo-conformance-tools/core/device"
 	fdodocommon "github.com/fido-alliance/iot-fdo-conformance-tools/core/device/common"
-	"github.com/fido-alliance/iot-fdo-conformance-tools/core/device/to1"
-	"github.com/fido-alliance/iot-fdo-conformance-tools/core/device/to2"
-	fdodo "github.com/fido-alliance/iot-fdo-conformance-tools/core/do"
 	dodbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/do/dbs"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/do/to0"
-	fdorv "github.com/fido-alliance/iot-fdo-conformance-tools/core/rv"
 	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/records"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/storage"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
 	testcomdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
 	"github.com/fido-alliance/iot-fdo-conformance-tools/dbs"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/probe"
 )
 
 const (
 	DEFAULT_PORT    = 8080
 	BADGER_LOCATION = "./badger.local.db"
+	SQLITE_LOCATION = "./sqlite.local.db"
 )
 
 func TryReadingWawDIFile(filepath string) (*fdoshared.WawDeviceCredential, error) {
@@ -65,13 +63,41 @@ func TryReadingWawDIFile(filepath string) (*fdoshared.WawDeviceCredential, error
 	return &wawdicred, nil
 }
 
-func InitBadgerDB() *badger.DB {
-	options := badger.DefaultOptions(BADGER_LOCATION)
-	options.Logger = nil
+func InitDB() storage.Store {
+	return InitDBAt(os.Getenv(string(fdoshared.CFG_ENV_STORAGE_PATH)))
+}
+
+// InitDBAt opens and migrates the DB at path, using STORAGE_BACKEND. An empty path selects the backend default
+func InitDBAt(path string) storage.Store {
+	backend := os.Getenv(string(fdoshared.CFG_ENV_STORAGE_BACKEND))
+
+	if path == "" && backend == storage.BACKEND_SQLITE {
+		path = SQLITE_LOCATION
+	} else if path == "" {
+		path = BADGER_LOCATION
+	}
+
+	db, err := storage.Open(backend, path)
+	if err != nil {
+		log.Panicln("Error opening DB. " + err.Error())
+	}
+
+	migrated, err := records.MigrateStore(db)
+	if err != nil {
+		log.Panicln("Error migrating DB records. " + err.Error())
+	}
+
+	if migrated > 0 {
+		log.Printf("Migrated %d DB records to the current schema", migrated)
+	}
 
-	db, err := badger.Open(options)
+	indexed, err := dbs.NewApiTokenDB(db).IndexTokens()
 	if err != nil {
-		log.Panicln("Error opening Badger DB. " + err.Error())
+		log.Panicln("Error indexing API tokens. " + err.Error())
+	}
+
+	if indexed > 0 {
+		log.Printf("Indexed %d API tokens", indexed)
 	}
 
 	return db
@@ -118,6 +144,8 @@ func loadEnvCtx() context.Context {
 
 	ctx = context.WithValue(ctx, fdoshared.CFG_ENV_INTEROP_ENABLED, iopEnabled)
 	ctx = TryEnvAndSaveToCtx(ctx, fdoshared.CFG_ENV_FDO_SERVICE_URL, defaultUrl, iopEnabled)
+	ctx = TryEnvAndSaveToCtx(ctx, fdoshared.CFG_ENV_FDO_RV_URL, ctx.Value(fdoshared.CFG_ENV_FDO_SERVICE_URL).(string), false)
+	ctx = TryEnvAndSaveToCtx(ctx, fdoshared.CFG_ENV_FDO_API_URL, ctx.Value(fdoshared.CFG_ENV_FDO_SERVICE_URL).(string), false)
 	ctx = TryEnvAndSaveToCtx(ctx, fdoshared.CFG_ENV_INTEROP_DASHBOARD_RV_AUTHZ, "", iopEnabled)
 	ctx = TryEnvAndSaveToCtx(ctx, fdoshared.CFG_ENV_INTEROP_DASHBOARD_DO_AUTHZ, "", iopEnabled)
 	ctx = TryEnvAndSaveToCtx(ctx, fdoshared.CFG_ENV_INTEROP_DO_TOKEN_MAPPING, "", iopEnabled)
@@ -125,7 +153,7 @@ func loadEnvCtx() context.Context {
 	return ctx
 }
 
-func checkAndSeed(db *badger.DB) error {
+func checkAndSeed(db storage.Store) error {
 	time.Sleep(4 * time.Second)
 
 	devbasedb := dbs.NewDeviceBaseDB(db)
@@ -149,12 +177,38 @@ func checkFrontendExists() bool {
 	return !os.IsNotExist(err)
 }
 
+// writeProfile prints the capability profile as JSON, or saves it to outPath if set
+func writeProfile(profile *probe.Profile, outPath string) error {
+	profileBytes, err := json.MarshalIndent(profile, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding profile. %s", err.Error())
+	}
+
+	if outPath == "" {
+		fmt.Println(string(profileBytes))
+		return nil
+	}
+
+	err = os.WriteFile(outPath, profileBytes, 0644)
+	if err != nil {
+		return fmt.Errorf("error writing profile. %s", err.Error())
+	}
+
+	log.Printf("Saved capability profile to %s", outPath)
+	return nil
+}
+
 func main() {
 	err := godotenv.Load()
 	if err != nil {
 		log.Println("Error loading .env file. " + err.Error())
 	}
 
+	err = fdoshared.InitLogger(os.Getenv(string(fdoshared.CFG_ENV_LOG_LEVEL)), os.Getenv(string(fdoshared.CFG_ENV_LOG_FORMAT)), os.Getenv(string(fdoshared.CFG_ENV_LOG_OUTPUT)))
+	if err != nil {
+		log.Fatalln("Error configuring logger. " + err.Error())
+	}
+
 	cliapp := &cli.App{
 		EnableBashCompletion: true,
 		Compiled:             time.Now(),
@@ -177,33 +231,91 @@ func main() {
 						Aliases: []string{"f"},
 						Usage:   "Force server to start without checking for frontend folder",
 					},
+					&cli.BoolFlag{
+						Name:  "ephemeral",
+						Usage: "Keep all data in memory and generate device credentials on demand instead of pre-seeding. Nothing is persisted on exit",
+					},
+					&cli.StringFlag{
+						Name:  "roles",
+						Value: "rv,do,api",
+						Usage: "Comma separated roles to serve: rv, do and api",
+					},
+					&cli.StringFlag{
+						Name:  "rv-addr",
+						Usage: "Listen address of the RV, e.g. :8040. Defaults to PORT. Also the default FDO_RV_URL",
+					},
+					&cli.StringFlag{
+						Name:  "do-addr",
+						Usage: "Listen address of the DO, e.g. :8041. Defaults to PORT. Also the default FDO_SERVICE_URL",
+					},
+					&cli.StringFlag{
+						Name:  "api-addr",
+						Usage: "Listen address of the API and frontend. Defaults to PORT. Also the default FDO_API_URL",
+					},
+					&cli.StringFlag{
+						Name:  "rv-db",
+						Usage: "DB path of the RV. Defaults to STORAGE_PATH",
+					},
+					&cli.StringFlag{
+						Name:  "do-db",
+						Usage: "DB path of the DO. Defaults to STORAGE_PATH",
+					},
+					&cli.StringFlag{
+						Name:  "api-db",
+						Usage: "DB path of the API. Defaults to STORAGE_PATH",
+					},
 				},
 				Action: func(c *cli.Context) error {
 					force := c.Bool("force")
+					ephemeral := c.Bool("ephemeral")
 
-					db := InitBadgerDB()
-					defer db.Close()
+					roles, err := ParseRoles(c.String("roles"))
+					if err != nil {
+						return err
+					}
 
-					seedCheck := checkAndSeed(db)
-					if seedCheck != nil {
-						return seedCheck
+					var roleConfigs []RoleConfig
+					for _, role := range roles {
+						roleConfigs = append(roleConfigs, RoleConfig{
+							Role:   role,
+							Addr:   c.String(string(role) + "-addr"),
+							DbPath: c.String(string(role) + "-db"),
+						})
 					}
 
-					if !checkFrontendExists() && !force {
+					if hasRole(roleConfigs, ROLE_API) && !checkFrontendExists() && !force {
 						return fmt.Errorf("./frontend folder not found")
 					}
 
+					if ephemeral {
+						log.Println("Running in ephemeral mode. All data will be lost on exit")
+					}
+
+					err = checkRoleDBs(roleConfigs, ephemeral)
+					if err != nil {
+						return err
+					}
+
+					roleDbs, openedDbs := openRoleDBs(roleConfigs, ephemeral)
+					for _, db := range openedDbs {
+						defer db.Close()
+					}
+
+					// Only the API uses the seeded device credentials
+					if hasRole(roleConfigs, ROLE_API) && !ephemeral {
+						seedCheck := checkAndSeed(roleDbs[ROLE_API])
+						if seedCheck != nil {
+							return seedCheck
+						}
+					}
+
 					ctx := loadEnvCtx()
+					ctx = context.WithValue(ctx, fdoshared.CFG_ENV_EPHEMERAL, ephemeral)
 
-					// Setup FDO listeners
-					fdodo.SetupServer(db, ctx)
-					fdorv.SetupServer(db, ctx)
-					api.SetupServer(db, ctx)
+					ctx = withRoleUrls(ctx, roleConfigs)
 
 					selectedPort := ctx.Value(fdoshared.CFG_ENV_PORT).(int)
-					log.Printf("Starting server at port %d... \n. http://localhost:%d", selectedPort, selectedPort)
-
-					err = http.ListenAndServe(fmt.Sprintf(":%d", selectedPort), nil)
+					err = serveRoles(roleConfigs, roleDbs, fmt.Sprintf(":%d", selectedPort), ctx)
 					if err != nil {
 						log.Panicln("Error starting HTTP server. " + err.Error())
 					}
@@ -216,7 +328,7 @@ func main() {
 				Usage:     "Seed FDO Cred Base",
 				UsageText: "Generates one hundred thousand cred bases to be used in testing",
 				Action: func(c *cli.Context) error {
-					db := InitBadgerDB()
+					db := InitDB()
 					defer db.Close()
 
 					return checkAndSeed(db)
@@ -425,28 +537,12 @@ func main() {
 								return err
 							}
 
-							to1inst := to1.NewTo1Requestor(fdoshared.SRVEntry{
-								SrvURL: url,
-							}, *wawcred)
-
-							helloRvAck31, _, err := to1inst.HelloRV30(testcom.NULL_TEST)
-							if err != nil {
-								log.Printf("Error running HelloRV30. %s", err.Error())
-								return nil
-							}
-
-							to1d, _, err := to1inst.ProveToRV32(*helloRvAck31, testcom.NULL_TEST)
+							to1dPayload, err := fdodeviceimplementation.RunTo1(c.Context, url, *wawcred)
 							if err != nil {
-								log.Printf("Error running ProveToRV32. %s", err.Error())
+								log.Println(err.Error())
 								return nil
 							}
 
-							var to1dPayload fdoshared.To1dBlobPayload
-							err = fdoshared.CborCust.Unmarshal(to1d.Payload, &to1dPayload)
-							if err != nil {
-								return fmt.Errorf("error decoding TO1D payload! %s", err.Error())
-							}
-
 							rvdns := to1dPayload.To1dRV[0].RVDNS
 							rvipd := to1dPayload.To1dRV[0].RVIP
 							rvport := to1dPayload.To1dRV[0].RVPort
@@ -487,114 +583,14 @@ func main() {
 								return err
 							}
 
-							log.Println("Starting HelloDevice60")
-							to2inst := to2.NewTo2Requestor(fdoshared.SRVEntry{
-								SrvURL: url,
-							}, *wawcred, fdoshared.KEX_ECDH256, fdoshared.CIPHER_A128GCM)
-
-							to2proveOvhdrPayload, _, err := to2inst.HelloDevice60(testcom.NULL_TEST)
-							if err != nil {
-								log.Printf("Error running HelloDevice60. %s", err.Error())
-								return nil
-							}
-
-							// 62
-							var ovEntries []fdoshared.CoseSignature
-							for i := 0; i < int(to2proveOvhdrPayload.NumOVEntries); i++ {
-								log.Printf("Requesting GetOVNextEntry62 for entry %d \n", i)
-								nextEntry, _, err := to2inst.GetOVNextEntry62(uint8(i), testcom.NULL_TEST)
-								if err != nil {
-									log.Println(err.Error())
-									return nil
-								}
-
-								if nextEntry.OVEntryNum != uint8(i) {
-									log.Printf("Server retured wrong entry. Expected %d. Got %d", i, nextEntry.OVEntryNum)
-									return nil
-								}
-
-								ovEntries = append(ovEntries, nextEntry.OVEntry)
-							}
-
-							err = fdoshared.OVEntryArray(ovEntries).VerifyEntries(to2proveOvhdrPayload.OVHeader, to2proveOvhdrPayload.HMac)
-							if err != nil {
-								log.Println(err)
-								return nil
-							}
-
-							lastOvEntry := ovEntries[len(ovEntries)-1]
-							loePubKey, _ := lastOvEntry.GetOVEntryPubKey()
-
-							err = to2inst.ProveOVHdr61PubKey.Equal(loePubKey)
-							if err != nil {
-								log.Println(err)
-								return nil
-							}
-
-							// 64
-							log.Println("Starting ProveDevice64")
-							_, _, err = to2inst.ProveDevice64(testcom.NULL_TEST)
+							to2inst, ownerSims, err := fdodeviceimplementation.RunTo2(ctx, url, *wawcred, fdoshared.KEX_ECDH256, fdoshared.CIPHER_A128GCM)
 							if err != nil {
-								log.Println(err)
+								log.Println(err.Error())
 								return nil
 							}
 
-							// 66
-							log.Println("Starting DeviceServiceInfoReady66")
-							_, _, err = to2inst.DeviceServiceInfoReady66(testcom.NULL_TEST)
-							if err != nil {
-								log.Println(err)
-								return nil
-							}
-
-							// 68
-							log.Println("Starting DeviceServiceInfo68")
-
-							deviceSims := fdoshared.GetDeviceOSSims()
-							var ownerSims fdoshared.SIMS // TODO
-
-							for i, deviceSim := range deviceSims {
-								log.Println("Sending DeviceServiceInfo68 for sim " + deviceSim.ServiceInfoKey)
-								log.Println(hex.EncodeToString(deviceSim.ServiceInfoVal))
-								_, _, err := to2inst.DeviceServiceInfo68(fdoshared.DeviceServiceInfo68{
-									ServiceInfo: []fdoshared.ServiceInfoKV{
-										deviceSim,
-									},
-									IsMoreServiceInfo: i+1 <= len(deviceSims),
-								}, testcom.NULL_TEST)
-								if err != nil {
-									log.Println(err)
-									return nil
-								}
-							}
-
-							for {
-								ownerSim, _, err := to2inst.DeviceServiceInfo68(fdoshared.DeviceServiceInfo68{
-									ServiceInfo:       []fdoshared.ServiceInfoKV{},
-									IsMoreServiceInfo: false,
-								}, testcom.NULL_TEST)
-								if err != nil {
-									log.Println(err)
-									return nil
-								}
-
-								ownerSims = append(ownerSims, ownerSim.ServiceInfo...)
-
-								for _, ownerSim := range ownerSims {
-									log.Println("Receiving OwnerSim DeviceServiceInfo68: " + ownerSim.ServiceInfoKey)
-								}
-
-								if ownerSim.IsDone {
-									break
-								}
-							}
-
-							// 70
-							log.Println("Starting Done70")
-							_, _, err = to2inst.Done70(testcom.NULL_TEST)
-							if err != nil {
-								log.Println(err)
-								return nil
+							for _, ownerSim := range ownerSims {
+								log.Println("Received OwnerSim: " + ownerSim.ServiceInfoKey)
 							}
 
 							log.Println("Success To2")
@@ -639,7 +635,7 @@ func main() {
 								}
 							}()
 
-							db := InitBadgerDB()
+							db := InitDB()
 							defer db.Close()
 
 							doVoucherDB := dodbs.NewVoucherDB(db)
@@ -702,7 +698,7 @@ func main() {
 							ctx := loadEnvCtx()
 
 							// VoucherDB
-							db := InitBadgerDB()
+							db := InitDB()
 							defer db.Close()
 							doVoucherDB := dodbs.NewVoucherDB(db)
 
@@ -742,6 +738,64 @@ func main() {
 					},
 				},
 			},
+			{
+				Name:        "probe",
+				Description: "Capability discovery for RV and DO implementations",
+				Usage:       "probe [cmd]",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "rv",
+						Usage:     "Probes which device signature types the RV accepts in HelloRV30. Registers test devices with the RV using TO0",
+						UsageText: "[--out file] [FDO RV Server URL]",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "out",
+								Usage: "Write the capability profile to a file instead of stdout",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							if c.Args().Len() != 1 {
+								return fmt.Errorf("missing URL. Expected: [FDO RV Server URL]")
+							}
+
+							profile, err := probe.ProbeRV(loadEnvCtx(), c.Args().Get(0))
+							if err != nil {
+								return err
+							}
+
+							return writeProfile(profile, c.String("out"))
+						},
+					},
+					{
+						Name:      "do",
+						Usage:     "Probes which device signature types, key exchanges and cipher suites the DO accepts in HelloDevice60. The DO must hold the device voucher",
+						UsageText: "[--out file] [FDO DO Server URL] [Path to DI file]",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "out",
+								Usage: "Write the capability profile to a file instead of stdout",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							if c.Args().Len() != 2 {
+								return fmt.Errorf("missing URL or filename. Expected: [FDO DO Server URL] [Path to DI file]")
+							}
+
+							wawcred, err := TryReadingWawDIFile(c.Args().Get(1))
+							if err != nil {
+								return err
+							}
+
+							profile, err := probe.ProbeDO(loadEnvCtx(), c.Args().Get(0), *wawcred)
+							if err != nil {
+								return err
+							}
+
+							return writeProfile(profile, c.String("out"))
+						},
+					},
+				},
+			},
 			{
 				Name:        "reset",
 				Description: "Reset methods",
@@ -750,7 +804,7 @@ func main() {
 					{
 						Name: "users",
 						Action: func(ctx *cli.Context) error {
-							db := InitBadgerDB()
+							db := InitDB()
 							defer db.Close()
 
 							userDB := dbs.NewUserTestDB(db)
@@ -761,7 +815,7 @@ func main() {
 					{
 						Name: "listenerdb",
 						Action: func(ctx *cli.Context) error {
-							db := InitBadgerDB()
+							db := InitDB()
 							defer db.Close()
 
 							listenerDB := testcomdbs.NewListenerTestDB(db)
@@ -771,6 +825,81 @@ func main() {
 					},
 				},
 			},
+			{
+				Name:        "db",
+				Description: "Database backup methods",
+				Usage:       "db [cmd]",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "export",
+						Usage:     "Exports users, test instances, run history, DO vouchers, RV owner-sign entries and seeded credentials to a JSON lines archive",
+						UsageText: "[--user email] [Path to archive file]",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "user",
+								Usage: "Only export the given user and the entries their tests reference",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							if c.Args().Len() != 1 {
+								return fmt.Errorf("missing archive path")
+							}
+
+							archiveFile, err := os.Create(c.Args().Get(0))
+							if err != nil {
+								return fmt.Errorf("error creating archive file. %s", err.Error())
+							}
+							defer archiveFile.Close()
+
+							db := InitDB()
+							defer db.Close()
+
+							count, err := dbs.ExportDB(db, archiveFile, c.String("user"))
+							if err != nil {
+								return fmt.Errorf("error exporting db. %s", err.Error())
+							}
+
+							log.Printf("Exported %d entries to %s", count, c.Args().Get(0))
+
+							return nil
+						},
+					},
+					{
+						Name:      "import",
+						Usage:     "Imports an archive created by db export",
+						UsageText: "[--overwrite] [Path to archive file]",
+						Flags: []cli.Flag{
+							&cli.BoolFlag{
+								Name:  "overwrite",
+								Usage: "Replace existing entries instead of skipping them",
+							},
+						},
+						Action: func(c *cli.Context) error {
+							if c.Args().Len() != 1 {
+								return fmt.Errorf("missing archive path")
+							}
+
+							archiveFile, err := os.Open(c.Args().Get(0))
+							if err != nil {
+								return fmt.Errorf("error opening archive file. %s", err.Error())
+							}
+							defer archiveFile.Close()
+
+							db := InitDB()
+							defer db.Close()
+
+							imported, skipped, err := dbs.ImportDB(db, archiveFile, c.Bool("overwrite"))
+							if err != nil {
+								return fmt.Errorf("error importing db after %d entries. %s", imported, err.Error())
+							}
+
+							log.Printf("Imported %d entries, skipped %d existing or expired entries", imported, skipped)
+
+							return nil
+						},
+					},
+				},
+			},
 		},
 	}
 
@@ -0,0 +1,319 @@
+// Package probe discovers which signature types, key exchanges and cipher suites an RV or DO implementation supports
+package probe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	fdodevice "github.com/fido-alliance/iot-fdo-conformance-tools/core/device"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/do/to0"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+)
+
+type Outcome string
+
+const (
+	// The target answered with a valid response
+	OUTCOME_ACCEPTED Outcome = "accepted"
+	// The target answered with a decodable FDO error
+	OUTCOME_REJECTED Outcome = "rejected"
+	// The target did not answer, or answered with something that is neither a valid response nor an FDO error
+	OUTCOME_MISHANDLED Outcome = "mishandled"
+)
+
+type Result struct {
+	Option     string                 `json:"option"`
+	Outcome    Outcome                `json:"outcome"`
+	HttpStatus int                    `json:"httpStatus,omitempty"`
+	ErrorCode  fdoshared.FdoErrorCode `json:"errorCode,omitempty"`
+	Detail     string                 `json:"detail,omitempty"`
+}
+
+// Profile is the capability profile of a target. Each option is probed on its own, with the other options
+// kept at a baseline the target is expected to support
+type Profile struct {
+	Target       string   `json:"target"`
+	Role         string   `json:"role"`
+	ProbedAt     int64    `json:"probedAt"`
+	SgTypes      []Result `json:"sgTypes"`
+	KexSuites    []Result `json:"kexSuites,omitempty"`
+	CipherSuites []Result `json:"cipherSuites,omitempty"`
+
+	// The public key encoding the DO uses for the owner key in ProveOVHdr61. Neither HelloRV30 nor
+	// HelloDevice60 carries a key, so the encoding is observed rather than driven
+	OwnerPkEnc string `json:"ownerPkEnc,omitempty"`
+}
+
+// Supported returns the options of the results that the target accepted
+func Supported(results []Result) []string {
+	var options []string
+	for _, result := range results {
+		if result.Outcome == OUTCOME_ACCEPTED {
+			options = append(options, result.Option)
+		}
+	}
+
+	return options
+}
+
+// FilterCryptoSuites drops the suites whose key exchange or cipher the DO rejected, so a test run can skip them
+func (h *Profile) FilterCryptoSuites(suites []testcom.CryptoSuite) []testcom.CryptoSuite {
+	supportedKex := map[string]bool{}
+	for _, option := range Supported(h.KexSuites) {
+		supportedKex[option] = true
+	}
+
+	supportedCiphers := map[string]bool{}
+	for _, option := range Supported(h.CipherSuites) {
+		supportedCiphers[option] = true
+	}
+
+	var filtered []testcom.CryptoSuite
+	for _, suite := range suites {
+		if supportedKex[string(suite.Kex)] && supportedCiphers[fdoshared.CipherSuiteNames[suite.Cipher]] {
+			filtered = append(filtered, suite)
+		}
+	}
+
+	return filtered
+}
+
+// classify sorts a response into accepted, rejected or mishandled. decodeResponse checks a 200 response body
+func classify(option string, bodyBytes []byte, httpStatus int, sendErr error, decodeResponse func([]byte) error) Result {
+	result := Result{
+		Option:     option,
+		HttpStatus: httpStatus,
+	}
+
+	if sendErr != nil {
+		result.Outcome = OUTCOME_MISHANDLED
+		result.Detail = sendErr.Error()
+		return result
+	}
+
+	var fdoError fdoshared.FdoError
+	if httpStatus != http.StatusOK {
+		err := fdoshared.CborCust.Unmarshal(bodyBytes, &fdoError)
+		if err != nil {
+			result.Outcome = OUTCOME_MISHANDLED
+			result.Detail = "Non 200 response is not an FDO error. " + err.Error()
+			return result
+		}
+
+		result.Outcome = OUTCOME_REJECTED
+		result.ErrorCode = fdoError.EMErrorCode
+		result.Detail = fdoError.EMErrorStr
+		return result
+	}
+
+	err := decodeResponse(bodyBytes)
+	if err != nil {
+		result.Outcome = OUTCOME_MISHANDLED
+		result.Detail = "Invalid response. " + err.Error()
+		return result
+	}
+
+	result.Outcome = OUTCOME_ACCEPTED
+	return result
+}
+
+func sortedCiphers() []fdoshared.CipherSuiteName {
+	var ciphers []fdoshared.CipherSuiteName
+	for cipher := range fdoshared.CipherSuitesInfoMap {
+		ciphers = append(ciphers, cipher)
+	}
+	sort.Slice(ciphers, func(i, j int) bool { return ciphers[i] < ciphers[j] })
+
+	return ciphers
+}
+
+// allSgTypes includes the EPID types, that the tools can not generate credentials for but can still announce
+var allSgTypes = []fdoshared.SgType{
+	fdoshared.StSECP256R1,
+	fdoshared.StSECP384R1,
+	fdoshared.StRSA2048,
+	fdoshared.StRSA3072,
+	fdoshared.StEPID10,
+	fdoshared.StEPID11,
+}
+
+// registerDevice generates a device credential and voucher, and registers the voucher with the RV in TO0.
+// ctx must carry the owner address, see fdoshared.CFG_ENV_FDO_SERVICE_URL
+func registerDevice(ctx context.Context, rvUrl string, sgType fdoshared.SgType) (*fdoshared.DeviceCredAndVoucher, error) {
+	credential, err := fdoshared.NewWawDeviceCredential(sgType)
+	if err != nil {
+		return nil, errors.New("Failed to generate device credential. " + err.Error())
+	}
+
+	rvInfo, err := fdoshared.UrlsToRendezvousInfo([]string{rvUrl})
+	if err != nil {
+		return nil, errors.New("Failed to generate RV info. " + err.Error())
+	}
+
+	credAndVoucher, err := fdodevice.NewVirtualDeviceAndVoucher(*credential, sgType, rvInfo, testcom.NULL_TEST)
+	if err != nil {
+		return nil, errors.New("Failed to generate voucher. " + err.Error())
+	}
+	credAndVoucher.VoucherDBEntry.SgType = sgType
+
+	to0inst := to0.NewTo0Requestor(fdoshared.SRVEntry{
+		SrvURL: rvUrl,
+	}, credAndVoucher.VoucherDBEntry, ctx)
+
+	helloAck21, _, err := to0inst.Hello20(testcom.NULL_TEST)
+	if err != nil {
+		return nil, errors.New("Error running Hello20. " + err.Error())
+	}
+
+	_, _, err = to0inst.OwnerSign22(helloAck21.NonceTO0Sign, testcom.NULL_TEST)
+	if err != nil {
+		return nil, errors.New("Error running OwnerSign22. " + err.Error())
+	}
+
+	return credAndVoucher, nil
+}
+
+// ProbeRV sends HelloRV30 with every device signature type. A device of each type the tools can generate is
+// first registered in TO0, the remaining types are announced for a registered SECP256R1 device
+func ProbeRV(ctx context.Context, rvUrl string) (*Profile, error) {
+	registered := map[fdoshared.SgType]*fdoshared.DeviceCredAndVoucher{}
+	for _, sgType := range fdoshared.DeviceSgTypeList {
+		credAndVoucher, err := registerDevice(ctx, rvUrl, sgType)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to register %s device with the RV. %s", fdoshared.SgTypeNames[sgType], err.Error())
+		}
+
+		registered[sgType] = credAndVoucher
+	}
+
+	profile := Profile{
+		Target:   rvUrl,
+		Role:     "rv",
+		ProbedAt: time.Now().Unix(),
+	}
+
+	for _, sgType := range allSgTypes {
+		credAndVoucher, ok := registered[sgType]
+		if !ok {
+			credAndVoucher = registered[fdoshared.StSECP256R1]
+		}
+
+		sigInfo := credAndVoucher.WawDeviceCredential.DCSigInfo
+		sigInfo.SgType = sgType
+
+		helloRv30Bytes, err := fdoshared.CborCust.Marshal(fdoshared.HelloRV30{
+			Guid:      credAndVoucher.WawDeviceCredential.DCGuid,
+			EASigInfo: sigInfo,
+		})
+		if err != nil {
+			return nil, errors.New("Error marshaling HelloRV30. " + err.Error())
+		}
+
+		bodyBytes, _, httpStatus, err := fdoshared.SendCborPost(ctx, fdoshared.SRVEntry{SrvURL: rvUrl}, fdoshared.TO1_30_HELLO_RV, helloRv30Bytes, nil)
+		profile.SgTypes = append(profile.SgTypes, classify(fdoshared.SgTypeNames[sgType], bodyBytes, httpStatus, err, func(respBytes []byte) error {
+			var helloRVAck31 fdoshared.HelloRVAck31
+			return fdoshared.CborCust.Unmarshal(respBytes, &helloRVAck31)
+		}))
+	}
+
+	return &profile, nil
+}
+
+// ProbeDO sends HelloDevice60 for the device, whose voucher must be loaded into the DO, varying the device signature
+// type, the key exchange and the cipher suite in turn. The baseline is the device's own signature type, the matching
+// ECDH key exchange and A128GCM
+func ProbeDO(ctx context.Context, doUrl string, credential fdoshared.WawDeviceCredential) (*Profile, error) {
+	baselineKex, ok := fdoshared.SgTypeToKexSuitName[credential.DCSigInfo.SgType]
+	if !ok {
+		return nil, fmt.Errorf("Unsupported device signature type %d", credential.DCSigInfo.SgType)
+	}
+
+	profile := Profile{
+		Target:   doUrl,
+		Role:     "do",
+		ProbedAt: time.Now().Unix(),
+	}
+
+	hello := func(option string, sgType fdoshared.SgType, kex fdoshared.KexSuiteName, cipher fdoshared.CipherSuiteName) (Result, error) {
+		sigInfo := credential.DCSigInfo
+		sigInfo.SgType = sgType
+
+		helloDevice60Bytes, err := fdoshared.CborCust.Marshal(fdoshared.HelloDevice60{
+			MaxDeviceMessageSize: 0,
+			Guid:                 credential.DCGuid,
+			NonceTO2ProveOV:      fdoshared.NewFdoNonce(),
+			KexSuiteName:         kex,
+			CipherSuiteName:      cipher,
+			EASigInfo:            sigInfo,
+		})
+		if err != nil {
+			return Result{}, errors.New("Error marshaling HelloDevice60. " + err.Error())
+		}
+
+		bodyBytes, _, httpStatus, err := fdoshared.SendCborPost(ctx, fdoshared.SRVEntry{SrvURL: doUrl}, fdoshared.TO2_60_HELLO_DEVICE, helloDevice60Bytes, nil)
+		return classify(option, bodyBytes, httpStatus, err, func(respBytes []byte) error {
+			var proveOVHdr61 fdoshared.CoseSignature
+			err := fdoshared.CborCust.Unmarshal(respBytes, &proveOVHdr61)
+			if err != nil {
+				return err
+			}
+
+			ownerPubKey := proveOVHdr61.Unprotected.CUPHOwnerPubKey
+			if ownerPubKey == nil {
+				return errors.New("ProveOVHdr61 is missing the owner public key")
+			}
+
+			err = fdoshared.VerifyCoseSignature(proveOVHdr61, *ownerPubKey)
+			if err != nil {
+				return err
+			}
+
+			var proveOVHdrPayload fdoshared.TO2ProveOVHdrPayload
+			err = fdoshared.CborCust.Unmarshal(proveOVHdr61.Payload, &proveOVHdrPayload)
+			if err != nil {
+				return err
+			}
+
+			if proveOVHdrPayload.EBSigInfo.SgType != sgType {
+				return errors.New("ProveOVHdr61 eBSigInfo does not match eASigInfo")
+			}
+
+			profile.OwnerPkEnc = fdoshared.FdoPkEncNames[ownerPubKey.PkEnc]
+			return nil
+		}), nil
+	}
+
+	for _, sgType := range allSgTypes {
+		result, err := hello(fdoshared.SgTypeNames[sgType], sgType, baselineKex, fdoshared.CIPHER_A128GCM)
+		if err != nil {
+			return nil, err
+		}
+
+		profile.SgTypes = append(profile.SgTypes, result)
+	}
+
+	for _, kex := range fdoshared.KexSuitNames {
+		result, err := hello(string(kex), credential.DCSigInfo.SgType, kex, fdoshared.CIPHER_A128GCM)
+		if err != nil {
+			return nil, err
+		}
+
+		profile.KexSuites = append(profile.KexSuites, result)
+	}
+
+	for _, cipher := range sortedCiphers() {
+		result, err := hello(fdoshared.CipherSuiteNames[cipher], credential.DCSigInfo.SgType, baselineKex, cipher)
+		if err != nil {
+			return nil, err
+		}
+
+		profile.CipherSuites = append(profile.CipherSuites, result)
+	}
+
+	return &profile, nil
+}
@@ -0,0 +1,75 @@
+package probe
+
+import (
+	"context"
+	"testing"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/harness"
+)
+
+func TestProbeRV(t *testing.T) {
+	h := harness.NewHarness(nil)
+	defer h.Close()
+
+	profile, err := ProbeRV(h.Ctx, h.RV.URL)
+	if err != nil {
+		t.Fatalf("Failed to probe RV. %s", err.Error())
+	}
+
+	if len(profile.SgTypes) != len(allSgTypes) {
+		t.Fatalf("Expected %d sgType results. Got %d", len(allSgTypes), len(profile.SgTypes))
+	}
+
+	for _, result := range profile.SgTypes {
+		if result.Outcome == OUTCOME_MISHANDLED {
+			t.Errorf("Expected RV to answer %s cleanly. Got %+v", result.Option, result)
+		}
+	}
+
+	supported := Supported(profile.SgTypes)
+	if len(supported) == 0 || supported[0] != fdoshared.SgTypeNames[fdoshared.StSECP256R1] {
+		t.Errorf("Expected RV to accept SECP256R1. Got %v", supported)
+	}
+}
+
+func TestProbeDO(t *testing.T) {
+	h := harness.NewHarness(nil)
+	defer h.Close()
+
+	credAndVoucher, err := h.NewDevice(fdoshared.StSECP256R1)
+	if err != nil {
+		t.Fatalf("Failed to create device. %s", err.Error())
+	}
+
+	profile, err := ProbeDO(context.Background(), h.DO.URL, credAndVoucher.WawDeviceCredential)
+	if err != nil {
+		t.Fatalf("Failed to probe DO. %s", err.Error())
+	}
+
+	if len(profile.KexSuites) != len(fdoshared.KexSuitNames) || len(profile.CipherSuites) != len(fdoshared.CipherSuitesInfoMap) {
+		t.Fatalf("Expected every kex and cipher suite to be probed. Got %d and %d", len(profile.KexSuites), len(profile.CipherSuites))
+	}
+
+	for _, results := range [][]Result{profile.SgTypes, profile.KexSuites, profile.CipherSuites} {
+		for _, result := range results {
+			if result.Outcome == OUTCOME_MISHANDLED {
+				t.Errorf("Expected DO to answer %s cleanly. Got %+v", result.Option, result)
+			}
+		}
+	}
+
+	if profile.SgTypes[0].Outcome != OUTCOME_ACCEPTED || profile.KexSuites[0].Outcome != OUTCOME_ACCEPTED {
+		t.Errorf("Expected DO to accept the SECP256R1/ECDH256 baseline. Got %+v %+v", profile.SgTypes[0], profile.KexSuites[0])
+	}
+
+	if profile.OwnerPkEnc != fdoshared.FdoPkEncNames[fdoshared.X509] {
+		t.Errorf("Expected X509 owner key. Got %s", profile.OwnerPkEnc)
+	}
+
+	suites := testcom.GetCryptoSuites(fdoshared.StSECP256R1)
+	if len(profile.FilterCryptoSuites(suites)) != len(suites) {
+		t.Errorf("Expected DO to support every suite it negotiates in HelloDevice60")
+	}
+}
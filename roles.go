@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/fido-alliance/iot-fdo-conformance-tools/api"
+	fdodo "github.com/fido-alliance/iot-fdo-conformance-tools/core/do"
+	fdorv "github.com/fido-alliance/iot-fdo-conformance-tools/core/rv"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/storage"
+)
+
+type ServeRole string
+
+const (
+	ROLE_RV  ServeRole = "rv"
+	ROLE_DO  ServeRole = "do"
+	ROLE_API ServeRole = "api"
+)
+
+// RoleConfig is where a role listens and which DB it uses. Empty values fall back to PORT and the default DB
+type RoleConfig struct {
+	Role   ServeRole
+	Addr   string
+	DbPath string
+}
+
+// ParseRoles parses a comma separated role list, such as "rv,do,api"
+func ParseRoles(rolesList string) ([]ServeRole, error) {
+	var roles []ServeRole
+	for _, roleName := range strings.Split(rolesList, ",") {
+		role := ServeRole(strings.ToLower(strings.TrimSpace(roleName)))
+		if role == "" {
+			continue
+		}
+
+		if role != ROLE_RV && role != ROLE_DO && role != ROLE_API {
+			return nil, fmt.Errorf("unknown role %s. Expected rv, do or api", role)
+		}
+
+		for _, existingRole := range roles {
+			if existingRole == role {
+				return nil, fmt.Errorf("role %s is listed twice", role)
+			}
+		}
+
+		roles = append(roles, role)
+	}
+
+	if len(roles) == 0 {
+		return nil, errors.New("no roles to serve")
+	}
+
+	return roles, nil
+}
+
+func hasRole(roleConfigs []RoleConfig, role ServeRole) bool {
+	for _, roleConfig := range roleConfigs {
+		if roleConfig.Role == role {
+			return true
+		}
+	}
+
+	return false
+}
+
+// openRoleDBs opens one DB per distinct path. Roles without a path share the default DB
+func openRoleDBs(roleConfigs []RoleConfig, ephemeral bool) (map[ServeRole]storage.Store, []storage.Store) {
+	roleDbs := map[ServeRole]storage.Store{}
+	dbsByPath := map[string]storage.Store{}
+	var opened []storage.Store
+
+	for _, roleConfig := range roleConfigs {
+		path := roleConfig.DbPath
+		if ephemeral {
+			path = ""
+		}
+
+		db, ok := dbsByPath[path]
+		if !ok {
+			if ephemeral {
+				db = storage.NewMemoryStore()
+			} else {
+				db = InitDBAt(path)
+			}
+
+			dbsByPath[path] = db
+			opened = append(opened, db)
+		}
+
+		roleDbs[roleConfig.Role] = db
+	}
+
+	return roleDbs, opened
+}
+
+// checkRoleDBs rejects an api role that shares the process with the rv or do role, but not their DB.
+// The API writes the device test listeners and the DO test vouchers that the RV and DO serve
+func checkRoleDBs(roleConfigs []RoleConfig, ephemeral bool) error {
+	if ephemeral || !hasRole(roleConfigs, ROLE_API) {
+		return nil
+	}
+
+	var apiDbPath string
+	for _, roleConfig := range roleConfigs {
+		if roleConfig.Role == ROLE_API {
+			apiDbPath = roleConfig.DbPath
+		}
+	}
+
+	for _, roleConfig := range roleConfigs {
+		if roleConfig.Role != ROLE_API && roleConfig.DbPath != apiDbPath {
+			return fmt.Errorf("the %s role must use the DB of the api role when served in the same process. Use the same --%s-db and --api-db, or serve the api separately", roleConfig.Role, roleConfig.Role)
+		}
+	}
+
+	return nil
+}
+
+// roleUrlEntries are the public URLs of the roles, see withRoleUrls
+var roleUrlEntries = map[ServeRole]fdoshared.CONFIG_ENTRY{
+	ROLE_RV:  fdoshared.CFG_ENV_FDO_RV_URL,
+	ROLE_DO:  fdoshared.CFG_ENV_FDO_SERVICE_URL,
+	ROLE_API: fdoshared.CFG_ENV_FDO_API_URL,
+}
+
+// withRoleUrls sets the public URL of every role served on its own address, unless it is set in the environment.
+// The DO sends its URL to the RV in TO0, the API registers device tests with the RV and links to itself in webhooks
+func withRoleUrls(ctx context.Context, roleConfigs []RoleConfig) context.Context {
+	for _, roleConfig := range roleConfigs {
+		urlEntry := roleUrlEntries[roleConfig.Role]
+		if roleConfig.Addr == "" || os.Getenv(string(urlEntry)) != "" {
+			continue
+		}
+
+		ctx = context.WithValue(ctx, urlEntry, serviceUrlForAddr(roleConfig.Addr))
+	}
+
+	return ctx
+}
+
+// serviceUrlForAddr is the default public URL of a role that listens on its own address
+func serviceUrlForAddr(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return ""
+	}
+
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+
+	return "http://" + net.JoinHostPort(host, port)
+}
+
+// serveRoles registers each role on the mux of its listen address, and serves all addresses until one fails
+func serveRoles(roleConfigs []RoleConfig, roleDbs map[ServeRole]storage.Store, defaultAddr string, ctx context.Context) error {
+	var addrs []string
+	muxes := map[string]*http.ServeMux{}
+	registries := map[string]*prometheus.Registry{}
+
+	for _, roleConfig := range roleConfigs {
+		addr := roleConfig.Addr
+		if addr == "" {
+			addr = defaultAddr
+		}
+
+		serveMux, ok := muxes[addr]
+		if !ok {
+			// Every address serves the gauges of its own roles
+			registries[addr] = prometheus.NewRegistry()
+
+			serveMux = http.NewServeMux()
+			serveMux.Handle("/metrics", fdoshared.MetricsHandler(registries[addr]))
+
+			muxes[addr] = serveMux
+			addrs = append(addrs, addr)
+		}
+
+		roleCtx := fdoshared.WithMetricsRegistry(ctx, registries[addr])
+
+		db := roleDbs[roleConfig.Role]
+		switch roleConfig.Role {
+		case ROLE_RV:
+			fdorv.RegisterRoutes(serveMux, db, roleCtx)
+		case ROLE_DO:
+			fdodo.RegisterRoutes(serveMux, db, roleCtx)
+		case ROLE_API:
+			api.RegisterRoutes(serveMux, db, roleCtx)
+		}
+
+		log.Printf("Serving %s at %s", roleConfig.Role, addr)
+	}
+
+	errs := make(chan error, len(addrs))
+	for _, addr := range addrs {
+		go func(addr string, serveMux *http.ServeMux) {
+			err := http.ListenAndServe(addr, serveMux)
+			errs <- fmt.Errorf("server at %s stopped. %s", addr, err.Error())
+		}(addr, muxes[addr])
+	}
+
+	return <-errs
+}
@@ -1,6 +1,7 @@
 package testexec
 
 import (
+	"context"
 	"log"
 
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/device/to2"
@@ -10,16 +11,9 @@ import (
 	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
 )
 
-func preExecuteTo2_68(reqte reqtestsdeps.RequestTestInst) (*to2.To2Requestor, error) {
-	testCred, err := reqte.TestVouchers.GetVoucher(testcom.NULL_TEST)
-	if err != nil {
-		return nil, err
-	}
-
+func preExecuteTo2_68(reqte reqtestsdeps.RequestTestInst, testCred *fdoshared.DeviceCredAndVoucher, ctx context.Context) (*to2.To2Requestor, error) {
 	// Generating TO0 handler
-	to2requestor := to2.NewTo2Requestor(fdoshared.SRVEntry{
-		SrvURL: reqte.URL,
-	}, testCred.WawDeviceCredential, fdoshared.SgTypeToKexSuitName[testCred.VoucherDBEntry.SgType], fdoshared.CIPHER_A128GCM)
+	to2requestor := newTo2Requestor(reqte, testCred, ctx)
 
 	proveOVHdrPayload61, _, err := to2requestor.HelloDevice60(testcom.NULL_TEST)
 	if err != nil {
@@ -67,158 +61,156 @@ func preExecuteTo2_68(reqte reqtestsdeps.RequestTestInst) (*to2.To2Requestor, er
 	return &to2requestor, nil
 }
 
-func executeTo2_68(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTestDB) {
-	for _, testId := range testcom.FIDO_TEST_LIST_DOT_68 {
-		to2requestor, err := preExecuteTo2_68(reqte)
-		if err != nil {
-			reqtDB.ReportTest(reqte.Uuid, testId, testcom.FDOTestState{
-				Passed: false,
-				Error:  "Error running TO2 DeviceServiceInfoReady66 batch. Pre setup failed. " + err.Error(),
-			})
-			return
-		}
+func executeTo2_68(reqte reqtestsdeps.RequestTestInst, testCred *fdoshared.DeviceCredAndVoucher, testId testcom.FDOTestID, reqtDB *testdbs.RequestTestDB, ctx context.Context) {
+	to2requestor, err := preExecuteTo2_68(reqte, testCred, ctx)
+	if err != nil {
+		reportTest(ctx, reqtDB, reqte.Uuid, testId, testcom.FDOTestState{
+			Passed: false,
+			Error:  "Error running TO2 DeviceServiceInfoReady66 batch. Pre setup failed. " + err.Error(),
+		})
+		return
+	}
 
-		switch testId {
-		case testcom.FIDO_DOT_68_POSITIVE:
-			var deviceSims []fdoshared.ServiceInfoKV = fdoshared.GetDeviceOSSims()
+	switch testId {
+	case testcom.FIDO_DOT_68_POSITIVE:
+		var deviceSims []fdoshared.ServiceInfoKV = fdoshared.GetDeviceOSSims()
 
-			for i, deviceSim := range deviceSims {
-				deviceInfo := fdoshared.DeviceServiceInfo68{
-					ServiceInfo: []fdoshared.ServiceInfoKV{
-						deviceSim,
-					},
-					IsMoreServiceInfo: i+1 <= len(deviceSims),
-				}
-				_, _, err := to2requestor.DeviceServiceInfo68(deviceInfo, testcom.NULL_TEST)
-				if err != nil {
-					reqtDB.ReportTest(reqte.Uuid, testId, testcom.FDOTestState{
-						Passed: false,
-						Error:  err.Error(),
-					})
-					return
-				}
+		for i, deviceSim := range deviceSims {
+			deviceInfo := fdoshared.DeviceServiceInfo68{
+				ServiceInfo: []fdoshared.ServiceInfoKV{
+					deviceSim,
+				},
+				IsMoreServiceInfo: i+1 <= len(deviceSims),
 			}
+			_, _, err := to2requestor.DeviceServiceInfo68(deviceInfo, testcom.NULL_TEST)
+			if err != nil {
+				reportTest(ctx, reqtDB, reqte.Uuid, testId, testcom.FDOTestState{
+					Passed: false,
+					Error:  err.Error(),
+				})
+				return
+			}
+		}
 
-			maxCounter := 255
-			for {
-				ownerSim, _, err := to2requestor.DeviceServiceInfo68(fdoshared.DeviceServiceInfo68{
-					ServiceInfo:       []fdoshared.ServiceInfoKV{},
-					IsMoreServiceInfo: false,
-				}, testcom.NULL_TEST)
-				if err != nil {
-					reqtDB.ReportTest(reqte.Uuid, testId, testcom.FDOTestState{
-						Passed: false,
-						Error:  err.Error(),
-					})
-					return
-				}
-
-				log.Println("Receiving OwnerSim DeviceServiceInfo68")
+		maxCounter := 255
+		for {
+			ownerSim, _, err := to2requestor.DeviceServiceInfo68(fdoshared.DeviceServiceInfo68{
+				ServiceInfo:       []fdoshared.ServiceInfoKV{},
+				IsMoreServiceInfo: false,
+			}, testcom.NULL_TEST)
+			if err != nil {
+				reportTest(ctx, reqtDB, reqte.Uuid, testId, testcom.FDOTestState{
+					Passed: false,
+					Error:  err.Error(),
+				})
+				return
+			}
 
-				if ownerSim.IsDone {
-					break
-				}
+			log.Println("Receiving OwnerSim DeviceServiceInfo68")
 
-				maxCounter = maxCounter - 1
-				if maxCounter <= 0 {
-					reqtDB.ReportTest(reqte.Uuid, testId, testcom.FDOTestState{
-						Passed: false,
-						Error:  "Error running positive test. Owner sent more than 255 SIMs",
-					})
-					return
-				}
+			if ownerSim.IsDone {
+				break
 			}
 
-			reqtDB.ReportTest(reqte.Uuid, testId, testcom.FDOTestState{
-				Passed: true,
-			})
+			maxCounter = maxCounter - 1
+			if maxCounter <= 0 {
+				reportTest(ctx, reqtDB, reqte.Uuid, testId, testcom.FDOTestState{
+					Passed: false,
+					Error:  "Error running positive test. Owner sent more than 255 SIMs",
+				})
+				return
+			}
+		}
 
-		default:
-			var testState *testcom.FDOTestState
-			var deviceSims []fdoshared.ServiceInfoKV = fdoshared.GetDeviceOSSims()
+		reportTest(ctx, reqtDB, reqte.Uuid, testId, testcom.FDOTestState{
+			Passed: true,
+		})
 
-			randomIndex := fdoshared.NewRandomInt(0, len(deviceSims)-1)
-			for i, deviceSim := range deviceSims {
-				selectedTestId := testcom.NULL_TEST
+	default:
+		var testState *testcom.FDOTestState
+		var deviceSims []fdoshared.ServiceInfoKV = fdoshared.GetDeviceOSSims()
 
-				deviceInfo := fdoshared.DeviceServiceInfo68{
-					ServiceInfo: []fdoshared.ServiceInfoKV{
-						deviceSim,
-					},
-					IsMoreServiceInfo: i+1 <= len(deviceSims),
-				}
+		randomIndex := fdoshared.NewRandomInt(0, len(deviceSims)-1)
+		for i, deviceSim := range deviceSims {
+			selectedTestId := testcom.NULL_TEST
 
-				if randomIndex == i {
-					selectedTestId = testId
-				}
+			deviceInfo := fdoshared.DeviceServiceInfo68{
+				ServiceInfo: []fdoshared.ServiceInfoKV{
+					deviceSim,
+				},
+				IsMoreServiceInfo: i+1 <= len(deviceSims),
+			}
 
-				// Here we want to do the device SIMs correctly
-				if testId == testcom.FIDO_DOT_68_BAD_COMPLETION_LOGIC {
-					selectedTestId = testcom.NULL_TEST
-				}
+			if randomIndex == i {
+				selectedTestId = testId
+			}
 
-				_, testState, err = to2requestor.DeviceServiceInfo68(deviceInfo, selectedTestId)
-				if testState == nil && err != nil {
-					reqtDB.ReportTest(reqte.Uuid, testId, testcom.FDOTestState{
-						Passed: false,
-						Error:  err.Error(),
-					})
-					return
-				}
+			// Here we want to do the device SIMs correctly
+			if testId == testcom.FIDO_DOT_68_BAD_COMPLETION_LOGIC {
+				selectedTestId = testcom.NULL_TEST
+			}
 
-				if testState != nil {
-					break
-				}
+			_, testState, err = to2requestor.DeviceServiceInfo68(deviceInfo, selectedTestId)
+			if testState == nil && err != nil {
+				reportTest(ctx, reqtDB, reqte.Uuid, testId, testcom.FDOTestState{
+					Passed: false,
+					Error:  err.Error(),
+				})
+				return
 			}
 
 			if testState != nil {
-				reqtDB.ReportTest(reqte.Uuid, testId, *testState)
-				continue
+				break
 			}
+		}
 
-			maxCounter := 255
-			for {
-				selectedTestId := testcom.NULL_TEST
+		if testState != nil {
+			reportTest(ctx, reqtDB, reqte.Uuid, testId, *testState)
+			return
+		}
 
-				getOwnerInfo := fdoshared.DeviceServiceInfo68{
-					ServiceInfo:       nil,
-					IsMoreServiceInfo: false,
-				}
+		maxCounter := 255
+		for {
+			selectedTestId := testcom.NULL_TEST
 
-				if testId == testcom.FIDO_DOT_68_BAD_COMPLETION_LOGIC && maxCounter != 255 {
-					selectedTestId = testcom.FIDO_DOT_68_BAD_COMPLETION_LOGIC
+			getOwnerInfo := fdoshared.DeviceServiceInfo68{
+				ServiceInfo:       nil,
+				IsMoreServiceInfo: false,
+			}
 
-					getOwnerInfo.ServiceInfo = []fdoshared.ServiceInfoKV{
-						deviceSims[fdoshared.NewRandomInt(0, len(deviceSims)-1)],
-					}
+			if testId == testcom.FIDO_DOT_68_BAD_COMPLETION_LOGIC && maxCounter != 255 {
+				selectedTestId = testcom.FIDO_DOT_68_BAD_COMPLETION_LOGIC
 
-					getOwnerInfo.IsMoreServiceInfo = true
+				getOwnerInfo.ServiceInfo = []fdoshared.ServiceInfoKV{
+					deviceSims[fdoshared.NewRandomInt(0, len(deviceSims)-1)],
 				}
 
-				_, testState, err := to2requestor.DeviceServiceInfo68(getOwnerInfo, selectedTestId)
-				if testState == nil && err != nil {
-					reqtDB.ReportTest(reqte.Uuid, testId, testcom.FDOTestState{
-						Passed: false,
-						Error:  err.Error(),
-					})
-					return
-				}
+				getOwnerInfo.IsMoreServiceInfo = true
+			}
 
-				log.Println("Receiving OwnerSim DeviceServiceInfo68")
+			_, testState, err := to2requestor.DeviceServiceInfo68(getOwnerInfo, selectedTestId)
+			if testState == nil && err != nil {
+				reportTest(ctx, reqtDB, reqte.Uuid, testId, testcom.FDOTestState{
+					Passed: false,
+					Error:  err.Error(),
+				})
+				return
+			}
 
-				if testId == testcom.FIDO_DOT_68_BAD_COMPLETION_LOGIC && maxCounter != 255 {
-					reqtDB.ReportTest(reqte.Uuid, testId, *testState)
-					break
-				}
+			log.Println("Receiving OwnerSim DeviceServiceInfo68")
 
-				maxCounter = maxCounter - 1
-				if maxCounter <= 0 {
-					reqtDB.ReportTest(reqte.Uuid, testId, testcom.FDOTestState{
-						Passed: false,
-						Error:  "Error running test. Too many SIMs or retries.",
-					})
-					return
-				}
+			if testId == testcom.FIDO_DOT_68_BAD_COMPLETION_LOGIC && maxCounter != 255 {
+				reportTest(ctx, reqtDB, reqte.Uuid, testId, *testState)
+				break
+			}
+
+			maxCounter = maxCounter - 1
+			if maxCounter <= 0 {
+				reportTest(ctx, reqtDB, reqte.Uuid, testId, testcom.FDOTestState{
+					Passed: false,
+					Error:  "Error running test. Too many SIMs or retries.",
+				})
+				return
 			}
 		}
 	}
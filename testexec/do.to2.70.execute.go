@@ -1,6 +1,7 @@
 package testexec
 
 import (
+	"context"
 	"errors"
 
 	"github.com/fido-alliance/iot-fdo-conformance-tools/core/device/to2"
@@ -10,16 +11,9 @@ import (
 	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
 )
 
-func preExecuteTo2_70(reqte reqtestsdeps.RequestTestInst) (*to2.To2Requestor, error) {
-	testCred, err := reqte.TestVouchers.GetVoucher(testcom.NULL_TEST)
-	if err != nil {
-		return nil, err
-	}
-
+func preExecuteTo2_70(reqte reqtestsdeps.RequestTestInst, testCred *fdoshared.DeviceCredAndVoucher, ctx context.Context) (*to2.To2Requestor, error) {
 	// Generating TO2 handler
-	to2requestor := to2.NewTo2Requestor(fdoshared.SRVEntry{
-		SrvURL: reqte.URL,
-	}, testCred.WawDeviceCredential, fdoshared.SgTypeToKexSuitName[testCred.VoucherDBEntry.SgType], fdoshared.CIPHER_A128GCM)
+	to2requestor := newTo2Requestor(reqte, testCred, ctx)
 
 	proveOVHdrPayload61, _, err := to2requestor.HelloDevice60(testcom.NULL_TEST)
 	if err != nil {
@@ -101,44 +95,42 @@ func preExecuteTo2_70(reqte reqtestsdeps.RequestTestInst) (*to2.To2Requestor, er
 	return &to2requestor, nil
 }
 
-func executeTo2_70(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTestDB) {
-	for _, testId := range testcom.FIDO_TEST_LIST_DOT_70 {
-		to2requestor, err := preExecuteTo2_70(reqte)
+func executeTo2_70(reqte reqtestsdeps.RequestTestInst, testCred *fdoshared.DeviceCredAndVoucher, testId testcom.FDOTestID, reqtDB *testdbs.RequestTestDB, ctx context.Context) {
+	to2requestor, err := preExecuteTo2_70(reqte, testCred, ctx)
+	if err != nil {
+		reportTest(ctx, reqtDB, reqte.Uuid, testId, testcom.FDOTestState{
+			Passed: false,
+			Error:  "Error running TO2 batch. Pre setup failed. " + err.Error(),
+		})
+		return
+	}
+
+	switch testId {
+	case testcom.FIDO_DOT_70_POSITIVE:
+		_, _, err = to2requestor.Done70(testcom.NULL_TEST)
 		if err != nil {
-			reqtDB.ReportTest(reqte.Uuid, testId, testcom.FDOTestState{
+			reportTest(ctx, reqtDB, reqte.Uuid, testId, testcom.FDOTestState{
 				Passed: false,
-				Error:  "Error running TO2 batch. Pre setup failed. " + err.Error(),
+				Error:  err.Error(),
 			})
 			return
+		} else {
+			reportTest(ctx, reqtDB, reqte.Uuid, testId, testcom.FDOTestState{
+				Passed: true,
+			})
 		}
 
-		switch testId {
-		case testcom.FIDO_DOT_70_POSITIVE:
-			_, _, err = to2requestor.Done70(testcom.NULL_TEST)
-			if err != nil {
-				reqtDB.ReportTest(reqte.Uuid, testId, testcom.FDOTestState{
-					Passed: false,
-					Error:  err.Error(),
-				})
-				return
-			} else {
-				reqtDB.ReportTest(reqte.Uuid, testId, testcom.FDOTestState{
-					Passed: true,
-				})
-			}
-
-		default:
-			_, rvtTestState, err := to2requestor.Done70(testId)
-			if rvtTestState == nil && err != nil {
-				errTestState := testcom.FDOTestState{
-					Passed: false,
-					Error:  err.Error(),
-				}
-
-				rvtTestState = &errTestState
+	default:
+		_, rvtTestState, err := to2requestor.Done70(testId)
+		if rvtTestState == nil && err != nil {
+			errTestState := testcom.FDOTestState{
+				Passed: false,
+				Error:  err.Error(),
 			}
 
-			reqtDB.ReportTest(reqte.Uuid, testId, *rvtTestState)
+			rvtTestState = &errTestState
 		}
+
+		reportTest(ctx, reqtDB, reqte.Uuid, testId, *rvtTestState)
 	}
 }
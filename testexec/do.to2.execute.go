@@ -1,19 +1,193 @@
 package testexec
 
 import (
+	"context"
+
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/device/to2"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
 	testdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
 	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
 )
 
-func ExecuteDOTestsTo2(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTestDB) {
-	reqtDB.StartNewRun(reqte.Uuid)
+type to2TestExecutor func(reqte reqtestsdeps.RequestTestInst, testCred *fdoshared.DeviceCredAndVoucher, testId testcom.FDOTestID, reqtDB *testdbs.RequestTestDB, ctx context.Context)
+
+type SuiteMatrixMode string
+
+const (
+	// SMM_Positive runs the positive TO2 flow with every crypto suite
+	SMM_Positive SuiteMatrixMode = "positive"
+	// SMM_All runs all the TO2 tests with every crypto suite
+	SMM_All SuiteMatrixMode = "all"
+)
+
+func ParseSuiteMatrixMode(mode string) (SuiteMatrixMode, bool) {
+	switch SuiteMatrixMode(mode) {
+	case SMM_Positive, SMM_All:
+		return SuiteMatrixMode(mode), true
+	}
+
+	return "", false
+}
+
+type cryptoSuiteCtxKey struct{}
+
+// withCryptoSuite makes the TO2 requestors of a test use the suite, and tags the reported test IDs with it
+func withCryptoSuite(ctx context.Context, suite testcom.CryptoSuite) context.Context {
+	return context.WithValue(ctx, cryptoSuiteCtxKey{}, suite)
+}
+
+func getCryptoSuite(ctx context.Context) (testcom.CryptoSuite, bool) {
+	suite, ok := ctx.Value(cryptoSuiteCtxKey{}).(testcom.CryptoSuite)
+	return suite, ok
+}
+
+// newTo2Requestor uses the crypto suite in ctx if any, otherwise the key exchange matching the owner key and A128GCM
+func newTo2Requestor(reqte reqtestsdeps.RequestTestInst, testCred *fdoshared.DeviceCredAndVoucher, ctx context.Context) to2.To2Requestor {
+	suite, ok := getCryptoSuite(ctx)
+	if !ok {
+		suite = testcom.CryptoSuite{
+			Kex:    fdoshared.SgTypeToKexSuitName[testCred.VoucherDBEntry.SgType],
+			Cipher: fdoshared.CIPHER_A128GCM,
+		}
+	}
+
+	return to2.NewTo2Requestor(fdoshared.SRVEntry{
+		SrvURL: reqte.URL,
+	}, testCred.WawDeviceCredential, suite.Kex, suite.Cipher, ctx)
+}
+
+// ExecuteDOTestsTo2 starts a TO2 run in the background, and returns its id
+func ExecuteDOTestsTo2(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTestDB, ctx context.Context) (string, error) {
+	return startRunInBackground(ctx, reqte, reqtDB, func(run *activeRun) {
+		executeDOTestsTo2(run, reqte, reqtDB, []*testcom.CryptoSuite{nil}, true)
+	})
+}
+
+// ExecuteDOTestsTo2SuiteMatrix starts a run of the TO2 tests once for every crypto suite supported with the owner key,
+// and returns its id. The suites are narrowed down by the filter in ctx, if any, see testcom.WithCryptoSuiteFilter.
+// The run results are tagged with the suite, see testcom.NewSuiteMatrix
+func ExecuteDOTestsTo2SuiteMatrix(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTestDB, mode SuiteMatrixMode, ctx context.Context) (string, error) {
+	var suites []*testcom.CryptoSuite
+	if testCred, err := reqte.TestVouchers.GetVoucherForWorker(testcom.NULL_TEST, 0); err == nil {
+		cryptoSuites := testcom.GetCryptoSuites(testCred.VoucherDBEntry.SgType)
+		if filter, ok := testcom.GetCryptoSuiteFilter(ctx); ok {
+			cryptoSuites = filter(cryptoSuites)
+		}
+
+		for _, suite := range cryptoSuites {
+			suites = append(suites, &suite)
+		}
+	}
+
+	return startRunInBackground(ctx, reqte, reqtDB, func(run *activeRun) {
+		executeDOTestsTo2(run, reqte, reqtDB, suites, mode == SMM_All)
+	})
+}
+
+func executeDOTestsTo2(run *activeRun, reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTestDB, suites []*testcom.CryptoSuite, includeNegative bool) {
+	ctx := run.Ctx
+
+	// Every worker owns one voucher, so the concurrency is limited by the amount of vouchers
+	concurrency := NormaliseConcurrency(reqte.Concurrency)
+	voucherCount := reqte.TestVouchers.Count(testcom.NULL_TEST)
+	if voucherCount == 0 {
+		reportTest(run.Ctx, reqtDB, reqte.Uuid, testcom.NULL_TEST, testcom.NewFailTestState(testcom.NULL_TEST, "Error getting voucher for TO2. No vouchers found"))
+		return
+	}
+
+	if concurrency > voucherCount {
+		concurrency = voucherCount
+	}
+
+	var jobs []testJob
+	for _, suite := range suites {
+		if !includeNegative {
+			jobs = append(jobs, newTo2TestJobs(run, reqte, reqtDB, suite, []testcom.FDOTestID{testcom.FIDO_DOT_70_POSITIVE}, executeTo2_70)...)
+			continue
+		}
+
+		jobs = append(jobs, newTo2TestJobs(run, reqte, reqtDB, suite, testcom.FIDO_TEST_LIST_DOT_60, executeTo2_60)...)
+		jobs = append(jobs, newTo2TestJobs(run, reqte, reqtDB, suite, testcom.FIDO_TEST_LIST_DOT_62, executeTo2_62)...)
+		jobs = append(jobs, newTo2TestJobs(run, reqte, reqtDB, suite, testcom.FIDO_TEST_LIST_DOT_64, executeTo2_64)...)
+		jobs = append(jobs, newTo2TestJobs(run, reqte, reqtDB, suite, testcom.FIDO_TEST_LIST_DOT_66, executeTo2_66)...)
+		jobs = append(jobs, newTo2TestJobs(run, reqte, reqtDB, suite, testcom.FIDO_TEST_LIST_DOT_68, executeTo2_68)...)
+		jobs = append(jobs, newTo2TestJobs(run, reqte, reqtDB, suite, testcom.FIDO_TEST_LIST_DOT_70, executeTo2_70)...)
+		jobs = append(jobs, newTo2TestJobs(run, reqte, reqtDB, suite, testcom.FIDO_TEST_LIST_HTTP_TO2, executeTo2_Http)...)
+		jobs = append(jobs, newTo2TestJobs(run, reqte, reqtDB, suite, testcom.FIDO_TEST_LIST_DOT_ORDER, executeTo2_Order)...)
+		jobs = append(jobs, newTo2TestJobs(run, reqte, reqtDB, suite, testcom.FIDO_TEST_LIST_DOT_REPLAY, executeTo2_Replay)...)
+
+		// The expiry tests pause for a long time, so they only run when asked for
+		if _, ok := testcom.GetSessionExpiry(ctx); ok {
+			jobs = append(jobs, newTo2TestJobs(run, reqte, reqtDB, suite, testcom.FIDO_TEST_LIST_DOT_EXPIRY, executeTo2_Expiry)...)
+		}
+	}
+
+	runTestJobs(run.Ctx, concurrency, jobs)
+
+	// The stress test uses every device at the same time, so it runs on its own after the other tests, when asked for
+	if includeNegative && testcom.GetStressTests(ctx) && run.Ctx.Err() == nil {
+		stressTest := testcom.FIDO_DOT_STRESS_SESSION_ISOLATION
+		run.testStarted(stressTest)
+		executeTo2_Stress(reqte, reqte.TestVouchers[testcom.NULL_TEST], stressTest, reqtDB, newTestContext(run.Ctx, stressTest))
+	}
+}
+
+// newTo2TestJobs creates a job per test. A nil suite runs the tests with the default suite and untagged test IDs
+func newTo2TestJobs(run *activeRun, reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTestDB, suite *testcom.CryptoSuite, testIds []testcom.FDOTestID, executor to2TestExecutor) []testJob {
+	var jobs []testJob
+
+	for _, testId := range testIds {
+		jobs = append(jobs, func(workerId int) {
+			runCtx := run.Ctx
+			startedTestId := testId
+			if suite != nil {
+				runCtx = withCryptoSuite(runCtx, *suite)
+				startedTestId = testcom.NewSuiteTestID(testId, *suite)
+			}
+
+			run.testStarted(startedTestId)
+			ctx := newTestContext(runCtx, startedTestId)
+
+			testCred, err := reqte.TestVouchers.GetVoucherForWorker(testcom.NULL_TEST, workerId)
+			if err != nil {
+				reportTest(ctx, reqtDB, reqte.Uuid, testId, testcom.NewFailTestState(testId, "Error getting voucher for TO2. "+err.Error()))
+				return
+			}
+
+			executor(reqte, testCred, testId, reqtDB, ctx)
+		})
+	}
+
+	return jobs
+}
+
+// executeTo2_Http runs the flow up to each follow-up message, so the server rejects the request and not the session state
+func executeTo2_Http(reqte reqtestsdeps.RequestTestInst, testCred *fdoshared.DeviceCredAndVoucher, testId testcom.FDOTestID, reqtDB *testdbs.RequestTestDB, ctx context.Context) {
+	newSession := func(preExecute func(reqte reqtestsdeps.RequestTestInst, testCred *fdoshared.DeviceCredAndVoucher, ctx context.Context) (*to2.To2Requestor, error)) func(ctx context.Context) (string, error) {
+		return func(ctx context.Context) (string, error) {
+			to2requestor, err := preExecute(reqte, testCred, ctx)
+			if err != nil {
+				return "", err
+			}
+
+			return to2requestor.AuthzHeader, nil
+		}
+	}
+
+	preExecuteTo2_62 := func(reqte reqtestsdeps.RequestTestInst, testCred *fdoshared.DeviceCredAndVoucher, ctx context.Context) (*to2.To2Requestor, error) {
+		to2requestor := newTo2Requestor(reqte, testCred, ctx)
 
-	executeTo2_60(reqte, reqtDB)
-	executeTo2_62(reqte, reqtDB)
-	executeTo2_64(reqte, reqtDB)
-	executeTo2_66(reqte, reqtDB)
-	executeTo2_68(reqte, reqtDB)
-	executeTo2_70(reqte, reqtDB)
+		_, _, err := to2requestor.HelloDevice60(testcom.NULL_TEST)
+		return &to2requestor, err
+	}
 
-	reqtDB.FinishRun(reqte.Uuid)
+	executeHttpTest(reqte, []httpTestMessage{
+		{cmd: fdoshared.TO2_60_HELLO_DEVICE},
+		{cmd: fdoshared.TO2_62_GET_OVNEXTENTRY, newSession: newSession(preExecuteTo2_62)},
+		{cmd: fdoshared.TO2_64_PROVE_DEVICE, newSession: newSession(preExecuteTo2_64)},
+		{cmd: fdoshared.TO2_66_DEVICE_SERVICE_INFO_READY, newSession: newSession(preExecuteTo2_66)},
+		{cmd: fdoshared.TO2_68_DEVICE_SERVICE_INFO, newSession: newSession(preExecuteTo2_68)},
+		{cmd: fdoshared.TO2_70_DONE, newSession: newSession(preExecuteTo2_70)},
+	}, testId, reqtDB, ctx)
 }
@@ -0,0 +1,109 @@
+package testexec
+
+import (
+	"context"
+	"testing"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/storage"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+	testdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
+	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/harness"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/probe"
+)
+
+func TestExecuteDOTestsTo2SuiteMatrix(t *testing.T) {
+	h := harness.NewHarness(nil)
+	defer h.Close()
+
+	credAndVoucher, err := h.NewDevice(fdoshared.StSECP256R1)
+	if err != nil {
+		t.Fatalf("Failed to create device. %s", err.Error())
+	}
+
+	reqtDB := testdbs.NewRequestTestDB(storage.NewMemoryStore())
+	reqte := reqtestsdeps.NewRequestTestInst(h.DO.URL, fdoshared.To2, 4)
+	reqte.TestVouchers[testcom.NULL_TEST] = []fdoshared.DeviceCredAndVoucher{*credAndVoucher}
+
+	err = reqtDB.Save(reqte)
+	if err != nil {
+		t.Fatalf("Failed to save test instance. %s", err.Error())
+	}
+
+	runUuid, err := ExecuteDOTestsTo2SuiteMatrix(reqte, reqtDB, SMM_Positive, context.Background())
+	if err != nil {
+		t.Fatalf("Failed to start run. %s", err.Error())
+	}
+	WaitRun(runUuid)
+
+	result, err := reqtDB.Get(reqte.Uuid)
+	if err != nil {
+		t.Fatalf("Failed to get test instance. %s", err.Error())
+	}
+
+	if len(result.TestsHistory) != 1 {
+		t.Fatalf("Expected one finished run. Got %d", len(result.TestsHistory))
+	}
+
+	// ASYMKEX is skipped for an EC owner key, and so are the ciphers the requestor does not implement
+	var implementedCiphers int
+	for cipher := range fdoshared.CipherSuitesInfoMap {
+		if fdoshared.IsCipherSuiteImplemented(cipher) {
+			implementedCiphers++
+		}
+	}
+
+	suiteMatrix := result.TestsHistory[0].SuiteMatrix
+	expectedSuites := len(testcom.GetCryptoSuites(fdoshared.StSECP256R1))
+	if len(suiteMatrix) != expectedSuites || expectedSuites != 4*implementedCiphers {
+		t.Fatalf("Expected %d suites. Got %d", expectedSuites, len(suiteMatrix))
+	}
+
+	for _, entry := range suiteMatrix {
+		if entry.Passed != 1 || entry.Failed != 0 {
+			t.Errorf("Expected %s/%s to pass. Got %+v", entry.Kex, entry.Cipher, entry)
+		}
+	}
+}
+
+func TestExecuteDOTestsTo2SuiteMatrixProfile(t *testing.T) {
+	h := harness.NewHarness(nil)
+	defer h.Close()
+
+	reqte, reqtDB := newTo2TestInst(t, h, h.DO.URL, 1)
+
+	// The profile of a DO that only supports ECDH256 and A128GCM
+	profile := probe.Profile{
+		KexSuites: []probe.Result{
+			{Option: string(fdoshared.KEX_ECDH256), Outcome: probe.OUTCOME_ACCEPTED},
+			{Option: string(fdoshared.KEX_ECDH384), Outcome: probe.OUTCOME_REJECTED},
+		},
+		CipherSuites: []probe.Result{
+			{Option: fdoshared.CipherSuiteNames[fdoshared.CIPHER_A128GCM], Outcome: probe.OUTCOME_ACCEPTED},
+			{Option: fdoshared.CipherSuiteNames[fdoshared.CIPHER_A256GCM], Outcome: probe.OUTCOME_REJECTED},
+		},
+	}
+
+	ctx := testcom.WithCryptoSuiteFilter(context.Background(), profile.FilterCryptoSuites)
+	runUuid, err := ExecuteDOTestsTo2SuiteMatrix(reqte, reqtDB, SMM_Positive, ctx)
+	if err != nil {
+		t.Fatalf("Failed to start run. %s", err.Error())
+	}
+	WaitRun(runUuid)
+
+	result, err := reqtDB.Get(reqte.Uuid)
+	if err != nil {
+		t.Fatalf("Failed to get test instance. %s", err.Error())
+	}
+
+	suiteMatrix := result.TestsHistory[0].SuiteMatrix
+	if len(suiteMatrix) != 1 {
+		t.Fatalf("Expected only the suite the DO supports. Got %+v", suiteMatrix)
+	}
+
+	entry := suiteMatrix[0]
+	if entry.Kex != string(fdoshared.KEX_ECDH256) || entry.Cipher != fdoshared.CipherSuiteNames[fdoshared.CIPHER_A128GCM] || entry.Passed != 1 {
+		t.Errorf("Expected ECDH256/A128GCM to pass. Got %+v", entry)
+	}
+}
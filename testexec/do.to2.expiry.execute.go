@@ -0,0 +1,85 @@
+package testexec
+
+import (
+	"context"
+
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/device/to2"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+	testdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
+	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
+)
+
+// expectRecovered combines the rejection of a message in an expired session with the result of a new session, which must succeed
+func expectRecovered(testId testcom.FDOTestID, rejectState testcom.FDOTestState, recoverErr error) testcom.FDOTestState {
+	if !rejectState.Passed {
+		return rejectState
+	}
+
+	if recoverErr != nil {
+		return testcom.NewFailTestState(testId, "A new session failed after the expired one. "+recoverErr.Error())
+	}
+
+	return rejectState
+}
+
+func executeTo2_Expiry(reqte reqtestsdeps.RequestTestInst, testCred *fdoshared.DeviceCredAndVoucher, testId testcom.FDOTestID, reqtDB *testdbs.RequestTestDB, ctx context.Context) {
+	expiry, ok := testcom.GetSessionExpiry(ctx)
+	if !ok {
+		reportTest(ctx, reqtDB, reqte.Uuid, testId, testcom.NewFailTestState(testId, "Session expiry wait is not set"))
+		return
+	}
+
+	var to2requestor *to2.To2Requestor
+	var err error
+
+	switch testId {
+	case testcom.FIDO_DOT_62_EXPIRED_SESSION:
+		newRequestor := newTo2Requestor(reqte, testCred, ctx)
+		to2requestor = &newRequestor
+		_, _, err = to2requestor.HelloDevice60(testcom.NULL_TEST)
+	case testcom.FIDO_DOT_68_EXPIRED_SESSION:
+		to2requestor, err = preExecuteTo2_68(reqte, testCred, ctx)
+	default:
+		reportTest(ctx, reqtDB, reqte.Uuid, testId, testcom.NewFailTestState(testId, "Unsupported test "+string(testId)))
+		return
+	}
+
+	if err != nil {
+		reportTest(ctx, reqtDB, reqte.Uuid, testId, testcom.NewFailTestState(testId, "Error running TO2 batch. Pre setup failed. "+err.Error()))
+		return
+	}
+
+	err = expiry.Sleep(ctx, expiry.Wait)
+	if err != nil {
+		reportTest(ctx, reqtDB, reqte.Uuid, testId, testcom.NewFailTestState(testId, "Session expiry wait was interrupted. "+err.Error()))
+		return
+	}
+
+	var rejectState testcom.FDOTestState
+
+	switch testId {
+	case testcom.FIDO_DOT_62_EXPIRED_SESSION:
+		_, testState, err := to2requestor.GetOVNextEntry62(0, testId)
+		rejectState = orderTestState(testId, testState, err)
+
+	case testcom.FIDO_DOT_68_EXPIRED_SESSION:
+		_, testState, err := to2requestor.DeviceServiceInfo68(fdoshared.DeviceServiceInfo68{
+			ServiceInfo:       nil,
+			IsMoreServiceInfo: false,
+		}, testId)
+		rejectState = orderTestState(testId, testState, err)
+	}
+
+	// The target must then onboard the device in a new session
+	var recoverErr error
+	if rejectState.Passed {
+		var newSession *to2.To2Requestor
+		newSession, recoverErr = preExecuteTo2_70(reqte, testCred, ctx)
+		if recoverErr == nil {
+			_, _, recoverErr = newSession.Done70(testcom.NULL_TEST)
+		}
+	}
+
+	reportTest(ctx, reqtDB, reqte.Uuid, testId, expectRecovered(testId, rejectState, recoverErr))
+}
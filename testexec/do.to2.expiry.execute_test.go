@@ -0,0 +1,47 @@
+package testexec
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/storage"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/harness"
+)
+
+func TestExecuteTo2Expiry(t *testing.T) {
+	h := harness.NewHarness(nil)
+	defer h.Close()
+
+	reqte, reqtDB := newTo2TestInst(t, h, h.DO.URL, 1)
+
+	// The pause moves the harness clock instead of waiting
+	advanceClock := func(ctx context.Context, d time.Duration) error {
+		h.Clock.Advance(d)
+		return nil
+	}
+
+	ctx := testcom.WithSessionExpiry(h.Ctx, fdoshared.SESSION_TTL+time.Second, advanceClock)
+
+	tests := runTo2Tests(t, reqte, reqtDB, ctx, testcom.FIDO_TEST_LIST_DOT_EXPIRY, executeTo2_Expiry)
+	expectTestsPassed(t, tests, testcom.FIDO_TEST_LIST_DOT_EXPIRY)
+}
+
+func TestExecuteTo2ExpiryIgnoredByTarget(t *testing.T) {
+	// The store of the DO runs on the wall clock, so its sessions outlive the pause, as with a DO that ignores expiry
+	h := harness.NewHarness(storage.NewMemoryStore())
+	defer h.Close()
+
+	reqte, reqtDB := newTo2TestInst(t, h, h.DO.URL, 1)
+
+	skipPause := func(ctx context.Context, d time.Duration) error {
+		return nil
+	}
+
+	ctx := testcom.WithSessionExpiry(h.Ctx, fdoshared.SESSION_TTL+time.Second, skipPause)
+
+	tests := runTo2Tests(t, reqte, reqtDB, ctx, testcom.FIDO_TEST_LIST_DOT_EXPIRY, executeTo2_Expiry)
+	expectTestsFailed(t, tests, testcom.FIDO_TEST_LIST_DOT_EXPIRY)
+}
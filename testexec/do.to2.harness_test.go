@@ -0,0 +1,126 @@
+package testexec
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/storage"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+	testdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
+	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/harness"
+)
+
+// newTo2TestInst saves a TO2 test instance of the target, with a new device of the harness for every voucher
+func newTo2TestInst(t *testing.T, h *harness.Harness, targetUrl string, devices int) (reqtestsdeps.RequestTestInst, *testdbs.RequestTestDB) {
+	t.Helper()
+
+	var testCreds []fdoshared.DeviceCredAndVoucher
+	for i := 0; i < devices; i++ {
+		credAndVoucher, err := h.NewDevice(fdoshared.StSECP256R1)
+		if err != nil {
+			t.Fatalf("Failed to create device. %s", err.Error())
+		}
+
+		testCreds = append(testCreds, *credAndVoucher)
+	}
+
+	reqtDB := testdbs.NewRequestTestDB(storage.NewMemoryStore())
+	reqte := reqtestsdeps.NewRequestTestInst(targetUrl, fdoshared.To2, devices)
+	reqte.TestVouchers[testcom.NULL_TEST] = testCreds
+
+	err := reqtDB.Save(reqte)
+	if err != nil {
+		t.Fatalf("Failed to save test instance. %s", err.Error())
+	}
+
+	return reqte, reqtDB
+}
+
+// runTo2Tests runs the tests one after the other with the first voucher and strict error codes, and returns their results
+func runTo2Tests(t *testing.T, reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTestDB, ctx context.Context, testIds []testcom.FDOTestID, executor to2TestExecutor) reqtestsdeps.RequestTestResultMap {
+	t.Helper()
+
+	run, err := startRun(testcom.WithErrorCodeStrictness(ctx, testcom.ECS_Strict), reqte, reqtDB)
+	if err != nil {
+		t.Fatalf("Failed to start run. %s", err.Error())
+	}
+
+	testCred := &reqte.TestVouchers[testcom.NULL_TEST][0]
+	for _, testId := range testIds {
+		executor(reqte, testCred, testId, reqtDB, newTestContext(run.Ctx, testId))
+	}
+	run.finish()
+
+	result, err := reqtDB.Get(reqte.Uuid)
+	if err != nil {
+		t.Fatalf("Failed to get test instance. %s", err.Error())
+	}
+
+	return result.TestsHistory[0].Tests
+}
+
+func expectTestsPassed(t *testing.T, tests reqtestsdeps.RequestTestResultMap, testIds []testcom.FDOTestID) {
+	t.Helper()
+
+	for _, testId := range testIds {
+		testState, ok := tests[testId]
+		if !ok {
+			t.Errorf("Missing result for %s", testId)
+			continue
+		}
+
+		if !testState.Passed || len(testState.Warnings) != 0 {
+			t.Errorf("Expected %s to pass. Got %s %v", testId, testState.Error, testState.Warnings)
+		}
+	}
+}
+
+func expectTestsFailed(t *testing.T, tests reqtestsdeps.RequestTestResultMap, testIds []testcom.FDOTestID) {
+	t.Helper()
+
+	for _, testId := range testIds {
+		testState, ok := tests[testId]
+		if !ok {
+			t.Errorf("Missing result for %s", testId)
+			continue
+		}
+
+		if testState.Passed {
+			t.Errorf("Expected %s to fail", testId)
+		}
+	}
+}
+
+// acceptingHandler is a target that answers the messages the wrapped handler rejects with an empty success response,
+// as a DO that checks neither the message order nor the session binding would
+type acceptingHandler struct {
+	handler http.Handler
+}
+
+func (h acceptingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	recorder := httptest.NewRecorder()
+	h.handler.ServeHTTP(recorder, r)
+
+	for key, values := range recorder.Header() {
+		w.Header()[key] = values
+	}
+
+	if recorder.Code == http.StatusOK {
+		w.WriteHeader(recorder.Code)
+		w.Write(recorder.Body.Bytes())
+		return
+	}
+
+	w.Header().Set("Content-Type", fdoshared.CONTENT_TYPE_CBOR)
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte{0x80})
+}
+
+// newAcceptingTarget serves the DO of the harness behind an acceptingHandler. Call Close when done
+func newAcceptingTarget(h *harness.Harness) *httptest.Server {
+	return httptest.NewServer(acceptingHandler{h.DO.Config.Handler})
+}
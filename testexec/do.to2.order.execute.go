@@ -0,0 +1,101 @@
+package testexec
+
+import (
+	"context"
+
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/device/to2"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+	testdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
+	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
+)
+
+// orderTestState returns the result of a requestor call made with a test id, or a failure if the call failed before sending
+func orderTestState(testId testcom.FDOTestID, testState *testcom.FDOTestState, err error) testcom.FDOTestState {
+	if testState == nil {
+		errMsg := "Failed to send the message"
+		if err != nil {
+			errMsg = errMsg + ". " + err.Error()
+		}
+
+		return testcom.NewFailTestState(testId, errMsg)
+	}
+
+	return *testState
+}
+
+// expectSessionEnded combines the rejection of an out of order message with the result of continuing its session, which must fail too
+func expectSessionEnded(testId testcom.FDOTestID, rejectState testcom.FDOTestState, sessionState testcom.FDOTestState) testcom.FDOTestState {
+	if !rejectState.Passed {
+		return rejectState
+	}
+
+	if !sessionState.Passed {
+		return testcom.NewFailTestState(testId, "The session was not ended after the error. "+sessionState.Error)
+	}
+
+	rejectState.Warnings = append(rejectState.Warnings, sessionState.Warnings...)
+	return rejectState
+}
+
+func executeTo2_Order(reqte reqtestsdeps.RequestTestInst, testCred *fdoshared.DeviceCredAndVoucher, testId testcom.FDOTestID, reqtDB *testdbs.RequestTestDB, ctx context.Context) {
+	var to2requestor *to2.To2Requestor
+	var err error
+
+	switch testId {
+	case testcom.FIDO_DOT_64_BEFORE_62, testcom.FIDO_DOT_70_AFTER_60:
+		newRequestor := newTo2Requestor(reqte, testCred, ctx)
+		to2requestor = &newRequestor
+		_, _, err = to2requestor.HelloDevice60(testcom.NULL_TEST)
+	case testcom.FIDO_DOT_66_TWICE:
+		to2requestor, err = preExecuteTo2_66(reqte, testCred, ctx)
+		if err == nil {
+			_, _, err = to2requestor.DeviceServiceInfoReady66(testcom.NULL_TEST)
+		}
+	default:
+		reportTest(ctx, reqtDB, reqte.Uuid, testId, testcom.NewFailTestState(testId, "Unsupported test "+string(testId)))
+		return
+	}
+
+	if err != nil {
+		reportTest(ctx, reqtDB, reqte.Uuid, testId, testcom.NewFailTestState(testId, "Error running TO2 batch. Pre setup failed. "+err.Error()))
+		return
+	}
+
+	var rejectState testcom.FDOTestState
+	var sessionState testcom.FDOTestState
+
+	switch testId {
+	case testcom.FIDO_DOT_64_BEFORE_62:
+		_, testState, err := to2requestor.ProveDevice64(testId)
+		rejectState = orderTestState(testId, testState, err)
+
+		_, testState, err = to2requestor.GetOVNextEntry62(0, testId)
+		sessionState = orderTestState(testId, testState, err)
+
+	case testcom.FIDO_DOT_66_TWICE:
+		_, testState, err := to2requestor.DeviceServiceInfoReady66(testId)
+		rejectState = orderTestState(testId, testState, err)
+
+		_, testState, err = to2requestor.DeviceServiceInfo68(fdoshared.DeviceServiceInfo68{
+			ServiceInfo:       nil,
+			IsMoreServiceInfo: false,
+		}, testId)
+		sessionState = orderTestState(testId, testState, err)
+
+	case testcom.FIDO_DOT_70_AFTER_60:
+		// There is no session key before 64, so the device makes one up
+		to2requestor.SessionKey = fdoshared.SessionKeyInfo{
+			ShSe:        fdoshared.NewRandomBuffer(32),
+			ContextRand: []byte{},
+		}
+
+		_, testState, err := to2requestor.Done70(testId)
+		rejectState = orderTestState(testId, testState, err)
+
+		_, testState, err = to2requestor.GetOVNextEntry62(0, testId)
+		sessionState = orderTestState(testId, testState, err)
+	}
+
+	reportTest(ctx, reqtDB, reqte.Uuid, testId, expectSessionEnded(testId, rejectState, sessionState))
+}
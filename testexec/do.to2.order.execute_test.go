@@ -0,0 +1,31 @@
+package testexec
+
+import (
+	"testing"
+
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/harness"
+)
+
+func TestExecuteTo2Order(t *testing.T) {
+	h := harness.NewHarness(nil)
+	defer h.Close()
+
+	reqte, reqtDB := newTo2TestInst(t, h, h.DO.URL, 1)
+
+	tests := runTo2Tests(t, reqte, reqtDB, h.Ctx, testcom.FIDO_TEST_LIST_DOT_ORDER, executeTo2_Order)
+	expectTestsPassed(t, tests, testcom.FIDO_TEST_LIST_DOT_ORDER)
+}
+
+func TestExecuteTo2OrderAcceptingTarget(t *testing.T) {
+	h := harness.NewHarness(nil)
+	defer h.Close()
+
+	target := newAcceptingTarget(h)
+	defer target.Close()
+
+	reqte, reqtDB := newTo2TestInst(t, h, target.URL, 1)
+
+	tests := runTo2Tests(t, reqte, reqtDB, h.Ctx, testcom.FIDO_TEST_LIST_DOT_ORDER, executeTo2_Order)
+	expectTestsFailed(t, tests, testcom.FIDO_TEST_LIST_DOT_ORDER)
+}
@@ -0,0 +1,120 @@
+package testexec
+
+import (
+	"context"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+	testdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
+	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
+)
+
+// replayTestState sends a message captured in another session as is, and expects the target to reject it
+func replayTestState(ctx context.Context, reqte reqtestsdeps.RequestTestInst, cmd fdoshared.FdoCmd, captured []byte, authzHeader string, testId testcom.FDOTestID) testcom.FDOTestState {
+	bodyBytes, _, httpStatus, err := fdoshared.SendCborPost(ctx, fdoshared.SRVEntry{
+		SrvURL: reqte.URL,
+	}, cmd, captured, &authzHeader)
+	if err != nil {
+		return testcom.NewFailTestState(testId, "Failed to replay the message. "+err.Error())
+	}
+
+	return testcom.ExpectFdoErrorWithStrictness(bodyBytes, testId, httpStatus, testcom.GetErrorCodeStrictness(ctx))
+}
+
+func executeTo2_Replay(reqte reqtestsdeps.RequestTestInst, testCred *fdoshared.DeviceCredAndVoucher, testId testcom.FDOTestID, reqtDB *testdbs.RequestTestDB, ctx context.Context) {
+	var resultState testcom.FDOTestState
+
+	switch testId {
+	case testcom.FIDO_DOT_64_REPLAY:
+		captureCtx := fdoshared.NewSentMessagesContext(ctx)
+		sessionA, err := preExecuteTo2_64(reqte, testCred, captureCtx)
+		if err != nil {
+			reportTest(ctx, reqtDB, reqte.Uuid, testId, testcom.NewFailTestState(testId, "Error running TO2 batch. Pre setup failed. "+err.Error()))
+			return
+		}
+
+		_, _, err = sessionA.ProveDevice64(testcom.NULL_TEST)
+		if err != nil {
+			reportTest(ctx, reqtDB, reqte.Uuid, testId, testcom.NewFailTestState(testId, "Error running TO2 batch. ProveDevice64 failed. "+err.Error()))
+			return
+		}
+
+		captured, ok := fdoshared.GetSentMessagesRecorder(captureCtx).GetLastSent(fdoshared.TO2_64_PROVE_DEVICE)
+		if !ok {
+			reportTest(ctx, reqtDB, reqte.Uuid, testId, testcom.NewFailTestState(testId, "ProveDevice64 was not captured"))
+			return
+		}
+
+		sessionB, err := preExecuteTo2_64(reqte, testCred, ctx)
+		if err != nil {
+			reportTest(ctx, reqtDB, reqte.Uuid, testId, testcom.NewFailTestState(testId, "Error running TO2 batch. Pre setup failed. "+err.Error()))
+			return
+		}
+
+		resultState = replayTestState(ctx, reqte, fdoshared.TO2_64_PROVE_DEVICE, captured, sessionB.AuthzHeader, testId)
+
+	case testcom.FIDO_DOT_64_OTHER_GUID:
+		to2requestor, err := preExecuteTo2_64(reqte, testCred, ctx)
+		if err != nil {
+			reportTest(ctx, reqtDB, reqte.Uuid, testId, testcom.NewFailTestState(testId, "Error running TO2 batch. Pre setup failed. "+err.Error()))
+			return
+		}
+
+		_, testState, err := to2requestor.ProveDevice64(testId)
+		resultState = orderTestState(testId, testState, err)
+
+	case testcom.FIDO_DOT_64_PARALLEL_SESSION:
+		sessionA, err := preExecuteTo2_64(reqte, testCred, ctx)
+		if err != nil {
+			reportTest(ctx, reqtDB, reqte.Uuid, testId, testcom.NewFailTestState(testId, "Error running TO2 batch. Pre setup failed. "+err.Error()))
+			return
+		}
+
+		sessionB, err := preExecuteTo2_64(reqte, testCred, ctx)
+		if err != nil {
+			reportTest(ctx, reqtDB, reqte.Uuid, testId, testcom.NewFailTestState(testId, "Error running TO2 batch. Pre setup failed. "+err.Error()))
+			return
+		}
+
+		// Session B answers with the nonce of session A, which must not be accepted in B
+		sessionB.NonceTO2ProveDv61 = sessionA.NonceTO2ProveDv61
+		_, testState, err := sessionB.ProveDevice64(testId)
+		resultState = orderTestState(testId, testState, err)
+
+		// Session A must not be affected by B
+		if resultState.Passed {
+			_, _, err = sessionA.ProveDevice64(testcom.NULL_TEST)
+			if err != nil {
+				resultState = testcom.NewFailTestState(testId, "The parallel session failed after the rejection. "+err.Error())
+			}
+		}
+
+	case testcom.FIDO_DOT_70_AFTER_DONE:
+		to2requestor, err := preExecuteTo2_70(reqte, testCred, ctx)
+		if err != nil {
+			reportTest(ctx, reqtDB, reqte.Uuid, testId, testcom.NewFailTestState(testId, "Error running TO2 batch. Pre setup failed. "+err.Error()))
+			return
+		}
+
+		_, _, err = to2requestor.Done70(testcom.NULL_TEST)
+		if err != nil {
+			reportTest(ctx, reqtDB, reqte.Uuid, testId, testcom.NewFailTestState(testId, "Error running TO2 batch. Done70 failed. "+err.Error()))
+			return
+		}
+
+		_, testState, err := to2requestor.Done70(testId)
+		rejectState := orderTestState(testId, testState, err)
+
+		_, testState, err = to2requestor.DeviceServiceInfo68(fdoshared.DeviceServiceInfo68{
+			ServiceInfo:       nil,
+			IsMoreServiceInfo: false,
+		}, testId)
+
+		resultState = testcom.MergeTestStates(testId, []testcom.FDOTestState{rejectState, orderTestState(testId, testState, err)})
+
+	default:
+		resultState = testcom.NewFailTestState(testId, "Unsupported test "+string(testId))
+	}
+
+	reportTest(ctx, reqtDB, reqte.Uuid, testId, resultState)
+}
@@ -0,0 +1,32 @@
+package testexec
+
+import (
+	"testing"
+
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/harness"
+)
+
+func TestExecuteTo2Replay(t *testing.T) {
+	h := harness.NewHarness(nil)
+	defer h.Close()
+
+	reqte, reqtDB := newTo2TestInst(t, h, h.DO.URL, 1)
+
+	tests := runTo2Tests(t, reqte, reqtDB, h.Ctx, testcom.FIDO_TEST_LIST_DOT_REPLAY, executeTo2_Replay)
+	expectTestsPassed(t, tests, testcom.FIDO_TEST_LIST_DOT_REPLAY)
+}
+
+func TestExecuteTo2ReplayAcceptingTarget(t *testing.T) {
+	h := harness.NewHarness(nil)
+	defer h.Close()
+
+	// The target accepts replayed messages, and messages proving another GUID or carrying the nonce of another session
+	target := newAcceptingTarget(h)
+	defer target.Close()
+
+	reqte, reqtDB := newTo2TestInst(t, h, target.URL, 1)
+
+	tests := runTo2Tests(t, reqte, reqtDB, h.Ctx, testcom.FIDO_TEST_LIST_DOT_REPLAY, executeTo2_Replay)
+	expectTestsFailed(t, tests, testcom.FIDO_TEST_LIST_DOT_REPLAY)
+}
@@ -0,0 +1,199 @@
+package testexec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+	testdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
+	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
+)
+
+// runStressTo2Flow onboards the device, calling pause before every message. On top of the nonce checks of the requestor,
+// it checks that the DO proves the voucher of the device. It returns the ServiceInfo sent by the owner
+func runStressTo2Flow(reqte reqtestsdeps.RequestTestInst, testCred *fdoshared.DeviceCredAndVoucher, pause func(ctx context.Context) error, ctx context.Context) ([]fdoshared.ServiceInfoKV, error) {
+	to2requestor := newTo2Requestor(reqte, testCred, ctx)
+
+	if err := pause(ctx); err != nil {
+		return nil, err
+	}
+
+	proveOVHdrPayload61, _, err := to2requestor.HelloDevice60(testcom.NULL_TEST)
+	if err != nil {
+		return nil, err
+	}
+
+	var ovHeader fdoshared.OwnershipVoucherHeader
+	err = fdoshared.CborCust.Unmarshal(proveOVHdrPayload61.OVHeader, &ovHeader)
+	if err != nil {
+		return nil, errors.New("Failed to decode OVHeader of ProveOVHdr61. " + err.Error())
+	}
+
+	if !ovHeader.OVGuid.Equals(testCred.WawDeviceCredential.DCGuid) {
+		return nil, fmt.Errorf("ProveOVHdr61 is for the device %s", ovHeader.OVGuid.GetFormatted())
+	}
+
+	var ovEntries fdoshared.OVEntryArray
+	for i := 0; i < int(proveOVHdrPayload61.NumOVEntries); i++ {
+		if err := pause(ctx); err != nil {
+			return nil, err
+		}
+
+		nextEntry, _, err := to2requestor.GetOVNextEntry62(uint8(i), testcom.NULL_TEST)
+		if err != nil {
+			return nil, err
+		}
+
+		if nextEntry.OVEntryNum != uint8(i) {
+			return nil, fmt.Errorf("Requested OVEntry %d, got %d", i, nextEntry.OVEntryNum)
+		}
+
+		ovEntries = append(ovEntries, nextEntry.OVEntry)
+	}
+
+	err = ovEntries.VerifyEntries(proveOVHdrPayload61.OVHeader, proveOVHdrPayload61.HMac)
+	if err != nil {
+		return nil, err
+	}
+
+	ownerPubKey, err := testCred.VoucherDBEntry.Voucher.GetFinalOwnerPublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	err = to2requestor.ProveOVHdr61PubKey.Equal(ownerPubKey)
+	if err != nil {
+		return nil, errors.New("ProveOVHdr61 is not signed with the owner key of the voucher. " + err.Error())
+	}
+
+	if err := pause(ctx); err != nil {
+		return nil, err
+	}
+
+	_, _, err = to2requestor.ProveDevice64(testcom.NULL_TEST)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := pause(ctx); err != nil {
+		return nil, err
+	}
+
+	_, _, err = to2requestor.DeviceServiceInfoReady66(testcom.NULL_TEST)
+	if err != nil {
+		return nil, err
+	}
+
+	var ownerServiceInfo []fdoshared.ServiceInfoKV
+	deviceSims := fdoshared.GetDeviceOSSims()
+	for i, deviceSim := range deviceSims {
+		if err := pause(ctx); err != nil {
+			return nil, err
+		}
+
+		ownerSim, _, err := to2requestor.DeviceServiceInfo68(fdoshared.DeviceServiceInfo68{
+			ServiceInfo: []fdoshared.ServiceInfoKV{
+				deviceSim,
+			},
+			IsMoreServiceInfo: i+1 <= len(deviceSims),
+		}, testcom.NULL_TEST)
+		if err != nil {
+			return nil, err
+		}
+
+		ownerServiceInfo = append(ownerServiceInfo, ownerSim.ServiceInfo...)
+	}
+
+	for maxCounter := 255; ; maxCounter-- {
+		if maxCounter <= 0 {
+			return nil, errors.New("Owner sent more than 255 SIMs")
+		}
+
+		if err := pause(ctx); err != nil {
+			return nil, err
+		}
+
+		ownerSim, _, err := to2requestor.DeviceServiceInfo68(fdoshared.DeviceServiceInfo68{
+			ServiceInfo:       nil,
+			IsMoreServiceInfo: false,
+		}, testcom.NULL_TEST)
+		if err != nil {
+			return nil, err
+		}
+
+		ownerServiceInfo = append(ownerServiceInfo, ownerSim.ServiceInfo...)
+		if ownerSim.IsDone {
+			break
+		}
+	}
+
+	if err := pause(ctx); err != nil {
+		return nil, err
+	}
+
+	_, _, err = to2requestor.Done70(testcom.NULL_TEST)
+	if err != nil {
+		return nil, err
+	}
+
+	return ownerServiceInfo, nil
+}
+
+// sameServiceInfo is true if both have the same keys and values, in the same order
+func sameServiceInfo(a []fdoshared.ServiceInfoKV, b []fdoshared.ServiceInfoKV) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i].ServiceInfoKey != b[i].ServiceInfoKey || !bytes.Equal(a[i].ServiceInfoVal, b[i].ServiceInfoVal) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// executeTo2_Stress onboards every device once on its own, to learn the ServiceInfo the owner sends it, and then all
+// of them at the same time with interleaved messages. Every session must complete with the state of its own device
+func executeTo2_Stress(reqte reqtestsdeps.RequestTestInst, testCreds []fdoshared.DeviceCredAndVoucher, testId testcom.FDOTestID, reqtDB *testdbs.RequestTestDB, ctx context.Context) {
+	if len(testCreds) > STRESS_MAX_SESSIONS {
+		testCreds = testCreds[:STRESS_MAX_SESSIONS]
+	}
+
+	noPause := func(ctx context.Context) error {
+		return nil
+	}
+
+	guids := make([]fdoshared.FdoGuid, len(testCreds))
+	expectedServiceInfo := make([][]fdoshared.ServiceInfoKV, len(testCreds))
+	for i := range testCreds {
+		guids[i] = testCreds[i].WawDeviceCredential.DCGuid
+
+		ownerServiceInfo, err := runStressTo2Flow(reqte, &testCreds[i], noPause, ctx)
+		if err != nil {
+			reportTest(ctx, reqtDB, reqte.Uuid, testId, testcom.NewFailTestState(testId, fmt.Sprintf("Error running TO2 batch. Onboarding %s on its own failed. %s", guids[i].GetFormatted(), err.Error())))
+			return
+		}
+
+		expectedServiceInfo[i] = ownerServiceInfo
+	}
+
+	testState := runStressFlows(testId, guids, func(i int) error {
+		ownerServiceInfo, err := runStressTo2Flow(reqte, &testCreds[i], stressJitter, ctx)
+		if err != nil {
+			return err
+		}
+
+		if !sameServiceInfo(ownerServiceInfo, expectedServiceInfo[i]) {
+			return errors.New("The owner sent other ServiceInfo than when the device was onboarded on its own")
+		}
+
+		return nil
+	})
+
+	reportTest(ctx, reqtDB, reqte.Uuid, testId, testState)
+}
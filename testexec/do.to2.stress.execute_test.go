@@ -0,0 +1,114 @@
+package testexec
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+	testdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
+	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/harness"
+)
+
+// executeTo2_StressAll runs the stress test with every voucher of the test instance
+func executeTo2_StressAll(reqte reqtestsdeps.RequestTestInst, _ *fdoshared.DeviceCredAndVoucher, testId testcom.FDOTestID, reqtDB *testdbs.RequestTestDB, ctx context.Context) {
+	executeTo2_Stress(reqte, reqte.TestVouchers[testcom.NULL_TEST], testId, reqtDB, ctx)
+}
+
+// leakyHandler is a target that continues the latest session it started for every message, as a DO that keeps
+// the session state in a shared variable would. Sessions run one at a time still work
+type leakyHandler struct {
+	handler http.Handler
+
+	mu          sync.Mutex
+	latestAuthz string
+}
+
+func (h *leakyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	if r.Header.Get("Authorization") != "" {
+		r.Header.Set("Authorization", h.latestAuthz)
+	}
+	h.mu.Unlock()
+
+	recorder := httptest.NewRecorder()
+	h.handler.ServeHTTP(recorder, r)
+
+	if authz := recorder.Header().Get("Authorization"); authz != "" && r.Header.Get("Authorization") == "" {
+		h.mu.Lock()
+		h.latestAuthz = authz
+		h.mu.Unlock()
+	}
+
+	for key, values := range recorder.Header() {
+		w.Header()[key] = values
+	}
+
+	w.WriteHeader(recorder.Code)
+	w.Write(recorder.Body.Bytes())
+}
+
+func TestExecuteTo2Stress(t *testing.T) {
+	h := harness.NewHarness(nil)
+	defer h.Close()
+
+	reqte, reqtDB := newTo2TestInst(t, h, h.DO.URL, 8)
+
+	testIds := []testcom.FDOTestID{testcom.FIDO_DOT_STRESS_SESSION_ISOLATION}
+	tests := runTo2Tests(t, reqte, reqtDB, h.Ctx, testIds, executeTo2_StressAll)
+	expectTestsPassed(t, tests, testIds)
+}
+
+func TestExecuteTo2StressLeakyTarget(t *testing.T) {
+	h := harness.NewHarness(nil)
+	defer h.Close()
+
+	target := httptest.NewServer(&leakyHandler{handler: h.DO.Config.Handler})
+	defer target.Close()
+
+	reqte, reqtDB := newTo2TestInst(t, h, target.URL, 8)
+
+	testIds := []testcom.FDOTestID{testcom.FIDO_DOT_STRESS_SESSION_ISOLATION}
+	tests := runTo2Tests(t, reqte, reqtDB, h.Ctx, testIds, executeTo2_StressAll)
+	expectTestsFailed(t, tests, testIds)
+
+	// Every device is onboarded on its own first, so the failure must come from the concurrent sessions
+	if testState := tests[testcom.FIDO_DOT_STRESS_SESSION_ISOLATION]; strings.Contains(testState.Error, "on its own failed") {
+		t.Errorf("Expected the concurrent sessions to fail. Got %s", testState.Error)
+	}
+}
+
+func TestExecuteDOTestsTo2StressOption(t *testing.T) {
+	h := harness.NewHarness(nil)
+	defer h.Close()
+
+	for _, stressTests := range []bool{false, true} {
+		reqte, reqtDB := newTo2TestInst(t, h, h.DO.URL, 2)
+
+		ctx := h.Ctx
+		if stressTests {
+			ctx = testcom.WithStressTests(ctx)
+		}
+
+		runUuid, err := ExecuteDOTestsTo2(reqte, reqtDB, ctx)
+		if err != nil {
+			t.Fatalf("Failed to start run. %s", err.Error())
+		}
+		WaitRun(runUuid)
+
+		result, err := reqtDB.Get(reqte.Uuid)
+		if err != nil {
+			t.Fatalf("Failed to get test instance. %s", err.Error())
+		}
+
+		_, ok := result.TestsHistory[0].Tests[testcom.FIDO_DOT_STRESS_SESSION_ISOLATION]
+		if ok != stressTests {
+			t.Errorf("Expected the stress test to run only with the option. Option %t, ran %t", stressTests, ok)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package testexec
+
+import (
+	"context"
+	"fmt"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+	testdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
+	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
+)
+
+// httpTestMessage is a message of the protocol under test. newSession opens a session for the follow-up messages,
+// and returns its authorization. It is nil for the first message
+type httpTestMessage struct {
+	cmd        fdoshared.FdoCmd
+	newSession func(ctx context.Context) (string, error)
+}
+
+// executeHttpTest sends every message as the malformed request of the test, see testcom.NewHttpTestRequest
+func executeHttpTest(reqte reqtestsdeps.RequestTestInst, messages []httpTestMessage, testId testcom.FDOTestID, reqtDB *testdbs.RequestTestDB, ctx context.Context) {
+	srvEntry := fdoshared.SRVEntry{
+		SrvURL: reqte.URL,
+	}
+
+	var testStates []testcom.FDOTestState
+	for _, message := range messages {
+		if message.newSession == nil && testcom.IsHttpAuthorizationTest(testId) {
+			continue
+		}
+
+		var authzHeader string
+		if message.newSession != nil {
+			var err error
+			authzHeader, err = message.newSession(ctx)
+			if err != nil {
+				testStates = append(testStates, testcom.NewFailTestState(testId, fmt.Sprintf("%d: Failed to open session. %s", message.cmd, err.Error())))
+				continue
+			}
+		}
+
+		bodyBytes, respHeader, httpStatus, err := fdoshared.SendFdoRequest(ctx, srvEntry, message.cmd, testcom.NewHttpTestRequest(testId, authzHeader))
+		if err != nil {
+			testStates = append(testStates, testcom.NewFailTestState(testId, fmt.Sprintf("%d: %s", message.cmd, err.Error())))
+			continue
+		}
+
+		testStates = append(testStates, testcom.ExpectHttpError(testId, message.cmd, httpStatus, respHeader, bodyBytes, testcom.GetErrorCodeStrictness(ctx)))
+	}
+
+	reportTest(ctx, reqtDB, reqte.Uuid, testId, testcom.MergeTestStates(testId, testStates))
+}
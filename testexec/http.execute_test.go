@@ -0,0 +1,27 @@
+package testexec
+
+import (
+	"testing"
+
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/harness"
+)
+
+func TestExecuteTo2Http(t *testing.T) {
+	h := harness.NewHarness(nil)
+	defer h.Close()
+
+	reqte, reqtDB := newTo2TestInst(t, h, h.DO.URL, 1)
+
+	// Our own DO must respond with the exact statuses
+	tests := runTo2Tests(t, reqte, reqtDB, h.Ctx, testcom.FIDO_TEST_LIST_HTTP_TO2, executeTo2_Http)
+	expectTestsPassed(t, tests, testcom.FIDO_TEST_LIST_HTTP_TO2)
+
+	for _, testId := range testcom.FIDO_TEST_LIST_HTTP_TO2 {
+		for _, check := range testcom.FIDO_TEST_LIST_ERRMSG {
+			if checkState, ok := tests[testcom.NewErrorMessageTestID(testId, check)]; ok && !checkState.Passed {
+				t.Errorf("Expected %s of %s to pass. Got %s", check, testId, checkState.Error)
+			}
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package testexec
+
+import (
+	"context"
+	"sync"
+)
+
+const DefaultConcurrency int = 1
+const MaxConcurrency int = 16
+
+// testJob runs a single conformance test. workerId is stable for the lifetime of the worker
+// and is used to pick resources that must never be shared by concurrently running tests,
+// such as a device voucher.
+type testJob func(workerId int)
+
+// NormaliseConcurrency clamps a user supplied concurrency to the range supported by the executors.
+func NormaliseConcurrency(concurrency int) int {
+	if concurrency < 1 {
+		return DefaultConcurrency
+	}
+
+	if concurrency > MaxConcurrency {
+		return MaxConcurrency
+	}
+
+	return concurrency
+}
+
+// runTestJobs executes jobs using at most concurrency workers and waits for all of them to finish.
+// Once ctx is cancelled the remaining jobs are skipped.
+func runTestJobs(ctx context.Context, concurrency int, jobs []testJob) {
+	if len(jobs) == 0 {
+		return
+	}
+
+	concurrency = NormaliseConcurrency(concurrency)
+	if concurrency > len(jobs) {
+		concurrency = len(jobs)
+	}
+
+	jobsChan := make(chan testJob, len(jobs))
+	for _, job := range jobs {
+		jobsChan <- job
+	}
+	close(jobsChan)
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+
+		go func(workerId int) {
+			defer wg.Done()
+
+			for job := range jobsChan {
+				if ctx.Err() != nil {
+					return
+				}
+
+				job(workerId)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,168 @@
+package testexec
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+	testdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/events"
+	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
+)
+
+var ErrRunNotFound = errors.New("Run is not in progress")
+
+var (
+	activeRunsMu sync.Mutex
+	activeRuns   map[string]*activeRun = map[string]*activeRun{}
+)
+
+// CancelRun stops an in-progress run. Tests that are already running are aborted, results reported so far are kept
+func CancelRun(runUuid string) error {
+	activeRunsMu.Lock()
+	defer activeRunsMu.Unlock()
+
+	run, ok := activeRuns[runUuid]
+	if !ok {
+		return ErrRunNotFound
+	}
+
+	run.cancel()
+	return nil
+}
+
+// WaitRun blocks until the run is finished and its results are stored. It returns at once for a run that is not in progress
+func WaitRun(runUuid string) {
+	activeRunsMu.Lock()
+	run, ok := activeRuns[runUuid]
+	activeRunsMu.Unlock()
+
+	if ok {
+		<-run.done
+	}
+}
+
+type runUuidCtxKey struct{}
+
+// getRunUuid returns the run the tests of ctx belong to, so that their results are never filed under another run
+func getRunUuid(ctx context.Context) (string, bool) {
+	runUuid, ok := ctx.Value(runUuidCtxKey{}).(string)
+	return runUuid, ok
+}
+
+type activeRun struct {
+	Ctx    context.Context
+	Uuid   string
+	cancel context.CancelFunc
+	done   chan struct{}
+	reqte  reqtestsdeps.RequestTestInst
+	reqtDB *testdbs.RequestTestDB
+}
+
+func startRun(ctx context.Context, reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTestDB) (*activeRun, error) {
+	runUuid, err := reqtDB.StartNewRun(reqte.Uuid, testcom.GetErrorCodeStrictness(ctx))
+	if err != nil {
+		log.Println("Failed to start new test run. " + err.Error())
+		return nil, err
+	}
+
+	runCtx, cancel := context.WithCancel(context.WithValue(ctx, runUuidCtxKey{}, runUuid))
+
+	run := &activeRun{
+		Ctx:    runCtx,
+		Uuid:   runUuid,
+		cancel: cancel,
+		done:   make(chan struct{}),
+		reqte:  reqte,
+		reqtDB: reqtDB,
+	}
+
+	activeRunsMu.Lock()
+	activeRuns[runUuid] = run
+	activeRunsMu.Unlock()
+
+	return run, nil
+}
+
+// startRunInBackground starts a run, and executes it in the background. It returns the id of the run right away, so
+// that the caller can follow it, cancel it or wait for it with WaitRun
+func startRunInBackground(ctx context.Context, reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTestDB, execute func(run *activeRun)) (string, error) {
+	run, err := startRun(ctx, reqte, reqtDB)
+	if err != nil {
+		return "", err
+	}
+
+	go func() {
+		defer run.finish()
+		execute(run)
+	}()
+
+	return run.Uuid, nil
+}
+
+func (h *activeRun) finish() {
+	cancelled := h.Ctx.Err() != nil
+	h.cancel()
+
+	err := h.reqtDB.FinishRun(h.reqte.Uuid, h.Uuid, cancelled)
+	if err != nil {
+		log.Println("Failed to finish test run. " + err.Error())
+	}
+
+	activeRunsMu.Lock()
+	delete(activeRuns, h.Uuid)
+	activeRunsMu.Unlock()
+
+	close(h.done)
+}
+
+func (h *activeRun) testStarted(testId testcom.FDOTestID) {
+	events.Publish(events.NewTestStartedEvent(h.reqte.Uuid, h.Uuid, h.reqte.Protocol, testId))
+}
+
+// reportTest attaches the message timings recorded for the test to its result, and reports the checks of the
+// ErrorMessages received during the test as separate results
+func reportTest(ctx context.Context, reqtDB *testdbs.RequestTestDB, rvteid []byte, testId testcom.FDOTestID, testState testcom.FDOTestState) {
+	if recorder := fdoshared.GetTimingsRecorder(ctx); recorder != nil {
+		testState.Timings = recorder.GetTimings()
+	}
+
+	submitTestState(ctx, reqtDB, rvteid, testId, testState)
+
+	if recorder := fdoshared.GetReceivedErrorsRecorder(ctx); recorder != nil {
+		for _, checkState := range testcom.ValidateErrorMessages(testId, recorder.GetReceivedErrors()) {
+			submitTestState(ctx, reqtDB, rvteid, checkState.TestID, checkState)
+		}
+	}
+}
+
+// submitTestState files the result under the run of ctx, and tags the test ID with the crypto suite of a suite matrix run
+func submitTestState(ctx context.Context, reqtDB *testdbs.RequestTestDB, rvteid []byte, testId testcom.FDOTestID, testState testcom.FDOTestState) {
+	runUuid, ok := getRunUuid(ctx)
+	if !ok {
+		log.Printf("Failed to report %s. The test is not part of a run", testId)
+		return
+	}
+
+	if suite, ok := getCryptoSuite(ctx); ok {
+		if testState.TestID == testId {
+			testState.TestID = testcom.NewSuiteTestID(testId, suite)
+		}
+
+		testId = testcom.NewSuiteTestID(testId, suite)
+	}
+
+	reqtDB.ReportTest(rvteid, runUuid, testId, testState)
+}
+
+// newTestContext returns a context recording the message timings and received errors of a single test, and tagging its log lines with the test id
+func newTestContext(ctx context.Context, testId testcom.FDOTestID) context.Context {
+	ctx = fdoshared.NewLogContext(ctx)
+	fdoshared.SetLogTestId(ctx, string(testId))
+
+	ctx = fdoshared.NewReceivedErrorsContext(ctx)
+	return fdoshared.NewTimingsContext(ctx)
+}
@@ -11,146 +11,150 @@ import (
 	"github.com/fido-alliance/iot-fdo-conformance-tools/dbs"
 )
 
-func ExecuteRVTestsTo0(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTestDB, devDB *dbs.DeviceBaseDB, ctx context.Context) {
-	reqtDB.StartNewRun(reqte.Uuid)
+type to0TestExecutor func(reqte reqtestsdeps.RequestTestInst, testGuid fdoshared.FdoGuid, testId testcom.FDOTestID, reqtDB *testdbs.RequestTestDB, devDB *dbs.DeviceBaseDB, ctx context.Context)
 
-	for _, rv20test := range testcom.FIDO_TEST_LIST_RVT_20 {
-		randomGuid := reqte.FdoSeedIDs.GetRandomTestGuid()
-		testCredV, err := devDB.GetVANDV(randomGuid, rv20test)
-		if err != nil {
-			errTestState := testcom.FDOTestState{
-				Passed: false,
-				Error:  err.Error(),
-			}
+// ExecuteRVTestsTo0 starts a TO0 run in the background, and returns its id
+func ExecuteRVTestsTo0(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTestDB, devDB *dbs.DeviceBaseDB, ctx context.Context) (string, error) {
+	return startRunInBackground(ctx, reqte, reqtDB, func(run *activeRun) {
+		executeRVTestsTo0(run, reqte, reqtDB, devDB)
+	})
+}
 
-			reqtDB.ReportTest(reqte.Uuid, rv20test, errTestState)
-			continue
-		}
+func executeRVTestsTo0(run *activeRun, reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTestDB, devDB *dbs.DeviceBaseDB) {
+	ctx := run.Ctx
 
-		to0inst := to0.NewTo0Requestor(fdoshared.SRVEntry{
-			SrvURL: reqte.URL,
-		}, testCredV.VoucherDBEntry, ctx)
+	// Each test enrols its own device, so parallel tests never register the same GUID
+	testsCount := len(testcom.FIDO_TEST_LIST_RVT_20) + len(testcom.FIDO_TEST_LIST_RVT_22) + len(testcom.FIDO_TEST_LIST_VOUCHER) + len(testcom.FIDO_TEST_LIST_HTTP) + len(testcom.FIDO_TEST_LIST_RVT_ORDER)
 
-		switch rv20test {
-		case testcom.FIDO_RVT_20_POSITIVE:
-			var errTestState testcom.FDOTestState
-			_, _, err := to0inst.Hello20(testcom.NULL_TEST)
-			if err != nil {
-				errTestState = testcom.FDOTestState{
-					Passed: false,
-					Error:  err.Error(),
-				}
-				reqtDB.ReportTest(reqte.Uuid, rv20test, errTestState)
-				continue
-			} else {
-				errTestState = testcom.FDOTestState{
-					Passed: true,
-				}
-				reqtDB.ReportTest(reqte.Uuid, rv20test, errTestState)
-			}
+	// The expiry tests pause for a long time, so they only run when asked for
+	_, runExpiry := testcom.GetSessionExpiry(ctx)
+	if runExpiry {
+		testsCount += len(testcom.FIDO_TEST_LIST_RVT_EXPIRY)
+	}
 
-		default:
-			_, testState, err := to0inst.Hello20(rv20test)
-			if testState == nil && err != nil {
-				testState = &testcom.FDOTestState{
-					Passed: false,
-					Error:  err.Error(),
-				}
-			}
+	testGuids := reqte.FdoSeedIDs.GetUniqueTestGuids(testsCount)
+	if len(testGuids) == 0 {
+		reportTest(ctx, reqtDB, reqte.Uuid, testcom.NULL_TEST, testcom.NewFailTestState(testcom.NULL_TEST, "Error running TO0 tests. No seeded guids found"))
+		return
+	}
+
+	var jobs []testJob
+	addJobs := func(testIds []testcom.FDOTestID, executor to0TestExecutor) {
+		for _, testId := range testIds {
+			testGuid := testGuids[len(jobs)%len(testGuids)]
 
-			reqtDB.ReportTest(reqte.Uuid, rv20test, *testState)
+			jobs = append(jobs, func(workerId int) {
+				run.testStarted(testId)
+				executor(reqte, testGuid, testId, reqtDB, devDB, newTestContext(ctx, testId))
+			})
 		}
 	}
 
-	for _, rv22test := range testcom.FIDO_TEST_LIST_RVT_22 {
-		randomGuid := reqte.FdoSeedIDs.GetRandomTestGuid()
-		testCredV, err := devDB.GetVANDV(randomGuid, rv22test)
-		if err != nil {
-			errTestState := testcom.FDOTestState{
-				Passed: false,
-				Error:  err.Error(),
-			}
+	addJobs(testcom.FIDO_TEST_LIST_RVT_20, executeTo0_20)
+	addJobs(testcom.FIDO_TEST_LIST_RVT_22, executeTo0_22)
+	addJobs(testcom.FIDO_TEST_LIST_VOUCHER, executeTo0_22Voucher)
+	addJobs(testcom.FIDO_TEST_LIST_HTTP, executeTo0_Http)
+	addJobs(testcom.FIDO_TEST_LIST_RVT_ORDER, executeTo0_Order)
+	if runExpiry {
+		addJobs(testcom.FIDO_TEST_LIST_RVT_EXPIRY, executeTo0_Expiry)
+	}
+
+	runTestJobs(ctx, reqte.Concurrency, jobs)
+}
 
-			reqtDB.ReportTest(reqte.Uuid, rv22test, errTestState)
-			continue
+func executeTo0_20(reqte reqtestsdeps.RequestTestInst, testGuid fdoshared.FdoGuid, rv20test testcom.FDOTestID, reqtDB *testdbs.RequestTestDB, devDB *dbs.DeviceBaseDB, ctx context.Context) {
+	testCredV, err := devDB.GetVANDV(testGuid, rv20test)
+	if err != nil {
+		errTestState := testcom.FDOTestState{
+			Passed: false,
+			Error:  err.Error(),
 		}
 
-		to0inst := to0.NewTo0Requestor(fdoshared.SRVEntry{
-			SrvURL: reqte.URL,
-		}, testCredV.VoucherDBEntry, ctx)
+		reportTest(ctx, reqtDB, reqte.Uuid, rv20test, errTestState)
+		return
+	}
 
+	to0inst := to0.NewTo0Requestor(fdoshared.SRVEntry{
+		SrvURL: reqte.URL,
+	}, testCredV.VoucherDBEntry, ctx)
+
+	switch rv20test {
+	case testcom.FIDO_RVT_20_POSITIVE:
 		var errTestState testcom.FDOTestState
-		helloAck, _, err := to0inst.Hello20(testcom.NULL_TEST)
+		_, _, err := to0inst.Hello20(testcom.NULL_TEST)
 		if err != nil {
 			errTestState = testcom.FDOTestState{
 				Passed: false,
 				Error:  err.Error(),
 			}
-			reqtDB.ReportTest(reqte.Uuid, rv22test, errTestState)
-			continue
+			reportTest(ctx, reqtDB, reqte.Uuid, rv20test, errTestState)
+			return
+		} else {
+			errTestState = testcom.FDOTestState{
+				Passed: true,
+			}
+			reportTest(ctx, reqtDB, reqte.Uuid, rv20test, errTestState)
 		}
 
-		switch rv22test {
-		case testcom.FIDO_RVT_23_POSITIVE:
-			_, _, err = to0inst.OwnerSign22(helloAck.NonceTO0Sign, testcom.NULL_TEST)
-			if err != nil {
-				errTestState = testcom.FDOTestState{
-					Passed: false,
-					Error:  err.Error(),
-				}
-				reqtDB.ReportTest(reqte.Uuid, rv22test, errTestState)
-				continue
-			} else {
-				errTestState = testcom.FDOTestState{
-					Passed: true,
-				}
-				reqtDB.ReportTest(reqte.Uuid, rv22test, errTestState)
+	default:
+		_, testState, err := to0inst.Hello20(rv20test)
+		if testState == nil && err != nil {
+			testState = &testcom.FDOTestState{
+				Passed: false,
+				Error:  err.Error(),
 			}
+		}
 
-		default:
-			_, rvtTestState, err := to0inst.OwnerSign22(helloAck.NonceTO0Sign, rv22test)
-			if rvtTestState == nil && err != nil {
-				errTestState := testcom.FDOTestState{
-					Passed: false,
-					Error:  err.Error(),
-				}
-
-				rvtTestState = &errTestState
-			}
+		reportTest(ctx, reqtDB, reqte.Uuid, rv20test, *testState)
+	}
+}
 
-			reqtDB.ReportTest(reqte.Uuid, rv22test, *rvtTestState)
+func executeTo0_22(reqte reqtestsdeps.RequestTestInst, testGuid fdoshared.FdoGuid, rv22test testcom.FDOTestID, reqtDB *testdbs.RequestTestDB, devDB *dbs.DeviceBaseDB, ctx context.Context) {
+	testCredV, err := devDB.GetVANDV(testGuid, rv22test)
+	if err != nil {
+		errTestState := testcom.FDOTestState{
+			Passed: false,
+			Error:  err.Error(),
 		}
+
+		reportTest(ctx, reqtDB, reqte.Uuid, rv22test, errTestState)
+		return
 	}
 
-	for _, rv22VoucherTest := range testcom.FIDO_TEST_LIST_VOUCHER {
-		randomGuid := reqte.FdoSeedIDs.GetRandomTestGuid()
-		testCredV, err := devDB.GetVANDV(randomGuid, rv22VoucherTest)
-		if err != nil {
-			errTestState := testcom.FDOTestState{
-				Passed: false,
-				Error:  err.Error(),
-			}
+	to0inst := to0.NewTo0Requestor(fdoshared.SRVEntry{
+		SrvURL: reqte.URL,
+	}, testCredV.VoucherDBEntry, ctx)
 
-			reqtDB.ReportTest(reqte.Uuid, rv22VoucherTest, errTestState)
-			continue
+	var errTestState testcom.FDOTestState
+	helloAck, _, err := to0inst.Hello20(testcom.NULL_TEST)
+	if err != nil {
+		errTestState = testcom.FDOTestState{
+			Passed: false,
+			Error:  err.Error(),
 		}
+		reportTest(ctx, reqtDB, reqte.Uuid, rv22test, errTestState)
+		return
+	}
 
-		to0inst := to0.NewTo0Requestor(fdoshared.SRVEntry{
-			SrvURL: reqte.URL,
-		}, testCredV.VoucherDBEntry, ctx)
-
-		var errTestState testcom.FDOTestState
-		helloAck, _, err := to0inst.Hello20(testcom.NULL_TEST)
+	switch rv22test {
+	case testcom.FIDO_RVT_23_POSITIVE:
+		_, _, err = to0inst.OwnerSign22(helloAck.NonceTO0Sign, testcom.NULL_TEST)
 		if err != nil {
 			errTestState = testcom.FDOTestState{
 				Passed: false,
 				Error:  err.Error(),
 			}
-			reqtDB.ReportTest(reqte.Uuid, rv22VoucherTest, errTestState)
-			continue
+			reportTest(ctx, reqtDB, reqte.Uuid, rv22test, errTestState)
+			return
+		} else {
+			errTestState = testcom.FDOTestState{
+				Passed: true,
+			}
+			reportTest(ctx, reqtDB, reqte.Uuid, rv22test, errTestState)
 		}
 
-		_, rvtTestState, err := to0inst.OwnerSign22(helloAck.NonceTO0Sign, rv22VoucherTest)
+	default:
+		_, rvtTestState, err := to0inst.OwnerSign22(helloAck.NonceTO0Sign, rv22test)
 		if rvtTestState == nil && err != nil {
 			errTestState := testcom.FDOTestState{
 				Passed: false,
@@ -160,8 +164,130 @@ func ExecuteRVTestsTo0(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.Reque
 			rvtTestState = &errTestState
 		}
 
-		reqtDB.ReportTest(reqte.Uuid, rv22VoucherTest, *rvtTestState)
+		reportTest(ctx, reqtDB, reqte.Uuid, rv22test, *rvtTestState)
+	}
+}
+
+func executeTo0_22Voucher(reqte reqtestsdeps.RequestTestInst, testGuid fdoshared.FdoGuid, rv22VoucherTest testcom.FDOTestID, reqtDB *testdbs.RequestTestDB, devDB *dbs.DeviceBaseDB, ctx context.Context) {
+	testCredV, err := devDB.GetVANDV(testGuid, rv22VoucherTest)
+	if err != nil {
+		errTestState := testcom.FDOTestState{
+			Passed: false,
+			Error:  err.Error(),
+		}
+
+		reportTest(ctx, reqtDB, reqte.Uuid, rv22VoucherTest, errTestState)
+		return
+	}
+
+	to0inst := to0.NewTo0Requestor(fdoshared.SRVEntry{
+		SrvURL: reqte.URL,
+	}, testCredV.VoucherDBEntry, ctx)
+
+	var errTestState testcom.FDOTestState
+	helloAck, _, err := to0inst.Hello20(testcom.NULL_TEST)
+	if err != nil {
+		errTestState = testcom.FDOTestState{
+			Passed: false,
+			Error:  err.Error(),
+		}
+		reportTest(ctx, reqtDB, reqte.Uuid, rv22VoucherTest, errTestState)
+		return
+	}
+
+	_, rvtTestState, err := to0inst.OwnerSign22(helloAck.NonceTO0Sign, rv22VoucherTest)
+	if rvtTestState == nil && err != nil {
+		errTestState := testcom.FDOTestState{
+			Passed: false,
+			Error:  err.Error(),
+		}
+
+		rvtTestState = &errTestState
+	}
+
+	reportTest(ctx, reqtDB, reqte.Uuid, rv22VoucherTest, *rvtTestState)
+}
+
+func executeTo0_Http(reqte reqtestsdeps.RequestTestInst, testGuid fdoshared.FdoGuid, httpTest testcom.FDOTestID, reqtDB *testdbs.RequestTestDB, devDB *dbs.DeviceBaseDB, ctx context.Context) {
+	testCredV, err := devDB.GetVANDV(testGuid, testcom.NULL_TEST)
+	if err != nil {
+		reportTest(ctx, reqtDB, reqte.Uuid, httpTest, testcom.NewFailTestState(httpTest, err.Error()))
+		return
+	}
+
+	executeHttpTest(reqte, []httpTestMessage{
+		{cmd: fdoshared.TO0_20_HELLO},
+		{cmd: fdoshared.TO0_22_OWNER_SIGN, newSession: func(ctx context.Context) (string, error) {
+			to0inst := to0.NewTo0Requestor(fdoshared.SRVEntry{
+				SrvURL: reqte.URL,
+			}, testCredV.VoucherDBEntry, ctx)
+
+			_, _, err := to0inst.Hello20(testcom.NULL_TEST)
+			return to0inst.GetAuthzHeader(), err
+		}},
+	}, httpTest, reqtDB, ctx)
+}
+
+// executeTo0_Order sends OwnerSign22 without a Hello20, so without a session or a nonce from the RV
+func executeTo0_Order(reqte reqtestsdeps.RequestTestInst, testGuid fdoshared.FdoGuid, orderTest testcom.FDOTestID, reqtDB *testdbs.RequestTestDB, devDB *dbs.DeviceBaseDB, ctx context.Context) {
+	testCredV, err := devDB.GetVANDV(testGuid, testcom.NULL_TEST)
+	if err != nil {
+		reportTest(ctx, reqtDB, reqte.Uuid, orderTest, testcom.NewFailTestState(orderTest, err.Error()))
+		return
+	}
+
+	to0inst := to0.NewTo0Requestor(fdoshared.SRVEntry{
+		SrvURL: reqte.URL,
+	}, testCredV.VoucherDBEntry, ctx)
+
+	_, testState, err := to0inst.OwnerSign22(fdoshared.NewFdoNonce(), orderTest)
+	reportTest(ctx, reqtDB, reqte.Uuid, orderTest, orderTestState(orderTest, testState, err))
+}
+
+// executeTo0_Expiry pauses between Hello20 and OwnerSign22. The RV must reject the expired session, and then accept a new one
+func executeTo0_Expiry(reqte reqtestsdeps.RequestTestInst, testGuid fdoshared.FdoGuid, expiryTest testcom.FDOTestID, reqtDB *testdbs.RequestTestDB, devDB *dbs.DeviceBaseDB, ctx context.Context) {
+	expiry, ok := testcom.GetSessionExpiry(ctx)
+	if !ok {
+		reportTest(ctx, reqtDB, reqte.Uuid, expiryTest, testcom.NewFailTestState(expiryTest, "Session expiry wait is not set"))
+		return
+	}
+
+	testCredV, err := devDB.GetVANDV(testGuid, testcom.NULL_TEST)
+	if err != nil {
+		reportTest(ctx, reqtDB, reqte.Uuid, expiryTest, testcom.NewFailTestState(expiryTest, err.Error()))
+		return
+	}
+
+	newTo0Requestor := func() to0.To0Requestor {
+		return to0.NewTo0Requestor(fdoshared.SRVEntry{
+			SrvURL: reqte.URL,
+		}, testCredV.VoucherDBEntry, ctx)
+	}
+
+	to0inst := newTo0Requestor()
+	helloAck, _, err := to0inst.Hello20(testcom.NULL_TEST)
+	if err != nil {
+		reportTest(ctx, reqtDB, reqte.Uuid, expiryTest, testcom.NewFailTestState(expiryTest, "Error running test. Hello20 failed. "+err.Error()))
+		return
+	}
+
+	err = expiry.Sleep(ctx, expiry.Wait)
+	if err != nil {
+		reportTest(ctx, reqtDB, reqte.Uuid, expiryTest, testcom.NewFailTestState(expiryTest, "Session expiry wait was interrupted. "+err.Error()))
+		return
+	}
+
+	_, testState, err := to0inst.OwnerSign22(helloAck.NonceTO0Sign, expiryTest)
+	rejectState := orderTestState(expiryTest, testState, err)
+
+	var recoverErr error
+	if rejectState.Passed {
+		newSession := newTo0Requestor()
+		helloAck, _, recoverErr = newSession.Hello20(testcom.NULL_TEST)
+		if recoverErr == nil {
+			_, _, recoverErr = newSession.OwnerSign22(helloAck.NonceTO0Sign, testcom.NULL_TEST)
+		}
 	}
 
-	reqtDB.FinishRun(reqte.Uuid)
+	reportTest(ctx, reqtDB, reqte.Uuid, expiryTest, expectRecovered(expiryTest, rejectState, recoverErr))
 }
@@ -12,135 +12,348 @@ import (
 	"github.com/fido-alliance/iot-fdo-conformance-tools/dbs"
 )
 
-func ExecuteRVTestsTo1(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTestDB, devDB *dbs.DeviceBaseDB, ctx context.Context) {
-	reqtDB.StartNewRun(reqte.Uuid)
+// ExecuteRVTestsTo1 starts a TO1 run in the background, and returns its id
+func ExecuteRVTestsTo1(reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTestDB, devDB *dbs.DeviceBaseDB, ctx context.Context) (string, error) {
+	return startRunInBackground(ctx, reqte, reqtDB, func(run *activeRun) {
+		executeRVTestsTo1(run, reqte, reqtDB, devDB)
+	})
+}
 
-	// Generating voucher
-	randomGuid := reqte.FdoSeedIDs.GetRandomTestGuid()
-	testCredV, err := devDB.GetVANDV(randomGuid, testcom.NULL_TEST)
-	if err != nil {
-		errTestState := testcom.FDOTestState{
-			Passed: false,
-			Error:  err.Error(),
-		}
+func executeRVTestsTo1(run *activeRun, reqte reqtestsdeps.RequestTestInst, reqtDB *testdbs.RequestTestDB, devDB *dbs.DeviceBaseDB) {
+	ctx := run.Ctx
 
-		reqtDB.ReportTest(reqte.Uuid, testcom.NULL_TO1_SETUP, errTestState)
+	// Every worker gets its own seeded device, so parallel tests never share a GUID or a credential
+	guids := reqte.FdoSeedIDs.GetUniqueTestGuids(NormaliseConcurrency(reqte.Concurrency))
+	if len(guids) == 0 {
+		reportTest(ctx, reqtDB, reqte.Uuid, testcom.NULL_TO1_SETUP, testcom.NewFailTestState(testcom.NULL_TO1_SETUP, "No seeded devices"))
 		return
 	}
 
-	// Generating TO0 handler
+	credentials := make([]fdoshared.WawDeviceCredential, len(guids))
+	for i, guid := range guids {
+		credential, err := registerTo1Device(reqte, devDB, guid, ctx)
+		if err != nil {
+			errTestState := testcom.FDOTestState{
+				Passed: false,
+				Error:  err.Error(),
+			}
+
+			reportTest(ctx, reqtDB, reqte.Uuid, testcom.NULL_TO1_SETUP, errTestState)
+			return
+		}
+
+		credentials[i] = *credential
+	}
+
+	newTo1Requestor := func(workerId int) func(ctx context.Context) to1.To1Requestor {
+		return func(ctx context.Context) to1.To1Requestor {
+			return to1.NewTo1Requestor(fdoshared.SRVEntry{
+				SrvURL: reqte.URL,
+			}, credentials[workerId], ctx)
+		}
+	}
+
+	// Starting tests. Every test gets its own requestor on the device of its worker
+	var jobs []testJob
+	for _, rv30test := range testcom.FIDO_TEST_LIST_DEVT_30 {
+		jobs = append(jobs, func(workerId int) {
+			run.testStarted(rv30test)
+
+			testCtx := newTestContext(ctx, rv30test)
+			executeTo1_30(reqte, newTo1Requestor(workerId)(testCtx), rv30test, reqtDB, testCtx)
+		})
+	}
+
+	for _, rv32test := range testcom.FIDO_TEST_LIST_DEVT_32 {
+		jobs = append(jobs, func(workerId int) {
+			run.testStarted(rv32test)
+
+			testCtx := newTestContext(ctx, rv32test)
+			executeTo1_32(reqte, newTo1Requestor(workerId)(testCtx), rv32test, reqtDB, testCtx)
+		})
+	}
+
+	for _, orderTest := range testcom.FIDO_TEST_LIST_DEVT_ORDER {
+		jobs = append(jobs, func(workerId int) {
+			run.testStarted(orderTest)
+
+			testCtx := newTestContext(ctx, orderTest)
+			executeTo1_Order(reqte, newTo1Requestor(workerId)(testCtx), credentials[workerId], orderTest, reqtDB, testCtx)
+		})
+	}
+
+	for _, replayTest := range testcom.FIDO_TEST_LIST_DEVT_REPLAY {
+		jobs = append(jobs, func(workerId int) {
+			run.testStarted(replayTest)
+
+			executeTo1_Replay(reqte, newTo1Requestor(workerId), replayTest, reqtDB, newTestContext(ctx, replayTest))
+		})
+	}
+
+	// The expiry tests pause for a long time, so they only run when asked for
+	if _, ok := testcom.GetSessionExpiry(ctx); ok {
+		for _, expiryTest := range testcom.FIDO_TEST_LIST_DEVT_EXPIRY {
+			jobs = append(jobs, func(workerId int) {
+				run.testStarted(expiryTest)
+
+				executeTo1_Expiry(reqte, newTo1Requestor(workerId), expiryTest, reqtDB, newTestContext(ctx, expiryTest))
+			})
+		}
+	}
+
+	for _, httpTest := range testcom.FIDO_TEST_LIST_HTTP {
+		jobs = append(jobs, func(workerId int) {
+			run.testStarted(httpTest)
+
+			to1HttpMessages := []httpTestMessage{
+				{cmd: fdoshared.TO1_30_HELLO_RV},
+				{cmd: fdoshared.TO1_32_PROVE_TO_RV, newSession: func(ctx context.Context) (string, error) {
+					to1inst := newTo1Requestor(workerId)(ctx)
+
+					_, _, err := to1inst.HelloRV30(testcom.NULL_TEST)
+					return to1inst.GetAuthzHeader(), err
+				}},
+			}
+
+			executeHttpTest(reqte, to1HttpMessages, httpTest, reqtDB, newTestContext(ctx, httpTest))
+		})
+	}
+
+	runTestJobs(ctx, len(credentials), jobs)
+
+	// The stress test registers other devices and runs many sessions at the same time, so it runs on its own after the other tests, when asked for
+	if testcom.GetStressTests(ctx) && ctx.Err() == nil {
+		stressTest := testcom.FIDO_DEVT_STRESS_SESSION_ISOLATION
+		run.testStarted(stressTest)
+		executeTo1_Stress(reqte, devDB, stressTest, reqtDB, newTestContext(ctx, stressTest))
+	}
+}
+
+// registerTo1Device runs TO0 for the seeded device, so that the RV knows it for TO1
+func registerTo1Device(reqte reqtestsdeps.RequestTestInst, devDB *dbs.DeviceBaseDB, guid fdoshared.FdoGuid, ctx context.Context) (*fdoshared.WawDeviceCredential, error) {
+	testCredV, err := devDB.GetVANDV(guid, testcom.NULL_TEST)
+	if err != nil {
+		return nil, err
+	}
+
 	to0inst := to0.NewTo0Requestor(fdoshared.SRVEntry{
 		SrvURL: reqte.URL,
 	}, testCredV.VoucherDBEntry, ctx)
 
-	// Enroling voucher
-	var errTestState testcom.FDOTestState
 	helloAck, _, err := to0inst.Hello20(testcom.NULL_TEST)
 	if err != nil {
-		errTestState = testcom.FDOTestState{
-			Passed: false,
-			Error:  err.Error(),
-		}
-		reqtDB.ReportTest(reqte.Uuid, testcom.NULL_TO1_SETUP, errTestState)
-		return
+		return nil, err
 	}
 
 	_, _, err = to0inst.OwnerSign22(helloAck.NonceTO0Sign, testcom.NULL_TEST)
 	if err != nil {
-		errTestState = testcom.FDOTestState{
-			Passed: false,
-			Error:  err.Error(),
-		}
-		reqtDB.ReportTest(reqte.Uuid, testcom.NULL_TO1_SETUP, errTestState)
-		return
+		return nil, err
 	}
 
-	to1inst := to1.NewTo1Requestor(fdoshared.SRVEntry{
-		SrvURL: reqte.URL,
-	}, testCredV.WawDeviceCredential)
+	return &testCredV.WawDeviceCredential, nil
+}
 
-	// Starting tests
-	for _, rv30test := range testcom.FIDO_TEST_LIST_DEVT_30 {
-		switch rv30test {
+func executeTo1_30(reqte reqtestsdeps.RequestTestInst, to1inst to1.To1Requestor, rv30test testcom.FDOTestID, reqtDB *testdbs.RequestTestDB, ctx context.Context) {
+	switch rv30test {
 
-		case testcom.FIDO_DEVT_30_POSITIVE:
-			var errTestState testcom.FDOTestState
-			_, _, err := to1inst.HelloRV30(rv30test)
+	case testcom.FIDO_DEVT_30_POSITIVE:
+		var errTestState testcom.FDOTestState
+		_, _, err := to1inst.HelloRV30(rv30test)
 
-			if err != nil {
-				errTestState = testcom.FDOTestState{
-					Passed: false,
-					Error:  err.Error(),
-				}
-				reqtDB.ReportTest(reqte.Uuid, rv30test, errTestState)
-				return
-			} else {
-				errTestState = testcom.FDOTestState{
-					Passed: true,
-				}
-				reqtDB.ReportTest(reqte.Uuid, rv30test, errTestState)
+		if err != nil {
+			errTestState = testcom.FDOTestState{
+				Passed: false,
+				Error:  err.Error(),
 			}
+			reportTest(ctx, reqtDB, reqte.Uuid, rv30test, errTestState)
+			return
+		} else {
+			errTestState = testcom.FDOTestState{
+				Passed: true,
+			}
+			reportTest(ctx, reqtDB, reqte.Uuid, rv30test, errTestState)
+		}
 
-		default:
-			_, rvtTestState, err := to1inst.HelloRV30(rv30test)
-			if rvtTestState == nil && err != nil {
-				errTestState := testcom.FDOTestState{
-					Passed: false,
-					Error:  err.Error(),
-				}
-
-				rvtTestState = &errTestState
+	default:
+		_, rvtTestState, err := to1inst.HelloRV30(rv30test)
+		if rvtTestState == nil && err != nil {
+			errTestState := testcom.FDOTestState{
+				Passed: false,
+				Error:  err.Error(),
 			}
 
-			reqtDB.ReportTest(reqte.Uuid, rv30test, *rvtTestState)
+			rvtTestState = &errTestState
 		}
+
+		reportTest(ctx, reqtDB, reqte.Uuid, rv30test, *rvtTestState)
 	}
+}
+
+func executeTo1_32(reqte reqtestsdeps.RequestTestInst, to1inst to1.To1Requestor, rv32test testcom.FDOTestID, reqtDB *testdbs.RequestTestDB, ctx context.Context) {
+	helloRvAck31, _, err := to1inst.HelloRV30(testcom.NULL_TEST)
+	if err != nil {
+		errTestState := testcom.FDOTestState{
+			Passed: false,
+			Error:  "Error running test. Hello RV30 failed!" + err.Error(),
+		}
+		reportTest(ctx, reqtDB, reqte.Uuid, rv32test, errTestState)
+		return
+	}
+
+	switch rv32test {
+
+	case testcom.FIDO_DEVT_33_POSITIVE:
+		var errTestState testcom.FDOTestState
+		_, _, err := to1inst.ProveToRV32(*helloRvAck31, rv32test)
 
-	for _, rv32test := range testcom.FIDO_TEST_LIST_DEVT_32 {
-		helloRvAck31, _, err := to1inst.HelloRV30(testcom.NULL_TEST)
 		if err != nil {
 			errTestState = testcom.FDOTestState{
 				Passed: false,
-				Error:  "Error running test. Hello RV30 failed!" + err.Error(),
+				Error:  err.Error(),
+			}
+			reportTest(ctx, reqtDB, reqte.Uuid, rv32test, errTestState)
+			return
+		} else {
+			errTestState = testcom.FDOTestState{
+				Passed: true,
+			}
+			reportTest(ctx, reqtDB, reqte.Uuid, rv32test, errTestState)
+		}
+
+	default:
+		_, rvtTestState, err := to1inst.ProveToRV32(*helloRvAck31, rv32test)
+		if rvtTestState == nil && err != nil {
+			errTestState := testcom.FDOTestState{
+				Passed: false,
+				Error:  err.Error(),
 			}
-			reqtDB.ReportTest(reqte.Uuid, rv32test, errTestState)
-			continue
+
+			rvtTestState = &errTestState
+		}
+
+		reportTest(ctx, reqtDB, reqte.Uuid, rv32test, *rvtTestState)
+	}
+}
+
+// executeTo1_Order sends ProveToRV32 without a HelloRV30, so without a session or a nonce from the RV
+func executeTo1_Order(reqte reqtestsdeps.RequestTestInst, to1inst to1.To1Requestor, credential fdoshared.WawDeviceCredential, orderTest testcom.FDOTestID, reqtDB *testdbs.RequestTestDB, ctx context.Context) {
+	_, testState, err := to1inst.ProveToRV32(fdoshared.HelloRVAck31{
+		NonceTO1Proof: fdoshared.NewFdoNonce(),
+		EBSigInfo:     credential.DCSigInfo,
+	}, orderTest)
+
+	reportTest(ctx, reqtDB, reqte.Uuid, orderTest, orderTestState(orderTest, testState, err))
+}
+
+// executeTo1_Replay checks that the RV binds ProveToRV32 to the session and the GUID it was made for
+func executeTo1_Replay(reqte reqtestsdeps.RequestTestInst, newTo1Requestor func(ctx context.Context) to1.To1Requestor, replayTest testcom.FDOTestID, reqtDB *testdbs.RequestTestDB, ctx context.Context) {
+	var resultState testcom.FDOTestState
+
+	switch replayTest {
+	case testcom.FIDO_DEVT_32_REPLAY:
+		captureCtx := fdoshared.NewSentMessagesContext(ctx)
+		sessionA := newTo1Requestor(captureCtx)
+		helloRvAck31, _, err := sessionA.HelloRV30(testcom.NULL_TEST)
+		if err == nil {
+			_, _, err = sessionA.ProveToRV32(*helloRvAck31, testcom.NULL_TEST)
+		}
+		if err != nil {
+			reportTest(ctx, reqtDB, reqte.Uuid, replayTest, testcom.NewFailTestState(replayTest, "Error running test. Pre setup failed. "+err.Error()))
+			return
 		}
 
-		switch rv32test {
+		captured, ok := fdoshared.GetSentMessagesRecorder(captureCtx).GetLastSent(fdoshared.TO1_32_PROVE_TO_RV)
+		if !ok {
+			reportTest(ctx, reqtDB, reqte.Uuid, replayTest, testcom.NewFailTestState(replayTest, "ProveToRV32 was not captured"))
+			return
+		}
+
+		sessionB := newTo1Requestor(ctx)
+		_, _, err = sessionB.HelloRV30(testcom.NULL_TEST)
+		if err != nil {
+			reportTest(ctx, reqtDB, reqte.Uuid, replayTest, testcom.NewFailTestState(replayTest, "Error running test. Hello RV30 failed. "+err.Error()))
+			return
+		}
+
+		resultState = replayTestState(ctx, reqte, fdoshared.TO1_32_PROVE_TO_RV, captured, sessionB.GetAuthzHeader(), replayTest)
+
+	case testcom.FIDO_DEVT_32_OTHER_GUID:
+		to1inst := newTo1Requestor(ctx)
+		helloRvAck31, _, err := to1inst.HelloRV30(testcom.NULL_TEST)
+		if err != nil {
+			reportTest(ctx, reqtDB, reqte.Uuid, replayTest, testcom.NewFailTestState(replayTest, "Error running test. Hello RV30 failed. "+err.Error()))
+			return
+		}
+
+		_, testState, err := to1inst.ProveToRV32(*helloRvAck31, replayTest)
+		resultState = orderTestState(replayTest, testState, err)
+
+	case testcom.FIDO_DEVT_32_PARALLEL_SESSION:
+		sessionA := newTo1Requestor(ctx)
+		helloRvAck31A, _, err := sessionA.HelloRV30(testcom.NULL_TEST)
+		if err != nil {
+			reportTest(ctx, reqtDB, reqte.Uuid, replayTest, testcom.NewFailTestState(replayTest, "Error running test. Hello RV30 failed. "+err.Error()))
+			return
+		}
+
+		sessionB := newTo1Requestor(ctx)
+		_, _, err = sessionB.HelloRV30(testcom.NULL_TEST)
+		if err != nil {
+			reportTest(ctx, reqtDB, reqte.Uuid, replayTest, testcom.NewFailTestState(replayTest, "Error running test. Hello RV30 failed. "+err.Error()))
+			return
+		}
 
-		case testcom.FIDO_DEVT_33_POSITIVE:
-			var errTestState testcom.FDOTestState
-			_, _, err := to1inst.ProveToRV32(*helloRvAck31, rv32test)
+		// Session B answers with the nonce of session A, which must not be accepted in B
+		_, testState, err := sessionB.ProveToRV32(*helloRvAck31A, replayTest)
+		resultState = orderTestState(replayTest, testState, err)
 
+		// Session A must not be affected by B
+		if resultState.Passed {
+			_, _, err = sessionA.ProveToRV32(*helloRvAck31A, testcom.NULL_TEST)
 			if err != nil {
-				errTestState = testcom.FDOTestState{
-					Passed: false,
-					Error:  err.Error(),
-				}
-				reqtDB.ReportTest(reqte.Uuid, rv32test, errTestState)
-				return
-			} else {
-				errTestState = testcom.FDOTestState{
-					Passed: true,
-				}
-				reqtDB.ReportTest(reqte.Uuid, rv32test, errTestState)
+				resultState = testcom.NewFailTestState(replayTest, "The parallel session failed after the rejection. "+err.Error())
 			}
+		}
 
-		default:
-			_, rvtTestState, err := to1inst.ProveToRV32(*helloRvAck31, rv32test)
-			if rvtTestState == nil && err != nil {
-				errTestState := testcom.FDOTestState{
-					Passed: false,
-					Error:  err.Error(),
-				}
+	default:
+		resultState = testcom.NewFailTestState(replayTest, "Unsupported test "+string(replayTest))
+	}
 
-				rvtTestState = &errTestState
-			}
+	reportTest(ctx, reqtDB, reqte.Uuid, replayTest, resultState)
+}
+
+// executeTo1_Expiry pauses between HelloRV30 and ProveToRV32. The RV must reject the expired session, and then accept a new one
+func executeTo1_Expiry(reqte reqtestsdeps.RequestTestInst, newTo1Requestor func(ctx context.Context) to1.To1Requestor, expiryTest testcom.FDOTestID, reqtDB *testdbs.RequestTestDB, ctx context.Context) {
+	expiry, ok := testcom.GetSessionExpiry(ctx)
+	if !ok {
+		reportTest(ctx, reqtDB, reqte.Uuid, expiryTest, testcom.NewFailTestState(expiryTest, "Session expiry wait is not set"))
+		return
+	}
+
+	to1inst := newTo1Requestor(ctx)
+	helloRvAck31, _, err := to1inst.HelloRV30(testcom.NULL_TEST)
+	if err != nil {
+		reportTest(ctx, reqtDB, reqte.Uuid, expiryTest, testcom.NewFailTestState(expiryTest, "Error running test. Hello RV30 failed. "+err.Error()))
+		return
+	}
+
+	err = expiry.Sleep(ctx, expiry.Wait)
+	if err != nil {
+		reportTest(ctx, reqtDB, reqte.Uuid, expiryTest, testcom.NewFailTestState(expiryTest, "Session expiry wait was interrupted. "+err.Error()))
+		return
+	}
+
+	_, testState, err := to1inst.ProveToRV32(*helloRvAck31, expiryTest)
+	rejectState := orderTestState(expiryTest, testState, err)
 
-			reqtDB.ReportTest(reqte.Uuid, rv32test, *rvtTestState)
+	var recoverErr error
+	if rejectState.Passed {
+		newSession := newTo1Requestor(ctx)
+		helloRvAck31, _, recoverErr = newSession.HelloRV30(testcom.NULL_TEST)
+		if recoverErr == nil {
+			_, _, recoverErr = newSession.ProveToRV32(*helloRvAck31, testcom.NULL_TEST)
 		}
 	}
 
-	reqtDB.FinishRun(reqte.Uuid)
+	reportTest(ctx, reqtDB, reqte.Uuid, expiryTest, expectRecovered(expiryTest, rejectState, recoverErr))
 }
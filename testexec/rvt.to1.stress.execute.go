@@ -0,0 +1,103 @@
+package testexec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/device/to1"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/do/to0"
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+	testdbs "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/dbs"
+	reqtestsdeps "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom/request"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/dbs"
+)
+
+// registerStressTo0 runs TO0 for the device, and returns the To1d the RV must hand out for it
+func registerStressTo0(reqte reqtestsdeps.RequestTestInst, testCredV *fdoshared.DeviceCredAndVoucher, ctx context.Context) (*fdoshared.CoseSignature, error) {
+	captureCtx := fdoshared.NewSentMessagesContext(ctx)
+	to0inst := to0.NewTo0Requestor(fdoshared.SRVEntry{
+		SrvURL: reqte.URL,
+	}, testCredV.VoucherDBEntry, captureCtx)
+
+	helloAck, _, err := to0inst.Hello20(testcom.NULL_TEST)
+	if err != nil {
+		return nil, err
+	}
+
+	_, _, err = to0inst.OwnerSign22(helloAck.NonceTO0Sign, testcom.NULL_TEST)
+	if err != nil {
+		return nil, err
+	}
+
+	ownerSign22Bytes, ok := fdoshared.GetSentMessagesRecorder(captureCtx).GetLastSent(fdoshared.TO0_22_OWNER_SIGN)
+	if !ok {
+		return nil, errors.New("OwnerSign22 was not captured")
+	}
+
+	var ownerSign22 fdoshared.OwnerSign22
+	err = fdoshared.CborCust.Unmarshal(ownerSign22Bytes, &ownerSign22)
+	if err != nil {
+		return nil, errors.New("Failed to decode OwnerSign22. " + err.Error())
+	}
+
+	return &ownerSign22.To1d, nil
+}
+
+// executeTo1_Stress registers seeded devices with TO0, and then runs TO1 for all of them at the same time with
+// interleaved messages. Every device must get the To1d that was registered for it
+func executeTo1_Stress(reqte reqtestsdeps.RequestTestInst, devDB *dbs.DeviceBaseDB, testId testcom.FDOTestID, reqtDB *testdbs.RequestTestDB, ctx context.Context) {
+	guids := reqte.FdoSeedIDs.GetUniqueTestGuids(STRESS_MAX_SESSIONS)
+
+	credentials := make([]fdoshared.WawDeviceCredential, len(guids))
+	registeredTo1d := make([]*fdoshared.CoseSignature, len(guids))
+	for i, guid := range guids {
+		testCredV, err := devDB.GetVANDV(guid, testcom.NULL_TEST)
+		if err != nil {
+			reportTest(ctx, reqtDB, reqte.Uuid, testId, testcom.NewFailTestState(testId, err.Error()))
+			return
+		}
+
+		registeredTo1d[i], err = registerStressTo0(reqte, testCredV, ctx)
+		if err != nil {
+			reportTest(ctx, reqtDB, reqte.Uuid, testId, testcom.NewFailTestState(testId, fmt.Sprintf("Error running test. TO0 for %s failed. %s", guid.GetFormatted(), err.Error())))
+			return
+		}
+
+		credentials[i] = testCredV.WawDeviceCredential
+	}
+
+	testState := runStressFlows(testId, guids, func(i int) error {
+		to1inst := to1.NewTo1Requestor(fdoshared.SRVEntry{
+			SrvURL: reqte.URL,
+		}, credentials[i], ctx)
+
+		if err := stressJitter(ctx); err != nil {
+			return err
+		}
+
+		helloRvAck31, _, err := to1inst.HelloRV30(testcom.NULL_TEST)
+		if err != nil {
+			return err
+		}
+
+		if err := stressJitter(ctx); err != nil {
+			return err
+		}
+
+		to1d, _, err := to1inst.ProveToRV32(*helloRvAck31, testcom.NULL_TEST)
+		if err != nil {
+			return err
+		}
+
+		if !bytes.Equal(to1d.Payload, registeredTo1d[i].Payload) || !bytes.Equal(to1d.Signature, registeredTo1d[i].Signature) {
+			return errors.New("RVRedirect33 is not the To1d registered for the device")
+		}
+
+		return nil
+	})
+
+	reportTest(ctx, reqtDB, reqte.Uuid, testId, testState)
+}
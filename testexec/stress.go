@@ -0,0 +1,56 @@
+package testexec
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	fdoshared "github.com/fido-alliance/iot-fdo-conformance-tools/core/shared"
+	"github.com/fido-alliance/iot-fdo-conformance-tools/core/shared/testcom"
+)
+
+// STRESS_MAX_SESSIONS is the most flows a stress test runs at the same time
+const STRESS_MAX_SESSIONS int = 16
+
+// STRESS_MAX_JITTER is the longest pause of a stress test flow before a message, so that the messages of the flows interleave
+const STRESS_MAX_JITTER time.Duration = 20 * time.Millisecond
+
+// stressJitter pauses for a random time up to STRESS_MAX_JITTER
+func stressJitter(ctx context.Context) error {
+	jitter := time.Duration(fdoshared.NewRandomInt(0, int(STRESS_MAX_JITTER/time.Millisecond))) * time.Millisecond
+	return testcom.SleepContext(ctx, jitter)
+}
+
+// runStressFlows starts a flow for every guid at the same time, and fails if any of them fails
+func runStressFlows(testId testcom.FDOTestID, guids []fdoshared.FdoGuid, flow func(i int) error) testcom.FDOTestState {
+	errs := make([]error, len(guids))
+
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := range guids {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			<-start
+			errs[i] = flow(i)
+		}()
+	}
+
+	close(start)
+	wg.Wait()
+
+	var testStates []testcom.FDOTestState
+	for i, err := range errs {
+		if err != nil {
+			testStates = append(testStates, testcom.NewFailTestState(testId, fmt.Sprintf("%s: %s", guids[i].GetFormatted(), err.Error())))
+		}
+	}
+
+	if len(guids) < 2 {
+		testStates = append(testStates, testcom.NewWarnTestState(testId, []string{fmt.Sprintf("Only %d session was run. Use more devices to stress the target", len(guids))}))
+	}
+
+	return testcom.MergeTestStates(testId, testStates)
+}